@@ -0,0 +1,222 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/magical/nistec-extra/internal/fiat"
+)
+
+// fillCoordinate sets out to the big-endian encoding of x, or returns an error
+// if x is negative or doesn't fit.
+func fillCoordinate(out []byte, x *big.Int) error {
+	if x == nil || x.Sign() < 0 || x.BitLen() > 8*len(out) {
+		return errors.New("invalid coordinate")
+	}
+	x.FillBytes(out)
+	return nil
+}
+
+// Coordinates returns the affine coordinates of p as 28-byte big-endian
+// values, or an error if p is the point at infinity.
+func (p *P224Point) Coordinates() (x, y []byte, err error) {
+	var out [1 + 2*p224ElementLength]byte
+	b := p.bytes(&out)
+	if len(b) == 1 {
+		return nil, nil, errors.New("P224 point is the point at infinity")
+	}
+	return b[1 : 1+p224ElementLength : 1+p224ElementLength], b[1+p224ElementLength : 1+2*p224ElementLength : 1+2*p224ElementLength], nil
+}
+
+// SetCoordinates sets p to the point with affine coordinates x and y, which
+// must be 28-byte big-endian encodings of values in [0, P-1], and returns p.
+// If the values are not canonical or the point is not on the curve, it
+// returns nil and an error, and the receiver is unchanged.
+func (p *P224Point) SetCoordinates(x, y []byte) (*P224Point, error) {
+	if len(x) != p224ElementLength || len(y) != p224ElementLength {
+		return nil, errors.New("invalid P224 coordinate length")
+	}
+	xe, err := new(fiat.P224Element).SetBytes(x)
+	if err != nil {
+		return nil, err
+	}
+	ye, err := new(fiat.P224Element).SetBytes(y)
+	if err != nil {
+		return nil, err
+	}
+	if err := p224CheckOnCurve(xe, ye); err != nil {
+		return nil, err
+	}
+	p.x.Set(xe)
+	p.y.Set(ye)
+	p.z.One()
+	return p, nil
+}
+
+// Coordinates returns the affine coordinates of p as 48-byte big-endian
+// values, or an error if p is the point at infinity.
+func (p *P384Point) Coordinates() (x, y []byte, err error) {
+	var out [1 + 2*p384ElementLength]byte
+	b := p.bytes(&out)
+	if len(b) == 1 {
+		return nil, nil, errors.New("P384 point is the point at infinity")
+	}
+	return b[1 : 1+p384ElementLength : 1+p384ElementLength], b[1+p384ElementLength : 1+2*p384ElementLength : 1+2*p384ElementLength], nil
+}
+
+// SetCoordinates sets p to the point with affine coordinates x and y, which
+// must be 48-byte big-endian encodings of values in [0, P-1], and returns p.
+// If the values are not canonical or the point is not on the curve, it
+// returns nil and an error, and the receiver is unchanged.
+func (p *P384Point) SetCoordinates(x, y []byte) (*P384Point, error) {
+	if len(x) != p384ElementLength || len(y) != p384ElementLength {
+		return nil, errors.New("invalid P384 coordinate length")
+	}
+	xe, err := new(fiat.P384Element).SetBytes(x)
+	if err != nil {
+		return nil, err
+	}
+	ye, err := new(fiat.P384Element).SetBytes(y)
+	if err != nil {
+		return nil, err
+	}
+	if err := p384CheckOnCurve(xe, ye); err != nil {
+		return nil, err
+	}
+	p.x.Set(xe)
+	p.y.Set(ye)
+	p.z.One()
+	return p, nil
+}
+
+// Coordinates returns the affine coordinates of p as 66-byte big-endian
+// values, or an error if p is the point at infinity.
+func (p *P521Point) Coordinates() (x, y []byte, err error) {
+	var out [1 + 2*p521ElementLength]byte
+	b := p.bytes(&out)
+	if len(b) == 1 {
+		return nil, nil, errors.New("P521 point is the point at infinity")
+	}
+	return b[1 : 1+p521ElementLength : 1+p521ElementLength], b[1+p521ElementLength : 1+2*p521ElementLength : 1+2*p521ElementLength], nil
+}
+
+// SetCoordinates sets p to the point with affine coordinates x and y, which
+// must be 66-byte big-endian encodings of values in [0, P-1], and returns p.
+// If the values are not canonical or the point is not on the curve, it
+// returns nil and an error, and the receiver is unchanged.
+func (p *P521Point) SetCoordinates(x, y []byte) (*P521Point, error) {
+	if len(x) != p521ElementLength || len(y) != p521ElementLength {
+		return nil, errors.New("invalid P521 coordinate length")
+	}
+	xe, err := new(fiat.P521Element).SetBytes(x)
+	if err != nil {
+		return nil, err
+	}
+	ye, err := new(fiat.P521Element).SetBytes(y)
+	if err != nil {
+		return nil, err
+	}
+	if err := p521CheckOnCurve(xe, ye); err != nil {
+		return nil, err
+	}
+	p.x.Set(xe)
+	p.y.Set(ye)
+	p.z.One()
+	return p, nil
+}
+
+// CoordinatesBig is like Coordinates, but returns the coordinates as big.Int
+// values.
+func (p *P224Point) CoordinatesBig() (x, y *big.Int, err error) {
+	xb, yb, err := p.Coordinates()
+	if err != nil {
+		return nil, nil, err
+	}
+	return new(big.Int).SetBytes(xb), new(big.Int).SetBytes(yb), nil
+}
+
+// SetCoordinatesBig is like SetCoordinates, but takes the coordinates as
+// big.Int values.
+func (p *P224Point) SetCoordinatesBig(x, y *big.Int) (*P224Point, error) {
+	var xb, yb [p224ElementLength]byte
+	if err := fillCoordinate(xb[:], x); err != nil {
+		return nil, err
+	}
+	if err := fillCoordinate(yb[:], y); err != nil {
+		return nil, err
+	}
+	return p.SetCoordinates(xb[:], yb[:])
+}
+
+// CoordinatesBig is like Coordinates, but returns the coordinates as big.Int
+// values.
+func (p *P256Point) CoordinatesBig() (x, y *big.Int, err error) {
+	xb, yb, err := p.Coordinates()
+	if err != nil {
+		return nil, nil, err
+	}
+	return new(big.Int).SetBytes(xb), new(big.Int).SetBytes(yb), nil
+}
+
+// SetCoordinatesBig is like SetCoordinates, but takes the coordinates as
+// big.Int values.
+func (p *P256Point) SetCoordinatesBig(x, y *big.Int) (*P256Point, error) {
+	var xb, yb [p256ElementLength]byte
+	if err := fillCoordinate(xb[:], x); err != nil {
+		return nil, err
+	}
+	if err := fillCoordinate(yb[:], y); err != nil {
+		return nil, err
+	}
+	return p.SetCoordinates(xb[:], yb[:])
+}
+
+// CoordinatesBig is like Coordinates, but returns the coordinates as big.Int
+// values.
+func (p *P384Point) CoordinatesBig() (x, y *big.Int, err error) {
+	xb, yb, err := p.Coordinates()
+	if err != nil {
+		return nil, nil, err
+	}
+	return new(big.Int).SetBytes(xb), new(big.Int).SetBytes(yb), nil
+}
+
+// SetCoordinatesBig is like SetCoordinates, but takes the coordinates as
+// big.Int values.
+func (p *P384Point) SetCoordinatesBig(x, y *big.Int) (*P384Point, error) {
+	var xb, yb [p384ElementLength]byte
+	if err := fillCoordinate(xb[:], x); err != nil {
+		return nil, err
+	}
+	if err := fillCoordinate(yb[:], y); err != nil {
+		return nil, err
+	}
+	return p.SetCoordinates(xb[:], yb[:])
+}
+
+// CoordinatesBig is like Coordinates, but returns the coordinates as big.Int
+// values.
+func (p *P521Point) CoordinatesBig() (x, y *big.Int, err error) {
+	xb, yb, err := p.Coordinates()
+	if err != nil {
+		return nil, nil, err
+	}
+	return new(big.Int).SetBytes(xb), new(big.Int).SetBytes(yb), nil
+}
+
+// SetCoordinatesBig is like SetCoordinates, but takes the coordinates as
+// big.Int values.
+func (p *P521Point) SetCoordinatesBig(x, y *big.Int) (*P521Point, error) {
+	var xb, yb [p521ElementLength]byte
+	if err := fillCoordinate(xb[:], x); err != nil {
+		return nil, err
+	}
+	if err := fillCoordinate(yb[:], y); err != nil {
+		return nil, err
+	}
+	return p.SetCoordinates(xb[:], yb[:])
+}
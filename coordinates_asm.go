@@ -0,0 +1,47 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !purego && (amd64 || arm64 || (ppc64le && go1.19) || s390x)
+
+package nistec
+
+import "errors"
+
+// Coordinates returns the affine coordinates of p as 32-byte big-endian
+// values, or an error if p is the point at infinity.
+func (p *P256Point) Coordinates() (x, y []byte, err error) {
+	var out [p256UncompressedLength]byte
+	b := p.bytes(&out)
+	if len(b) == 1 {
+		return nil, nil, errors.New("P256 point is the point at infinity")
+	}
+	return b[1 : 1+p256ElementLength : 1+p256ElementLength], b[1+p256ElementLength : 1+2*p256ElementLength : 1+2*p256ElementLength], nil
+}
+
+// SetCoordinates sets p to the point with affine coordinates x and y, which
+// must be 32-byte big-endian encodings of values in [0, P-1], and returns p.
+// If the values are not canonical or the point is not on the curve, it
+// returns nil and an error, and the receiver is unchanged.
+func (p *P256Point) SetCoordinates(x, y []byte) (*P256Point, error) {
+	// See SetBytes for rr.
+	rr := p256Element{0x0000000000000003, 0xfffffffbffffffff,
+		0xfffffffffffffffe, 0x00000004fffffffd}
+
+	if len(x) != p256ElementLength || len(y) != p256ElementLength {
+		return nil, errors.New("invalid P256 coordinate length")
+	}
+	var r P256Point
+	p256BigToLittle(&r.x, (*[32]byte)(x))
+	p256BigToLittle(&r.y, (*[32]byte)(y))
+	if p256LessThanP(&r.x) == 0 || p256LessThanP(&r.y) == 0 {
+		return nil, errors.New("invalid P256 element encoding")
+	}
+	p256Mul(&r.x, &r.x, &rr)
+	p256Mul(&r.y, &r.y, &rr)
+	if err := p256CheckOnCurve(&r.x, &r.y); err != nil {
+		return nil, err
+	}
+	r.z = p256One
+	return p.Set(&r), nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build purego || (!amd64 && !arm64 && !(ppc64le && go1.19) && !s390x)
+
+package nistec
+
+import (
+	"errors"
+
+	"github.com/magical/nistec-extra/internal/fiat"
+)
+
+// Coordinates returns the affine coordinates of p as 32-byte big-endian
+// values, or an error if p is the point at infinity.
+func (p *P256Point) Coordinates() (x, y []byte, err error) {
+	var out [1 + 2*p256ElementLength]byte
+	b := p.bytes(&out)
+	if len(b) == 1 {
+		return nil, nil, errors.New("P256 point is the point at infinity")
+	}
+	return b[1 : 1+p256ElementLength : 1+p256ElementLength], b[1+p256ElementLength : 1+2*p256ElementLength : 1+2*p256ElementLength], nil
+}
+
+// SetCoordinates sets p to the point with affine coordinates x and y, which
+// must be 32-byte big-endian encodings of values in [0, P-1], and returns p.
+// If the values are not canonical or the point is not on the curve, it
+// returns nil and an error, and the receiver is unchanged.
+func (p *P256Point) SetCoordinates(x, y []byte) (*P256Point, error) {
+	if len(x) != p256ElementLength || len(y) != p256ElementLength {
+		return nil, errors.New("invalid P256 coordinate length")
+	}
+	xe, err := new(fiat.P256Element).SetBytes(x)
+	if err != nil {
+		return nil, err
+	}
+	ye, err := new(fiat.P256Element).SetBytes(y)
+	if err != nil {
+		return nil, err
+	}
+	if err := p256CheckOnCurve(xe, ye); err != nil {
+		return nil, err
+	}
+	p.x.Set(xe)
+	p.y.Set(ye)
+	p.z.One()
+	return p, nil
+}
@@ -0,0 +1,451 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec
+
+import "errors"
+
+// COSE_Key labels and values, from RFC 8152, Section 7.1 and 13.1.1.
+const (
+	coseLabelKty = 1
+	coseLabelAlg = 3
+	coseLabelCrv = -1
+	coseLabelX   = -2
+	coseLabelY   = -3
+	coseLabelD   = -4
+
+	coseKtyEC2 = 2
+)
+
+// marshalCOSE returns the COSE_Key encoding of the public key with
+// uncompressed encoding point and, if scalar is not nil, of the private key
+// scalar. The map is encoded in the deterministic order of RFC 8949, Section
+// 4.2.1, as expected by WebAuthn.
+func marshalCOSE(c *joseCurve, point, scalar []byte) ([]byte, error) {
+	if len(point) == 1 {
+		return nil, errors.New(c.name + " point is the point at infinity")
+	}
+	n := uint64(5)
+	if scalar != nil {
+		n++
+	}
+	b := appendCBORHead(nil, 5, n)
+	b = appendCBORInt(b, coseLabelKty)
+	b = appendCBORInt(b, coseKtyEC2)
+	b = appendCBORInt(b, coseLabelAlg)
+	b = appendCBORInt(b, c.coseAlg)
+	b = appendCBORInt(b, coseLabelCrv)
+	b = appendCBORInt(b, c.coseCrv)
+	b = appendCBORInt(b, coseLabelX)
+	b = appendCBORBytes(b, point[1:1+c.byteLen])
+	b = appendCBORInt(b, coseLabelY)
+	b = appendCBORBytes(b, point[1+c.byteLen:])
+	if scalar != nil {
+		b = appendCBORInt(b, coseLabelD)
+		b = appendCBORBytes(b, scalar)
+	}
+	return b, nil
+}
+
+// parseCOSE parses a COSE_Key, and returns the SEC 1 encoding of its public
+// key, which is not yet validated, and its private key if present. The key
+// must be of type EC2 on curve c. If the alg parameter is one of the ECDSA
+// algorithms, it must be the one for curve c.
+func parseCOSE(c *joseCurve, data []byte) (point, scalar []byte, err error) {
+	r := cborReader(data)
+	major, n, ok := r.readHead()
+	if !ok || major != 5 {
+		return nil, nil, errors.New("invalid COSE_Key encoding")
+	}
+	var kty, crv, alg int64
+	var x, y []byte
+	var hasKty, hasCrv, hasAlg, hasY, yBit bool
+	seen := make(map[int64]bool)
+	for i := uint64(0); i < n; i++ {
+		label, isInt, ok := r.readLabel()
+		if !ok {
+			return nil, nil, errors.New("invalid COSE_Key encoding")
+		}
+		if !isInt {
+			if !r.skip(0) {
+				return nil, nil, errors.New("invalid COSE_Key encoding")
+			}
+			continue
+		}
+		if seen[label] {
+			return nil, nil, errors.New("invalid COSE_Key encoding: duplicate label")
+		}
+		seen[label] = true
+		switch label {
+		case coseLabelKty:
+			kty, ok = r.readInt()
+			hasKty = true
+		case coseLabelAlg:
+			alg, ok = r.readInt()
+			hasAlg = true
+		case coseLabelCrv:
+			crv, ok = r.readInt()
+			hasCrv = true
+		case coseLabelX:
+			x, ok = r.readBytes()
+		case coseLabelY:
+			// The y-coordinate is either the full value, or its sign bit for
+			// a compressed point.
+			if r.peekMajor() == 7 {
+				yBit, ok = r.readBool()
+			} else {
+				y, ok = r.readBytes()
+			}
+			hasY = true
+		case coseLabelD:
+			scalar, ok = r.readBytes()
+		default:
+			ok = r.skip(0)
+		}
+		if !ok {
+			return nil, nil, errors.New("invalid COSE_Key encoding")
+		}
+	}
+	if len(r) != 0 {
+		return nil, nil, errors.New("invalid COSE_Key encoding: trailing data")
+	}
+
+	if !hasKty || kty != coseKtyEC2 {
+		return nil, nil, errors.New("COSE_Key is not an EC2 key")
+	}
+	if !hasCrv || crv != c.coseCrv {
+		return nil, nil, errors.New("COSE_Key is not on " + c.name)
+	}
+	if hasAlg && (alg == p256JOSE.coseAlg || alg == p384JOSE.coseAlg || alg == p521JOSE.coseAlg) && alg != c.coseAlg {
+		return nil, nil, errors.New("COSE_Key algorithm doesn't match the curve")
+	}
+	// RFC 8152, Section 13.1.1 requires leading zeroes to be preserved.
+	if len(x) != c.byteLen || !hasY || y != nil && len(y) != c.byteLen {
+		return nil, nil, errors.New("invalid COSE_Key coordinates")
+	}
+	if scalar != nil && len(scalar) != len(c.n) {
+		return nil, nil, errors.New("invalid COSE_Key private key length")
+	}
+	if y == nil {
+		point = make([]byte, 1, 1+c.byteLen)
+		point[0] = 2
+		if yBit {
+			point[0] = 3
+		}
+		return append(point, x...), scalar, nil
+	}
+	point = make([]byte, 1, 1+2*c.byteLen)
+	point[0] = 4
+	point = append(point, x...)
+	return append(point, y...), scalar, nil
+}
+
+func appendCBORHead(b []byte, major byte, n uint64) []byte {
+	major <<= 5
+	var size int
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= 0xff:
+		b, size = append(b, major|24), 1
+	case n <= 0xffff:
+		b, size = append(b, major|25), 2
+	case n <= 0xffffffff:
+		b, size = append(b, major|26), 4
+	default:
+		b, size = append(b, major|27), 8
+	}
+	for i := size - 1; i >= 0; i-- {
+		b = append(b, byte(n>>(8*i)))
+	}
+	return b
+}
+
+func appendCBORInt(b []byte, v int64) []byte {
+	if v < 0 {
+		return appendCBORHead(b, 1, uint64(-1-v))
+	}
+	return appendCBORHead(b, 0, uint64(v))
+}
+
+func appendCBORBytes(b, v []byte) []byte {
+	return append(appendCBORHead(b, 2, uint64(len(v))), v...)
+}
+
+// cborReader reads the subset of CBOR (RFC 8949) needed for COSE_Key. Only
+// definite length items are supported.
+type cborReader []byte
+
+// maxCBORDepth limits the nesting of skipped values.
+const maxCBORDepth = 16
+
+// readHead reads the initial byte and argument of a data item.
+func (r *cborReader) readHead() (major byte, arg uint64, ok bool) {
+	if len(*r) < 1 {
+		return 0, 0, false
+	}
+	major, info := (*r)[0]>>5, (*r)[0]&0x1f
+	*r = (*r)[1:]
+	var size int
+	switch {
+	case info < 24:
+		return major, uint64(info), true
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		// Reserved values and indefinite lengths.
+		return 0, 0, false
+	}
+	if len(*r) < size {
+		return 0, 0, false
+	}
+	for _, c := range (*r)[:size] {
+		arg = arg<<8 | uint64(c)
+	}
+	*r = (*r)[size:]
+	return major, arg, true
+}
+
+func (r *cborReader) peekMajor() byte {
+	if len(*r) < 1 {
+		return 0xff
+	}
+	return (*r)[0] >> 5
+}
+
+// readLabel reads a map label, which may be an integer or a text string. The
+// value of text labels is not returned.
+func (r *cborReader) readLabel() (label int64, isInt, ok bool) {
+	if m := r.peekMajor(); m == 3 {
+		return 0, false, r.skip(0)
+	}
+	label, ok = r.readInt()
+	return label, true, ok
+}
+
+func (r *cborReader) readInt() (int64, bool) {
+	major, arg, ok := r.readHead()
+	if !ok || arg > 1<<63-1 {
+		return 0, false
+	}
+	switch major {
+	case 0:
+		return int64(arg), true
+	case 1:
+		return -1 - int64(arg), true
+	default:
+		return 0, false
+	}
+}
+
+func (r *cborReader) readBytes() ([]byte, bool) {
+	major, arg, ok := r.readHead()
+	if !ok || major != 2 || arg > uint64(len(*r)) {
+		return nil, false
+	}
+	b := (*r)[:arg]
+	*r = (*r)[arg:]
+	return b, true
+}
+
+func (r *cborReader) readBool() (bool, bool) {
+	major, arg, ok := r.readHead()
+	if !ok || major != 7 || arg != 20 && arg != 21 {
+		return false, false
+	}
+	return arg == 21, true
+}
+
+// skip skips over a data item, including any nested items.
+func (r *cborReader) skip(depth int) bool {
+	if depth > maxCBORDepth {
+		return false
+	}
+	major, arg, ok := r.readHead()
+	if !ok {
+		return false
+	}
+	switch major {
+	case 0, 1, 7:
+		return true
+	case 2, 3:
+		if arg > uint64(len(*r)) {
+			return false
+		}
+		*r = (*r)[arg:]
+		return true
+	case 4, 5:
+		if major == 5 {
+			if arg > uint64(len(*r)) {
+				return false
+			}
+			arg *= 2
+		}
+		// Every item is at least one byte long.
+		if arg > uint64(len(*r)) {
+			return false
+		}
+		for i := uint64(0); i < arg; i++ {
+			if !r.skip(depth + 1) {
+				return false
+			}
+		}
+		return true
+	default: // 6, tagged item
+		return r.skip(depth + 1)
+	}
+}
+
+// BytesCOSE returns the COSE_Key encoding of p, as specified in RFC 8152,
+// Section 13.1.1, with the ES256 algorithm, or an error if p is the point at
+// infinity.
+func (p *P256Point) BytesCOSE() ([]byte, error) {
+	return marshalCOSE(p256JOSE, p.Bytes(), nil)
+}
+
+// SetBytesCOSE sets p to the public key in the COSE_Key key, and returns p. The
+// key must be of type EC2 on curve P-256, with an uncompressed or compressed
+// point, and any private key is ignored. If key is not a valid P-256 public
+// key, SetBytesCOSE returns nil and an error, and p is unchanged.
+func (p *P256Point) SetBytesCOSE(key []byte) (*P256Point, error) {
+	b, _, err := parseCOSE(p256JOSE, key)
+	if err != nil {
+		return nil, err
+	}
+	return p.SetBytes(b)
+}
+
+// P256PrivateKeyCOSE returns the COSE_Key encoding of the private key scalar,
+// including the public key.
+func P256PrivateKeyCOSE(scalar []byte) ([]byte, error) {
+	b, err := p256JOSE.privateKeyPoint(scalar)
+	if err != nil {
+		return nil, err
+	}
+	return marshalCOSE(p256JOSE, b, scalar)
+}
+
+// P256ScalarFromCOSE returns the scalar of the private key in the COSE_Key
+// key. The public key in key must match the scalar.
+func P256ScalarFromCOSE(key []byte) ([]byte, error) {
+	b, scalar, err := parseCOSE(p256JOSE, key)
+	if err != nil {
+		return nil, err
+	}
+	if scalar == nil {
+		return nil, errors.New("COSE_Key is not a private key")
+	}
+	q, err := NewP256Point().SetBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := p256JOSE.checkPrivateKey(scalar, q.Bytes()); err != nil {
+		return nil, err
+	}
+	return scalar, nil
+}
+
+// BytesCOSE returns the COSE_Key encoding of p, as specified in RFC 8152,
+// Section 13.1.1, with the ES384 algorithm, or an error if p is the point at
+// infinity.
+func (p *P384Point) BytesCOSE() ([]byte, error) {
+	return marshalCOSE(p384JOSE, p.Bytes(), nil)
+}
+
+// SetBytesCOSE sets p to the public key in the COSE_Key key, and returns p. The
+// key must be of type EC2 on curve P-384, with an uncompressed or compressed
+// point, and any private key is ignored. If key is not a valid P-384 public
+// key, SetBytesCOSE returns nil and an error, and p is unchanged.
+func (p *P384Point) SetBytesCOSE(key []byte) (*P384Point, error) {
+	b, _, err := parseCOSE(p384JOSE, key)
+	if err != nil {
+		return nil, err
+	}
+	return p.SetBytes(b)
+}
+
+// P384PrivateKeyCOSE returns the COSE_Key encoding of the private key scalar,
+// including the public key.
+func P384PrivateKeyCOSE(scalar []byte) ([]byte, error) {
+	b, err := p384JOSE.privateKeyPoint(scalar)
+	if err != nil {
+		return nil, err
+	}
+	return marshalCOSE(p384JOSE, b, scalar)
+}
+
+// P384ScalarFromCOSE returns the scalar of the private key in the COSE_Key
+// key. The public key in key must match the scalar.
+func P384ScalarFromCOSE(key []byte) ([]byte, error) {
+	b, scalar, err := parseCOSE(p384JOSE, key)
+	if err != nil {
+		return nil, err
+	}
+	if scalar == nil {
+		return nil, errors.New("COSE_Key is not a private key")
+	}
+	q, err := NewP384Point().SetBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := p384JOSE.checkPrivateKey(scalar, q.Bytes()); err != nil {
+		return nil, err
+	}
+	return scalar, nil
+}
+
+// BytesCOSE returns the COSE_Key encoding of p, as specified in RFC 8152,
+// Section 13.1.1, with the ES512 algorithm, or an error if p is the point at
+// infinity.
+func (p *P521Point) BytesCOSE() ([]byte, error) {
+	return marshalCOSE(p521JOSE, p.Bytes(), nil)
+}
+
+// SetBytesCOSE sets p to the public key in the COSE_Key key, and returns p. The
+// key must be of type EC2 on curve P-521, with an uncompressed or compressed
+// point, and any private key is ignored. If key is not a valid P-521 public
+// key, SetBytesCOSE returns nil and an error, and p is unchanged.
+func (p *P521Point) SetBytesCOSE(key []byte) (*P521Point, error) {
+	b, _, err := parseCOSE(p521JOSE, key)
+	if err != nil {
+		return nil, err
+	}
+	return p.SetBytes(b)
+}
+
+// P521PrivateKeyCOSE returns the COSE_Key encoding of the private key scalar,
+// including the public key.
+func P521PrivateKeyCOSE(scalar []byte) ([]byte, error) {
+	b, err := p521JOSE.privateKeyPoint(scalar)
+	if err != nil {
+		return nil, err
+	}
+	return marshalCOSE(p521JOSE, b, scalar)
+}
+
+// P521ScalarFromCOSE returns the scalar of the private key in the COSE_Key
+// key. The public key in key must match the scalar.
+func P521ScalarFromCOSE(key []byte) ([]byte, error) {
+	b, scalar, err := parseCOSE(p521JOSE, key)
+	if err != nil {
+		return nil, err
+	}
+	if scalar == nil {
+		return nil, errors.New("COSE_Key is not a private key")
+	}
+	q, err := NewP521Point().SetBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := p521JOSE.checkPrivateKey(scalar, q.Bytes()); err != nil {
+		return nil, err
+	}
+	return scalar, nil
+}
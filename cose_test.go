@@ -0,0 +1,203 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/magical/nistec-extra"
+)
+
+// Keys from RFC 8152, Appendix C.7.
+var rfc8152Keys = []struct {
+	kid     string
+	crv     byte
+	x, y, d string
+}{
+	{
+		kid: "meriadoc.brandybuck@buckland.example", crv: 1,
+		x: "65eda5a12577c2bae829437fe338701a10aaa375e1bb5b5de108de439c08551d",
+		y: "1e52ed75701163f7f9e40ddf9f341b3dc9ba860af7e0ca7ca7e9eecd0084d19c",
+		d: "aff907c99f9ad3aae6c4cdf21122bce2bd68b5283e6907154ad911840fa208cf",
+	},
+	{
+		kid: "11", crv: 1,
+		x: "bac5b11cad8f99f9c72b05cf4b9e26d244dc189f745228255a219a86d6a09eff",
+		y: "20138bf82dc1b6d562be0fa54ab7804a3a64b6d72ccfed6b6fb6ed28bbfc117e",
+		d: "57c92077664146e876760c9520d054aa93c3afb04e306705db6090308507b4d3",
+	},
+	{
+		kid: "bilbo.baggins@hobbiton.example", crv: 3,
+		x: "0072992cb3ac08ecf3e5c63dedec0d51a8c1f79ef2f82f94f3c737bf5de7986671eac625fe8257bbd0394644caaa3aaf8f27a4585fbbcad0f2457620085e5c8f42ad",
+		y: "01dca6947bce88bc5790485ac97427342bc35f887d86d65a089377e247e60baa55e4e8501e2ada5724ac51d6909008033ebc10ac999b9d7f5cc2519f3fe1ea1d9475",
+		d: "00085138ddabf5ca975f5860f91a08e91d6d5f9a76ad4018766a476680b55cd339e8ab6c72b5facdb2a2a50ac25bd086647dd3e2e6e99e84ca2c3609fdf177feb26d",
+	},
+}
+
+// cborMap encodes a CBOR map from alternating keys and values, which must be
+// already encoded.
+func cborMap(items ...[]byte) []byte {
+	b := []byte{0xa0 | byte(len(items)/2)}
+	for _, item := range items {
+		b = append(b, item...)
+	}
+	return b
+}
+
+func cborBytes(b []byte) []byte {
+	if len(b) < 24 {
+		return append([]byte{0x40 | byte(len(b))}, b...)
+	}
+	return append([]byte{0x58, byte(len(b))}, b...)
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestCOSEVectors(t *testing.T) {
+	for _, tt := range rfc8152Keys {
+		x, y, d := mustDecodeHex(tt.x), mustDecodeHex(tt.y), mustDecodeHex(tt.d)
+		pub := cborMap(
+			[]byte{0x20}, []byte{tt.crv}, // crv
+			[]byte{0x21}, cborBytes(x), // x
+			[]byte{0x22}, cborBytes(y), // y
+			[]byte{0x01}, []byte{0x02}, // kty: EC2
+			[]byte{0x02}, cborBytes([]byte(tt.kid)), // kid
+		)
+		priv := cborMap(
+			[]byte{0x01}, []byte{0x02},
+			[]byte{0x02}, cborBytes([]byte(tt.kid)),
+			[]byte{0x20}, []byte{tt.crv},
+			[]byte{0x21}, cborBytes(x),
+			[]byte{0x22}, cborBytes(y),
+			[]byte{0x23}, cborBytes(d),
+		)
+		want := append(append([]byte{4}, x...), y...)
+
+		switch tt.crv {
+		case 1:
+			p, err := nistec.NewP256Point().SetBytesCOSE(pub)
+			fatalIfErr(t, err)
+			if !bytes.Equal(p.Bytes(), want) {
+				t.Errorf("%s: SetBytesCOSE() = %x, want %x", tt.kid, p.Bytes(), want)
+			}
+			scalar, err := nistec.P256ScalarFromCOSE(priv)
+			fatalIfErr(t, err)
+			if !bytes.Equal(scalar, d) {
+				t.Errorf("%s: P256ScalarFromCOSE() = %x, want %x", tt.kid, scalar, d)
+			}
+			if _, err := nistec.NewP384Point().SetBytesCOSE(pub); err == nil {
+				t.Errorf("%s: P384Point.SetBytesCOSE accepted a P-256 key", tt.kid)
+			}
+			// ES256 (-7) and the deterministic label order of WebAuthn.
+			out, err := p.BytesCOSE()
+			fatalIfErr(t, err)
+			enc := cborMap([]byte{0x01}, []byte{0x02}, []byte{0x03}, []byte{0x26},
+				[]byte{0x20}, []byte{0x01}, []byte{0x21}, cborBytes(x), []byte{0x22}, cborBytes(y))
+			if !bytes.Equal(out, enc) {
+				t.Errorf("%s: BytesCOSE() = %x, want %x", tt.kid, out, enc)
+			}
+		case 3:
+			p, err := nistec.NewP521Point().SetBytesCOSE(pub)
+			fatalIfErr(t, err)
+			if !bytes.Equal(p.Bytes(), want) {
+				t.Errorf("%s: SetBytesCOSE() = %x, want %x", tt.kid, p.Bytes(), want)
+			}
+			scalar, err := nistec.P521ScalarFromCOSE(priv)
+			fatalIfErr(t, err)
+			if !bytes.Equal(scalar, d) {
+				t.Errorf("%s: P521ScalarFromCOSE() = %x, want %x", tt.kid, scalar, d)
+			}
+			// ES512 is -36, encoded as 0x38 0x23.
+			out, err := nistec.P521PrivateKeyCOSE(scalar)
+			fatalIfErr(t, err)
+			enc := cborMap([]byte{0x01}, []byte{0x02}, []byte{0x03}, []byte{0x38, 0x23},
+				[]byte{0x20}, []byte{0x03}, []byte{0x21}, cborBytes(x), []byte{0x22}, cborBytes(y),
+				[]byte{0x23}, cborBytes(d))
+			if !bytes.Equal(out, enc) {
+				t.Errorf("%s: P521PrivateKeyCOSE() = %x, want %x", tt.kid, out, enc)
+			}
+		}
+	}
+}
+
+func TestCOSE(t *testing.T) {
+	key := rfc8152Keys[0]
+	x, y, d := mustDecodeHex(key.x), mustDecodeHex(key.y), mustDecodeHex(key.d)
+	kty := []byte{0x01}
+	ec2 := []byte{0x02}
+	alg := []byte{0x03}
+	crv := []byte{0x20}
+	xl, yl, dl := []byte{0x21}, []byte{0x22}, []byte{0x23}
+
+	// A compressed point, with the sign of y as a boolean.
+	sign := []byte{0xf4 | y[len(y)-1]&1}
+	p, err := nistec.NewP256Point().SetBytesCOSE(cborMap(kty, ec2, crv, []byte{1}, xl, cborBytes(x), yl, sign))
+	fatalIfErr(t, err)
+	if !bytes.Equal(p.Bytes(), append(append([]byte{4}, x...), y...)) {
+		t.Errorf("SetBytesCOSE(compressed) = %x", p.Bytes())
+	}
+
+	// Unknown labels, including text ones with nested values, are ignored,
+	// and ECDH algorithms are allowed on any curve.
+	p, err = nistec.NewP256Point().SetBytesCOSE(cborMap(kty, ec2, alg, []byte{0x38, 0x18},
+		crv, []byte{1}, xl, cborBytes(x), yl, cborBytes(y),
+		[]byte{0x04}, []byte{0x82, 0x01, 0x02}, // key_ops: [1, 2]
+		[]byte{0x63, 'f', 'o', 'o'}, []byte{0xa1, 0x01, 0xc2, 0x41, 0x00}))
+	if err != nil {
+		t.Errorf("SetBytesCOSE rejected unknown labels: %v", err)
+	}
+
+	for name, enc := range map[string][]byte{
+		"wrong kty":      cborMap(kty, []byte{0x01}, crv, []byte{1}, xl, cborBytes(x), yl, cborBytes(y)),
+		"missing kty":    cborMap(crv, []byte{1}, xl, cborBytes(x), yl, cborBytes(y)),
+		"wrong crv":      cborMap(kty, ec2, crv, []byte{2}, xl, cborBytes(x), yl, cborBytes(y)),
+		"ES384 alg":      cborMap(kty, ec2, alg, []byte{0x38, 0x22}, crv, []byte{1}, xl, cborBytes(x), yl, cborBytes(y)),
+		"short x":        cborMap(kty, ec2, crv, []byte{1}, xl, cborBytes(x[1:]), yl, cborBytes(y)),
+		"long y":         cborMap(kty, ec2, crv, []byte{1}, xl, cborBytes(x), yl, cborBytes(append([]byte{0}, y...))),
+		"missing y":      cborMap(kty, ec2, crv, []byte{1}, xl, cborBytes(x)),
+		"x as int":       cborMap(kty, ec2, crv, []byte{1}, xl, []byte{0x01}, yl, cborBytes(y)),
+		"duplicate x":    cborMap(kty, ec2, crv, []byte{1}, xl, cborBytes(x), xl, cborBytes(x), yl, cborBytes(y)),
+		"wrong sign":     cborMap(kty, ec2, crv, []byte{1}, xl, cborBytes(x), yl, []byte{0xf6}),
+		"trailing data":  append(cborMap(kty, ec2, crv, []byte{1}, xl, cborBytes(x), yl, cborBytes(y)), 0),
+		"truncated":      cborMap(kty, ec2, crv, []byte{1}, xl, cborBytes(x), yl, cborBytes(y))[:70],
+		"indefinite map": append([]byte{0xbf}, cborMap(kty, ec2, crv, []byte{1}, xl, cborBytes(x), yl, cborBytes(y))[1:]...),
+		"array":          append([]byte{0x88}, cborMap(kty, ec2, crv, []byte{1}, xl, cborBytes(x), yl, cborBytes(y))[1:]...),
+		"deep nesting":   cborMap(kty, ec2, crv, []byte{1}, xl, cborBytes(x), yl, cborBytes(y), []byte{0x05}, append(bytes.Repeat([]byte{0x81}, 100), 0)),
+	} {
+		if _, err := nistec.NewP256Point().SetBytesCOSE(enc); err == nil {
+			t.Errorf("SetBytesCOSE accepted a key with %s", name)
+		}
+	}
+
+	other := append([]byte(nil), d...)
+	other[len(other)-1] ^= 1
+	for name, enc := range map[string][]byte{
+		"no d":      cborMap(kty, ec2, crv, []byte{1}, xl, cborBytes(x), yl, cborBytes(y)),
+		"another d": cborMap(kty, ec2, crv, []byte{1}, xl, cborBytes(x), yl, cborBytes(y), dl, cborBytes(other)),
+		"short d":   cborMap(kty, ec2, crv, []byte{1}, xl, cborBytes(x), yl, cborBytes(y), dl, cborBytes(d[1:])),
+	} {
+		if _, err := nistec.P256ScalarFromCOSE(enc); err == nil {
+			t.Errorf("P256ScalarFromCOSE accepted a key with %s", name)
+		}
+	}
+
+	for _, f := range []func() error{
+		func() error { _, err := nistec.NewP256Point().BytesCOSE(); return err },
+		func() error { _, err := nistec.NewP384Point().BytesCOSE(); return err },
+		func() error { _, err := nistec.P384PrivateKeyCOSE(make([]byte, 48)); return err },
+	} {
+		if f() == nil {
+			t.Error("encoded the point at infinity or a zero scalar")
+		}
+	}
+}
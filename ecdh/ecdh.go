@@ -0,0 +1,328 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ecdh implements Elliptic Curve Diffie-Hellman key agreement over the
+// NIST P curves, as specified in NIST SP 800-56A Rev. 3.
+//
+// Unlike crypto/ecdh, it exposes the private scalar, and it implements the key
+// agreement schemes of SP 800-56A on top of the ECC CDH primitive, with the key
+// derivation functions of ANSI X9.63 and NIST SP 800-56C Rev. 2.
+//
+// Public keys are always fully validated, as in SP 800-56A, Section
+// 5.6.2.3.3. The shared secret Z is zeroised as soon as the keying material is
+// derived from it, and ephemeral private keys are zeroised after use by the
+// schemes that consume them. Since Go can move and copy memory, zeroisation is
+// best effort.
+package ecdh
+
+import (
+	"crypto/elliptic"
+	"crypto/subtle"
+	"errors"
+	"io"
+
+	"github.com/magical/nistec-extra"
+	"github.com/magical/nistec-extra/group"
+)
+
+// Curve is one of the NIST P curves.
+type Curve struct {
+	name      string
+	scalarLen int
+	fieldLen  int
+	bitLen    int
+	order     []byte
+	g         group.Group
+
+	// publicKey returns the uncompressed encoding of [d]G.
+	publicKey func(d []byte) ([]byte, error)
+	// checkPoint validates the encoding of a point, and returns it
+	// uncompressed. It rejects the point at infinity.
+	checkPoint func(q []byte) ([]byte, error)
+	// sharedSecret returns the x coordinate of [d]Q. It fails if the result
+	// is the point at infinity.
+	sharedSecret func(d, q []byte) ([]byte, error)
+	// mqvSecret returns the x coordinate of [s](Qe + [t]Qs). It fails if the
+	// result is the point at infinity.
+	mqvSecret func(s, t, qe, qs []byte) ([]byte, error)
+}
+
+var (
+	p224 = newCurve("P-224", nistec.NewP224Point, elliptic.P224(), group.P224())
+	p256 = newCurve("P-256", nistec.NewP256Point, elliptic.P256(), group.P256())
+	p384 = newCurve("P-384", nistec.NewP384Point, elliptic.P384(), group.P384())
+	p521 = newCurve("P-521", nistec.NewP521Point, elliptic.P521(), group.P521())
+)
+
+// P224 returns a Curve implementing P-224.
+func P224() *Curve { return p224 }
+
+// P256 returns a Curve implementing P-256.
+func P256() *Curve { return p256 }
+
+// P384 returns a Curve implementing P-384.
+func P384() *Curve { return p384 }
+
+// P521 returns a Curve implementing P-521.
+func P521() *Curve { return p521 }
+
+// nistPoint is the set of methods of the nistec point types used here.
+type nistPoint[T any] interface {
+	SetBytes([]byte) (T, error)
+	Bytes() []byte
+	BytesX() ([]byte, error)
+	ScalarMult(T, []byte) (T, error)
+	ScalarBaseMult([]byte) (T, error)
+	Add(T, T) T
+}
+
+func newCurve[P nistPoint[P]](name string, newPoint func() P, c elliptic.Curve, g group.Group) *Curve {
+	n := c.Params().N
+	scalarLen := (n.BitLen() + 7) / 8
+	return &Curve{
+		name:      name,
+		scalarLen: scalarLen,
+		fieldLen:  (c.Params().BitSize + 7) / 8,
+		bitLen:    n.BitLen(),
+		order:     n.FillBytes(make([]byte, scalarLen)),
+		g:         g,
+		publicKey: func(d []byte) ([]byte, error) {
+			p, err := newPoint().ScalarBaseMult(d)
+			if err != nil {
+				return nil, err
+			}
+			return p.Bytes(), nil
+		},
+		checkPoint: func(q []byte) ([]byte, error) {
+			// SetBytes checks that the coordinates are in range, and that the
+			// point is on the curve. The cofactor is one, so every point on
+			// the curve is in the prime order subgroup.
+			p, err := newPoint().SetBytes(q)
+			if err != nil {
+				return nil, err
+			}
+			b := p.Bytes()
+			if len(b) == 1 {
+				return nil, errors.New("point at infinity")
+			}
+			return b, nil
+		},
+		sharedSecret: func(d, q []byte) ([]byte, error) {
+			p, err := newPoint().SetBytes(q)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.ScalarMult(p, d); err != nil {
+				return nil, err
+			}
+			return p.BytesX()
+		},
+		mqvSecret: func(s, t, qe, qs []byte) ([]byte, error) {
+			p, err := newPoint().SetBytes(qs)
+			if err != nil {
+				return nil, err
+			}
+			e, err := newPoint().SetBytes(qe)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.ScalarMult(p, t); err != nil {
+				return nil, err
+			}
+			if _, err := p.ScalarMult(p.Add(p, e), s); err != nil {
+				return nil, err
+			}
+			return p.BytesX()
+		},
+	}
+}
+
+// Name returns the name of the curve, such as "P-256".
+func (c *Curve) Name() string { return c.name }
+
+// ScalarLength returns the length of the encoding of a private key.
+func (c *Curve) ScalarLength() int { return c.scalarLen }
+
+// SharedSecretLength returns the length of the shared secret Z, the encoding
+// of an x coordinate.
+func (c *Curve) SharedSecretLength() int { return c.fieldLen }
+
+// PrivateKey is an ECDH private key.
+type PrivateKey struct {
+	c   *Curve
+	d   []byte
+	pub PublicKey
+}
+
+// PublicKey is an ECDH public key.
+type PublicKey struct {
+	c *Curve
+	q []byte
+}
+
+// GenerateKey returns a new private key, using rand as the source of
+// randomness, with the rejection sampling method of SP 800-56A, Section
+// 5.6.1.2.2.
+func GenerateKey(c *Curve, rand io.Reader) (*PrivateKey, error) {
+	d := make([]byte, c.scalarLen)
+	for {
+		if _, err := io.ReadFull(rand, d); err != nil {
+			return nil, err
+		}
+		// Mask off any excess bits, so that P-521 scalars aren't rejected most
+		// of the time.
+		if excess := len(d)*8 - c.bitLen; excess > 0 {
+			d[0] &= 1<<(8-excess) - 1
+		}
+		if checkScalar(d, c.order) == nil {
+			return newPrivateKey(c, d)
+		}
+	}
+}
+
+// NewPrivateKey returns the private key with scalar d, which must be the
+// big-endian encoding of an integer in [1, N-1], ScalarLength bytes long. d is
+// copied.
+func NewPrivateKey(c *Curve, d []byte) (*PrivateKey, error) {
+	if len(d) != c.scalarLen {
+		return nil, errors.New("ecdh: invalid private key length")
+	}
+	if err := checkScalar(d, c.order); err != nil {
+		return nil, errors.New("ecdh: invalid private key: " + err.Error())
+	}
+	return newPrivateKey(c, append([]byte(nil), d...))
+}
+
+func newPrivateKey(c *Curve, d []byte) (*PrivateKey, error) {
+	q, err := c.publicKey(d)
+	if err != nil {
+		return nil, err
+	}
+	return &PrivateKey{c: c, d: d, pub: PublicKey{c: c, q: q}}, nil
+}
+
+// checkScalar returns an error if d is not in [1, N-1], in constant time.
+func checkScalar(d, order []byte) error {
+	// Compute the borrow of d - N, from the least significant byte.
+	var borrow, acc byte
+	for i := len(d) - 1; i >= 0; i-- {
+		diff := uint16(d[i]) - uint16(order[i]) - uint16(borrow)
+		borrow = byte(diff>>8) & 1
+		acc |= d[i]
+	}
+	if borrow&^byte(subtle.ConstantTimeByteEq(acc, 0)) != 1 {
+		return errors.New("scalar out of range")
+	}
+	return nil
+}
+
+// NewPublicKey returns the public key encoded as q, an uncompressed or
+// compressed point, after performing the full public key validation of SP
+// 800-56A, Section 5.6.2.3.3.
+func NewPublicKey(c *Curve, q []byte) (*PublicKey, error) {
+	b, err := c.checkPoint(q)
+	if err != nil {
+		return nil, errors.New("ecdh: invalid public key: " + err.Error())
+	}
+	return &PublicKey{c: c, q: b}, nil
+}
+
+// Curve returns the curve of the key.
+func (k *PrivateKey) Curve() *Curve { return k.c }
+
+// Bytes returns a copy of the ScalarLength bytes big-endian encoding of the
+// private scalar.
+func (k *PrivateKey) Bytes() []byte { return append([]byte(nil), k.d...) }
+
+// PublicKey returns the public key corresponding to k.
+func (k *PrivateKey) PublicKey() *PublicKey { return &k.pub }
+
+// Destroy zeroises the private scalar. The key can't be used afterwards.
+func (k *PrivateKey) Destroy() { zeroise(k.d) }
+
+// Curve returns the curve of the key.
+func (k *PublicKey) Curve() *Curve { return k.c }
+
+// Bytes returns the uncompressed encoding of the public point.
+func (k *PublicKey) Bytes() []byte { return append([]byte(nil), k.q...) }
+
+// BytesCompressed returns the compressed encoding of the public point.
+func (k *PublicKey) BytesCompressed() []byte {
+	x := k.q[1 : 1+k.c.fieldLen]
+	return append([]byte{2 | k.q[len(k.q)-1]&1}, x...)
+}
+
+func zeroise(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// SharedSecret implements the ECC CDH primitive of SP 800-56A, Section
+// 5.7.1.2, and returns the shared secret Z, the x coordinate of [d]Q where d is
+// the private scalar of priv and Q the point of peer.
+//
+// Z must not be used directly as a key. The caller is responsible for passing
+// it to a KDF and zeroising it; DeriveKey does both.
+func SharedSecret(priv *PrivateKey, peer *PublicKey) ([]byte, error) {
+	if priv.c != peer.c {
+		return nil, errors.New("ecdh: keys are on different curves")
+	}
+	if checkScalar(priv.d, priv.c.order) != nil {
+		return nil, errors.New("ecdh: private key was destroyed")
+	}
+	z, err := priv.c.sharedSecret(priv.d, peer.q)
+	if err != nil {
+		return nil, errors.New("ecdh: shared secret is the point at infinity")
+	}
+	return z, nil
+}
+
+// DeriveKey computes the shared secret Z of priv and peer, derives length bytes
+// of keying material from it with kdf, and zeroises Z.
+func DeriveKey(priv *PrivateKey, peer *PublicKey, kdf KDF, length int) ([]byte, error) {
+	z, err := SharedSecret(priv, peer)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroise(z)
+	return kdf(z, length)
+}
+
+// EphemeralUnified implements the Ephemeral Unified Model scheme,
+// C(2e, 0s, ECC CDH) from SP 800-56A, Section 6.1.2.2, in which both parties
+// contribute an ephemeral key pair. Each party generates an ephemeral key with
+// GenerateKey, sends the public key to the other, and calls EphemeralUnified
+// with its private key and the received public key.
+//
+// The ephemeral private key is destroyed before EphemeralUnified returns, even
+// if an error occurred.
+func EphemeralUnified(ephemeral *PrivateKey, peer *PublicKey, kdf KDF, length int) ([]byte, error) {
+	defer ephemeral.Destroy()
+	return DeriveKey(ephemeral, peer, kdf, length)
+}
+
+// OnePassInitiate implements the role of party U in the One-Pass
+// Diffie-Hellman scheme, C(1e, 1s, ECC CDH) from SP 800-56A, Section 6.2.2.2,
+// in which U generates an ephemeral key pair and combines it with the static
+// public key of party V. It returns the ephemeral public key, to be sent to V,
+// and the derived keying material. The ephemeral private key is destroyed.
+func OnePassInitiate(rand io.Reader, static *PublicKey, kdf KDF, length int) (ephemeral *PublicKey, key []byte, err error) {
+	priv, err := GenerateKey(static.c, rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err = EphemeralUnified(priv, static, kdf, length)
+	if err != nil {
+		return nil, nil, err
+	}
+	return priv.PublicKey(), key, nil
+}
+
+// OnePassRespond implements the role of party V in the One-Pass
+// Diffie-Hellman scheme, combining the static private key of V with the
+// ephemeral public key received from U.
+func OnePassRespond(static *PrivateKey, ephemeral *PublicKey, kdf KDF, length int) ([]byte, error) {
+	return DeriveKey(static, ephemeral, kdf, length)
+}
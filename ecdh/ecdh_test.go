@@ -0,0 +1,393 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdh_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/magical/nistec-extra/ecdh"
+)
+
+var curves = []*ecdh.Curve{ecdh.P224(), ecdh.P256(), ecdh.P384(), ecdh.P521()}
+
+func fatalIfErr(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	fatalIfErr(t, err)
+	return b
+}
+
+type hexBytes []byte
+
+func (b *hexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := hex.DecodeString(s)
+	*b = v
+	return err
+}
+
+var curvesByName = map[string]*ecdh.Curve{
+	"P-224": ecdh.P224(),
+	"P-256": ecdh.P256(),
+	"P-384": ecdh.P384(),
+	"P-521": ecdh.P521(),
+}
+
+// TestCAVP checks the ECC CDH primitive against the first vector of each
+// curve of the NIST CAVP KAS ECC CDH primitive test vectors,
+// KAS_ECC_CDH_PrimitiveTest.txt. The P-521 values, which the file pads to 68
+// bytes, are trimmed to 66 bytes.
+func TestCAVP(t *testing.T) {
+	for _, tt := range []struct {
+		c               *ecdh.Curve
+		d, peer, pub, z string
+	}{
+		{
+			ecdh.P224(),
+			"8346a60fc6f293ca5a0d2af68ba71d1dd389e5e40837942df3e43cbd",
+			"04af33cd0629bc7e996320a3f40368f74de8704fa37b8fab69abaae280882092ccbba7930f419a8a4f9bb16978bbc3838729992559a6f2e2d7",
+			"048de2e26adf72c582d6568ef638c4fd59b18da171bdf501f1d929e0484a68a1c2b0fb22930d120555c1ece50ea98dea8407f71be36efac0de",
+			"7d96f9a3bd3c05cf5cc37feb8b9d5209d5c2597464dec3e9983743e8",
+		},
+		{
+			ecdh.P256(),
+			"7d7dc5f71eb29ddaf80d6214632eeae03d9058af1fb6d22ed80badb62bc1a534",
+			"04700c48f77f56584c5cc632ca65640db91b6bacce3a4df6b42ce7cc838833d287db71e509e3fd9b060ddb20ba5c51dcc5948d46fbf640dfe0441782cab85fa4ac",
+			"04ead218590119e8876b29146ff89ca61770c4edbbf97d38ce385ed281d8a6b23028af61281fd35e2fa7002523acc85a429cb06ee6648325389f59edfce1405141",
+			"46fc62106420ff012e54a434fbdd2d25ccc5852060561e68040dd7778997bd7b",
+		},
+		{
+			ecdh.P384(),
+			"3cc3122a68f0d95027ad38c067916ba0eb8c38894d22e1b15618b6818a661774ad463b205da88cf699ab4d43c9cf98a1",
+			"04a7c76b970c3b5fe8b05d2838ae04ab47697b9eaf52e764592efda27fe7513272734466b400091adbf2d68c58e0c50066ac68f19f2e1cb879aed43a9969b91a0839c4c38a49749b661efedf243451915ed0905a32b060992b468c64766fc8437a",
+			"049803807f2f6d2fd966cdd0290bd410c0190352fbec7ff6247de1302df86f25d34fe4a97bef60cff548355c015dbb3e5fba26ca69ec2f5b5d9dad20cc9da711383a9dbe34ea3fa5a2af75b46502629ad54dd8b7d73a8abb06a3a3be47d650cc99",
+			"5f9d29dc5e31a163060356213669c8ce132e22f57c9a04f40ba7fcead493b457e5621e766c40a2e3d4d6a04b25e533f1",
+		},
+		{
+			ecdh.P521(),
+			"017eecc07ab4b329068fba65e56a1f8890aa935e57134ae0ffcce802735151f4eac6564f6ee9974c5e6887a1fefee5743ae2241bfeb95d5ce31ddcb6f9edb4d6fc47",
+			"0400685a48e86c79f0f0875f7bc18d25eb5fc8c0b07e5da4f4370f3a9490340854334b1e1b87fa395464c60626124a4e70d0f785601d37c09870ebf176666877a2046d01ba52c56fc8776d9e8f5db4f0cc27636d0b741bbe05400697942e80b739884a83bde99e0f6716939e632bc8986fa18dccd443a348b6c3e522497955a4f3c302f676",
+			"0400602f9d0cf9e526b29e22381c203c48a886c2b0673033366314f1ffbcba240ba42f4ef38a76174635f91e6b4ed34275eb01c8467d05ca80315bf1a7bbd945f550a501b7c85f26f5d4b2d7355cf6b02117659943762b6d1db5ab4f1dbc44ce7b2946eb6c7de342962893fd387d1b73d7a8672d1f236961170b7eb3579953ee5cdc88cd2d",
+			"005fc70477c3e63bc3954bd0df3ea0d1f41ee21746ed95fc5e1fdf90930d5e136672d72cc770742d1711c3c3a4c334a0ad9759436a4d3c5bf6e74b9578fac148c831",
+		}} {
+		t.Run(tt.c.Name(), func(t *testing.T) {
+			priv, err := ecdh.NewPrivateKey(tt.c, decodeHex(t, tt.d))
+			fatalIfErr(t, err)
+			if got := priv.PublicKey().Bytes(); !bytes.Equal(got, decodeHex(t, tt.pub)) {
+				t.Errorf("public key = %x, want %s", got, tt.pub)
+			}
+			peer, err := ecdh.NewPublicKey(tt.c, decodeHex(t, tt.peer))
+			fatalIfErr(t, err)
+			z, err := ecdh.SharedSecret(priv, peer)
+			fatalIfErr(t, err)
+			if !bytes.Equal(z, decodeHex(t, tt.z)) {
+				t.Errorf("Z = %x, want %s", z, tt.z)
+			}
+			if len(z) != tt.c.SharedSecretLength() {
+				t.Errorf("len(Z) = %d, want %d", len(z), tt.c.SharedSecretLength())
+			}
+		})
+	}
+}
+
+// TestKASVectors checks the C(2e, 0s) and C(1e, 1s) schemes with the one-step
+// KDF against testdata/kas.json, generated by testdata/gen.py with the ECDH
+// and ConcatKDFHash of pyca/cryptography. U's key is read by OnePassInitiate.
+func TestKASVectors(t *testing.T) {
+	for _, v := range loadKASVectors(t).Schemes {
+		t.Run(v.Scheme+"/"+v.Curve, func(t *testing.T) {
+			c := curvesByName[v.Curve]
+			kdf := ecdh.OneStepKDF(hashes[v.Hash], v.FixedInfo)
+			u, err := ecdh.NewPrivateKey(c, v.DU)
+			fatalIfErr(t, err)
+			vPriv, err := ecdh.NewPrivateKey(c, v.DV)
+			fatalIfErr(t, err)
+			if got := u.PublicKey().Bytes(); !bytes.Equal(got, v.QU) {
+				t.Errorf("QU = %x, want %x", got, v.QU)
+			}
+			if got := vPriv.PublicKey().Bytes(); !bytes.Equal(got, v.QV) {
+				t.Errorf("QV = %x, want %x", got, v.QV)
+			}
+			z, err := ecdh.SharedSecret(u, vPriv.PublicKey())
+			fatalIfErr(t, err)
+			if !bytes.Equal(z, v.Z) {
+				t.Errorf("Z = %x, want %x", z, v.Z)
+			}
+
+			var ku, kv []byte
+			switch v.Scheme {
+			case "ephemeralUnified":
+				ku, err = ecdh.EphemeralUnified(u, vPriv.PublicKey(), kdf, len(v.DKM))
+				fatalIfErr(t, err)
+				kv, err = ecdh.EphemeralUnified(vPriv, u.PublicKey(), kdf, len(v.DKM))
+				fatalIfErr(t, err)
+			case "onePassDh":
+				var eph *ecdh.PublicKey
+				eph, ku, err = ecdh.OnePassInitiate(bytes.NewReader(v.DU), vPriv.PublicKey(), kdf, len(v.DKM))
+				fatalIfErr(t, err)
+				if !bytes.Equal(eph.Bytes(), v.QU) {
+					t.Errorf("ephemeral key = %x, want %x", eph.Bytes(), v.QU)
+				}
+				kv, err = ecdh.OnePassRespond(vPriv, eph, kdf, len(v.DKM))
+				fatalIfErr(t, err)
+			default:
+				t.Fatalf("unknown scheme %q", v.Scheme)
+			}
+			if !bytes.Equal(ku, v.DKM) {
+				t.Errorf("U DKM = %x, want %x", ku, v.DKM)
+			}
+			if !bytes.Equal(kv, v.DKM) {
+				t.Errorf("V DKM = %x, want %x", kv, v.DKM)
+			}
+		})
+	}
+}
+
+// TestACVP checks the ECC CDH primitive against the VAL groups of a NIST ACVP
+// KAS-ECC-SSC sample vector set, testdata/acvp-kas-ecc-ssc.json, whose source
+// field records where it was taken from. The IUT must compute Z and report
+// whether it matches the z of the server.
+func TestACVP(t *testing.T) {
+	data, err := os.ReadFile("testdata/acvp-kas-ecc-ssc.json")
+	fatalIfErr(t, err)
+	var vs struct {
+		TestGroups []struct {
+			Curve  string `json:"domainParameterGenerationMode"`
+			Scheme string
+			Tests  []struct {
+				TcID int
+				Z    hexBytes
+
+				EphemeralPrivateIut    hexBytes
+				EphemeralPublicIutX    hexBytes
+				EphemeralPublicIutY    hexBytes
+				EphemeralPublicServerX hexBytes
+				EphemeralPublicServerY hexBytes
+
+				StaticPrivateIut    hexBytes
+				StaticPublicIutX    hexBytes
+				StaticPublicIutY    hexBytes
+				StaticPublicServerX hexBytes
+				StaticPublicServerY hexBytes
+
+				TestPassed bool
+			}
+		}
+	}
+	fatalIfErr(t, json.Unmarshal(data, &vs))
+	for _, g := range vs.TestGroups {
+		c := curvesByName[g.Curve]
+		for _, tt := range g.Tests {
+			d, x, y := tt.EphemeralPrivateIut, tt.EphemeralPublicIutX, tt.EphemeralPublicIutY
+			sx, sy := tt.EphemeralPublicServerX, tt.EphemeralPublicServerY
+			if g.Scheme == "staticUnified" {
+				d, x, y = tt.StaticPrivateIut, tt.StaticPublicIutX, tt.StaticPublicIutY
+				sx, sy = tt.StaticPublicServerX, tt.StaticPublicServerY
+			}
+			priv, err := ecdh.NewPrivateKey(c, d)
+			fatalIfErr(t, err)
+			if got, want := priv.PublicKey().Bytes(), append(append([]byte{4}, x...), y...); !bytes.Equal(got, want) {
+				t.Errorf("tcId %d: public key = %x, want %x", tt.TcID, got, want)
+			}
+			peer, err := ecdh.NewPublicKey(c, append(append([]byte{4}, sx...), sy...))
+			fatalIfErr(t, err)
+			z, err := ecdh.SharedSecret(priv, peer)
+			fatalIfErr(t, err)
+			if got := bytes.Equal(z, tt.Z); got != tt.TestPassed {
+				t.Errorf("tcId %d: Z = %x, z = %x, testPassed = %v, want %v", tt.TcID, z, tt.Z, got, tt.TestPassed)
+			}
+		}
+	}
+}
+
+// TestWycheproof checks the ECC CDH primitive and public key validation
+// against the Project Wycheproof ECDH vectors for raw points, copied in
+// testdata/wycheproof from github.com/google/wycheproof at commit 2196000605e4
+// (Apache License 2.0). "acceptable" vectors, which use compressed points, may
+// either fail or succeed with the expected shared secret.
+func TestWycheproof(t *testing.T) {
+	for name, c := range map[string]*ecdh.Curve{
+		"secp224r1": ecdh.P224(),
+		"secp256r1": ecdh.P256(),
+		"secp384r1": ecdh.P384(),
+		"secp521r1": ecdh.P521(),
+	} {
+		t.Run(c.Name(), func(t *testing.T) {
+			data, err := os.ReadFile("testdata/wycheproof/ecdh_" + name + "_ecpoint_test.json")
+			fatalIfErr(t, err)
+			var vs struct {
+				TestGroups []struct {
+					Tests []struct {
+						TcID    int
+						Comment string
+						Public  hexBytes
+						Private string
+						Shared  hexBytes
+						Result  string
+					}
+				}
+			}
+			fatalIfErr(t, json.Unmarshal(data, &vs))
+			for _, g := range vs.TestGroups {
+				for _, tt := range g.Tests {
+					// Private keys are encoded as ASN.1 integers, with a
+					// leading zero byte if their top bit is set.
+					d, ok := new(big.Int).SetString(tt.Private, 16)
+					if !ok {
+						t.Fatalf("tcId %d: invalid private key %q", tt.TcID, tt.Private)
+					}
+					priv, err := ecdh.NewPrivateKey(c, d.FillBytes(make([]byte, c.ScalarLength())))
+					fatalIfErr(t, err)
+					z, err := sharedSecret(priv, c, tt.Public)
+					switch {
+					case err == nil && !bytes.Equal(z, tt.Shared):
+						t.Errorf("tcId %d (%s): Z = %x, want %x", tt.TcID, tt.Comment, z, tt.Shared)
+					case err == nil && tt.Result == "invalid":
+						t.Errorf("tcId %d (%s): invalid public key accepted", tt.TcID, tt.Comment)
+					case err != nil && tt.Result == "valid":
+						t.Errorf("tcId %d (%s): %v", tt.TcID, tt.Comment, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func sharedSecret(priv *ecdh.PrivateKey, c *ecdh.Curve, q []byte) ([]byte, error) {
+	peer, err := ecdh.NewPublicKey(c, q)
+	if err != nil {
+		return nil, err
+	}
+	return ecdh.SharedSecret(priv, peer)
+}
+
+func TestSchemes(t *testing.T) {
+	for _, c := range curves {
+		t.Run(c.Name(), func(t *testing.T) {
+			testSchemes(t, c)
+		})
+	}
+}
+
+func testSchemes(t *testing.T, c *ecdh.Curve) {
+	kdf := ecdh.X963KDF(sha256.New, []byte("test"))
+
+	// C(2e, 0s): both parties use ephemeral keys, which are destroyed.
+	u, err := ecdh.GenerateKey(c, rand.Reader)
+	fatalIfErr(t, err)
+	v, err := ecdh.GenerateKey(c, rand.Reader)
+	fatalIfErr(t, err)
+	ku, err := ecdh.EphemeralUnified(u, v.PublicKey(), kdf, 32)
+	fatalIfErr(t, err)
+	kv, err := ecdh.EphemeralUnified(v, u.PublicKey(), kdf, 32)
+	fatalIfErr(t, err)
+	if !bytes.Equal(ku, kv) {
+		t.Errorf("Ephemeral Unified keys differ: %x != %x", ku, kv)
+	}
+	if !bytes.Equal(u.Bytes(), make([]byte, c.ScalarLength())) {
+		t.Error("ephemeral key was not destroyed")
+	}
+	if _, err := ecdh.SharedSecret(u, v.PublicKey()); err == nil {
+		t.Error("destroyed key was used")
+	}
+
+	// C(1e, 1s): U uses an ephemeral key, V a static one.
+	static, err := ecdh.GenerateKey(c, rand.Reader)
+	fatalIfErr(t, err)
+	eph, ku, err := ecdh.OnePassInitiate(rand.Reader, static.PublicKey(), kdf, 48)
+	fatalIfErr(t, err)
+	kv, err = ecdh.OnePassRespond(static, eph, kdf, 48)
+	fatalIfErr(t, err)
+	if !bytes.Equal(ku, kv) {
+		t.Errorf("One-Pass keys differ: %x != %x", ku, kv)
+	}
+	if bytes.Equal(static.Bytes(), make([]byte, c.ScalarLength())) {
+		t.Error("static key was destroyed")
+	}
+
+	other := curves[0]
+	if c == other {
+		other = curves[1]
+	}
+	k, err := ecdh.GenerateKey(other, rand.Reader)
+	fatalIfErr(t, err)
+	if _, err := ecdh.SharedSecret(static, k.PublicKey()); err == nil {
+		t.Error("SharedSecret accepted keys on different curves")
+	}
+}
+
+func TestValidation(t *testing.T) {
+	for _, c := range curves {
+		t.Run(c.Name(), func(t *testing.T) {
+			priv, err := ecdh.GenerateKey(c, rand.Reader)
+			fatalIfErr(t, err)
+			q := priv.PublicKey().Bytes()
+
+			if _, err := ecdh.NewPublicKey(c, []byte{0}); err == nil {
+				t.Error("point at infinity accepted")
+			}
+			bad := append([]byte(nil), q...)
+			bad[len(bad)-1] ^= 1
+			if _, err := ecdh.NewPublicKey(c, bad); err == nil {
+				t.Error("point not on the curve accepted")
+			}
+			if _, err := ecdh.NewPublicKey(c, q[:len(q)-1]); err == nil {
+				t.Error("truncated point accepted")
+			}
+			// Compressed points are accepted, and stored uncompressed.
+			comp := priv.PublicKey().BytesCompressed()
+			if len(comp) != 1+c.SharedSecretLength() || comp[0] != 2|q[len(q)-1]&1 {
+				t.Errorf("BytesCompressed = %x", comp)
+			}
+			pub, err := ecdh.NewPublicKey(c, comp)
+			fatalIfErr(t, err)
+			if !bytes.Equal(pub.Bytes(), q) {
+				t.Error("compressed point decoded incorrectly")
+			}
+
+			if _, err := ecdh.NewPrivateKey(c, make([]byte, c.ScalarLength())); err == nil {
+				t.Error("zero private key accepted")
+			}
+			order := decodeHex(t, orders[c.Name()])
+			if _, err := ecdh.NewPrivateKey(c, order); err == nil {
+				t.Error("private key equal to N accepted")
+			}
+			order[len(order)-1]--
+			if _, err := ecdh.NewPrivateKey(c, order); err != nil {
+				t.Errorf("private key N-1 rejected: %v", err)
+			}
+			if _, err := ecdh.NewPrivateKey(c, priv.Bytes()[1:]); err == nil {
+				t.Error("short private key accepted")
+			}
+			if _, err := ecdh.GenerateKey(c, bytes.NewReader(nil)); err == nil {
+				t.Error("GenerateKey succeeded with an empty rand")
+			}
+		})
+	}
+}
+
+var orders = map[string]string{
+	"P-224": "ffffffffffffffffffffffffffff16a2e0b8f03e13dd29455c5c2a3d",
+	"P-256": "ffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632551",
+	"P-384": "ffffffffffffffffffffffffffffffffffffffffffffffffc7634d81f4372ddf581a0db248b0a77aecec196accc52973",
+	"P-521": "01fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffa51868783bf2f966b7fcc0148f709a5d03bb5c9b8899c47aebb6fb71e91386409",
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdh
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// A KDF derives length bytes of keying material from the shared secret z. It
+// must not retain z.
+type KDF func(z []byte, length int) ([]byte, error)
+
+// X963KDF returns the key derivation function of ANSI X9.63, also specified
+// in SEC 1, Version 2.0, Section 3.6.1, which concatenates
+//
+//	H(Z || Counter || SharedInfo)
+//
+// for a big-endian 32-bit Counter starting at 1.
+func X963KDF(h func() hash.Hash, sharedInfo []byte) KDF {
+	sharedInfo = append([]byte(nil), sharedInfo...)
+	return func(z []byte, length int) ([]byte, error) {
+		return counterKDF(h, z, sharedInfo, length, false)
+	}
+}
+
+// OneStepKDF returns the hash-based one-step key derivation function of NIST
+// SP 800-56C Rev. 2, Section 4.1, Option 1, also known as the concatenation
+// KDF, which concatenates
+//
+//	H(Counter || Z || FixedInfo)
+//
+// for a big-endian 32-bit Counter starting at 1.
+func OneStepKDF(h func() hash.Hash, fixedInfo []byte) KDF {
+	fixedInfo = append([]byte(nil), fixedInfo...)
+	return func(z []byte, length int) ([]byte, error) {
+		return counterKDF(h, z, fixedInfo, length, true)
+	}
+}
+
+// counterKDF implements both X963KDF and OneStepKDF, which only differ by the
+// position of the counter.
+func counterKDF(h func() hash.Hash, z, info []byte, length int, counterFirst bool) ([]byte, error) {
+	H := h()
+	if length < 0 || uint64(length) > uint64(H.Size())*(1<<32-1) {
+		return nil, errors.New("ecdh: requested key length too large")
+	}
+	out := make([]byte, 0, length+H.Size())
+	var counter [4]byte
+	for i := uint32(1); len(out) < length; i++ {
+		binary.BigEndian.PutUint32(counter[:], i)
+		H.Reset()
+		if counterFirst {
+			H.Write(counter[:])
+			H.Write(z)
+		} else {
+			H.Write(z)
+			H.Write(counter[:])
+		}
+		H.Write(info)
+		out = H.Sum(out)
+	}
+	return out[:length], nil
+}
+
+// HKDF returns the two-step key derivation function of NIST SP 800-56C
+// Rev. 2, Section 5, instantiated with HMAC as in RFC 5869.
+func HKDF(h func() hash.Hash, salt, info []byte) KDF {
+	salt = append([]byte(nil), salt...)
+	info = append([]byte(nil), info...)
+	return func(z []byte, length int) ([]byte, error) {
+		if length < 0 || length > 255*h().Size() {
+			return nil, errors.New("ecdh: requested key length too large")
+		}
+		out := make([]byte, length)
+		if _, err := io.ReadFull(hkdf.New(h, z, salt, info), out); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+}
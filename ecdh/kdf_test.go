@@ -0,0 +1,166 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdh_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"hash"
+	"os"
+	"testing"
+
+	"github.com/magical/nistec-extra/ecdh"
+)
+
+func TestKDF(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		kdf  func(h func() hash.Hash, info []byte) ecdh.KDF
+		h    func() hash.Hash
+		z    string
+		info string
+		want string
+	}{
+		// From the NIST CAVP ANSI X9.63 KDF test vectors, SHA-256.
+		{
+			"X9.63", ecdh.X963KDF, sha256.New,
+			"96c05619d56c328ab95fe84b18264b08725b85e33fd34f08", "",
+			"443024c3dae66b95e6f5670601558f71",
+		},
+		{
+			"X9.63", ecdh.X963KDF, sha256.New,
+			"22518b10e70f2a3f243810ae3254139efbee04aa57c7af7d",
+			"75eef81aa3041e33b80971203d2c0c52",
+			"c498af77161cc59f2962b9a713e2b215152d139766ce34a776df11866a69bf2e52a13d9c7c6fc878c50c5ea0bc7b00e0da2447cfd874f6cf92f30d0097111485500c90c3af8b487872d04685d14c8d1dc8d7fa08beb0ce0ababc11f0bd496269142d43525a78e5bc79a17f59676a5706dc54d54d4d1f0bd7e386128ec26afc21",
+		},
+	} {
+		want := decodeHex(t, tt.want)
+		got, err := tt.kdf(tt.h, decodeHex(t, tt.info))(decodeHex(t, tt.z), len(want))
+		fatalIfErr(t, err)
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s(%s) = %x, want %x", tt.name, tt.z, got, want)
+		}
+	}
+}
+
+var hashes = map[string]func() hash.Hash{
+	"SHA-224": sha256.New224,
+	"SHA-256": sha256.New,
+	"SHA-384": sha512.New384,
+	"SHA-512": sha512.New,
+}
+
+// kasVectors are the vectors of testdata/kas.json, generated by
+// testdata/gen.py with the ECDH and ConcatKDFHash of pyca/cryptography.
+type kasVectors struct {
+	OneStepKDF []struct {
+		Hash         string
+		Z, FixedInfo hexBytes
+		DKM          hexBytes
+	}
+	Schemes []struct {
+		Scheme, Curve, Hash string
+		DU, QU, DV, QV      hexBytes
+		Z, FixedInfo, DKM   hexBytes
+	}
+}
+
+func loadKASVectors(t *testing.T) *kasVectors {
+	data, err := os.ReadFile("testdata/kas.json")
+	fatalIfErr(t, err)
+	v := new(kasVectors)
+	fatalIfErr(t, json.Unmarshal(data, v))
+	return v
+}
+
+// TestOneStepKDF checks the one-step KDF against the ConcatKDFHash of
+// pyca/cryptography.
+func TestOneStepKDF(t *testing.T) {
+	for _, v := range loadKASVectors(t).OneStepKDF {
+		got, err := ecdh.OneStepKDF(hashes[v.Hash], v.FixedInfo)(v.Z, len(v.DKM))
+		fatalIfErr(t, err)
+		if !bytes.Equal(got, v.DKM) {
+			t.Errorf("%s: OneStepKDF(%x) = %x, want %x", v.Hash, v.Z, got, v.DKM)
+		}
+	}
+}
+
+// TestHKDF checks Test Case 1 of RFC 5869, Appendix A.
+func TestHKDF(t *testing.T) {
+	ikm := decodeHex(t, "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt := decodeHex(t, "000102030405060708090a0b0c")
+	info := decodeHex(t, "f0f1f2f3f4f5f6f7f8f9")
+	want := decodeHex(t, "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865")
+	got, err := ecdh.HKDF(sha256.New, salt, info)(ikm, len(want))
+	fatalIfErr(t, err)
+	if !bytes.Equal(got, want) {
+		t.Errorf("HKDF = %x, want %x", got, want)
+	}
+	if _, err := ecdh.HKDF(sha256.New, salt, info)(ikm, 255*32+1); err == nil {
+		t.Error("HKDF accepted a length over 255 blocks")
+	}
+}
+
+// TestACVPHKDF checks HKDF against a NIST ACVP KDA HKDF sample vector set,
+// testdata/acvp-kda-hkdf.json, whose source field records where it was taken
+// from. FixedInfo is the concatenation of PartyUInfo and PartyVInfo, each the
+// party identifier followed by its ephemeral data, if any.
+func TestACVPHKDF(t *testing.T) {
+	data, err := os.ReadFile("testdata/acvp-kda-hkdf.json")
+	fatalIfErr(t, err)
+	type partyInfo struct {
+		PartyID       hexBytes
+		EphemeralData hexBytes
+	}
+	var vs struct {
+		TestGroups []struct {
+			TestType         string
+			KDFConfiguration struct {
+				HMACAlg string
+			}
+			Tests []struct {
+				TcID                             int
+				FixedInfoPartyU, FixedInfoPartyV partyInfo
+				KDFParameter                     struct {
+					L       int
+					Salt, Z hexBytes
+				}
+				DKM        hexBytes
+				TestPassed bool
+			}
+		}
+	}
+	fatalIfErr(t, json.Unmarshal(data, &vs))
+	acvpHashes := map[string]func() hash.Hash{
+		"SHA2-224":     sha256.New224,
+		"SHA2-256":     sha256.New,
+		"SHA2-384":     sha512.New384,
+		"SHA2-512":     sha512.New,
+		"SHA2-512/224": sha512.New512_224,
+		"SHA2-512/256": sha512.New512_256,
+	}
+	for _, g := range vs.TestGroups {
+		h := acvpHashes[g.KDFConfiguration.HMACAlg]
+		for _, tt := range g.Tests {
+			var fixedInfo []byte
+			for _, p := range []partyInfo{tt.FixedInfoPartyU, tt.FixedInfoPartyV} {
+				fixedInfo = append(fixedInfo, p.PartyID...)
+				fixedInfo = append(fixedInfo, p.EphemeralData...)
+			}
+			p := tt.KDFParameter
+			dkm, err := ecdh.HKDF(h, p.Salt, fixedInfo)(p.Z, p.L/8)
+			fatalIfErr(t, err)
+			got := bytes.Equal(dkm, tt.DKM)
+			if g.TestType == "AFT" && !got {
+				t.Errorf("tcId %d: DKM = %x, want %x", tt.TcID, dkm, tt.DKM)
+			}
+			if g.TestType == "VAL" && got != tt.TestPassed {
+				t.Errorf("tcId %d: DKM = %x, dkm = %x, testPassed = %v, want %v", tt.TcID, dkm, tt.DKM, got, tt.TestPassed)
+			}
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdh
+
+import (
+	"errors"
+	"hash"
+)
+
+// In MQV, each party combines its ephemeral and static private keys into an
+// implicit signature S = de + t × ds mod N, and computes the shared point
+// [S](Qe' + [t']Qs') from the ephemeral and static public keys of the other
+// party. MQV derives t from the x coordinate of the ephemeral public key, while
+// FHMQV hashes the ephemeral and static public keys of both parties, which
+// binds the shared secret to the whole exchange and gives it a security proof.
+//
+// Each party needs a static key pair, whose public key the other party has
+// obtained and validated beforehand, and generates an ephemeral key pair for
+// each exchange, with GenerateKey.
+
+// avf returns the associate value of the public point encoded as q, as defined
+// in SP 800-56A, Section 5.7.2.2: the x coordinate reduced modulo 2^⌈f/2⌉, plus
+// 2^⌈f/2⌉, where f is the bit length of N.
+func (c *Curve) avf(q []byte) []byte {
+	x := q[1 : 1+c.fieldLen]
+	half := (c.bitLen + 1) / 2
+	// Copy the bytes up to the one holding bit half, then clear the bits above
+	// it and set it.
+	n := half/8 + 1
+	out := make([]byte, c.scalarLen)
+	copy(out[len(out)-n:], x[len(x)-n:])
+	top := &out[len(out)-n]
+	*top &= 1<<(half%8) - 1
+	*top |= 1 << (half % 8)
+	return out
+}
+
+// checkMQVKeys checks that the keys are on the same curve, and that the
+// private keys were not destroyed.
+func checkMQVKeys(static, ephemeral *PrivateKey, peerStatic, peerEphemeral *PublicKey) (*Curve, error) {
+	c := static.c
+	if ephemeral.c != c || peerStatic.c != c || peerEphemeral.c != c {
+		return nil, errors.New("ecdh: keys are on different curves")
+	}
+	if checkScalar(static.d, c.order) != nil || checkScalar(ephemeral.d, c.order) != nil {
+		return nil, errors.New("ecdh: private key was destroyed")
+	}
+	return c, nil
+}
+
+// implicitSecret returns the x coordinate of [S](Qe' + [t']Qs'), for the
+// implicit signature S = de + t × ds mod N.
+func (c *Curve) implicitSecret(static, ephemeral *PrivateKey, t []byte, peerStatic, peerEphemeral *PublicKey, peerT []byte) ([]byte, error) {
+	de, err := c.g.NewScalar().SetBytes(ephemeral.d)
+	if err != nil {
+		return nil, err
+	}
+	ds, err := c.g.NewScalar().SetBytes(static.d)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := c.g.NewScalar().SetBytes(t)
+	if err != nil {
+		return nil, err
+	}
+	s := ds.Multiply(ds, ts).Add(ds, de).Bytes()
+	defer zeroise(s)
+	z, err := c.mqvSecret(s, peerT, peerEphemeral.q, peerStatic.q)
+	if err != nil {
+		return nil, errors.New("ecdh: shared secret is the point at infinity")
+	}
+	return z, nil
+}
+
+// MQVSharedSecret implements the ECC MQV primitive of SP 800-56A, Section
+// 5.7.2.3, and returns the shared secret Z computed from the static and
+// ephemeral private keys of one party and the static and ephemeral public keys
+// of the other. Both parties obtain the same Z.
+//
+// Z must not be used directly as a key. The caller is responsible for passing
+// it to a KDF and zeroising it; MQV does both.
+func MQVSharedSecret(static, ephemeral *PrivateKey, peerStatic, peerEphemeral *PublicKey) ([]byte, error) {
+	c, err := checkMQVKeys(static, ephemeral, peerStatic, peerEphemeral)
+	if err != nil {
+		return nil, err
+	}
+	return c.implicitSecret(static, ephemeral, c.avf(ephemeral.pub.q),
+		peerStatic, peerEphemeral, c.avf(peerEphemeral.q))
+}
+
+// MQV implements the Full MQV scheme, C(2e, 2s, ECC MQV) from SP 800-56A,
+// Section 6.1.1.3. It computes the shared secret Z as MQVSharedSecret does,
+// derives length bytes of keying material from it with kdf, and zeroises Z.
+//
+// The ephemeral private key is destroyed before MQV returns, even if an error
+// occurred.
+func MQV(static, ephemeral *PrivateKey, peerStatic, peerEphemeral *PublicKey, kdf KDF, length int) ([]byte, error) {
+	defer ephemeral.Destroy()
+	z, err := MQVSharedSecret(static, ephemeral, peerStatic, peerEphemeral)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroise(z)
+	return kdf(z, length)
+}
+
+// FHMQVSharedSecret implements the FHMQV protocol of Sarr, Elbaz-Vincent and
+// Bajard, "A Secure and Efficient Authenticated Diffie-Hellman Protocol", and
+// returns the shared secret Z computed from the static and ephemeral private
+// keys of one party and the static and ephemeral public keys of the other.
+//
+// Unlike MQV, FHMQV distinguishes the initiator, party U with static key A and
+// ephemeral key X, from the responder, party V with static key B and ephemeral
+// key Y. With H̄ the first ⌈f/2⌉ bits of the hash h, where f is the bit length
+// of N,
+//
+//	d = H̄(X || Y || A || B)
+//	e = H̄(Y || X || A || B)
+//
+// where the points are uncompressed, and the static public keys stand for the
+// identities of the parties. U computes Z as the x coordinate of
+// [x + d × a](Y + [e]B), and V as that of [y + e × b](X + [d]A).
+//
+// The output of h must be at least ⌈f/2⌉ bits long.
+func FHMQVSharedSecret(h func() hash.Hash, static, ephemeral *PrivateKey, peerStatic, peerEphemeral *PublicKey, initiator bool) ([]byte, error) {
+	c, err := checkMQVKeys(static, ephemeral, peerStatic, peerEphemeral)
+	if err != nil {
+		return nil, err
+	}
+	a, x, b, y := static.pub.q, ephemeral.pub.q, peerStatic.q, peerEphemeral.q
+	if !initiator {
+		a, x, b, y = b, y, a, x
+	}
+	d, err := c.hashBar(h, x, y, a, b)
+	if err != nil {
+		return nil, err
+	}
+	e, err := c.hashBar(h, y, x, a, b)
+	if err != nil {
+		return nil, err
+	}
+	if !initiator {
+		d, e = e, d
+	}
+	return c.implicitSecret(static, ephemeral, d, peerStatic, peerEphemeral, e)
+}
+
+// hashBar returns the first ⌈f/2⌉ bits of H(points...), as a ScalarLength
+// bytes big-endian integer.
+func (c *Curve) hashBar(h func() hash.Hash, points ...[]byte) ([]byte, error) {
+	half := (c.bitLen + 1) / 2
+	H := h()
+	if H.Size()*8 < half {
+		return nil, errors.New("ecdh: hash output too short for FHMQV")
+	}
+	for _, p := range points {
+		H.Write(p)
+	}
+	sum := H.Sum(nil)[:(half+7)/8]
+	out := make([]byte, c.scalarLen)
+	copy(out[len(out)-len(sum):], sum)
+	// Shift right by the excess bits of the last byte.
+	if excess := len(sum)*8 - half; excess > 0 {
+		for i := len(out) - 1; i > 0; i-- {
+			out[i] = out[i]>>excess | out[i-1]<<(8-excess)
+		}
+		out[0] >>= excess
+	}
+	return out, nil
+}
+
+// FHMQV computes the shared secret Z as FHMQVSharedSecret does, derives length
+// bytes of keying material from it with kdf, and zeroises Z.
+//
+// The ephemeral private key is destroyed before FHMQV returns, even if an
+// error occurred.
+func FHMQV(h func() hash.Hash, static, ephemeral *PrivateKey, peerStatic, peerEphemeral *PublicKey, initiator bool, kdf KDF, length int) ([]byte, error) {
+	defer ephemeral.Destroy()
+	z, err := FHMQVSharedSecret(h, static, ephemeral, peerStatic, peerEphemeral, initiator)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroise(z)
+	return kdf(z, length)
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdh_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"testing"
+
+	"github.com/magical/nistec-extra/ecdh"
+)
+
+// TestMQVKnownAnswer checks MQV and FHMQV against values computed with an
+// independent implementation, for party U with static key a and ephemeral key
+// x, and party V with static key b and ephemeral key y.
+func TestMQVKnownAnswer(t *testing.T) {
+	for _, tt := range []struct {
+		c          *ecdh.Curve
+		h          func() hash.Hash
+		a, x, b, y string
+		mqv, fhmqv string
+	}{
+		{
+			ecdh.P256(), sha256.New,
+			"5dad58bc0c96166f4ccb90009d010a38618ab51748720880c4606f5d2bd1b036",
+			"c56a80035039768ba5a2bfc02e765705bb7bdd01f4c6f20898b1fb53dcb1c363",
+			"81587cd08856b71b143d1754b60dce0c45c491d93de9e7e0212053e9831f2481",
+			"e42dd969a7a65a2bf3497641088f1cbaded6f87ff6479339cf00e63a7e7a174b",
+			"d9c1887cc3f753d208bb089f92ab1403d3fca23d11d842d643acf7806737a677",
+			"2f29a526d5c733844e292286b116bd59f8875a90957ed6223cec979fb856aabf",
+		},
+		{
+			ecdh.P384(), sha512.New384,
+			"7ee9b4b7356bda6a9a0cd8c389b162910bd2e3f7cf6166173200414aa52c0c03eae20c1ef247221ee800c25a8b93304e",
+			"7b145830dfd14e40a00d4a02f8df7137e6517c4ecdce1268d7c0872288ebf59628b86be739175278ae71dc5bf982562c",
+			"b3430f7802876d049a6bd7b22d77055c1e8d48f05686bbae86fab3d50c931fcc5d68fd5293b9c46c85de623372e14f75",
+			"76b194eda6aaad40d876e69e5ed1154892f21fe929cb5bf41a360ee4d094854f42c25aaad331ecaab18cc3e0039b5215",
+			"9e7a25bfcee2d3bb44806d4d728b275c70a13104ef1fd800b2c9700616d01dcea4a89c566fcde6238e69ee310c254d76",
+			"79027ac5ecb4d3f67979d247d7a8a7b649ba9879ba046a861cc03b180af38f8f94df785a020102bf17ab07e54ee3238a",
+		},
+	} {
+		t.Run(tt.c.Name(), func(t *testing.T) {
+			key := func(s string) *ecdh.PrivateKey {
+				k, err := ecdh.NewPrivateKey(tt.c, decodeHex(t, s))
+				fatalIfErr(t, err)
+				return k
+			}
+			a, x, b, y := key(tt.a), key(tt.x), key(tt.b), key(tt.y)
+
+			for _, z := range []func() ([]byte, error){
+				func() ([]byte, error) {
+					return ecdh.MQVSharedSecret(a, x, b.PublicKey(), y.PublicKey())
+				},
+				func() ([]byte, error) {
+					return ecdh.MQVSharedSecret(b, y, a.PublicKey(), x.PublicKey())
+				},
+			} {
+				got, err := z()
+				fatalIfErr(t, err)
+				if want := decodeHex(t, tt.mqv); !bytes.Equal(got, want) {
+					t.Errorf("MQV Z = %x, want %x", got, want)
+				}
+			}
+
+			for _, z := range []func() ([]byte, error){
+				func() ([]byte, error) {
+					return ecdh.FHMQVSharedSecret(tt.h, a, x, b.PublicKey(), y.PublicKey(), true)
+				},
+				func() ([]byte, error) {
+					return ecdh.FHMQVSharedSecret(tt.h, b, y, a.PublicKey(), x.PublicKey(), false)
+				},
+			} {
+				got, err := z()
+				fatalIfErr(t, err)
+				if want := decodeHex(t, tt.fhmqv); !bytes.Equal(got, want) {
+					t.Errorf("FHMQV Z = %x, want %x", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMQV(t *testing.T) {
+	for _, c := range []*ecdh.Curve{ecdh.P256(), ecdh.P384()} {
+		t.Run(c.Name(), func(t *testing.T) {
+			testMQV(t, c)
+		})
+	}
+}
+
+func testMQV(t *testing.T, c *ecdh.Curve) {
+	kdf := ecdh.OneStepKDF(sha256.New, []byte("mqv"))
+	newKey := func() *ecdh.PrivateKey {
+		k, err := ecdh.GenerateKey(c, rand.Reader)
+		fatalIfErr(t, err)
+		return k
+	}
+	a, b := newKey(), newKey()
+
+	x, y := newKey(), newKey()
+	ku, err := ecdh.MQV(a, x, b.PublicKey(), y.PublicKey(), kdf, 32)
+	fatalIfErr(t, err)
+	kv, err := ecdh.MQV(b, y, a.PublicKey(), x.PublicKey(), kdf, 32)
+	fatalIfErr(t, err)
+	if !bytes.Equal(ku, kv) {
+		t.Errorf("MQV keys differ: %x != %x", ku, kv)
+	}
+	if !bytes.Equal(x.Bytes(), make([]byte, c.ScalarLength())) {
+		t.Error("ephemeral key was not destroyed")
+	}
+	if _, err := ecdh.MQV(a, x, b.PublicKey(), y.PublicKey(), kdf, 32); err == nil {
+		t.Error("destroyed ephemeral key was used")
+	}
+
+	x, y = newKey(), newKey()
+	ku, err = ecdh.FHMQV(sha512.New, a, x, b.PublicKey(), y.PublicKey(), true, kdf, 32)
+	fatalIfErr(t, err)
+	kv, err = ecdh.FHMQV(sha512.New, b, y, a.PublicKey(), x.PublicKey(), false, kdf, 32)
+	fatalIfErr(t, err)
+	if !bytes.Equal(ku, kv) {
+		t.Errorf("FHMQV keys differ: %x != %x", ku, kv)
+	}
+
+	// Both parties claiming the same role, or using the wrong keys, don't
+	// agree.
+	x, y = newKey(), newKey()
+	zu, err := ecdh.FHMQVSharedSecret(sha512.New, a, x, b.PublicKey(), y.PublicKey(), true)
+	fatalIfErr(t, err)
+	zv, err := ecdh.FHMQVSharedSecret(sha512.New, b, y, a.PublicKey(), x.PublicKey(), true)
+	fatalIfErr(t, err)
+	if bytes.Equal(zu, zv) {
+		t.Error("FHMQV agreed with two initiators")
+	}
+	zu, err = ecdh.MQVSharedSecret(a, x, b.PublicKey(), y.PublicKey())
+	fatalIfErr(t, err)
+	zv, err = ecdh.MQVSharedSecret(b, y, newKey().PublicKey(), x.PublicKey())
+	fatalIfErr(t, err)
+	if bytes.Equal(zu, zv) {
+		t.Error("MQV agreed with the wrong static key")
+	}
+
+	other := ecdh.P256()
+	if c == other {
+		other = ecdh.P384()
+	}
+	k, err := ecdh.GenerateKey(other, rand.Reader)
+	fatalIfErr(t, err)
+	if _, err := ecdh.MQVSharedSecret(a, x, k.PublicKey(), y.PublicKey()); err == nil {
+		t.Error("MQV accepted keys on different curves")
+	}
+	if _, err := ecdh.FHMQVSharedSecret(sha256.New, a, x, b.PublicKey(), k.PublicKey(), true); err == nil {
+		t.Error("FHMQV accepted keys on different curves")
+	}
+}
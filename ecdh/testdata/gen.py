@@ -0,0 +1,122 @@
+"""Generates kas.json, known-answer vectors for the one-step KDF of NIST SP
+800-56C Rev. 2, Section 4.1, Option 1, and for the C(2e, 0s) and C(1e, 1s)
+schemes of SP 800-56A Rev. 3 using it, with the ECDH and ConcatKDFHash of
+pyca/cryptography (OpenSSL), independently of this repository. Run with:
+
+    python3 gen.py > kas.json
+
+FixedInfo uses the concatenation format of SP 800-56A, Section 5.8.2.1.1,
+AlgorithmID || PartyUInfo || PartyVInfo, where each PartyInfo is the party
+identifier followed by its ephemeral public key, if any.
+"""
+
+import hashlib
+import json
+
+import cryptography
+from cryptography.hazmat.backends.openssl import backend
+from cryptography.hazmat.primitives import hashes, serialization
+from cryptography.hazmat.primitives.asymmetric import ec
+from cryptography.hazmat.primitives.kdf.concatkdf import ConcatKDFHash
+
+CURVES = [
+    # name, curve, order, length, hash
+    ('P-224', ec.SECP224R1(),
+     0xffffffffffffffffffffffffffff16a2e0b8f03e13dd29455c5c2a3d, 28, 'SHA-224'),
+    ('P-256', ec.SECP256R1(),
+     0xffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632551, 32, 'SHA-256'),
+    ('P-384', ec.SECP384R1(),
+     0xffffffffffffffffffffffffffffffffffffffffffffffffc7634d81f4372ddf581a0db248b0a77aecec196accc52973, 48, 'SHA-384'),
+    ('P-521', ec.SECP521R1(),
+     0x01fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffa51868783bf2f966b7fcc0148f709a5d03bb5c9b8899c47aebb6fb71e91386409, 66, 'SHA-512'),
+]
+
+HASHES = {
+    'SHA-224': hashes.SHA224,
+    'SHA-256': hashes.SHA256,
+    'SHA-384': hashes.SHA384,
+    'SHA-512': hashes.SHA512,
+}
+
+# The one-step KDF vectors derive keys from the Z values of the first P-256
+# and P-384 vectors of the CAVP KAS ECC CDH primitive tests.
+KDF_VECTORS = [
+    ('SHA-256', '46fc62106420ff012e54a434fbdd2d25ccc5852060561e68040dd7778997bd7b', b'ecdh test', 48),
+    ('SHA-384', '5f9d29dc5e31a163060356213669c8ce132e22f57c9a04f40ba7fcead493b457e5621e766c40a2e3d4d6a04b25e533f1', b'', 32),
+    ('SHA-224', '7d96f9a3bd3c05cf5cc37feb8b9d5209d5c2597464dec3e9983743e8', b'\x00' * 64, 100),
+    ('SHA-512', '005fc70477c3e63bc3954bd0df3ea0d1f41ee21746ed95fc5e1fdf90930d5e136672d72cc770742d1711c3c3a4c334a0ad9759436a4d3c5bf6e74b9578fac148c831', b'one-step', 130),
+]
+
+SOURCE = (
+    'Generated by gen.py with the ECDH and ConcatKDFHash of '
+    'pyca/cryptography %s (%s).'
+    % (cryptography.__version__, backend.openssl_version_text()))
+
+
+def scalar(label, n, length):
+    """Derives a deterministic private key from a label, in the format read
+    by GenerateKey, which masks the excess bits of P-521 scalars."""
+    b = hashlib.sha512(label).digest()
+    d = bytearray((b + hashlib.sha512(b).digest())[:length])
+    if length == 66:
+        d[0] &= 1
+    assert 0 < int.from_bytes(d, 'big') < n
+    return bytes(d)
+
+
+def key(curve, d):
+    return ec.derive_private_key(int.from_bytes(d, 'big'), curve)
+
+
+def point(k):
+    return k.public_key().public_bytes(
+        serialization.Encoding.X962, serialization.PublicFormat.UncompressedPoint)
+
+
+def one_step(hname, z, fixed_info, length):
+    return ConcatKDFHash(algorithm=HASHES[hname](), length=length, otherinfo=fixed_info).derive(z)
+
+
+def main():
+    kdf = []
+    for hname, z, fixed_info, length in KDF_VECTORS:
+        kdf.append({
+            'hash': hname,
+            'z': z,
+            'fixedInfo': fixed_info.hex(),
+            'dkm': one_step(hname, bytes.fromhex(z), fixed_info, length).hex(),
+        })
+
+    schemes = []
+    for name, curve, n, length, hname in CURVES:
+        for scheme in ('ephemeralUnified', 'onePassDh'):
+            label = ('%s %s' % (scheme, name)).encode()
+            du = scalar(label + b' U', n, length)
+            dv = scalar(label + b' V', n, length)
+            u, v = key(curve, du), key(curve, dv)
+            z = u.exchange(ec.ECDH(), v.public_key())
+            assert z == v.exchange(ec.ECDH(), u.public_key())
+
+            # Only ephemeral public keys are part of PartyInfo.
+            fixed_info = label + b'U' + point(u) + b'V'
+            if scheme == 'ephemeralUnified':
+                fixed_info += point(v)
+            l = 2 * length
+            schemes.append({
+                'scheme': scheme,
+                'curve': name,
+                'hash': hname,
+                'dU': du.hex(),
+                'QU': point(u).hex(),
+                'dV': dv.hex(),
+                'QV': point(v).hex(),
+                'z': z.hex(),
+                'fixedInfo': fixed_info.hex(),
+                'dkm': one_step(hname, z, fixed_info, l).hex(),
+            })
+
+    print(json.dumps({'source': SOURCE, 'oneStepKDF': kdf, 'schemes': schemes}, indent='\t'))
+
+
+if __name__ == '__main__':
+    main()
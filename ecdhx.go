@@ -0,0 +1,380 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec
+
+import (
+	"crypto/subtle"
+	"errors"
+
+	"github.com/magical/nistec-extra/internal/fiat"
+)
+
+// ladderScalar validates that k is in [1, n-1], and sets out to k + n or
+// k + 2n, whichever has bit bitLen set, where bitLen is the bit length of n.
+// k and n must have the same length, and out must be one byte longer.
+//
+// Since [n]P is the identity, the result is equivalent to k, but it always has
+// bitLen + 1 bits, so that a Montgomery ladder over it can start from R0 = P
+// and run for a fixed number of steps, without ever reaching the identity
+// except for k = 1, n-2, and n-1 (see ladderExceptions).
+func ladderScalar(out, k, n []byte, bitLen int) error {
+	if len(k) != len(n) {
+		return errors.New("invalid scalar length")
+	}
+	if len(out) != len(k)+1 {
+		panic("nistec: internal error: ladderScalar called with wrong lengths")
+	}
+
+	// Check 0 < k < n by computing k - n and looking at the borrow.
+	var borrow, acc int
+	for i := len(k) - 1; i >= 0; i-- {
+		d := int(k[i]) - int(n[i]) - borrow
+		borrow = (d >> 8) & 1
+		acc |= int(k[i])
+	}
+	if borrow == 0 || acc == 0 {
+		return errors.New("invalid scalar: out of range")
+	}
+
+	// t1 = k + n, t2 = k + 2n.
+	var t1, t2 [1 + 66]byte
+	var c1, c2 int
+	for i := len(k) - 1; i >= 0; i-- {
+		s := int(k[i]) + int(n[i]) + c1
+		t1[i+1], c1 = byte(s), s>>8
+		s = int(t1[i+1]) + int(n[i]) + c2
+		t2[i+1], c2 = byte(s), s>>8
+	}
+	t1[0] = byte(c1)
+	t2[0] = byte(c1 + c2)
+
+	// k + n >= 2^bitLen if bit bitLen of k + n is set. If not, k + 2n is
+	// between 2^bitLen and 2^(bitLen+1) because n > 2^(bitLen-1).
+	top := int(t1[len(out)-1-bitLen/8]>>(bitLen%8)) & 1
+	for i := range out {
+		out[i] = byte(subtle.ConstantTimeSelect(top, int(t1[i]), int(t2[i])))
+	}
+	return nil
+}
+
+// ladderExceptions returns whether k is equal to 1, n-1, and n-2, in constant
+// time. These are the scalars for which the Montgomery ladder over
+// ladderScalar(k) reaches the identity in one of its registers, and so for
+// which the x-only formulas can't be used.
+func ladderExceptions(k, n []byte) (isOne, isMinusOne, isMinusTwo int) {
+	var one, minusOne, minusTwo [66]byte
+	one[len(k)-1] = 1
+	var b1, b2 int
+	for i := len(k) - 1; i >= 0; i-- {
+		d := int(n[i]) - int(one[i]) - b1
+		minusOne[i], b1 = byte(d), (d>>8)&1
+		d = int(minusOne[i]) - int(one[i]) - b2
+		minusTwo[i], b2 = byte(d), (d>>8)&1
+	}
+	isOne = subtle.ConstantTimeCompare(k, one[:len(k)])
+	isMinusOne = subtle.ConstantTimeCompare(k, minusOne[:len(k)])
+	isMinusTwo = subtle.ConstantTimeCompare(k, minusTwo[:len(k)])
+	return
+}
+
+// P384ECDHX performs an ECDH key agreement between scalar, a 48-byte big-endian
+// private key in [1, N-1], and the peer public key peerX, which is either the
+// 48-byte x-coordinate or the 49-byte compressed encoding of a point. It
+// returns the 48-byte x-coordinate of the shared point, as specified in
+// SEC 1, Version 2.0, Section 3.3.1.
+//
+// The y-coordinate of the peer public key is never needed. peerX is checked to
+// be the x-coordinate of a point on the curve, rather than on its quadratic
+// twist, and the shared point is computed with a constant-time x-only co-Z
+// Montgomery ladder.
+func P384ECDHX(scalar, peerX []byte) ([]byte, error) {
+	var k [p384ElementLength + 1]byte
+	if err := ladderScalar(k[:], scalar, p384Order, 384); err != nil {
+		return nil, err
+	}
+	switch {
+	case len(peerX) == p384ElementLength:
+	case len(peerX) == 1+p384ElementLength && (peerX[0] == 2 || peerX[0] == 3):
+		peerX = peerX[1:]
+	default:
+		return nil, errors.New("invalid P384 x-coordinate encoding")
+	}
+	xD, err := new(fiat.P384Element).SetBytes(peerX)
+	if err != nil {
+		return nil, err
+	}
+	y2 := p384Polynomial(new(fiat.P384Element), xD)
+	if !p384Sqrt(new(fiat.P384Element), y2) {
+		return nil, errors.New("invalid P384 x-coordinate: point is on the twist")
+	}
+
+	// The ladder keeps R0 = [m]P and R1 = [m+1]P, where m is the prefix of k
+	// processed so far, as (X0:Z) and (X1:Z), with x(R0) = X0/Z and
+	// x(R1) = X1/Z sharing the same Z coordinate. Since the top bit of k is
+	// always set, it starts from R0 = P and R1 = [2]P.
+	x0, x1, z := new(fiat.P384Element), new(fiat.P384Element), new(fiat.P384Element)
+	p384XDouble(x1, z, xD, new(fiat.P384Element).One())
+	twoX, twoZ := new(fiat.P384Element).Set(x1), new(fiat.P384Element).Set(z)
+	x0.Mul(xD, z)
+
+	for i := 384 - 1; i >= 0; i-- {
+		bit := int(k[len(k)-1-i/8]>>(i%8)) & 1
+		p384CondSwap(x0, x1, bit)
+		p384LadderStep(x0, x1, z, xD)
+		p384CondSwap(x0, x1, bit)
+	}
+
+	// The ladder formulas don't handle R0 or R1 being the point at infinity,
+	// which happens only for k = 1, N-2, and N-1. Fix those cases up.
+	isOne, isMinusOne, isMinusTwo := ladderExceptions(scalar, p384Order)
+	one := new(fiat.P384Element).One()
+	x0.Select(xD, x0, isOne|isMinusOne)
+	z.Select(one, z, isOne|isMinusOne)
+	x0.Select(twoX, x0, isMinusTwo)
+	z.Select(twoZ, z, isMinusTwo)
+
+	if z.IsZero() == 1 {
+		return nil, errors.New("P384 point is the point at infinity")
+	}
+	zinv := new(fiat.P384Element).Invert(z)
+	x0.Mul(x0, zinv)
+	return x0.Bytes(), nil
+}
+
+// p384CondSwap swaps a and b if cond == 1, and leaves them unchanged if cond == 0.
+func p384CondSwap(a, b *fiat.P384Element, cond int) {
+	t := new(fiat.P384Element).Set(a)
+	a.Select(b, a, cond)
+	b.Select(t, b, cond)
+}
+
+// p384XDouble sets (x2:z2) to the x-only projective coordinates of [2]P, where
+// x(P) = x/z. x2 and z2 must not overlap x and z.
+func p384XDouble(x2, z2, x, z *fiat.P384Element) {
+	// x([2]P) = ((x² + 3z²)² - 8bxz³) / (4z(x³ - 3xz² + bz³))
+	zz := new(fiat.P384Element).Square(z)
+	threeZZ := new(fiat.P384Element).Add(zz, zz)
+	threeZZ.Add(threeZZ, zz)
+	bzzz := new(fiat.P384Element).Mul(p384B(), zz)
+	bzzz.Mul(bzzz, z)
+	xx := new(fiat.P384Element).Square(x)
+
+	x2.Add(xx, threeZZ)
+	x2.Square(x2)
+	t := new(fiat.P384Element).Mul(x, bzzz)
+	t.Add(t, t)
+	t.Add(t, t)
+	t.Add(t, t)
+	x2.Sub(x2, t)
+
+	z2.Sub(xx, threeZZ)
+	z2.Mul(z2, x)
+	z2.Add(z2, bzzz)
+	z2.Mul(z2, z)
+	z2.Add(z2, z2)
+	z2.Add(z2, z2)
+}
+
+// p384LadderStep sets (x0, x1, z) to the co-Z x-only coordinates of [2]R0 and
+// R0 + R1, where x(R0) = x0/z, x(R1) = x1/z, and x(R1 - R0) = xD.
+func p384LadderStep(x0, x1, z, xD *fiat.P384Element) {
+	// Differential addition, from "Weierstraß Elliptic Curves and Side-Channel
+	// Attacks" by Brier and Joye, with the points sharing the same Z:
+	//
+	//   x(R0 + R1) = (2(X0 + X1)(X0X1 - 3Z²) + 4bZ³ - xD·Z(X0 - X1)²) / (Z(X0 - X1)²)
+	//
+	zz := new(fiat.P384Element).Square(z)
+	threeZZ := new(fiat.P384Element).Add(zz, zz)
+	threeZZ.Add(threeZZ, zz)
+	bzzz := new(fiat.P384Element).Mul(p384B(), zz)
+	bzzz.Mul(bzzz, z)
+
+	d := new(fiat.P384Element).Sub(x0, x1)
+	d.Square(d)
+	addX := new(fiat.P384Element).Mul(x0, x1)
+	addX.Sub(addX, threeZZ)
+	t := new(fiat.P384Element).Add(x0, x1)
+	addX.Mul(addX, t)
+	addX.Add(addX, bzzz)
+	addX.Add(addX, bzzz)
+	addX.Add(addX, addX)
+	t.Mul(xD, z)
+	t.Mul(t, d)
+	addX.Sub(addX, t)
+
+	// Doubling, as in p384XDouble:
+	//
+	//   x([2]R0) = ((X0² + 3Z²)² - 8bX0Z³) / (4Z(X0³ - 3X0Z² + bZ³))
+	//
+	xx := new(fiat.P384Element).Square(x0)
+	dblX := new(fiat.P384Element).Add(xx, threeZZ)
+	dblX.Square(dblX)
+	t.Mul(x0, bzzz)
+	t.Add(t, t)
+	t.Add(t, t)
+	t.Add(t, t)
+	dblX.Sub(dblX, t)
+	dblZ := new(fiat.P384Element).Sub(xx, threeZZ)
+	dblZ.Mul(dblZ, x0)
+	dblZ.Add(dblZ, bzzz)
+	dblZ.Add(dblZ, dblZ)
+	dblZ.Add(dblZ, dblZ)
+
+	// Bring both results to the common Z' = Z(X0 - X1)²·4(X0³ - 3X0Z² + bZ³).
+	x0.Mul(dblX, d)
+	x1.Mul(addX, dblZ)
+	z.Mul(z, d)
+	z.Mul(z, dblZ)
+}
+
+// P521ECDHX performs an ECDH key agreement between scalar, a 66-byte big-endian
+// private key in [1, N-1], and the peer public key peerX, which is either the
+// 66-byte x-coordinate or the 67-byte compressed encoding of a point. It
+// returns the 66-byte x-coordinate of the shared point, as specified in
+// SEC 1, Version 2.0, Section 3.3.1.
+//
+// The y-coordinate of the peer public key is never needed. peerX is checked to
+// be the x-coordinate of a point on the curve, rather than on its quadratic
+// twist, and the shared point is computed with a constant-time x-only co-Z
+// Montgomery ladder.
+func P521ECDHX(scalar, peerX []byte) ([]byte, error) {
+	var k [p521ElementLength + 1]byte
+	if err := ladderScalar(k[:], scalar, p521Order, 521); err != nil {
+		return nil, err
+	}
+	switch {
+	case len(peerX) == p521ElementLength:
+	case len(peerX) == 1+p521ElementLength && (peerX[0] == 2 || peerX[0] == 3):
+		peerX = peerX[1:]
+	default:
+		return nil, errors.New("invalid P521 x-coordinate encoding")
+	}
+	xD, err := new(fiat.P521Element).SetBytes(peerX)
+	if err != nil {
+		return nil, err
+	}
+	y2 := p521Polynomial(new(fiat.P521Element), xD)
+	if !p521Sqrt(new(fiat.P521Element), y2) {
+		return nil, errors.New("invalid P521 x-coordinate: point is on the twist")
+	}
+
+	// The ladder keeps R0 = [m]P and R1 = [m+1]P, where m is the prefix of k
+	// processed so far, as (X0:Z) and (X1:Z), with x(R0) = X0/Z and
+	// x(R1) = X1/Z sharing the same Z coordinate. Since the top bit of k is
+	// always set, it starts from R0 = P and R1 = [2]P.
+	x0, x1, z := new(fiat.P521Element), new(fiat.P521Element), new(fiat.P521Element)
+	p521XDouble(x1, z, xD, new(fiat.P521Element).One())
+	twoX, twoZ := new(fiat.P521Element).Set(x1), new(fiat.P521Element).Set(z)
+	x0.Mul(xD, z)
+
+	for i := 521 - 1; i >= 0; i-- {
+		bit := int(k[len(k)-1-i/8]>>(i%8)) & 1
+		p521CondSwap(x0, x1, bit)
+		p521LadderStep(x0, x1, z, xD)
+		p521CondSwap(x0, x1, bit)
+	}
+
+	// The ladder formulas don't handle R0 or R1 being the point at infinity,
+	// which happens only for k = 1, N-2, and N-1. Fix those cases up.
+	isOne, isMinusOne, isMinusTwo := ladderExceptions(scalar, p521Order)
+	one := new(fiat.P521Element).One()
+	x0.Select(xD, x0, isOne|isMinusOne)
+	z.Select(one, z, isOne|isMinusOne)
+	x0.Select(twoX, x0, isMinusTwo)
+	z.Select(twoZ, z, isMinusTwo)
+
+	if z.IsZero() == 1 {
+		return nil, errors.New("P521 point is the point at infinity")
+	}
+	zinv := new(fiat.P521Element).Invert(z)
+	x0.Mul(x0, zinv)
+	return x0.Bytes(), nil
+}
+
+// p521CondSwap swaps a and b if cond == 1, and leaves them unchanged if cond == 0.
+func p521CondSwap(a, b *fiat.P521Element, cond int) {
+	t := new(fiat.P521Element).Set(a)
+	a.Select(b, a, cond)
+	b.Select(t, b, cond)
+}
+
+// p521XDouble sets (x2:z2) to the x-only projective coordinates of [2]P, where
+// x(P) = x/z. x2 and z2 must not overlap x and z.
+func p521XDouble(x2, z2, x, z *fiat.P521Element) {
+	// x([2]P) = ((x² + 3z²)² - 8bxz³) / (4z(x³ - 3xz² + bz³))
+	zz := new(fiat.P521Element).Square(z)
+	threeZZ := new(fiat.P521Element).Add(zz, zz)
+	threeZZ.Add(threeZZ, zz)
+	bzzz := new(fiat.P521Element).Mul(p521B(), zz)
+	bzzz.Mul(bzzz, z)
+	xx := new(fiat.P521Element).Square(x)
+
+	x2.Add(xx, threeZZ)
+	x2.Square(x2)
+	t := new(fiat.P521Element).Mul(x, bzzz)
+	t.Add(t, t)
+	t.Add(t, t)
+	t.Add(t, t)
+	x2.Sub(x2, t)
+
+	z2.Sub(xx, threeZZ)
+	z2.Mul(z2, x)
+	z2.Add(z2, bzzz)
+	z2.Mul(z2, z)
+	z2.Add(z2, z2)
+	z2.Add(z2, z2)
+}
+
+// p521LadderStep sets (x0, x1, z) to the co-Z x-only coordinates of [2]R0 and
+// R0 + R1, where x(R0) = x0/z, x(R1) = x1/z, and x(R1 - R0) = xD.
+func p521LadderStep(x0, x1, z, xD *fiat.P521Element) {
+	// Differential addition, from "Weierstraß Elliptic Curves and Side-Channel
+	// Attacks" by Brier and Joye, with the points sharing the same Z:
+	//
+	//   x(R0 + R1) = (2(X0 + X1)(X0X1 - 3Z²) + 4bZ³ - xD·Z(X0 - X1)²) / (Z(X0 - X1)²)
+	//
+	zz := new(fiat.P521Element).Square(z)
+	threeZZ := new(fiat.P521Element).Add(zz, zz)
+	threeZZ.Add(threeZZ, zz)
+	bzzz := new(fiat.P521Element).Mul(p521B(), zz)
+	bzzz.Mul(bzzz, z)
+
+	d := new(fiat.P521Element).Sub(x0, x1)
+	d.Square(d)
+	addX := new(fiat.P521Element).Mul(x0, x1)
+	addX.Sub(addX, threeZZ)
+	t := new(fiat.P521Element).Add(x0, x1)
+	addX.Mul(addX, t)
+	addX.Add(addX, bzzz)
+	addX.Add(addX, bzzz)
+	addX.Add(addX, addX)
+	t.Mul(xD, z)
+	t.Mul(t, d)
+	addX.Sub(addX, t)
+
+	// Doubling, as in p521XDouble:
+	//
+	//   x([2]R0) = ((X0² + 3Z²)² - 8bX0Z³) / (4Z(X0³ - 3X0Z² + bZ³))
+	//
+	xx := new(fiat.P521Element).Square(x0)
+	dblX := new(fiat.P521Element).Add(xx, threeZZ)
+	dblX.Square(dblX)
+	t.Mul(x0, bzzz)
+	t.Add(t, t)
+	t.Add(t, t)
+	t.Add(t, t)
+	dblX.Sub(dblX, t)
+	dblZ := new(fiat.P521Element).Sub(xx, threeZZ)
+	dblZ.Mul(dblZ, x0)
+	dblZ.Add(dblZ, bzzz)
+	dblZ.Add(dblZ, dblZ)
+	dblZ.Add(dblZ, dblZ)
+
+	// Bring both results to the common Z' = Z(X0 - X1)²·4(X0³ - 3X0Z² + bZ³).
+	x0.Mul(dblX, d)
+	x1.Mul(addX, dblZ)
+	z.Mul(z, d)
+	z.Mul(z, dblZ)
+}
@@ -0,0 +1,206 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !purego && (amd64 || arm64 || (ppc64le && go1.19) || s390x)
+
+package nistec
+
+import "errors"
+
+// P256ECDHX performs an ECDH key agreement between scalar, a 32-byte big-endian
+// private key in [1, N-1], and the peer public key peerX, which is either the
+// 32-byte x-coordinate or the 33-byte compressed encoding of a point. It
+// returns the 32-byte x-coordinate of the shared point, as specified in
+// SEC 1, Version 2.0, Section 3.3.1.
+//
+// The y-coordinate of the peer public key is never needed. peerX is checked to
+// be the x-coordinate of a point on the curve, rather than on its quadratic
+// twist, and the shared point is computed with a constant-time x-only co-Z
+// Montgomery ladder.
+func P256ECDHX(scalar, peerX []byte) ([]byte, error) {
+	// See SetBytes for rr.
+	rr := p256Element{0x0000000000000003, 0xfffffffbffffffff,
+		0xfffffffffffffffe, 0x00000004fffffffd}
+
+	var k [p256ElementLength + 1]byte
+	if err := ladderScalar(k[:], scalar, p256Order, 256); err != nil {
+		return nil, err
+	}
+	switch {
+	case len(peerX) == p256ElementLength:
+	case len(peerX) == p256CompressedLength && (peerX[0] == 2 || peerX[0] == 3):
+		peerX = peerX[1:]
+	default:
+		return nil, errors.New("invalid P256 x-coordinate encoding")
+	}
+	xD := new(p256Element)
+	p256BigToLittle(xD, (*[32]byte)(peerX))
+	if p256LessThanP(xD) == 0 {
+		return nil, errors.New("invalid P256 element encoding")
+	}
+	p256Mul(xD, xD, &rr)
+	y2 := p256Polynomial(new(p256Element), xD)
+	if !p256Sqrt(y2, y2) {
+		return nil, errors.New("invalid P256 x-coordinate: point is on the twist")
+	}
+
+	// The ladder keeps R0 = [m]P and R1 = [m+1]P, where m is the prefix of k
+	// processed so far, as (X0:Z) and (X1:Z), with x(R0) = X0/Z and
+	// x(R1) = X1/Z sharing the same Z coordinate. Since the top bit of k is
+	// always set, it starts from R0 = P and R1 = [2]P.
+	x0, x1, z := new(p256Element), new(p256Element), new(p256Element)
+	p256XDouble(x1, z, xD, &p256One)
+	twoX, twoZ := *x1, *z
+	p256Mul(x0, xD, z)
+
+	for i := 256 - 1; i >= 0; i-- {
+		bit := int(k[len(k)-1-i/8]>>(i%8)) & 1
+		p256CondSwap(x0, x1, bit)
+		p256LadderStep(x0, x1, z, xD)
+		p256CondSwap(x0, x1, bit)
+	}
+
+	// The ladder formulas don't handle R0 or R1 being the point at infinity,
+	// which happens only for k = 1, N-2, and N-1. Fix those cases up.
+	isOne, isMinusOne, isMinusTwo := ladderExceptions(scalar, p256Order)
+	p256ElementSelect(x0, xD, x0, isOne|isMinusOne)
+	p256ElementSelect(z, &p256One, z, isOne|isMinusOne)
+	p256ElementSelect(x0, &twoX, x0, isMinusTwo)
+	p256ElementSelect(z, &twoZ, z, isMinusTwo)
+
+	if p256Equal(z, &p256Zero) == 1 {
+		return nil, errors.New("P256 point is the point at infinity")
+	}
+	zinv := new(p256Element)
+	p256Inverse(zinv, z)
+	p256Mul(x0, x0, zinv)
+	p256FromMont(x0, x0)
+	var out [p256ElementLength]byte
+	p256LittleToBig(&out, x0)
+	return out[:], nil
+}
+
+// p256Sub sets res = x - y.
+func p256Sub(res, x, y *p256Element) {
+	t := *y
+	p256NegCond(&t, 1)
+	p256Add(res, x, &t)
+}
+
+// p256ElementSelect sets res to a if cond == 1, and to b if cond == 0.
+func p256ElementSelect(res, a, b *p256Element, cond int) {
+	mask := -uint64(cond)
+	for i := range res {
+		res[i] = a[i]&mask | b[i]&^mask
+	}
+}
+
+// p256CondSwap swaps a and b if cond == 1, and leaves them unchanged if cond == 0.
+func p256CondSwap(a, b *p256Element, cond int) {
+	mask := -uint64(cond)
+	for i := range a {
+		t := (a[i] ^ b[i]) & mask
+		a[i] ^= t
+		b[i] ^= t
+	}
+}
+
+// p256XDouble sets (x2:z2) to the x-only projective coordinates of [2]P, where
+// x(P) = x/z. x2 and z2 must not overlap x and z.
+func p256XDouble(x2, z2, x, z *p256Element) {
+	p256B := &p256Element{0xd89cdf6229c4bddf, 0xacf005cd78843090,
+		0xe5a220abf7212ed6, 0xdc30061d04874834}
+
+	// x([2]P) = ((x² + 3z²)² - 8bxz³) / (4z(x³ - 3xz² + bz³))
+	zz := new(p256Element)
+	p256Sqr(zz, z, 1)
+	threeZZ := new(p256Element)
+	p256Add(threeZZ, zz, zz)
+	p256Add(threeZZ, threeZZ, zz)
+	bzzz := new(p256Element)
+	p256Mul(bzzz, p256B, zz)
+	p256Mul(bzzz, bzzz, z)
+	xx := new(p256Element)
+	p256Sqr(xx, x, 1)
+
+	p256Add(x2, xx, threeZZ)
+	p256Sqr(x2, x2, 1)
+	t := new(p256Element)
+	p256Mul(t, x, bzzz)
+	p256Add(t, t, t)
+	p256Add(t, t, t)
+	p256Add(t, t, t)
+	p256Sub(x2, x2, t)
+
+	p256Sub(z2, xx, threeZZ)
+	p256Mul(z2, z2, x)
+	p256Add(z2, z2, bzzz)
+	p256Mul(z2, z2, z)
+	p256Add(z2, z2, z2)
+	p256Add(z2, z2, z2)
+}
+
+// p256LadderStep sets (x0, x1, z) to the co-Z x-only coordinates of [2]R0 and
+// R0 + R1, where x(R0) = x0/z, x(R1) = x1/z, and x(R1 - R0) = xD.
+func p256LadderStep(x0, x1, z, xD *p256Element) {
+	p256B := &p256Element{0xd89cdf6229c4bddf, 0xacf005cd78843090,
+		0xe5a220abf7212ed6, 0xdc30061d04874834}
+
+	// Differential addition, from "Weierstraß Elliptic Curves and Side-Channel
+	// Attacks" by Brier and Joye, with the points sharing the same Z:
+	//
+	//   x(R0 + R1) = (2(X0 + X1)(X0X1 - 3Z²) + 4bZ³ - xD·Z(X0 - X1)²) / (Z(X0 - X1)²)
+	//
+	zz := new(p256Element)
+	p256Sqr(zz, z, 1)
+	threeZZ := new(p256Element)
+	p256Add(threeZZ, zz, zz)
+	p256Add(threeZZ, threeZZ, zz)
+	bzzz := new(p256Element)
+	p256Mul(bzzz, p256B, zz)
+	p256Mul(bzzz, bzzz, z)
+
+	d := new(p256Element)
+	p256Sub(d, x0, x1)
+	p256Sqr(d, d, 1)
+	addX := new(p256Element)
+	p256Mul(addX, x0, x1)
+	p256Sub(addX, addX, threeZZ)
+	t := new(p256Element)
+	p256Add(t, x0, x1)
+	p256Mul(addX, addX, t)
+	p256Add(addX, addX, bzzz)
+	p256Add(addX, addX, bzzz)
+	p256Add(addX, addX, addX)
+	p256Mul(t, xD, z)
+	p256Mul(t, t, d)
+	p256Sub(addX, addX, t)
+
+	// Doubling, as in p256XDouble:
+	//
+	//   x([2]R0) = ((X0² + 3Z²)² - 8bX0Z³) / (4Z(X0³ - 3X0Z² + bZ³))
+	//
+	xx := new(p256Element)
+	p256Sqr(xx, x0, 1)
+	dblX := new(p256Element)
+	p256Add(dblX, xx, threeZZ)
+	p256Sqr(dblX, dblX, 1)
+	p256Mul(t, x0, bzzz)
+	p256Add(t, t, t)
+	p256Add(t, t, t)
+	p256Add(t, t, t)
+	p256Sub(dblX, dblX, t)
+	dblZ := new(p256Element)
+	p256Sub(dblZ, xx, threeZZ)
+	p256Mul(dblZ, dblZ, x0)
+	p256Add(dblZ, dblZ, bzzz)
+	p256Add(dblZ, dblZ, dblZ)
+	p256Add(dblZ, dblZ, dblZ)
+
+	// Bring both results to the common Z' = Z(X0 - X1)²·4(X0³ - 3X0Z² + bZ³).
+	p256Mul(x0, dblX, d)
+	p256Mul(x1, addX, dblZ)
+	p256Mul(z, z, d)
+	p256Mul(z, z, dblZ)
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build purego || (!amd64 && !arm64 && !(ppc64le && go1.19) && !s390x)
+
+package nistec
+
+import (
+	"errors"
+
+	"github.com/magical/nistec-extra/internal/fiat"
+)
+
+// P256ECDHX performs an ECDH key agreement between scalar, a 32-byte big-endian
+// private key in [1, N-1], and the peer public key peerX, which is either the
+// 32-byte x-coordinate or the 33-byte compressed encoding of a point. It
+// returns the 32-byte x-coordinate of the shared point, as specified in
+// SEC 1, Version 2.0, Section 3.3.1.
+//
+// The y-coordinate of the peer public key is never needed. peerX is checked to
+// be the x-coordinate of a point on the curve, rather than on its quadratic
+// twist, and the shared point is computed with a constant-time x-only co-Z
+// Montgomery ladder.
+func P256ECDHX(scalar, peerX []byte) ([]byte, error) {
+	var k [p256ElementLength + 1]byte
+	if err := ladderScalar(k[:], scalar, p256Order, 256); err != nil {
+		return nil, err
+	}
+	switch {
+	case len(peerX) == p256ElementLength:
+	case len(peerX) == 1+p256ElementLength && (peerX[0] == 2 || peerX[0] == 3):
+		peerX = peerX[1:]
+	default:
+		return nil, errors.New("invalid P256 x-coordinate encoding")
+	}
+	xD, err := new(fiat.P256Element).SetBytes(peerX)
+	if err != nil {
+		return nil, err
+	}
+	y2 := p256Polynomial(new(fiat.P256Element), xD)
+	if !p256Sqrt(new(fiat.P256Element), y2) {
+		return nil, errors.New("invalid P256 x-coordinate: point is on the twist")
+	}
+
+	// The ladder keeps R0 = [m]P and R1 = [m+1]P, where m is the prefix of k
+	// processed so far, as (X0:Z) and (X1:Z), with x(R0) = X0/Z and
+	// x(R1) = X1/Z sharing the same Z coordinate. Since the top bit of k is
+	// always set, it starts from R0 = P and R1 = [2]P.
+	x0, x1, z := new(fiat.P256Element), new(fiat.P256Element), new(fiat.P256Element)
+	p256XDouble(x1, z, xD, new(fiat.P256Element).One())
+	twoX, twoZ := new(fiat.P256Element).Set(x1), new(fiat.P256Element).Set(z)
+	x0.Mul(xD, z)
+
+	for i := 256 - 1; i >= 0; i-- {
+		bit := int(k[len(k)-1-i/8]>>(i%8)) & 1
+		p256CondSwap(x0, x1, bit)
+		p256LadderStep(x0, x1, z, xD)
+		p256CondSwap(x0, x1, bit)
+	}
+
+	// The ladder formulas don't handle R0 or R1 being the point at infinity,
+	// which happens only for k = 1, N-2, and N-1. Fix those cases up.
+	isOne, isMinusOne, isMinusTwo := ladderExceptions(scalar, p256Order)
+	one := new(fiat.P256Element).One()
+	x0.Select(xD, x0, isOne|isMinusOne)
+	z.Select(one, z, isOne|isMinusOne)
+	x0.Select(twoX, x0, isMinusTwo)
+	z.Select(twoZ, z, isMinusTwo)
+
+	if z.IsZero() == 1 {
+		return nil, errors.New("P256 point is the point at infinity")
+	}
+	zinv := new(fiat.P256Element).Invert(z)
+	x0.Mul(x0, zinv)
+	return x0.Bytes(), nil
+}
+
+// p256CondSwap swaps a and b if cond == 1, and leaves them unchanged if cond == 0.
+func p256CondSwap(a, b *fiat.P256Element, cond int) {
+	t := new(fiat.P256Element).Set(a)
+	a.Select(b, a, cond)
+	b.Select(t, b, cond)
+}
+
+// p256XDouble sets (x2:z2) to the x-only projective coordinates of [2]P, where
+// x(P) = x/z. x2 and z2 must not overlap x and z.
+func p256XDouble(x2, z2, x, z *fiat.P256Element) {
+	// x([2]P) = ((x² + 3z²)² - 8bxz³) / (4z(x³ - 3xz² + bz³))
+	zz := new(fiat.P256Element).Square(z)
+	threeZZ := new(fiat.P256Element).Add(zz, zz)
+	threeZZ.Add(threeZZ, zz)
+	bzzz := new(fiat.P256Element).Mul(p256B(), zz)
+	bzzz.Mul(bzzz, z)
+	xx := new(fiat.P256Element).Square(x)
+
+	x2.Add(xx, threeZZ)
+	x2.Square(x2)
+	t := new(fiat.P256Element).Mul(x, bzzz)
+	t.Add(t, t)
+	t.Add(t, t)
+	t.Add(t, t)
+	x2.Sub(x2, t)
+
+	z2.Sub(xx, threeZZ)
+	z2.Mul(z2, x)
+	z2.Add(z2, bzzz)
+	z2.Mul(z2, z)
+	z2.Add(z2, z2)
+	z2.Add(z2, z2)
+}
+
+// p256LadderStep sets (x0, x1, z) to the co-Z x-only coordinates of [2]R0 and
+// R0 + R1, where x(R0) = x0/z, x(R1) = x1/z, and x(R1 - R0) = xD.
+func p256LadderStep(x0, x1, z, xD *fiat.P256Element) {
+	// Differential addition, from "Weierstraß Elliptic Curves and Side-Channel
+	// Attacks" by Brier and Joye, with the points sharing the same Z:
+	//
+	//   x(R0 + R1) = (2(X0 + X1)(X0X1 - 3Z²) + 4bZ³ - xD·Z(X0 - X1)²) / (Z(X0 - X1)²)
+	//
+	zz := new(fiat.P256Element).Square(z)
+	threeZZ := new(fiat.P256Element).Add(zz, zz)
+	threeZZ.Add(threeZZ, zz)
+	bzzz := new(fiat.P256Element).Mul(p256B(), zz)
+	bzzz.Mul(bzzz, z)
+
+	d := new(fiat.P256Element).Sub(x0, x1)
+	d.Square(d)
+	addX := new(fiat.P256Element).Mul(x0, x1)
+	addX.Sub(addX, threeZZ)
+	t := new(fiat.P256Element).Add(x0, x1)
+	addX.Mul(addX, t)
+	addX.Add(addX, bzzz)
+	addX.Add(addX, bzzz)
+	addX.Add(addX, addX)
+	t.Mul(xD, z)
+	t.Mul(t, d)
+	addX.Sub(addX, t)
+
+	// Doubling, as in p256XDouble:
+	//
+	//   x([2]R0) = ((X0² + 3Z²)² - 8bX0Z³) / (4Z(X0³ - 3X0Z² + bZ³))
+	//
+	xx := new(fiat.P256Element).Square(x0)
+	dblX := new(fiat.P256Element).Add(xx, threeZZ)
+	dblX.Square(dblX)
+	t.Mul(x0, bzzz)
+	t.Add(t, t)
+	t.Add(t, t)
+	t.Add(t, t)
+	dblX.Sub(dblX, t)
+	dblZ := new(fiat.P256Element).Sub(xx, threeZZ)
+	dblZ.Mul(dblZ, x0)
+	dblZ.Add(dblZ, bzzz)
+	dblZ.Add(dblZ, dblZ)
+	dblZ.Add(dblZ, dblZ)
+
+	// Bring both results to the common Z' = Z(X0 - X1)²·4(X0³ - 3X0Z² + bZ³).
+	x0.Mul(dblX, d)
+	x1.Mul(addX, dblZ)
+	z.Mul(z, d)
+	z.Mul(z, dblZ)
+}
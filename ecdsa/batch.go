@@ -0,0 +1,250 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdsa
+
+import (
+	"io"
+	"math/bits"
+
+	"github.com/magical/nistec-extra/group"
+)
+
+// BatchItem is a signature to be checked by VerifyBatch.
+type BatchItem struct {
+	PublicKey *PublicKey
+	Digest    []byte
+	R, S      []byte
+
+	// RecoveryID is the recovery id of the signature, as returned by
+	// SignRecoverable, if HasRecoveryID is true. Without it, the sign of the y
+	// coordinate of R is unknown, and VerifyBatch has to search for it.
+	RecoveryID    byte
+	HasRecoveryID bool
+}
+
+// batchSecurity is the length in bytes of the random coefficients of the
+// combined check. A batch with invalid signatures passes it with probability
+// at most 2⁻¹²⁸.
+const batchSecurity = 16
+
+// unhintedBatchSize is the number of signatures without a recovery id that are
+// checked together. Each of the 2^unhintedBatchSize choices of signs is another
+// chance for a batch with invalid signatures to pass, so their combined check
+// passes with probability at most 2⁻¹²⁰.
+const unhintedBatchSize = 8
+
+// VerifyBatch checks the signatures in batch, and returns whether each of them
+// is valid, as Verify would. The batch may mix keys and curves.
+//
+// The signatures are first checked together, by lifting each r to a point R,
+// and checking that
+//
+//	Σ zᵢ × ([eᵢ × sᵢ⁻¹]G + [rᵢ × sᵢ⁻¹]Qᵢ - Rᵢ) = 0
+//
+// for random 128-bit zᵢ read from rand. That's much faster than checking each
+// signature, but only tells whether all of them are valid. If they are not, or
+// if a recovery id is wrong, VerifyBatch falls back to verifying each
+// signature individually.
+//
+// The signatures with a recovery id, which selects R, are all checked with a
+// single multi-scalar multiplication. For the others, r only determines R up
+// to its sign, so they are checked in groups of eight, by computing the sum
+// Σ zᵢ × ([eᵢ × sᵢ⁻¹]G + [rᵢ × sᵢ⁻¹]Qᵢ) and looking for the choice of signs
+// of the zᵢ × Rᵢ that adds up to it. That takes 256 point additions per group,
+// on top of the multi-scalar multiplication, so providing recovery ids is
+// faster, but it's still much faster than individual verification.
+//
+// VerifyBatch is variable-time, and must only be used with public inputs. It
+// only returns an error if reading from rand fails.
+func VerifyBatch(rand io.Reader, batch []BatchItem) (valid []bool, err error) {
+	valid = make([]bool, len(batch))
+	hinted := make(map[*Curve][]int)
+	unhinted := make(map[*Curve][]int)
+	for i, item := range batch {
+		c := item.PublicKey.c
+		if item.HasRecoveryID {
+			hinted[c] = append(hinted[c], i)
+		} else {
+			unhinted[c] = append(unhinted[c], i)
+		}
+	}
+	check := func(idx []int, verify func(rand io.Reader, batch []BatchItem, idx []int) (bool, error)) error {
+		ok, err := verify(rand, batch, idx)
+		if err != nil {
+			return err
+		}
+		for _, i := range idx {
+			if ok {
+				valid[i] = true
+			} else {
+				valid[i] = Verify(batch[i].PublicKey, batch[i].Digest, batch[i].R, batch[i].S)
+			}
+		}
+		return nil
+	}
+	for c, idx := range hinted {
+		if err := check(idx, c.verifyCombined); err != nil {
+			return nil, err
+		}
+	}
+	for c, idx := range unhinted {
+		for len(idx) > 0 {
+			n := len(idx)
+			if n > unhintedBatchSize {
+				n = unhintedBatchSize
+			}
+			if err := check(idx[:n], c.verifySigns); err != nil {
+				return nil, err
+			}
+			idx = idx[n:]
+		}
+	}
+	return valid, nil
+}
+
+// combinedTerms reads the random coefficients zᵢ for batch[i] for each i in
+// idx, which must all be signatures on c, and returns them along with the
+// scalars and points of a multi-scalar multiplication for
+//
+//	Σ zᵢ × ([eᵢ × sᵢ⁻¹]G + [rᵢ × sᵢ⁻¹]Qᵢ)
+//
+// The generator and each distinct public key appear once, with the sum of
+// their coefficients. The zᵢ are encoded as c.byteLen long scalars. ok is
+// false if any r or s is out of range.
+func (c *Curve) combinedTerms(rand io.Reader, batch []BatchItem, idx []int) (zs, scalars [][]byte, points []group.Element, ok bool, err error) {
+	z := make([]byte, len(idx)*batchSecurity)
+	if _, err := io.ReadFull(rand, z); err != nil {
+		return nil, nil, nil, false, err
+	}
+
+	rs := make([]group.Scalar, len(idx))
+	ss := make([]group.Scalar, len(idx))
+	for j, i := range idx {
+		item := &batch[i]
+		var err error
+		if rs[j], err = c.g.NewScalar().SetBytes(item.R); err != nil || rs[j].IsZero() == 1 {
+			return nil, nil, nil, false, nil
+		}
+		if ss[j], err = c.g.NewScalar().SetBytes(item.S); err != nil || ss[j].IsZero() == 1 {
+			return nil, nil, nil, false, nil
+		}
+	}
+	ws := c.batchInvert(ss)
+
+	gCoeff := c.g.NewScalar()
+	keyCoeffs := make(map[*PublicKey]group.Scalar)
+	var keys []*PublicKey
+	t, zj := c.g.NewScalar(), c.g.NewScalar()
+	for j, i := range idx {
+		item := &batch[i]
+		zb := make([]byte, c.byteLen)
+		copy(zb[c.byteLen-batchSecurity:], z[j*batchSecurity:])
+		zj.SetReducedBytes(zb)
+		zs = append(zs, zb)
+
+		t.Multiply(c.hashToScalar(item.Digest), ws[j])
+		gCoeff.Add(gCoeff, t.Multiply(t, zj))
+
+		t.Multiply(rs[j], ws[j])
+		t.Multiply(t, zj)
+		if k, ok := keyCoeffs[item.PublicKey]; ok {
+			k.Add(k, t)
+		} else {
+			keyCoeffs[item.PublicKey] = c.g.NewScalar().Set(t)
+			keys = append(keys, item.PublicKey)
+		}
+	}
+	scalars = append(scalars, gCoeff.Bytes())
+	points = append(points, c.g.NewElement().SetGenerator())
+	for _, k := range keys {
+		scalars = append(scalars, keyCoeffs[k].Bytes())
+		points = append(points, k.q)
+	}
+	return zs, scalars, points, true, nil
+}
+
+// verifyCombined reports whether the combined check passes for batch[i] for
+// each i in idx, which must all be signatures with recovery ids on c.
+func (c *Curve) verifyCombined(rand io.Reader, batch []BatchItem, idx []int) (bool, error) {
+	zs, scalars, points, ok, err := c.combinedTerms(rand, batch, idx)
+	if err != nil || !ok {
+		return false, err
+	}
+	// Each Rᵢ is negated and multiplied by the short zᵢ in the same
+	// multi-scalar multiplication.
+	for j, i := range idx {
+		R, err := c.liftX(batch[i].R, batch[i].RecoveryID)
+		if err != nil {
+			return false, nil
+		}
+		scalars = append(scalars, zs[j])
+		points = append(points, R.Negate(R))
+	}
+	return c.msm(scalars, points).IsIdentity() == 1, nil
+}
+
+// verifySigns reports whether the combined check passes for batch[i] for each
+// i in idx, which must all be signatures on c, for some choice of the signs of
+// the Rᵢ.
+func (c *Curve) verifySigns(rand io.Reader, batch []BatchItem, idx []int) (bool, error) {
+	zs, scalars, points, ok, err := c.combinedTerms(rand, batch, idx)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	// D = Σ zᵢ × Rᵢ - A, where A is the sum of the G and Q terms, and each Rᵢ
+	// starts with an even y coordinate. Flipping the sign of Rᵢ subtracts
+	// [2zᵢ]Rᵢ from D, if it was added, or adds it back.
+	D := c.msm(scalars, points)
+	D.Negate(D)
+	twoP := make([]group.Element, len(idx))
+	for j, i := range idx {
+		R, err := c.liftX(batch[i].R, 0)
+		if err != nil {
+			return false, nil
+		}
+		P := c.straus([][]byte{zs[j][c.byteLen-batchSecurity:]}, []group.Element{R})
+		D.Add(D, P)
+		twoP[j] = P.Double(P)
+	}
+
+	// Walk all the choices of signs in Gray code order, so that each step
+	// flips a single sign.
+	flipped := make([]bool, len(idx))
+	for m := 0; ; m++ {
+		if D.IsIdentity() == 1 {
+			return true, nil
+		}
+		if m == 1<<len(idx)-1 {
+			return false, nil
+		}
+		j := bits.TrailingZeros(uint(m + 1))
+		if flipped[j] {
+			D.Add(D, twoP[j])
+		} else {
+			D.Subtract(D, twoP[j])
+		}
+		flipped[j] = !flipped[j]
+	}
+}
+
+// batchInvert returns the inverses of xs, which must be non-zero, with a
+// single inversion, using Montgomery's trick.
+func (c *Curve) batchInvert(xs []group.Scalar) []group.Scalar {
+	// prefix[i] = x₀ × ... × xᵢ₋₁
+	prefix := make([]group.Scalar, len(xs))
+	acc := c.g.NewScalar().SetUint64(1)
+	for i, x := range xs {
+		prefix[i] = c.g.NewScalar().Set(acc)
+		acc.Multiply(acc, x)
+	}
+	acc.Invert(acc)
+	out := make([]group.Scalar, len(xs))
+	for i := len(xs) - 1; i >= 0; i-- {
+		out[i] = c.g.NewScalar().Multiply(acc, prefix[i])
+		acc.Multiply(acc, xs[i])
+	}
+	return out
+}
@@ -0,0 +1,128 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdsa_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/magical/nistec-extra/ecdsa"
+)
+
+func TestVerifyBatch(t *testing.T) {
+	for _, tt := range curves {
+		t.Run(tt.c.Name(), func(t *testing.T) {
+			// Small batches use Straus' method, and large ones Pippenger's.
+			testVerifyBatch(t, tt.c, 5)
+			testVerifyBatch(t, tt.c, 40)
+		})
+	}
+}
+
+func testVerifyBatch(t *testing.T, c *ecdsa.Curve, n int) {
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		var err error
+		keys[i], err = ecdsa.GenerateKey(c, rand.Reader)
+		fatalIfErr(t, err)
+	}
+	batch := make([]ecdsa.BatchItem, n)
+	for i := range batch {
+		priv := keys[i%len(keys)]
+		digest := hashMsg(sha256.New, []byte{byte(i)})
+		r, s, v, err := ecdsa.SignRecoverable(rand.Reader, priv, sha256.New, digest)
+		fatalIfErr(t, err)
+		batch[i] = ecdsa.BatchItem{
+			PublicKey: priv.PublicKey(),
+			Digest:    digest,
+			R:         r, S: s,
+			RecoveryID: v, HasRecoveryID: i%7 != 3,
+		}
+	}
+
+	check := func(name string, batch []ecdsa.BatchItem, want func(i int) bool) {
+		t.Helper()
+		valid, err := ecdsa.VerifyBatch(rand.Reader, batch)
+		fatalIfErr(t, err)
+		if len(valid) != len(batch) {
+			t.Fatalf("%s: got %d results for %d signatures", name, len(valid), len(batch))
+		}
+		for i := range valid {
+			if valid[i] != want(i) {
+				t.Errorf("%s: valid[%d] = %v", name, i, valid[i])
+			}
+		}
+	}
+	all := func(int) bool { return true }
+	check("valid", batch, all)
+
+	bad := append([]ecdsa.BatchItem(nil), batch...)
+	bad[1].Digest = hashMsg(sha256.New, []byte("wrong"))
+	bad[n-1].S = bytes.Repeat([]byte{0xff}, c.ScalarLength())
+	check("invalid", bad, func(i int) bool { return i != 1 && i != n-1 })
+
+	// A wrong recovery id doesn't make a valid signature fail.
+	hints := append([]ecdsa.BatchItem(nil), batch...)
+	hints[2].RecoveryID ^= 1
+	hints[4].RecoveryID = 2
+	check("wrong recovery ids", hints, all)
+
+	// Signatures on other curves are checked separately.
+	other := ecdsa.P256()
+	if c == other {
+		other = ecdsa.P384()
+	}
+	priv, err := ecdsa.GenerateKey(other, rand.Reader)
+	fatalIfErr(t, err)
+	r, s, v, err := ecdsa.SignRecoverable(rand.Reader, priv, sha256.New, batch[0].Digest)
+	fatalIfErr(t, err)
+	mixed := append([]ecdsa.BatchItem{{PublicKey: priv.PublicKey(), Digest: batch[0].Digest,
+		R: r, S: s, RecoveryID: v, HasRecoveryID: true}}, batch...)
+	check("mixed curves", mixed, all)
+
+	check("empty", nil, all)
+
+	if _, err := ecdsa.VerifyBatch(bytes.NewReader(nil), batch); err == nil {
+		t.Error("VerifyBatch succeeded with an empty rand")
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	priv, err := ecdsa.GenerateKey(ecdsa.P256(), rand.Reader)
+	fatalIfErr(b, err)
+	digest := hashMsg(sha256.New, []byte("benchmark"))
+	r, s := ecdsa.SignDeterministic(priv, sha256.New, digest)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !ecdsa.Verify(priv.PublicKey(), digest, r, s) {
+			b.Fatal("verification failed")
+		}
+	}
+}
+
+func BenchmarkVerifyBatch(b *testing.B) {
+	priv, err := ecdsa.GenerateKey(ecdsa.P256(), rand.Reader)
+	fatalIfErr(b, err)
+	batch := make([]ecdsa.BatchItem, 256)
+	for i := range batch {
+		digest := hashMsg(sha256.New, []byte{byte(i)})
+		r, s, v := ecdsa.SignDeterministicRecoverable(priv, sha256.New, digest)
+		batch[i] = ecdsa.BatchItem{PublicKey: priv.PublicKey(), Digest: digest,
+			R: r, S: s, RecoveryID: v, HasRecoveryID: true}
+	}
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		valid, err := ecdsa.VerifyBatch(rand.Reader, batch)
+		fatalIfErr(b, err)
+		if !valid[0] {
+			b.Fatal("verification failed")
+		}
+	}
+	b.ReportMetric(float64(time.Since(start).Nanoseconds())/float64(b.N*len(batch)), "ns/sig")
+}
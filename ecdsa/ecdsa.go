@@ -0,0 +1,322 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ecdsa implements the Elliptic Curve Digital Signature Algorithm, as
+// specified in FIPS 186-5 and SEC 1, Version 2.0, over the NIST P curves.
+//
+// Unlike crypto/ecdsa, signatures are handled as pairs of big-endian integers
+// r and s, each exactly as long as the encoding of a scalar, and the DER and
+// fixed-width encodings are exposed separately. Nonces are generated with the
+// deterministic procedure of RFC 6979 or its hedged variant, which mixes in
+// additional randomness.
+//
+// Signing is constant-time. Verification is variable-time, as it only handles
+// public values.
+package ecdsa
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"hash"
+	"io"
+	"sync"
+
+	"github.com/magical/nistec-extra"
+	"github.com/magical/nistec-extra/group"
+)
+
+// Curve is one of the NIST P curves. It's safe for concurrent use.
+type Curve struct {
+	name string
+	g    group.Group
+
+	qlen     int // bit length of the order
+	byteLen  int // length of a scalar
+	fieldLen int // length of a coordinate
+
+	// inverse, if not nil, is a faster constant-time inversion modulo the
+	// order. It may fail, in which case the group inversion is used instead.
+	inverse func(k []byte) ([]byte, error)
+
+	// tableOnce guards table, the precomputed multiples of the generator used
+	// by verification.
+	tableOnce sync.Once
+	table     *table
+}
+
+var p224, p256, p384, p521 *Curve
+var p224Once, p256Once, p384Once, p521Once sync.Once
+
+// P224 returns a Curve implementing P-224.
+func P224() *Curve {
+	p224Once.Do(func() { p224 = newCurve("P-224", group.P224(), elliptic.P224(), nil) })
+	return p224
+}
+
+// P256 returns a Curve implementing P-256.
+func P256() *Curve {
+	p256Once.Do(func() { p256 = newCurve("P-256", group.P256(), elliptic.P256(), nistec.P256OrdInverse) })
+	return p256
+}
+
+// P384 returns a Curve implementing P-384.
+func P384() *Curve {
+	p384Once.Do(func() { p384 = newCurve("P-384", group.P384(), elliptic.P384(), nil) })
+	return p384
+}
+
+// P521 returns a Curve implementing P-521.
+func P521() *Curve {
+	p521Once.Do(func() { p521 = newCurve("P-521", group.P521(), elliptic.P521(), nil) })
+	return p521
+}
+
+func newCurve(name string, g group.Group, c elliptic.Curve, inverse func([]byte) ([]byte, error)) *Curve {
+	return &Curve{
+		name:     name,
+		g:        g,
+		qlen:     c.Params().N.BitLen(),
+		byteLen:  g.ScalarLength(),
+		fieldLen: g.ElementLength() - 1,
+		inverse:  inverse,
+	}
+}
+
+// Name returns the name of the curve, such as "P-256".
+func (c *Curve) Name() string { return c.name }
+
+// Group returns the prime order group of the curve, which can be used to
+// operate on the scalars and points of keys and signatures.
+func (c *Curve) Group() group.Group { return c.g }
+
+// ScalarLength returns the length of the encoding of a private key, and of
+// each of the r and s values of a signature.
+func (c *Curve) ScalarLength() int { return c.byteLen }
+
+// PrivateKey is an ECDSA private key.
+type PrivateKey struct {
+	pub PublicKey
+	d   group.Scalar
+}
+
+// PublicKey is an ECDSA public key.
+type PublicKey struct {
+	c *Curve
+	q group.Element
+}
+
+// GenerateKey returns a new private key, using rand as the source of
+// randomness.
+func GenerateKey(c *Curve, rand io.Reader) (*PrivateKey, error) {
+	d, err := c.g.RandomScalar(rand)
+	if err != nil {
+		return nil, err
+	}
+	return newPrivateKey(c, d), nil
+}
+
+// NewPrivateKey returns the private key with scalar d, which must be the
+// big-endian encoding of an integer in [1, N-1], ScalarLength bytes long.
+func NewPrivateKey(c *Curve, d []byte) (*PrivateKey, error) {
+	s, err := c.g.NewScalar().SetBytes(d)
+	if err != nil {
+		return nil, errors.New("ecdsa: invalid private key: " + err.Error())
+	}
+	if s.IsZero() == 1 {
+		return nil, errors.New("ecdsa: invalid private key: zero scalar")
+	}
+	return newPrivateKey(c, s), nil
+}
+
+func newPrivateKey(c *Curve, d group.Scalar) *PrivateKey {
+	q := c.g.NewElement().ScalarBaseMult(d)
+	return &PrivateKey{pub: PublicKey{c: c, q: q}, d: d}
+}
+
+// NewPublicKey returns the public key encoded as q, an uncompressed or
+// compressed point. The point at infinity is rejected.
+func NewPublicKey(c *Curve, q []byte) (*PublicKey, error) {
+	p, err := c.g.NewElement().SetBytes(q)
+	if err != nil {
+		return nil, errors.New("ecdsa: invalid public key: " + err.Error())
+	}
+	if p.IsIdentity() == 1 {
+		return nil, errors.New("ecdsa: invalid public key: point at infinity")
+	}
+	return &PublicKey{c: c, q: p}, nil
+}
+
+// Curve returns the curve of the key.
+func (k *PrivateKey) Curve() *Curve { return k.pub.c }
+
+// Bytes returns the ScalarLength bytes big-endian encoding of the private
+// scalar.
+func (k *PrivateKey) Bytes() []byte { return k.d.Bytes() }
+
+// PublicKey returns the public key corresponding to k.
+func (k *PrivateKey) PublicKey() *PublicKey { return &k.pub }
+
+// Curve returns the curve of the key.
+func (k *PublicKey) Curve() *Curve { return k.c }
+
+// Bytes returns the uncompressed encoding of the public point.
+func (k *PublicKey) Bytes() []byte { return k.q.Bytes() }
+
+// Sign signs digest, the output of hashing the message with h, with a hedged
+// nonce. The nonce is derived as in RFC 6979, Section 3.6, from the private
+// key, the digest, and ScalarLength bytes read from rand, so that it remains
+// secret even if rand is broken, and that faults are harder to induce than with
+// fully deterministic signatures.
+//
+// Digests longer than the order are truncated as specified by FIPS 186-5.
+func Sign(rand io.Reader, priv *PrivateKey, h func() hash.Hash, digest []byte) (r, s []byte, err error) {
+	c := priv.pub.c
+	entropy := make([]byte, c.byteLen)
+	if _, err := io.ReadFull(rand, entropy); err != nil {
+		return nil, nil, err
+	}
+	r, s, _ = sign(priv, h, digest, entropy)
+	return r, s, nil
+}
+
+// SignDeterministic signs digest, the output of hashing the message with h,
+// with the deterministic nonce of RFC 6979. Signing the same digest with the
+// same key always returns the same signature.
+func SignDeterministic(priv *PrivateKey, h func() hash.Hash, digest []byte) (r, s []byte) {
+	r, s, _ = sign(priv, h, digest, nil)
+	return r, s
+}
+
+// sign returns the signature (r, s) and its recovery id.
+func sign(priv *PrivateKey, h func() hash.Hash, digest, entropy []byte) (r, s []byte, v byte) {
+	c := priv.pub.c
+	e := c.hashToScalar(digest)
+
+	// bits2octets(h1) is the encoding of e, and int2octets(x) of the private
+	// scalar.
+	drbg := newHMACDRBG(h, priv.d.Bytes(), e.Bytes(), entropy)
+	R := c.g.NewElement()
+	for {
+		k, err := c.g.NewScalar().SetBytes(c.bits2int(drbg.generate(c.byteLen)))
+		if err != nil || k.IsZero() == 1 {
+			continue
+		}
+
+		R.ScalarBaseMult(k)
+		x := R.Bytes()[1 : 1+c.fieldLen]
+		rs := c.g.NewScalar().SetReducedBytes(x)
+		if rs.IsZero() == 1 {
+			continue
+		}
+
+		// s = k⁻¹(e + rd)
+		ss := c.g.NewScalar().Multiply(rs, priv.d)
+		ss.Add(ss, e)
+		ss.Multiply(ss, c.invert(k))
+		if ss.IsZero() == 1 {
+			continue
+		}
+		r = rs.Bytes()
+		return r, ss.Bytes(), recoveryID(R, x, r)
+	}
+}
+
+// invert returns k⁻¹ mod N in constant time.
+func (c *Curve) invert(k group.Scalar) group.Scalar {
+	if c.inverse != nil {
+		if b, err := c.inverse(k.Bytes()); err == nil {
+			if kInv, err := c.g.NewScalar().SetBytes(b); err == nil {
+				return kInv
+			}
+		}
+	}
+	return c.g.NewScalar().Invert(k)
+}
+
+// bits2int implements bits2int from RFC 6979, Section 2.3.2, returning the
+// leftmost qlen bits of b as a ScalarLength bytes big-endian integer. This is
+// also how FIPS 186-5 truncates digests.
+func (c *Curve) bits2int(b []byte) []byte {
+	out := make([]byte, c.byteLen)
+	if len(b) < c.byteLen {
+		copy(out[c.byteLen-len(b):], b)
+		return out
+	}
+	copy(out, b)
+	// When the order is not a whole number of bytes, as for P-521, drop the
+	// excess low bits.
+	if excess := c.byteLen*8 - c.qlen; excess > 0 {
+		for i := len(out) - 1; i > 0; i-- {
+			out[i] = out[i]>>excess | out[i-1]<<(8-excess)
+		}
+		out[0] >>= excess
+	}
+	return out
+}
+
+// hashToScalar returns bits2int(digest) mod N.
+func (c *Curve) hashToScalar(digest []byte) group.Scalar {
+	return c.g.NewScalar().SetReducedBytes(c.bits2int(digest))
+}
+
+// Verify reports whether r and s are a valid signature of digest by pub. r and
+// s must be ScalarLength bytes long, and encode integers in [1, N-1].
+//
+// Both low and high s values are accepted. Use IsLowS to reject malleated
+// signatures.
+func Verify(pub *PublicKey, digest, r, s []byte) bool {
+	c := pub.c
+	rs, err := c.g.NewScalar().SetBytes(r)
+	if err != nil || rs.IsZero() == 1 {
+		return false
+	}
+	ss, err := c.g.NewScalar().SetBytes(s)
+	if err != nil || ss.IsZero() == 1 {
+		return false
+	}
+	e := c.hashToScalar(digest)
+
+	// R = [e × s⁻¹]G + [r × s⁻¹]Q
+	w := c.g.NewScalar().Invert(ss)
+	u1 := c.g.NewScalar().Multiply(e, w)
+	u2 := c.g.NewScalar().Multiply(rs, w)
+	R := c.combinedMult(u1.Bytes(), u2.Bytes(), pub.q)
+	if R.IsIdentity() == 1 {
+		return false
+	}
+	v := c.g.NewScalar().SetReducedBytes(R.Bytes()[1 : 1+c.fieldLen])
+	return v.Equal(rs) == 1
+}
+
+// NormalizeS returns s if it's at most (N-1)/2, and N - s otherwise. Both
+// (r, s) and (r, N - s) are valid signatures of the same digest, and some
+// protocols only accept the lower one, to prevent malleability.
+//
+// NormalizeS is constant-time. It returns an error if s is not the
+// ScalarLength bytes encoding of an integer in [0, N-1].
+func NormalizeS(c *Curve, s []byte) ([]byte, error) {
+	ss, err := c.g.NewScalar().SetBytes(s)
+	if err != nil {
+		return nil, errors.New("ecdsa: invalid s value: " + err.Error())
+	}
+	neg := c.g.NewScalar().Negate(ss)
+	return ss.Select(neg, ss, isHighS(c, ss)).Bytes(), nil
+}
+
+// IsLowS reports whether s is the encoding of an integer in [1, (N-1)/2], as
+// returned by NormalizeS.
+func IsLowS(c *Curve, s []byte) bool {
+	ss, err := c.g.NewScalar().SetBytes(s)
+	if err != nil || ss.IsZero() == 1 {
+		return false
+	}
+	return isHighS(c, ss) == 0
+}
+
+// isHighS returns 1 if s > (N-1)/2, and 0 otherwise.
+func isHighS(c *Curve, s group.Scalar) int {
+	// N is odd, so 2s mod N is even if 2s < N, and odd if it wrapped around.
+	t := c.g.NewScalar().Add(s, s).Bytes()
+	return int(t[len(t)-1] & 1)
+}
@@ -0,0 +1,102 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdsa
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/cryptobyte"
+	"golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// MarshalDER returns the DER encoding of the signature (r, s), as the
+// Ecdsa-Sig-Value SEQUENCE of RFC 3279, used by X.509, TLS, and crypto/ecdsa.
+// r and s are big-endian integers.
+func MarshalDER(r, s []byte) []byte {
+	var b cryptobyte.Builder
+	b.AddASN1(asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		addASN1Unsigned(b, r)
+		addASN1Unsigned(b, s)
+	})
+	return b.BytesOrPanic()
+}
+
+// addASN1Unsigned adds the minimal INTEGER encoding of the unsigned big-endian
+// integer x.
+func addASN1Unsigned(b *cryptobyte.Builder, x []byte) {
+	for len(x) > 0 && x[0] == 0 {
+		x = x[1:]
+	}
+	b.AddASN1(asn1.INTEGER, func(b *cryptobyte.Builder) {
+		if len(x) == 0 || x[0]&0x80 != 0 {
+			b.AddUint8(0)
+		}
+		b.AddBytes(x)
+	})
+}
+
+// ParseDER parses a DER encoded Ecdsa-Sig-Value, and returns r and s as
+// ScalarLength bytes big-endian integers, ready to be passed to Verify.
+//
+// BER encodings, such as non-minimal lengths or integers with extra leading
+// zeroes, are rejected, as are trailing data, negative values, and values too
+// large to be encoded in ScalarLength bytes. r and s might still be zero or
+// larger than the order, which makes Verify fail.
+func ParseDER(c *Curve, sig []byte) (r, s []byte, err error) {
+	var inner cryptobyte.String
+	var rb, sb []byte
+	input := cryptobyte.String(sig)
+	if !input.ReadASN1(&inner, asn1.SEQUENCE) || !input.Empty() ||
+		!inner.ReadASN1Integer(&rb) || !inner.ReadASN1Integer(&sb) ||
+		!inner.Empty() {
+		return nil, nil, errors.New("ecdsa: invalid DER signature")
+	}
+	if r, err = c.padScalar(rb); err != nil {
+		return nil, nil, err
+	}
+	if s, err = c.padScalar(sb); err != nil {
+		return nil, nil, err
+	}
+	return r, s, nil
+}
+
+// padScalar left-pads x with zeroes to ScalarLength bytes.
+func (c *Curve) padScalar(x []byte) ([]byte, error) {
+	for len(x) > 0 && x[0] == 0 {
+		x = x[1:]
+	}
+	if len(x) > c.byteLen {
+		return nil, errors.New("ecdsa: signature value out of range")
+	}
+	out := make([]byte, c.byteLen)
+	copy(out[c.byteLen-len(x):], x)
+	return out, nil
+}
+
+// MarshalFixed returns the fixed-width encoding of the signature (r, s), the
+// concatenation of r and s each padded to ScalarLength bytes, as used by JOSE,
+// COSE, WebAuthn, and PKCS #11, and specified in IEEE P1363.
+func MarshalFixed(c *Curve, r, s []byte) ([]byte, error) {
+	rb, err := c.padScalar(r)
+	if err != nil {
+		return nil, err
+	}
+	sb, err := c.padScalar(s)
+	if err != nil {
+		return nil, err
+	}
+	return append(rb, sb...), nil
+}
+
+// ParseFixed splits a fixed-width signature, which must be exactly twice
+// ScalarLength bytes long, into r and s.
+func ParseFixed(c *Curve, sig []byte) (r, s []byte, err error) {
+	if len(sig) != 2*c.byteLen {
+		return nil, nil, errors.New("ecdsa: invalid fixed-width signature length")
+	}
+	r = append([]byte(nil), sig[:c.byteLen]...)
+	s = append([]byte(nil), sig[c.byteLen:]...)
+	return r, s, nil
+}
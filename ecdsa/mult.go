@@ -0,0 +1,167 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdsa
+
+import (
+	"math/bits"
+
+	"github.com/magical/nistec-extra/group"
+)
+
+// table holds [1]P through [15]P, for 4-bit fixed windows.
+type table [15]group.Element
+
+func newTable(g group.Group, p group.Element) *table {
+	t := new(table)
+	t[0] = g.NewElement().Set(p)
+	for i := 1; i < len(t); i++ {
+		t[i] = g.NewElement().Add(t[i-1], p)
+	}
+	return t
+}
+
+func (c *Curve) generatorTable() *table {
+	c.tableOnce.Do(func() {
+		c.table = newTable(c.g, c.g.NewElement().SetGenerator())
+	})
+	return c.table
+}
+
+// combinedMult returns [u1]G + [u2]Q, where G is the generator, with Straus'
+// method: the two multiplications share the doublings, which are most of the
+// cost. u1 and u2 are big-endian and ScalarLength bytes long.
+//
+// combinedMult is variable-time, and must only be used with public inputs.
+func (c *Curve) combinedMult(u1, u2 []byte, q group.Element) group.Element {
+	tg, tq := c.generatorTable(), newTable(c.g, q)
+	r := c.g.NewElement()
+	started := false
+	for i := 0; i < 2*len(u1); i++ {
+		if started {
+			r.Double(r).Double(r).Double(r).Double(r)
+		}
+		w1, w2 := nibble(u1, i), nibble(u2, i)
+		if w1 != 0 {
+			r.Add(r, tg[w1-1])
+			started = true
+		}
+		if w2 != 0 {
+			r.Add(r, tq[w2-1])
+			started = true
+		}
+	}
+	return r
+}
+
+// nibble returns the i-th most significant 4-bit window of b.
+func nibble(b []byte, i int) byte {
+	if i%2 == 0 {
+		return b[i/2] >> 4
+	}
+	return b[i/2] & 0xf
+}
+
+// msm returns Σ [scalars[i]]points[i], a multi-scalar multiplication. The
+// scalars are big-endian, and all of the same length.
+//
+// msm is variable-time, and must only be used with public inputs.
+func (c *Curve) msm(scalars [][]byte, points []group.Element) group.Element {
+	if len(points) < 32 {
+		return c.straus(scalars, points)
+	}
+	// The optimal window is around log₂(n) for n points, minus a bit to
+	// account for the cost of summing the buckets.
+	w := bits.Len(uint(len(points))) - 2
+	if w > 12 {
+		w = 12
+	}
+	return c.pippenger(scalars, points, w)
+}
+
+// straus implements msm with interleaved 4-bit fixed windows, sharing the
+// doublings across all the points, like combinedMult.
+func (c *Curve) straus(scalars [][]byte, points []group.Element) group.Element {
+	tables := make([]*table, len(points))
+	for i, p := range points {
+		tables[i] = newTable(c.g, p)
+	}
+	r := c.g.NewElement()
+	if len(scalars) == 0 {
+		return r
+	}
+	started := false
+	for i := 0; i < 2*len(scalars[0]); i++ {
+		if started {
+			r.Double(r).Double(r).Double(r).Double(r)
+		}
+		for j, s := range scalars {
+			if w := nibble(s, i); w != 0 {
+				r.Add(r, tables[j][w-1])
+				started = true
+			}
+		}
+	}
+	return r
+}
+
+// pippenger implements msm with the bucket method and w-bit windows, which
+// takes roughly one addition per point per window, instead of one per 4 bits
+// as in straus, plus 2^(w+1) additions per window to sum the buckets.
+func (c *Curve) pippenger(scalars [][]byte, points []group.Element, w int) group.Element {
+	buckets := make([]group.Element, 1<<w-1)
+	for i := range buckets {
+		buckets[i] = c.g.NewElement()
+	}
+	used := make([]bool, len(buckets))
+	sum, acc := c.g.NewElement(), c.g.NewElement()
+
+	r := c.g.NewElement()
+	bitLen := 8 * len(scalars[0])
+	for off := (bitLen - 1) / w * w; off >= 0; off -= w {
+		for i := 0; i < w; i++ {
+			r.Double(r)
+		}
+		for i := range used {
+			used[i] = false
+		}
+		for i, s := range scalars {
+			d := window(s, off, w)
+			if d == 0 {
+				continue
+			}
+			if used[d-1] {
+				buckets[d-1].Add(buckets[d-1], points[i])
+			} else {
+				buckets[d-1].Set(points[i])
+				used[d-1] = true
+			}
+		}
+		// Σ d × bucket[d] is computed as the sum of the running sums of the
+		// buckets, from the highest.
+		sum.Set(c.g.NewElement())
+		acc.Set(sum)
+		for d := len(buckets) - 1; d >= 0; d-- {
+			if used[d] {
+				sum.Add(sum, buckets[d])
+			}
+			acc.Add(acc, sum)
+		}
+		r.Add(r, acc)
+	}
+	return r
+}
+
+// window returns the w bits of the big-endian b starting at bit off, counting
+// from the least significant bit.
+func window(b []byte, off, w int) int {
+	var d int
+	for i := off + w - 1; i >= off; i-- {
+		d <<= 1
+		if i < 8*len(b) {
+			d |= int(b[len(b)-1-i/8]>>(i%8)) & 1
+		}
+	}
+	return d
+}
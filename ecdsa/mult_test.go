@@ -0,0 +1,124 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdsa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/magical/nistec-extra/group"
+)
+
+func TestMSM(t *testing.T) {
+	for _, c := range []*Curve{P224(), P256(), P384(), P521()} {
+		t.Run(c.Name(), func(t *testing.T) {
+			for _, n := range []int{0, 1, 2, 31, 32, 70} {
+				scalars := make([][]byte, n)
+				points := make([]group.Element, n)
+				want := c.g.NewElement()
+				for i := range points {
+					s, err := c.g.RandomScalar(rand.Reader)
+					if err != nil {
+						t.Fatal(err)
+					}
+					k, err := c.g.RandomScalar(rand.Reader)
+					if err != nil {
+						t.Fatal(err)
+					}
+					// Include some zero and short scalars.
+					switch i % 5 {
+					case 1:
+						s = c.g.NewScalar()
+					case 2:
+						s.SetUint64(uint64(i))
+					}
+					scalars[i] = s.Bytes()
+					points[i] = c.g.NewElement().ScalarBaseMult(k)
+					want.Add(want, c.g.NewElement().ScalarMult(points[i], s))
+				}
+				if got := c.msm(scalars, points); got.Equal(want) != 1 {
+					t.Errorf("msm of %d points = %x, want %x", n, got.Bytes(), want.Bytes())
+				}
+				if n > 0 {
+					for _, w := range []int{1, 3, 8} {
+						if got := c.pippenger(scalars, points, w); got.Equal(want) != 1 {
+							t.Errorf("pippenger of %d points with w = %d = %x, want %x", n, w, got.Bytes(), want.Bytes())
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestVerifyCombined checks that valid batches pass the combined check, rather
+// than relying on the fallback to individual verification.
+func TestVerifyCombined(t *testing.T) {
+	for _, c := range []*Curve{P224(), P256(), P384(), P521()} {
+		t.Run(c.Name(), func(t *testing.T) {
+			priv, err := GenerateKey(c, rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var batch []BatchItem
+			var idx []int
+			for i := 0; i < 40; i++ {
+				digest := sha256.Sum256([]byte{byte(i)})
+				r, s, v := SignDeterministicRecoverable(priv, sha256.New, digest[:])
+				batch = append(batch, BatchItem{PublicKey: priv.PublicKey(), Digest: digest[:],
+					R: r, S: s, RecoveryID: v, HasRecoveryID: true})
+				idx = append(idx, i)
+			}
+			for _, n := range []int{1, 2, 40} {
+				ok, err := c.verifyCombined(rand.Reader, batch, idx[:n])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !ok {
+					t.Errorf("combined check of %d valid signatures failed", n)
+				}
+			}
+			batch[7].RecoveryID ^= 1
+			if ok, _ := c.verifyCombined(rand.Reader, batch, idx); ok {
+				t.Error("combined check passed with a wrong recovery id")
+			}
+		})
+	}
+}
+
+// TestVerifySigns checks that valid batches without recovery ids pass the
+// combined check for any mix of signs of R, and invalid ones don't.
+func TestVerifySigns(t *testing.T) {
+	for _, c := range []*Curve{P224(), P256(), P384(), P521()} {
+		t.Run(c.Name(), func(t *testing.T) {
+			priv, err := GenerateKey(c, rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var batch []BatchItem
+			var idx []int
+			for i := 0; i < unhintedBatchSize; i++ {
+				digest := sha256.Sum256([]byte{byte(i)})
+				r, s := SignDeterministic(priv, sha256.New, digest[:])
+				batch = append(batch, BatchItem{PublicKey: priv.PublicKey(), Digest: digest[:], R: r, S: s})
+				idx = append(idx, i)
+			}
+			for _, n := range []int{1, 2, unhintedBatchSize} {
+				ok, err := c.verifySigns(rand.Reader, batch, idx[:n])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !ok {
+					t.Errorf("combined check of %d valid signatures failed", n)
+				}
+			}
+			batch[3].Digest = batch[4].Digest
+			if ok, _ := c.verifySigns(rand.Reader, batch, idx); ok {
+				t.Error("combined check passed with an invalid signature")
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdsa
+
+import (
+	"crypto/subtle"
+	"errors"
+	"hash"
+	"io"
+	"math/big"
+
+	"github.com/magical/nistec-extra/group"
+)
+
+// A recovery id v identifies which of the up to four points R with an x
+// coordinate congruent to r modulo N was used to produce a signature, as in
+// SEC 1, Version 2.0, Section 4.1.6. The low bit of v is the parity of the y
+// coordinate of R, and the second bit is set if the x coordinate is r + N
+// rather than r. The latter happens with negligible probability for the NIST
+// curves, whose order is very close to the field size.
+
+// SignRecoverable is like Sign, but also returns the recovery id of the
+// signature, which allows RecoverPublicKey to return the signer's public key.
+func SignRecoverable(rand io.Reader, priv *PrivateKey, h func() hash.Hash, digest []byte) (r, s []byte, v byte, err error) {
+	c := priv.pub.c
+	entropy := make([]byte, c.byteLen)
+	if _, err := io.ReadFull(rand, entropy); err != nil {
+		return nil, nil, 0, err
+	}
+	r, s, v = sign(priv, h, digest, entropy)
+	return r, s, v, nil
+}
+
+// SignDeterministicRecoverable is like SignDeterministic, but also returns
+// the recovery id of the signature.
+func SignDeterministicRecoverable(priv *PrivateKey, h func() hash.Hash, digest []byte) (r, s []byte, v byte) {
+	return sign(priv, h, digest, nil)
+}
+
+// recoveryID returns the recovery id of R, whose x coordinate is x, for a
+// signature with value r.
+func recoveryID(R group.Element, x, r []byte) byte {
+	odd := R.BytesCompressed()[0] & 1
+	overflow := byte(1 ^ subtle.ConstantTimeCompare(x, r))
+	return odd | overflow<<1
+}
+
+// liftX returns the point R with x coordinate r, or r + N if the second bit of
+// the recovery id v is set, and with the y parity given by its low bit. The
+// decompression of SetBytes rejects x values that are not coordinates of a
+// point, or not reduced modulo the field prime.
+func (c *Curve) liftX(r []byte, v byte) (group.Element, error) {
+	x := new(big.Int).SetBytes(r)
+	if v&2 != 0 {
+		x.Add(x, new(big.Int).SetBytes(c.g.Order()))
+		if x.BitLen() > 8*c.fieldLen {
+			return nil, errors.New("ecdsa: invalid recovery id for r value")
+		}
+	}
+	enc := make([]byte, 1+c.fieldLen)
+	enc[0] = 2 | v&1
+	x.FillBytes(enc[1:])
+	R, err := c.g.NewElement().SetBytes(enc)
+	if err != nil {
+		return nil, errors.New("ecdsa: r value and recovery id don't match a point")
+	}
+	return R, nil
+}
+
+// RecoverPublicKey returns the public key that produced the signature (r, s)
+// of digest with recovery id v, as returned by SignRecoverable. If the
+// signature is valid for the returned key, as it is for any (r, s) and v for
+// which RecoverPublicKey succeeds, Verify with that key will return true.
+//
+// Replacing s with N - s, for example with NormalizeS, flips the low bit of the
+// recovery id.
+//
+// RecoverPublicKey is variable-time, and must only be used with public inputs.
+func RecoverPublicKey(c *Curve, digest, r, s []byte, v byte) (*PublicKey, error) {
+	if v > 3 {
+		return nil, errors.New("ecdsa: invalid recovery id")
+	}
+	rs, err := c.g.NewScalar().SetBytes(r)
+	if err != nil || rs.IsZero() == 1 {
+		return nil, errors.New("ecdsa: invalid r value")
+	}
+	ss, err := c.g.NewScalar().SetBytes(s)
+	if err != nil || ss.IsZero() == 1 {
+		return nil, errors.New("ecdsa: invalid s value")
+	}
+
+	R, err := c.liftX(r, v)
+	if err != nil {
+		return nil, err
+	}
+
+	// Q = r⁻¹(sR - eG) = [-e × r⁻¹]G + [s × r⁻¹]R
+	w := c.g.NewScalar().Invert(rs)
+	u1 := c.hashToScalar(digest)
+	u1.Negate(u1).Multiply(u1, w)
+	u2 := c.g.NewScalar().Multiply(ss, w)
+	Q := c.combinedMult(u1.Bytes(), u2.Bytes(), R)
+	if Q.IsIdentity() == 1 {
+		return nil, errors.New("ecdsa: recovered public key is the point at infinity")
+	}
+	return &PublicKey{c: c, q: Q}, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdsa_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"math/big"
+	"testing"
+
+	"github.com/magical/nistec-extra/ecdsa"
+)
+
+func TestRecoverPublicKey(t *testing.T) {
+	for _, tt := range curves {
+		t.Run(tt.c.Name(), func(t *testing.T) {
+			testRecoverPublicKey(t, tt.c)
+		})
+	}
+}
+
+func testRecoverPublicKey(t *testing.T, c *ecdsa.Curve) {
+	seen := [2]bool{}
+	for i := 0; i < 16; i++ {
+		priv, err := ecdsa.GenerateKey(c, rand.Reader)
+		fatalIfErr(t, err)
+		want := priv.PublicKey().Bytes()
+		digest := hashMsg(sha512.New, []byte{byte(i)})
+
+		var r, s []byte
+		var v byte
+		if i%2 == 0 {
+			r, s, v = ecdsa.SignDeterministicRecoverable(priv, sha512.New, digest)
+			rr, ss := ecdsa.SignDeterministic(priv, sha512.New, digest)
+			if !bytes.Equal(r, rr) || !bytes.Equal(s, ss) {
+				t.Fatal("SignDeterministicRecoverable and SignDeterministic differ")
+			}
+		} else {
+			r, s, v, err = ecdsa.SignRecoverable(rand.Reader, priv, sha512.New, digest)
+			fatalIfErr(t, err)
+		}
+		if v > 1 {
+			t.Fatalf("recovery id = %d", v)
+		}
+		seen[v] = true
+
+		pub, err := ecdsa.RecoverPublicKey(c, digest, r, s, v)
+		fatalIfErr(t, err)
+		if !bytes.Equal(pub.Bytes(), want) {
+			t.Errorf("RecoverPublicKey = %x, want %x", pub.Bytes(), want)
+		}
+
+		// The other parity recovers a different key, for which the signature
+		// is also valid.
+		other, err := ecdsa.RecoverPublicKey(c, digest, r, s, v^1)
+		fatalIfErr(t, err)
+		if bytes.Equal(other.Bytes(), want) {
+			t.Error("both recovery ids returned the same key")
+		}
+		if !ecdsa.Verify(other, digest, r, s) {
+			t.Error("signature is invalid for the other recovered key")
+		}
+
+		// Negating s flips the parity.
+		low, err := ecdsa.NormalizeS(c, s)
+		fatalIfErr(t, err)
+		if !bytes.Equal(low, s) {
+			pub, err := ecdsa.RecoverPublicKey(c, digest, r, low, v^1)
+			fatalIfErr(t, err)
+			if !bytes.Equal(pub.Bytes(), want) {
+				t.Error("RecoverPublicKey with normalized s failed")
+			}
+		}
+
+		// x = r + N is never a valid coordinate for these values of r.
+		if _, err := ecdsa.RecoverPublicKey(c, digest, r, s, v|2); err == nil {
+			t.Error("RecoverPublicKey accepted x = r + N")
+		}
+	}
+	if !seen[0] || !seen[1] {
+		t.Errorf("recovery ids seen: %v", seen)
+	}
+
+	zero := make([]byte, c.ScalarLength())
+	one := append(zero[1:len(zero):len(zero)], 1)
+	digest := hashMsg(sha256.New, []byte("hello"))
+	for _, tt := range []struct {
+		name string
+		r, s []byte
+		v    byte
+	}{
+		{"r = 0", zero, one, 0},
+		{"s = 0", one, zero, 0},
+		{"r = N", c.Group().Order(), one, 0},
+		{"v = 4", one, one, 4},
+		{"short r", one[1:], one, 0},
+	} {
+		if _, err := ecdsa.RecoverPublicKey(c, digest, tt.r, tt.s, tt.v); err == nil {
+			t.Errorf("%s: RecoverPublicKey succeeded", tt.name)
+		}
+	}
+}
+
+// TestRecoverOverflow exercises recovery ids 2 and 3, which can't be produced
+// by signing in practice, with points whose x coordinate is larger than N.
+func TestRecoverOverflow(t *testing.T) {
+	for _, tt := range curves {
+		t.Run(tt.c.Name(), func(t *testing.T) {
+			c := tt.c
+			n := new(big.Int).SetBytes(c.Group().Order())
+			fieldLen := c.Group().ElementLength() - 1
+			enc := make([]byte, 1+fieldLen)
+			enc[0] = 3
+			for i := int64(1); ; i++ {
+				x := new(big.Int).Add(n, big.NewInt(i))
+				x.FillBytes(enc[1:])
+				if _, err := c.Group().NewElement().SetBytes(enc); err != nil {
+					continue
+				}
+				r := big.NewInt(i).FillBytes(make([]byte, c.ScalarLength()))
+				s := append(make([]byte, c.ScalarLength()-1), 42)
+				digest := hashMsg(sha256.New, []byte("overflow"))
+				pub, err := ecdsa.RecoverPublicKey(c, digest, r, s, 3)
+				fatalIfErr(t, err)
+				if !ecdsa.Verify(pub, digest, r, s) {
+					t.Error("signature is invalid for the recovered key")
+				}
+				return
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdsa
+
+import (
+	"crypto/hmac"
+	"hash"
+)
+
+// hmacDRBG is the HMAC_DRBG instance used by RFC 6979, Section 3.2, to
+// generate nonces.
+type hmacDRBG struct {
+	h    func() hash.Hash
+	k, v []byte
+
+	// generated is set after the first call to generate.
+	generated bool
+}
+
+// newHMACDRBG instantiates the generator with the private key x, the reduced
+// digest h1, and the optional additional data of RFC 6979, Section 3.6.
+func newHMACDRBG(h func() hash.Hash, x, h1, extra []byte) *hmacDRBG {
+	size := h().Size()
+	d := &hmacDRBG{h: h, k: make([]byte, size), v: make([]byte, size)}
+	for i := range d.v {
+		d.v[i] = 0x01
+	}
+	d.update(0x00, x, h1, extra)
+	d.update(0x01, x, h1, extra)
+	return d
+}
+
+// update sets K = HMAC_K(V || sep || data) and V = HMAC_K(V).
+func (d *hmacDRBG) update(sep byte, data ...[]byte) {
+	mac := hmac.New(d.h, d.k)
+	mac.Write(d.v)
+	mac.Write([]byte{sep})
+	for _, b := range data {
+		mac.Write(b)
+	}
+	d.k = mac.Sum(d.k[:0])
+	mac = hmac.New(d.h, d.k)
+	mac.Write(d.v)
+	d.v = mac.Sum(d.v[:0])
+}
+
+// generate returns the next n bytes of output, as in step h.2. Calls after the
+// first reject the previous candidate with the update of step h.3.
+func (d *hmacDRBG) generate(n int) []byte {
+	if d.generated {
+		d.update(0x00)
+	}
+	d.generated = true
+	mac := hmac.New(d.h, d.k)
+	out := make([]byte, 0, n+len(d.v))
+	for len(out) < n {
+		mac.Reset()
+		mac.Write(d.v)
+		d.v = mac.Sum(d.v[:0])
+		out = append(out, d.v...)
+	}
+	return out[:n]
+}
@@ -0,0 +1,116 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdsa_test
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magical/nistec-extra/ecdsa"
+)
+
+// TestWycheproof checks Verify, ParseDER, and ParseFixed against the Project
+// Wycheproof ECDSA vectors for the NIST curves, copied in testdata/wycheproof
+// from the testvectors_v1 directory of github.com/C2SP/wycheproof at commit
+// fca0d3ba9f12 (Apache License 2.0). The SHA-3 and SHAKE files are left out.
+func TestWycheproof(t *testing.T) {
+	curves := map[string]*ecdsa.Curve{
+		"secp224r1": ecdsa.P224(),
+		"secp256r1": ecdsa.P256(),
+		"secp384r1": ecdsa.P384(),
+		"secp521r1": ecdsa.P521(),
+	}
+	hashes := map[string]func() hash.Hash{
+		"SHA-224":     sha256.New224,
+		"SHA-256":     sha256.New,
+		"SHA-384":     sha512.New384,
+		"SHA-512":     sha512.New,
+		"SHA-512/224": sha512.New512_224,
+		"SHA-512/256": sha512.New512_256,
+	}
+	files, err := filepath.Glob("testdata/wycheproof/ecdsa_secp*_test.json")
+	fatalIfErr(t, err)
+	if len(files) == 0 {
+		t.Fatal("no ECDSA vectors in testdata/wycheproof")
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), "_test.json")
+		curve := curves[strings.Split(name, "_")[1]]
+		if curve == nil {
+			t.Fatalf("unknown curve in %s", name)
+		}
+		t.Run(name, func(t *testing.T) {
+			testWycheproof(t, file, curve, hashes, strings.HasSuffix(name, "_p1363"))
+		})
+	}
+}
+
+func testWycheproof(t *testing.T, file string, c *ecdsa.Curve, hashes map[string]func() hash.Hash, fixed bool) {
+	var root struct {
+		TestGroups []struct {
+			PublicKey struct {
+				Uncompressed string `json:"uncompressed"`
+			} `json:"publicKey"`
+			SHA   string `json:"sha"`
+			Tests []struct {
+				TcID    int      `json:"tcId"`
+				Comment string   `json:"comment"`
+				Flags   []string `json:"flags"`
+				Msg     string   `json:"msg"`
+				Sig     string   `json:"sig"`
+				Result  string   `json:"result"`
+			} `json:"tests"`
+		} `json:"testGroups"`
+	}
+	data, err := os.ReadFile(file)
+	fatalIfErr(t, err)
+	fatalIfErr(t, json.Unmarshal(data, &root))
+
+	for _, tg := range root.TestGroups {
+		h := hashes[tg.SHA]
+		if h == nil {
+			t.Logf("skipping unsupported hash %s", tg.SHA)
+			continue
+		}
+		pub, err := ecdsa.NewPublicKey(c, decodeHex(t, tg.PublicKey.Uncompressed))
+		fatalIfErr(t, err)
+		for _, tc := range tg.Tests {
+			msg, err := hex.DecodeString(tc.Msg)
+			fatalIfErr(t, err)
+			sig, err := hex.DecodeString(tc.Sig)
+			fatalIfErr(t, err)
+
+			var r, s []byte
+			if fixed {
+				r, s, err = ecdsa.ParseFixed(c, sig)
+			} else {
+				r, s, err = ecdsa.ParseDER(c, sig)
+			}
+			got := err == nil && ecdsa.Verify(pub, hashMsg(h, msg), r, s)
+
+			switch tc.Result {
+			case "valid":
+				if !got {
+					t.Errorf("tcId %d (%s, %v): valid signature rejected", tc.TcID, tc.Comment, tc.Flags)
+				}
+			case "invalid":
+				if got {
+					t.Errorf("tcId %d (%s, %v): invalid signature accepted", tc.TcID, tc.Comment, tc.Flags)
+				}
+			case "acceptable":
+				// Either outcome is allowed.
+			default:
+				t.Fatalf("tcId %d: unknown result %q", tc.TcID, tc.Result)
+			}
+		}
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ecies implements the Elliptic Curve Integrated Encryption Scheme of
+// SEC 1, Version 2.0, Section 5.1, over the NIST P curves.
+//
+// To encrypt a message M to the public key Q, the sender generates an
+// ephemeral key pair (k, R), computes the shared secret Z, the x coordinate of
+// [k]Q, and derives an encryption key, and a MAC key or a nonce, from Z and
+// SharedInfo1 with the ANSI X9.63 KDF. The ciphertext is
+//
+//	R || C || D
+//
+// where R is encoded compressed or uncompressed, C is the encryption of M, and
+// D is either HMAC(MK, C || SharedInfo2) with AES-CTR, or the tag of AES-GCM
+// with SharedInfo2 as additional data. AES-GCM is not part of SEC 1, and is
+// used like in other ECIES profiles, with the 12 bytes nonce derived from Z
+// following the key.
+package ecies
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"hash"
+	"io"
+
+	"github.com/magical/nistec-extra/ecdh"
+)
+
+// Cipher is the symmetric encryption scheme used by ECIES.
+type Cipher int
+
+const (
+	// AESCTR is AES in CTR mode with a zero initial counter block, which is
+	// safe since the key is only used once, followed by HMAC.
+	AESCTR Cipher = iota
+	// AESGCM is AES-GCM, which includes its own authentication.
+	AESGCM
+)
+
+// gcmNonceSize is the size of the nonce derived for AES-GCM.
+const gcmNonceSize = 12
+
+// Params are the parameters of ECIES. Both parties must use the same Params.
+type Params struct {
+	// Hash is the hash function of the X9.63 KDF and of HMAC, which must be
+	// crypto.SHA256 or crypto.SHA384. If zero, SHA-256 is used.
+	Hash crypto.Hash
+	// Cipher is the encryption scheme.
+	Cipher Cipher
+	// KeyLength is the length in bytes of the AES key, 16, 24 or 32. If zero,
+	// 16 is used.
+	KeyLength int
+	// Compressed selects the compressed encoding for the ephemeral public
+	// key. Decrypt accepts both encodings regardless.
+	Compressed bool
+
+	// CofactorMode and OldCofactorMode select the cofactor Diffie-Hellman
+	// primitive, and the backwards compatibility mode of SEC 1, Section 5.1.
+	// They have no effect, since the cofactor of the NIST curves is one, and
+	// exist for parity with the options of other implementations.
+	CofactorMode, OldCofactorMode bool
+
+	// SharedInfo1 is passed to the KDF, and SharedInfo2 is authenticated
+	// together with the ciphertext. Both are optional.
+	SharedInfo1, SharedInfo2 []byte
+}
+
+var errDecrypt = errors.New("ecies: decryption failed")
+
+// check returns the hash function and key length of p, with the defaults
+// applied.
+func (p *Params) check() (func() hash.Hash, int, error) {
+	var h func() hash.Hash
+	switch p.Hash {
+	case 0, crypto.SHA256:
+		h = sha256.New
+	case crypto.SHA384:
+		h = sha512.New384
+	default:
+		return nil, 0, errors.New("ecies: unsupported hash function")
+	}
+	keyLen := p.KeyLength
+	if keyLen == 0 {
+		keyLen = 16
+	}
+	if keyLen != 16 && keyLen != 24 && keyLen != 32 {
+		return nil, 0, errors.New("ecies: invalid key length")
+	}
+	if p.Cipher != AESCTR && p.Cipher != AESGCM {
+		return nil, 0, errors.New("ecies: unknown cipher")
+	}
+	return h, keyLen, nil
+}
+
+// Overhead returns the difference between the lengths of a ciphertext and of
+// its plaintext, for a recipient key on c.
+func (p *Params) Overhead(c *ecdh.Curve) int {
+	n := 1 + c.SharedSecretLength()
+	if !p.Compressed {
+		n += c.SharedSecretLength()
+	}
+	if p.Cipher == AESGCM {
+		return n + 16
+	}
+	h, _, err := p.check()
+	if err != nil {
+		return n
+	}
+	return n + h().Size()
+}
+
+// Encrypt encrypts msg to the public key pub, using an ephemeral key generated
+// from rand.
+func Encrypt(rand io.Reader, pub *ecdh.PublicKey, p *Params, msg []byte) ([]byte, error) {
+	h, keyLen, err := p.check()
+	if err != nil {
+		return nil, err
+	}
+	ephemeral, err := ecdh.GenerateKey(pub.Curve(), rand)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	if p.Compressed {
+		out = ephemeral.PublicKey().BytesCompressed()
+	} else {
+		out = ephemeral.PublicKey().Bytes()
+	}
+	k, err := ecdh.EphemeralUnified(ephemeral, pub, ecdh.X963KDF(h, p.SharedInfo1), keyLen+p.secondKeyLength(h))
+	if err != nil {
+		return nil, err
+	}
+	defer zeroise(k)
+	ek, mk := k[:keyLen], k[keyLen:]
+	block, err := aes.NewCipher(ek)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Cipher == AESGCM {
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		return aead.Seal(out, mk, msg, p.SharedInfo2), nil
+	}
+	out, c := sliceForAppend(out, len(msg))
+	cipher.NewCTR(block, make([]byte, aes.BlockSize)).XORKeyStream(c, msg)
+	return p.mac(h, mk, out, c), nil
+}
+
+// secondKeyLength returns the length of the MAC key, or of the nonce.
+func (p *Params) secondKeyLength(h func() hash.Hash) int {
+	if p.Cipher == AESGCM {
+		return gcmNonceSize
+	}
+	return h().Size()
+}
+
+// mac appends HMAC(mk, c || SharedInfo2) to out.
+func (p *Params) mac(h func() hash.Hash, mk, out, c []byte) []byte {
+	m := hmac.New(h, mk)
+	m.Write(c)
+	m.Write(p.SharedInfo2)
+	return m.Sum(out)
+}
+
+// Decrypt decrypts the ciphertext ct with the private key priv.
+//
+// All failures past parsing the ephemeral public key return the same error,
+// and the MAC or tag is checked in constant time before any plaintext is
+// produced.
+func Decrypt(priv *ecdh.PrivateKey, p *Params, ct []byte) ([]byte, error) {
+	h, keyLen, err := p.check()
+	if err != nil {
+		return nil, err
+	}
+	c := priv.Curve()
+	var rLen int
+	switch {
+	case len(ct) > 0 && ct[0] == 4:
+		rLen = 1 + 2*c.SharedSecretLength()
+	case len(ct) > 0 && (ct[0] == 2 || ct[0] == 3):
+		rLen = 1 + c.SharedSecretLength()
+	default:
+		return nil, errDecrypt
+	}
+	tagLen := 16
+	if p.Cipher == AESCTR {
+		tagLen = h().Size()
+	}
+	if len(ct) < rLen+tagLen {
+		return nil, errDecrypt
+	}
+	R, err := ecdh.NewPublicKey(c, ct[:rLen])
+	if err != nil {
+		return nil, errDecrypt
+	}
+	k, err := ecdh.DeriveKey(priv, R, ecdh.X963KDF(h, p.SharedInfo1), keyLen+p.secondKeyLength(h))
+	if err != nil {
+		return nil, errDecrypt
+	}
+	defer zeroise(k)
+	ek, mk := k[:keyLen], k[keyLen:]
+	block, err := aes.NewCipher(ek)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Cipher == AESGCM {
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := aead.Open(nil, mk, ct[rLen:], p.SharedInfo2)
+		if err != nil {
+			return nil, errDecrypt
+		}
+		return msg, nil
+	}
+	body, tag := ct[rLen:len(ct)-tagLen], ct[len(ct)-tagLen:]
+	if !hmac.Equal(p.mac(h, mk, nil, body), tag) {
+		return nil, errDecrypt
+	}
+	msg := make([]byte, len(body))
+	cipher.NewCTR(block, make([]byte, aes.BlockSize)).XORKeyStream(msg, body)
+	return msg, nil
+}
+
+func zeroise(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// sliceForAppend takes a slice and a requested number of bytes. It returns a
+// slice with the contents of the given slice followed by that many bytes and a
+// second slice that aliases into it and contains only the extra bytes.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
@@ -0,0 +1,167 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecies_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/magical/nistec-extra/ecdh"
+	"github.com/magical/nistec-extra/ecies"
+)
+
+func fatalIfErr(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	fatalIfErr(t, err)
+	return b
+}
+
+var curves = map[string]*ecdh.Curve{
+	"P-224": ecdh.P224(),
+	"P-256": ecdh.P256(),
+	"P-384": ecdh.P384(),
+	"P-521": ecdh.P521(),
+}
+
+// TestVectors checks the vectors in testdata/vectors.json, which were
+// generated by testdata/gen.py with the ECDH, X9.63 KDF, AES and HMAC of
+// pyca/cryptography. The source field of the file records the library
+// version and the framing.
+func TestVectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/vectors.json")
+	fatalIfErr(t, err)
+	var vectors struct {
+		Source  string
+		Vectors []struct {
+			Curve, Hash, Cipher string
+			KeyLength           int
+			Compressed          bool
+			D, K                string
+			SharedInfo1         string
+			SharedInfo2         string
+			Msg, Ct             string
+		}
+	}
+	fatalIfErr(t, json.Unmarshal(data, &vectors))
+	for i, v := range vectors.Vectors {
+		c := curves[v.Curve]
+		p := &ecies.Params{
+			Hash:        map[string]crypto.Hash{"sha256": crypto.SHA256, "sha384": crypto.SHA384}[v.Hash],
+			Cipher:      map[string]ecies.Cipher{"ctr": ecies.AESCTR, "gcm": ecies.AESGCM}[v.Cipher],
+			KeyLength:   v.KeyLength,
+			Compressed:  v.Compressed,
+			SharedInfo1: decodeHex(t, v.SharedInfo1),
+			SharedInfo2: decodeHex(t, v.SharedInfo2),
+		}
+		priv, err := ecdh.NewPrivateKey(c, decodeHex(t, v.D))
+		fatalIfErr(t, err)
+		msg, ct := decodeHex(t, v.Msg), decodeHex(t, v.Ct)
+
+		got, err := ecies.Encrypt(bytes.NewReader(decodeHex(t, v.K)), priv.PublicKey(), p, msg)
+		fatalIfErr(t, err)
+		if !bytes.Equal(got, ct) {
+			t.Errorf("#%d: Encrypt = %x, want %x", i, got, ct)
+		}
+		if len(ct)-len(msg) != p.Overhead(c) {
+			t.Errorf("#%d: Overhead = %d, want %d", i, p.Overhead(c), len(ct)-len(msg))
+		}
+		got, err = ecies.Decrypt(priv, p, ct)
+		if err != nil {
+			t.Errorf("#%d: Decrypt: %v", i, err)
+		} else if !bytes.Equal(got, msg) {
+			t.Errorf("#%d: Decrypt = %x, want %x", i, got, msg)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	for _, p := range []*ecies.Params{
+		{},
+		{Cipher: ecies.AESGCM, Compressed: true},
+		{Hash: crypto.SHA384, KeyLength: 32, SharedInfo1: []byte("a"), SharedInfo2: []byte("b")},
+		{Hash: crypto.SHA384, Cipher: ecies.AESGCM, SharedInfo2: []byte("b"), CofactorMode: true},
+		{CofactorMode: true, OldCofactorMode: true},
+	} {
+		testEncryptDecrypt(t, p)
+	}
+}
+
+func testEncryptDecrypt(t *testing.T, p *ecies.Params) {
+	priv, err := ecdh.GenerateKey(ecdh.P256(), rand.Reader)
+	fatalIfErr(t, err)
+	msg := []byte("hello world")
+	ct, err := ecies.Encrypt(rand.Reader, priv.PublicKey(), p, msg)
+	fatalIfErr(t, err)
+	got, err := ecies.Decrypt(priv, p, ct)
+	fatalIfErr(t, err)
+	if !bytes.Equal(got, msg) {
+		t.Errorf("Decrypt = %q, want %q", got, msg)
+	}
+
+	// The cofactor flags don't change the result.
+	q := *p
+	q.CofactorMode, q.OldCofactorMode = !p.CofactorMode, !p.OldCofactorMode
+	if _, err := ecies.Decrypt(priv, &q, ct); err != nil {
+		t.Errorf("Decrypt with other cofactor flags: %v", err)
+	}
+
+	// Any modification is detected.
+	for i := range ct {
+		bad := append([]byte(nil), ct...)
+		bad[i] ^= 0x40
+		if _, err := ecies.Decrypt(priv, p, bad); err == nil {
+			t.Errorf("Decrypt accepted a ciphertext with byte %d modified", i)
+		}
+	}
+	for _, bad := range [][]byte{nil, ct[:1], ct[:len(ct)-1], append(ct, 0)} {
+		if _, err := ecies.Decrypt(priv, p, bad); err == nil {
+			t.Errorf("Decrypt accepted a ciphertext of length %d", len(bad))
+		}
+	}
+	other, err := ecdh.GenerateKey(ecdh.P256(), rand.Reader)
+	fatalIfErr(t, err)
+	if _, err := ecies.Decrypt(other, p, ct); err == nil {
+		t.Error("Decrypt succeeded with the wrong key")
+	}
+	q = *p
+	q.SharedInfo2 = []byte("c")
+	if _, err := ecies.Decrypt(priv, &q, ct); err == nil {
+		t.Error("Decrypt succeeded with the wrong SharedInfo2")
+	}
+	q = *p
+	q.SharedInfo1 = []byte("c")
+	if _, err := ecies.Decrypt(priv, &q, ct); err == nil {
+		t.Error("Decrypt succeeded with the wrong SharedInfo1")
+	}
+}
+
+func TestParams(t *testing.T) {
+	priv, err := ecdh.GenerateKey(ecdh.P256(), rand.Reader)
+	fatalIfErr(t, err)
+	for _, p := range []*ecies.Params{
+		{KeyLength: 20},
+		{Hash: crypto.SHA512},
+		{Hash: crypto.SHA512_256},
+		{Hash: crypto.SHA3_256},
+		{Cipher: 2},
+	} {
+		if _, err := ecies.Encrypt(rand.Reader, priv.PublicKey(), p, nil); err == nil {
+			t.Errorf("Encrypt accepted invalid params %+v", p)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+"""Generates vectors.json with pyca/cryptography, whose ECDH, ANSI X9.63 KDF
+(X963KDF), AES and HMAC are backed by OpenSSL. Run with:
+
+    python3 gen.py > vectors.json
+
+No SEC 1 ECIES implementation could be run to produce these vectors, so
+they check the primitives, while the framing is assembled here as
+described in the source field of vectors.json.
+"""
+
+import hashlib
+import json
+
+import cryptography
+from cryptography.hazmat.backends.openssl import backend
+from cryptography.hazmat.primitives import hashes, hmac, serialization
+from cryptography.hazmat.primitives.asymmetric import ec
+from cryptography.hazmat.primitives.ciphers import Cipher, algorithms, modes
+from cryptography.hazmat.primitives.ciphers.aead import AESGCM
+from cryptography.hazmat.primitives.kdf.x963kdf import X963KDF
+
+CURVES = {
+    'P-224': (ec.SECP224R1(), 28),
+    'P-256': (ec.SECP256R1(), 32),
+    'P-384': (ec.SECP384R1(), 48),
+    'P-521': (ec.SECP521R1(), 66),
+}
+
+HASHES = {'sha256': hashes.SHA256, 'sha384': hashes.SHA384}
+
+CASES = [
+    ('P-256', 'sha256', 'ctr', 16, False, b'', b'', b'hello world'),
+    ('P-256', 'sha256', 'ctr', 16, True, b'', b'', b''),
+    ('P-256', 'sha256', 'gcm', 16, True, b'info1', b'info2', b'hello world'),
+    ('P-256', 'sha256', 'gcm', 32, False, b'', b'', b'The quick brown fox jumps over the lazy dog'),
+    ('P-224', 'sha256', 'ctr', 24, False, b'info1', b'info2', b'hello world'),
+    ('P-224', 'sha256', 'gcm', 24, True, b'', b'', b'hello world'),
+    ('P-384', 'sha384', 'ctr', 32, True, b'info1', b'info2', b'The quick brown fox jumps over the lazy dog'),
+    ('P-384', 'sha384', 'gcm', 32, False, b'info1', b'', b'hello world'),
+    ('P-521', 'sha384', 'ctr', 32, False, b'', b'info2', b'hello world'),
+    ('P-521', 'sha256', 'gcm', 16, True, b'info1', b'info2', b'The quick brown fox jumps over the lazy dog'),
+]
+
+SOURCE = (
+    'Generated by gen.py with pyca/cryptography %s (%s). ECDH, the ANSI X9.63 '
+    'KDF, AES and HMAC are computed by the library. The ciphertext is '
+    'R || C || D as in SEC 1, Section 5.1.3, with the KDF output split into '
+    'the AES key followed by the HMAC key, or by the 12 bytes AES-GCM nonce. '
+    'AES-CTR uses a zero initial counter block, D is HMAC(MK, C || '
+    'SharedInfo2), and AES-GCM authenticates SharedInfo2 as additional data.'
+    % (cryptography.__version__, backend.openssl_version_text()))
+
+
+def scalar(label, length):
+    """Derives a deterministic private key from a label."""
+    b = hashlib.sha512(label).digest()
+    d = bytearray((b + hashlib.sha512(b).digest())[:length])
+    if length == 66:
+        d[0] &= 1
+    return bytes(d)
+
+
+def encrypt(curve, length, hname, cipher, key_length, compressed, d, k, info1, info2, msg):
+    recipient = ec.derive_private_key(int.from_bytes(d, 'big'), curve)
+    eph = ec.derive_private_key(int.from_bytes(k, 'big'), curve)
+    fmt = serialization.PublicFormat.CompressedPoint if compressed else \
+        serialization.PublicFormat.UncompressedPoint
+    R = eph.public_key().public_bytes(serialization.Encoding.X962, fmt)
+    z = eph.exchange(ec.ECDH(), recipient.public_key())
+    assert len(z) == length
+
+    h = HASHES[hname]
+    second = 12 if cipher == 'gcm' else h.digest_size
+    km = X963KDF(algorithm=h(), length=key_length + second, sharedinfo=info1).derive(z)
+    ek, mk = km[:key_length], km[key_length:]
+
+    if cipher == 'gcm':
+        return R + AESGCM(ek).encrypt(mk, msg, info2)
+    enc = Cipher(algorithms.AES(ek), modes.CTR(bytes(16))).encryptor()
+    c = enc.update(msg) + enc.finalize()
+    mac = hmac.HMAC(mk, h())
+    mac.update(c + info2)
+    return R + c + mac.finalize()
+
+
+def main():
+    vectors = []
+    for i, (name, hname, cipher, key_length, compressed, info1, info2, msg) in enumerate(CASES):
+        curve, length = CURVES[name]
+        d = scalar(b'recipient %d' % i, length)
+        k = scalar(b'ephemeral %d' % i, length)
+        ct = encrypt(curve, length, hname, cipher, key_length, compressed, d, k, info1, info2, msg)
+        vectors.append({
+            'curve': name,
+            'hash': hname,
+            'cipher': cipher,
+            'keyLength': key_length,
+            'compressed': compressed,
+            'd': d.hex(),
+            'k': k.hex(),
+            'sharedInfo1': info1.hex(),
+            'sharedInfo2': info2.hex(),
+            'msg': msg.hex(),
+            'ct': ct.hex(),
+        })
+    print(json.dumps({'source': SOURCE, 'vectors': vectors}, indent='\t'))
+
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,136 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec_test
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/magical/nistec-extra"
+)
+
+func TestCurve(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		curve     elliptic.Curve
+		reference elliptic.Curve
+	}{
+		{"P224", nistec.P224Curve(), elliptic.P224()},
+		{"P256", nistec.P256Curve(), elliptic.P256()},
+		{"P384", nistec.P384Curve(), elliptic.P384()},
+		{"P521", nistec.P521Curve(), elliptic.P521()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			testCurve(t, tt.curve, tt.reference)
+		})
+	}
+}
+
+type combinedMult interface {
+	CombinedMult(Px, Py *big.Int, s1, s2 []byte) (x, y *big.Int)
+}
+
+func testCurve(t *testing.T, c, ref elliptic.Curve) {
+	if c.Params().Name != ref.Params().Name || c.Params().N.Cmp(ref.Params().N) != 0 {
+		t.Fatalf("Params() = %+v, want %+v", c.Params(), ref.Params())
+	}
+	byteLen := (c.Params().BitSize + 7) / 8
+
+	checkEqual := func(op string, x1, y1, x2, y2 *big.Int) {
+		t.Helper()
+		if x1.Cmp(x2) != 0 || y1.Cmp(y2) != 0 {
+			t.Errorf("%s = (%x, %x), want (%x, %x)", op, x1, y1, x2, y2)
+		}
+	}
+
+	k1 := make([]byte, byteLen)
+	rand.Read(k1)
+	k2 := make([]byte, byteLen+8)
+	rand.Read(k2)
+	x1, y1 := c.ScalarBaseMult(k1)
+	x2, y2 := ref.ScalarBaseMult(k1)
+	checkEqual("ScalarBaseMult", x1, y1, x2, y2)
+	x1, y1 = c.ScalarBaseMult(k2)
+	x2, y2 = ref.ScalarBaseMult(k2)
+	checkEqual("ScalarBaseMult (long scalar)", x1, y1, x2, y2)
+	x1, y1 = c.ScalarBaseMult(k1[:3])
+	x2, y2 = ref.ScalarBaseMult(k1[:3])
+	checkEqual("ScalarBaseMult (short scalar)", x1, y1, x2, y2)
+
+	Px, Py := ref.ScalarBaseMult(k2)
+	x1, y1 = c.ScalarMult(Px, Py, k1)
+	x2, y2 = ref.ScalarMult(Px, Py, k1)
+	checkEqual("ScalarMult", x1, y1, x2, y2)
+
+	Gx, Gy := c.Params().Gx, c.Params().Gy
+	x1, y1 = c.Add(Px, Py, Gx, Gy)
+	x2, y2 = ref.Add(Px, Py, Gx, Gy)
+	checkEqual("Add", x1, y1, x2, y2)
+	x1, y1 = c.Double(Px, Py)
+	x2, y2 = ref.Double(Px, Py)
+	checkEqual("Double", x1, y1, x2, y2)
+
+	x1, y1 = c.(combinedMult).CombinedMult(Px, Py, k1, k2)
+	ax, ay := ref.ScalarBaseMult(k1)
+	bx, by := ref.ScalarMult(Px, Py, k2)
+	x2, y2 = ref.Add(ax, ay, bx, by)
+	checkEqual("CombinedMult", x1, y1, x2, y2)
+
+	// The point at infinity is (0, 0).
+	zero := new(big.Int)
+	if c.IsOnCurve(zero, zero) {
+		t.Error("IsOnCurve(0, 0) = true")
+	}
+	x1, y1 = c.Add(Px, Py, zero, zero)
+	checkEqual("P + ∞", x1, y1, Px, Py)
+	negPy := new(big.Int).Sub(c.Params().P, Py)
+	x1, y1 = c.Add(Px, Py, Px, negPy)
+	checkEqual("P + -P", x1, y1, zero, zero)
+	x1, y1 = c.ScalarMult(Px, Py, c.Params().N.Bytes())
+	checkEqual("[N]P", x1, y1, zero, zero)
+	x1, y1 = c.ScalarBaseMult(nil)
+	checkEqual("[0]G", x1, y1, zero, zero)
+	x1, y1 = c.Double(zero, zero)
+	checkEqual("2∞", x1, y1, zero, zero)
+
+	if !c.IsOnCurve(Px, Py) {
+		t.Error("IsOnCurve(P) = false")
+	}
+	offX := new(big.Int).Add(Px, big.NewInt(1))
+	for _, bad := range [][2]*big.Int{
+		{offX, Py},
+		{new(big.Int).Neg(Px), Py},
+		{new(big.Int).Add(Px, c.Params().P), Py},
+	} {
+		if c.IsOnCurve(bad[0], bad[1]) {
+			t.Errorf("IsOnCurve(%x, %x) = true", bad[0], bad[1])
+		}
+		checkPanics(t, "Add", func() { c.Add(bad[0], bad[1], Px, Py) })
+		checkPanics(t, "Double", func() { c.Double(bad[0], bad[1]) })
+		checkPanics(t, "ScalarMult", func() { c.ScalarMult(bad[0], bad[1], k1) })
+		checkPanics(t, "CombinedMult", func() { c.(combinedMult).CombinedMult(bad[0], bad[1], k1, k2) })
+	}
+
+	// Legacy helpers that go through the elliptic.Curve interface.
+	enc := elliptic.Marshal(c, Px, Py)
+	x1, y1 = elliptic.Unmarshal(c, enc)
+	if x1 == nil {
+		t.Fatal("elliptic.Unmarshal failed")
+	}
+	checkEqual("Unmarshal", x1, y1, Px, Py)
+}
+
+func checkPanics(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		t.Helper()
+		if recover() == nil {
+			t.Errorf("%s did not panic on an invalid point", name)
+		}
+	}()
+	f()
+}
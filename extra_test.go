@@ -7,6 +7,12 @@ package nistec_test
 import (
 	"bytes"
 	"crypto/elliptic"
+	"crypto/rand"
+	"encoding"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"io"
 	"math/big"
 	"testing"
 
@@ -62,3 +68,606 @@ func testNegate[P nistPointExtra[P]](t *testing.T, newPoint func() P, c elliptic
 		t.Error("-P (aliasing) != -P")
 	}
 }
+
+type nistPointHardened[T any] interface {
+	nistPoint[T]
+	ScalarMultHardened(T, []byte, io.Reader) (T, error)
+}
+
+func TestScalarMultHardened(t *testing.T) {
+	t.Run("P224", func(t *testing.T) {
+		testScalarMultHardened(t, nistec.NewP224Point, elliptic.P224())
+	})
+	t.Run("P256", func(t *testing.T) {
+		testScalarMultHardened(t, nistec.NewP256Point, elliptic.P256())
+	})
+	t.Run("P384", func(t *testing.T) {
+		testScalarMultHardened(t, nistec.NewP384Point, elliptic.P384())
+	})
+	t.Run("P521", func(t *testing.T) {
+		testScalarMultHardened(t, nistec.NewP521Point, elliptic.P521())
+	})
+}
+
+func testScalarMultHardened[P nistPointHardened[P]](t *testing.T, newPoint func() P, c elliptic.Curve) {
+	p := newPoint().SetGenerator()
+	p.Add(p, p) // make a test point with z != 1
+
+	byteLen := (c.Params().BitSize + 7) / 8
+	N := c.Params().N
+	scalars := [][]byte{
+		make([]byte, byteLen),
+		big.NewInt(1).FillBytes(make([]byte, byteLen)),
+		new(big.Int).Sub(N, big.NewInt(1)).FillBytes(make([]byte, byteLen)),
+		N.FillBytes(make([]byte, byteLen)),
+		new(big.Int).Add(N, big.NewInt(1)).FillBytes(make([]byte, byteLen)),
+	}
+	for i := 0; i < 10; i++ {
+		s := make([]byte, byteLen)
+		rand.Read(s)
+		scalars = append(scalars, s)
+	}
+
+	for _, s := range scalars {
+		want, err := newPoint().ScalarMult(p, s)
+		fatalIfErr(t, err)
+		got, err := newPoint().ScalarMultHardened(p, s, rand.Reader)
+		fatalIfErr(t, err)
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Errorf("ScalarMultHardened(%x) != ScalarMult(%x)", s, s)
+		}
+	}
+
+	// Aliasing.
+	s := scalars[len(scalars)-1]
+	want, err := newPoint().ScalarMult(p, s)
+	fatalIfErr(t, err)
+	_, err = p.ScalarMultHardened(p, s, rand.Reader)
+	fatalIfErr(t, err)
+	if !bytes.Equal(p.Bytes(), want.Bytes()) {
+		t.Error("ScalarMultHardened (aliasing) != ScalarMult")
+	}
+
+	if _, err := newPoint().ScalarMultHardened(p, s[1:], rand.Reader); err == nil {
+		t.Error("ScalarMultHardened accepted a short scalar")
+	}
+	if _, err := newPoint().ScalarMultHardened(p, s, bytes.NewReader(nil)); err == nil {
+		t.Error("ScalarMultHardened succeeded with a failing reader")
+	}
+	// A reader that only returns zeroes never produces a valid λ.
+	if _, err := newPoint().ScalarMultHardened(p, s, zeroReader{}); err == nil {
+		t.Error("ScalarMultHardened succeeded with a zero reader")
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
+
+type nistPointX[T any] interface {
+	nistPoint[T]
+	BytesX() ([]byte, error)
+	BytesCompressed() []byte
+}
+
+func TestECDHX(t *testing.T) {
+	t.Run("P256", func(t *testing.T) {
+		testECDHX(t, nistec.NewP256Point, elliptic.P256(), nistec.P256ECDHX)
+	})
+	t.Run("P384", func(t *testing.T) {
+		testECDHX(t, nistec.NewP384Point, elliptic.P384(), nistec.P384ECDHX)
+	})
+	t.Run("P521", func(t *testing.T) {
+		testECDHX(t, nistec.NewP521Point, elliptic.P521(), nistec.P521ECDHX)
+	})
+}
+
+func testECDHX[P nistPointX[P]](t *testing.T, newPoint func() P, c elliptic.Curve, ecdhx func(scalar, peerX []byte) ([]byte, error)) {
+	byteLen := (c.Params().BitSize + 7) / 8
+	N := c.Params().N
+	randomScalar := func() []byte {
+		k, err := rand.Int(rand.Reader, N)
+		fatalIfErr(t, err)
+		return k.FillBytes(make([]byte, byteLen))
+	}
+
+	peer, err := newPoint().ScalarBaseMult(randomScalar())
+	fatalIfErr(t, err)
+	peerX, err := peer.BytesX()
+	fatalIfErr(t, err)
+
+	var scalars [][]byte
+	for _, k := range []int64{1, 2, 3, 4, -1, -2, -3, -4} {
+		s := big.NewInt(k)
+		if k < 0 {
+			s.Add(s, N)
+		}
+		scalars = append(scalars, s.FillBytes(make([]byte, byteLen)))
+	}
+	for i := 0; i < 10; i++ {
+		scalars = append(scalars, randomScalar())
+	}
+
+	for _, s := range scalars {
+		p, err := newPoint().ScalarMult(peer, s)
+		fatalIfErr(t, err)
+		want, err := p.BytesX()
+		fatalIfErr(t, err)
+		got, err := ecdhx(s, peerX)
+		fatalIfErr(t, err)
+		if !bytes.Equal(got, want) {
+			t.Errorf("ECDHX(%x) = %x, want %x", s, got, want)
+		}
+		got, err = ecdhx(s, peer.BytesCompressed())
+		fatalIfErr(t, err)
+		if !bytes.Equal(got, want) {
+			t.Errorf("ECDHX(%x) with compressed key = %x, want %x", s, got, want)
+		}
+	}
+
+	// Find an x-coordinate on the quadratic twist.
+	params := c.Params()
+	x := new(big.Int).SetBytes(peerX)
+	for {
+		x.Add(x, big.NewInt(1))
+		y2 := new(big.Int).Exp(x, big.NewInt(3), params.P)
+		y2.Sub(y2, new(big.Int).Mul(x, big.NewInt(3)))
+		y2.Add(y2, params.B)
+		y2.Mod(y2, params.P)
+		if new(big.Int).ModSqrt(y2, params.P) == nil {
+			break
+		}
+	}
+	s := scalars[len(scalars)-1]
+	if _, err := ecdhx(s, x.FillBytes(make([]byte, byteLen))); err == nil {
+		t.Error("ECDHX accepted a point on the twist")
+	}
+
+	if _, err := ecdhx(make([]byte, byteLen), peerX); err == nil {
+		t.Error("ECDHX accepted a zero scalar")
+	}
+	if _, err := ecdhx(N.FillBytes(make([]byte, byteLen)), peerX); err == nil {
+		t.Error("ECDHX accepted a scalar equal to N")
+	}
+	if _, err := ecdhx(s[1:], peerX); err == nil {
+		t.Error("ECDHX accepted a short scalar")
+	}
+	if _, err := ecdhx(s, params.P.FillBytes(make([]byte, byteLen))); err == nil {
+		t.Error("ECDHX accepted an x-coordinate equal to P")
+	}
+	if _, err := ecdhx(s, peer.Bytes()); err == nil {
+		t.Error("ECDHX accepted an uncompressed point")
+	}
+}
+
+type nistPointCoordinates[T any] interface {
+	nistPoint[T]
+	Coordinates() (x, y []byte, err error)
+	SetCoordinates(x, y []byte) (T, error)
+	CoordinatesBig() (x, y *big.Int, err error)
+	SetCoordinatesBig(x, y *big.Int) (T, error)
+}
+
+func TestCoordinates(t *testing.T) {
+	t.Run("P224", func(t *testing.T) {
+		testCoordinates(t, nistec.NewP224Point, elliptic.P224())
+	})
+	t.Run("P256", func(t *testing.T) {
+		testCoordinates(t, nistec.NewP256Point, elliptic.P256())
+	})
+	t.Run("P384", func(t *testing.T) {
+		testCoordinates(t, nistec.NewP384Point, elliptic.P384())
+	})
+	t.Run("P521", func(t *testing.T) {
+		testCoordinates(t, nistec.NewP521Point, elliptic.P521())
+	})
+}
+
+func testCoordinates[P nistPointCoordinates[P]](t *testing.T, newPoint func() P, c elliptic.Curve) {
+	byteLen := (c.Params().BitSize + 7) / 8
+	p := newPoint().SetGenerator()
+	p.Add(p, p) // make a test point with z != 1
+
+	x, y, err := p.Coordinates()
+	fatalIfErr(t, err)
+	if enc := p.Bytes(); !bytes.Equal(x, enc[1:1+byteLen]) || !bytes.Equal(y, enc[1+byteLen:]) {
+		t.Errorf("Coordinates() = %x, %x, want %x", x, y, enc[1:])
+	}
+	// Appending to x must not overwrite y.
+	yCopy := append([]byte(nil), y...)
+	_ = append(x, make([]byte, byteLen)...)
+	if !bytes.Equal(y, yCopy) {
+		t.Error("appending to x modified y")
+	}
+	q, err := newPoint().SetCoordinates(x, y)
+	fatalIfErr(t, err)
+	if !bytes.Equal(q.Bytes(), p.Bytes()) {
+		t.Error("SetCoordinates round trip failed")
+	}
+
+	bx, by, err := p.CoordinatesBig()
+	fatalIfErr(t, err)
+	wantX, wantY := c.Double(c.Params().Gx, c.Params().Gy)
+	if bx.Cmp(wantX) != 0 || by.Cmp(wantY) != 0 {
+		t.Errorf("CoordinatesBig() = %x, %x, want %x, %x", bx, by, wantX, wantY)
+	}
+	q, err = newPoint().SetCoordinatesBig(bx, by)
+	fatalIfErr(t, err)
+	if !bytes.Equal(q.Bytes(), p.Bytes()) {
+		t.Error("SetCoordinatesBig round trip failed")
+	}
+
+	if _, _, err := newPoint().Coordinates(); err == nil {
+		t.Error("Coordinates accepted the point at infinity")
+	}
+	if _, _, err := newPoint().CoordinatesBig(); err == nil {
+		t.Error("CoordinatesBig accepted the point at infinity")
+	}
+
+	prime := c.Params().P
+	yPlusP := new(big.Int).Add(by, prime)
+	for _, tt := range []struct {
+		name   string
+		x, y   []byte
+		bx, by *big.Int
+	}{
+		{"off curve", x, x, bx, bx},
+		{"x = P", prime.FillBytes(make([]byte, byteLen)), y, prime, by},
+		{"short", x[1:], y, nil, nil},
+		{"zero", make([]byte, byteLen), make([]byte, byteLen), new(big.Int), new(big.Int)},
+		{"negative", nil, nil, new(big.Int).Neg(bx), by},
+		{"y + P", nil, nil, bx, yPlusP},
+	} {
+		if tt.x != nil {
+			if _, err := newPoint().SetCoordinates(tt.x, tt.y); err == nil {
+				t.Errorf("SetCoordinates accepted %s coordinates", tt.name)
+			}
+		}
+		if tt.bx != nil {
+			if _, err := newPoint().SetCoordinatesBig(tt.bx, tt.by); err == nil {
+				t.Errorf("SetCoordinatesBig accepted %s coordinates", tt.name)
+			}
+		}
+	}
+}
+
+type nistPointHybrid[T any] interface {
+	nistPoint[T]
+	BytesCompressed() []byte
+	BytesHybrid() []byte
+	SetBytesWithMode([]byte, nistec.ParseMode) (T, error)
+}
+
+func TestHybridEncoding(t *testing.T) {
+	t.Run("P224", func(t *testing.T) {
+		testHybridEncoding(t, nistec.NewP224Point)
+	})
+	t.Run("P256", func(t *testing.T) {
+		testHybridEncoding(t, nistec.NewP256Point)
+	})
+	t.Run("P384", func(t *testing.T) {
+		testHybridEncoding(t, nistec.NewP384Point)
+	})
+	t.Run("P521", func(t *testing.T) {
+		testHybridEncoding(t, nistec.NewP521Point)
+	})
+
+	// The P-256 generator has an odd y-coordinate.
+	g := "046b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c296" +
+		"4fe342e2fe1a7f9b8ee7eb4a7c0f9e162bce33576b315ececbb6406837bf51f5"
+	if got := hex.EncodeToString(nistec.NewP256Point().SetGenerator().BytesHybrid()); got != "07"+g[2:] {
+		t.Errorf("P-256 generator hybrid encoding = %s", got)
+	}
+	for _, tt := range []struct {
+		enc string
+		ok  bool
+	}{
+		{"07" + g[2:], true},
+		{"06" + g[2:], false}, // malformed parity
+		{"07" + g[2:len(g)-2], false},
+		{"05" + g[2:], false},
+	} {
+		b, _ := hex.DecodeString(tt.enc)
+		_, err := nistec.NewP256Point().SetBytesWithMode(b, nistec.AllowHybrid)
+		if (err == nil) != tt.ok {
+			t.Errorf("SetBytesWithMode(%s) error = %v, want ok = %v", tt.enc, err, tt.ok)
+		}
+	}
+}
+
+func testHybridEncoding[P nistPointHybrid[P]](t *testing.T, newPoint func() P) {
+	g := newPoint().SetGenerator()
+	p := newPoint().SetGenerator()
+	parities := map[byte]bool{}
+	for i := 0; i < 16; i++ {
+		p.Add(p, g)
+
+		h := p.BytesHybrid()
+		u := p.Bytes()
+		if h[0] != 6|u[len(u)-1]&1 || !bytes.Equal(h[1:], u[1:]) {
+			t.Fatalf("BytesHybrid() = %x, Bytes() = %x", h, u)
+		}
+		parities[h[0]] = true
+
+		q, err := newPoint().SetBytesWithMode(h, nistec.AllowHybrid|nistec.RejectInfinity)
+		fatalIfErr(t, err)
+		if !bytes.Equal(q.Bytes(), u) {
+			t.Errorf("SetBytesWithMode(%x) round trip failed", h)
+		}
+		if _, err := newPoint().SetBytes(h); err == nil {
+			t.Errorf("SetBytes accepted hybrid encoding %x", h)
+		}
+		if _, err := newPoint().SetBytesWithMode(h, 0); err == nil {
+			t.Errorf("SetBytesWithMode accepted hybrid encoding %x without AllowHybrid", h)
+		}
+		h[0] ^= 1
+		if _, err := newPoint().SetBytesWithMode(h, nistec.AllowHybrid); err == nil {
+			t.Errorf("SetBytesWithMode accepted hybrid encoding %x with the wrong parity", h)
+		}
+
+		for _, enc := range [][]byte{u, p.BytesCompressed()} {
+			if _, err := newPoint().SetBytesWithMode(enc, nistec.RejectInfinity); err != nil {
+				t.Errorf("SetBytesWithMode(%x, RejectInfinity) = %v", enc, err)
+			}
+		}
+	}
+	if len(parities) != 2 {
+		t.Errorf("only saw hybrid prefixes %v", parities)
+	}
+
+	inf := newPoint()
+	if h := inf.BytesHybrid(); !bytes.Equal(h, []byte{0}) {
+		t.Errorf("BytesHybrid(∞) = %x", h)
+	}
+	if _, err := newPoint().SetBytesWithMode([]byte{0}, nistec.AllowHybrid); err != nil {
+		t.Errorf("SetBytesWithMode(∞, AllowHybrid) = %v", err)
+	}
+	if _, err := newPoint().SetBytesWithMode([]byte{0}, nistec.RejectInfinity); err == nil {
+		t.Error("SetBytesWithMode accepted the point at infinity with RejectInfinity")
+	}
+}
+
+type nistPointXOnly[T any] interface {
+	nistPoint[T]
+	SetBytesX([]byte) (T, error)
+	BytesXOnly() ([]byte, error)
+	BytesX() ([]byte, error)
+	Negate(T) T
+	SetEvenY(T) (T, int)
+}
+
+func TestXOnly(t *testing.T) {
+	t.Run("P224", func(t *testing.T) {
+		testXOnly(t, nistec.NewP224Point, elliptic.P224(), nistec.P224EvenYScalar)
+	})
+	t.Run("P256", func(t *testing.T) {
+		testXOnly(t, nistec.NewP256Point, elliptic.P256(), nistec.P256EvenYScalar)
+	})
+	t.Run("P384", func(t *testing.T) {
+		testXOnly(t, nistec.NewP384Point, elliptic.P384(), nistec.P384EvenYScalar)
+	})
+	t.Run("P521", func(t *testing.T) {
+		testXOnly(t, nistec.NewP521Point, elliptic.P521(), nistec.P521EvenYScalar)
+	})
+}
+
+func testXOnly[P nistPointXOnly[P]](t *testing.T, newPoint func() P, c elliptic.Curve, evenYScalar func([]byte) ([]byte, error)) {
+	byteLen := (c.Params().BitSize + 7) / 8
+	N := c.Params().N
+	sawOdd, sawEven := false, false
+	for i := 0; i < 16; i++ {
+		k, err := rand.Int(rand.Reader, N)
+		fatalIfErr(t, err)
+		if k.Sign() == 0 {
+			continue
+		}
+		scalar := k.FillBytes(make([]byte, byteLen))
+		p, err := newPoint().ScalarBaseMult(scalar)
+		fatalIfErr(t, err)
+		enc := p.Bytes()
+		odd := enc[len(enc)-1]&1 == 1
+
+		x, _ := p.BytesX()
+		lifted, err := newPoint().SetBytesX(x)
+		fatalIfErr(t, err)
+		want := p
+		if odd {
+			sawOdd = true
+			want = newPoint().Negate(p)
+			if _, err := p.BytesXOnly(); err == nil {
+				t.Error("BytesXOnly accepted a point with odd y")
+			}
+		} else {
+			sawEven = true
+			xOnly, err := p.BytesXOnly()
+			fatalIfErr(t, err)
+			if !bytes.Equal(xOnly, x) {
+				t.Errorf("BytesXOnly() = %x, want %x", xOnly, x)
+			}
+		}
+		if !bytes.Equal(lifted.Bytes(), want.Bytes()) {
+			t.Errorf("SetBytesX(%x) = %x, want %x", x, lifted.Bytes(), want.Bytes())
+		}
+
+		norm, negated := newPoint().SetEvenY(p)
+		if negated != boolToInt(odd) || !bytes.Equal(norm.Bytes(), want.Bytes()) {
+			t.Errorf("SetEvenY(%x) = %x, %d", enc, norm.Bytes(), negated)
+		}
+
+		evenScalar, err := evenYScalar(scalar)
+		fatalIfErr(t, err)
+		wantScalar := k
+		if odd {
+			wantScalar = new(big.Int).Sub(N, k)
+		}
+		if new(big.Int).SetBytes(evenScalar).Cmp(wantScalar) != 0 {
+			t.Errorf("EvenYScalar(%x) = %x, want %x", scalar, evenScalar, wantScalar)
+		}
+		q, err := newPoint().ScalarBaseMult(evenScalar)
+		fatalIfErr(t, err)
+		xOnly, err := q.BytesXOnly()
+		fatalIfErr(t, err)
+		if !bytes.Equal(xOnly, x) {
+			t.Errorf("BytesXOnly([EvenYScalar]G) = %x, want %x", xOnly, x)
+		}
+	}
+	if !sawOdd || !sawEven {
+		t.Error("didn't test both parities")
+	}
+
+	// Find an x-coordinate that is not on the curve (x³ - 3x + b is not a
+	// quadratic residue).
+	params := c.Params()
+	x := big.NewInt(1)
+	for {
+		y2 := new(big.Int).Exp(x, big.NewInt(3), params.P)
+		y2.Sub(y2, new(big.Int).Mul(x, big.NewInt(3)))
+		y2.Add(y2, params.B)
+		y2.Mod(y2, params.P)
+		if new(big.Int).ModSqrt(y2, params.P) == nil {
+			break
+		}
+		x.Add(x, big.NewInt(1))
+	}
+	if _, err := newPoint().SetBytesX(x.FillBytes(make([]byte, byteLen))); err == nil {
+		t.Errorf("SetBytesX accepted x = %d, which is not on the curve", x)
+	}
+	if _, err := newPoint().SetBytesX(params.P.FillBytes(make([]byte, byteLen))); err == nil {
+		t.Error("SetBytesX accepted x = P")
+	}
+	if _, err := newPoint().SetBytesX(make([]byte, byteLen-1)); err == nil {
+		t.Error("SetBytesX accepted a short encoding")
+	}
+	if _, err := newPoint().BytesXOnly(); err == nil {
+		t.Error("BytesXOnly accepted the point at infinity")
+	}
+	if _, err := evenYScalar(make([]byte, byteLen)); err == nil {
+		t.Error("EvenYScalar accepted zero")
+	}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type nistPointMarshal[T any] interface {
+	nistPoint[T]
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+	encoding.TextMarshaler
+	encoding.TextUnmarshaler
+	AppendBinary([]byte) ([]byte, error)
+	AppendText([]byte) ([]byte, error)
+	BytesCompressed() []byte
+}
+
+func TestMarshal(t *testing.T) {
+	t.Run("P224", func(t *testing.T) {
+		testMarshal(t, nistec.NewP224Point)
+	})
+	t.Run("P256", func(t *testing.T) {
+		testMarshal(t, nistec.NewP256Point)
+	})
+	t.Run("P384", func(t *testing.T) {
+		testMarshal(t, nistec.NewP384Point)
+	})
+	t.Run("P521", func(t *testing.T) {
+		testMarshal(t, nistec.NewP521Point)
+	})
+}
+
+func testMarshal[P nistPointMarshal[P]](t *testing.T, newPoint func() P) {
+	p := newPoint().SetGenerator()
+	p.Double(p)
+
+	b, err := p.MarshalBinary()
+	fatalIfErr(t, err)
+	if !bytes.Equal(b, p.Bytes()) {
+		t.Errorf("MarshalBinary() = %x, want %x", b, p.Bytes())
+	}
+	b, err = p.AppendBinary([]byte("prefix"))
+	fatalIfErr(t, err)
+	if !bytes.Equal(b, append([]byte("prefix"), p.Bytes()...)) {
+		t.Errorf("AppendBinary() = %x", b)
+	}
+	text, err := p.MarshalText()
+	fatalIfErr(t, err)
+	if string(text) != hex.EncodeToString(p.Bytes()) {
+		t.Errorf("MarshalText() = %s, want %x", text, p.Bytes())
+	}
+	text, err = p.AppendText([]byte("prefix"))
+	fatalIfErr(t, err)
+	if string(text) != "prefix"+hex.EncodeToString(p.Bytes()) {
+		t.Errorf("AppendText() = %s", text)
+	}
+
+	for _, enc := range [][]byte{p.Bytes(), p.BytesCompressed()} {
+		q := newPoint()
+		fatalIfErr(t, q.UnmarshalBinary(enc))
+		if !bytes.Equal(q.Bytes(), p.Bytes()) {
+			t.Errorf("UnmarshalBinary(%x) = %x", enc, q.Bytes())
+		}
+		q = newPoint()
+		fatalIfErr(t, q.UnmarshalText([]byte(hex.EncodeToString(enc))))
+		if !bytes.Equal(q.Bytes(), p.Bytes()) {
+			t.Errorf("UnmarshalText(%x) = %x", enc, q.Bytes())
+		}
+	}
+
+	// Invalid encodings must be rejected, and leave the point unchanged.
+	bad := p.Bytes()
+	bad[len(bad)-1] ^= 1
+	q := newPoint().SetGenerator()
+	for _, enc := range [][]byte{bad, p.Bytes()[1:], {}} {
+		if err := q.UnmarshalBinary(enc); err == nil {
+			t.Errorf("UnmarshalBinary(%x) accepted an invalid encoding", enc)
+		}
+		if err := q.UnmarshalText([]byte(hex.EncodeToString(enc))); err == nil {
+			t.Errorf("UnmarshalText(%x) accepted an invalid encoding", enc)
+		}
+	}
+	if err := q.UnmarshalText([]byte("zz")); err == nil {
+		t.Error("UnmarshalText accepted invalid hex")
+	}
+	if !bytes.Equal(q.Bytes(), newPoint().SetGenerator().Bytes()) {
+		t.Error("failed unmarshal modified the point")
+	}
+
+	// encoding/json and encoding/gob must work on struct fields, including
+	// when the decoder allocates the zero value of the point type.
+	type keys struct {
+		Public P
+		Ident  P
+	}
+	in := keys{Public: p, Ident: newPoint()}
+	j, err := json.Marshal(in)
+	fatalIfErr(t, err)
+	want := `{"Public":"` + hex.EncodeToString(p.Bytes()) + `","Ident":"00"}`
+	if string(j) != want {
+		t.Errorf("json.Marshal() = %s, want %s", j, want)
+	}
+	var out keys
+	fatalIfErr(t, json.Unmarshal(j, &out))
+	if !bytes.Equal(out.Public.Bytes(), p.Bytes()) || !bytes.Equal(out.Ident.Bytes(), []byte{0}) {
+		t.Errorf("json.Unmarshal() = %x, %x", out.Public.Bytes(), out.Ident.Bytes())
+	}
+	if err := json.Unmarshal([]byte(`{"Public":"`+hex.EncodeToString(bad)+`"}`), &out); err == nil {
+		t.Error("json.Unmarshal accepted an invalid point")
+	}
+
+	var buf bytes.Buffer
+	fatalIfErr(t, gob.NewEncoder(&buf).Encode(in))
+	var outGob keys
+	fatalIfErr(t, gob.NewDecoder(&buf).Decode(&outGob))
+	if !bytes.Equal(outGob.Public.Bytes(), p.Bytes()) || !bytes.Equal(outGob.Ident.Bytes(), []byte{0}) {
+		t.Errorf("gob round trip = %x, %x", outGob.Public.Bytes(), outGob.Ident.Bytes())
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec
+
+import (
+	"errors"
+	"io"
+
+	"github.com/magical/nistec-extra/internal/fiat"
+)
+
+// ErrFaultDetected is returned by the Checked scalar multiplication methods if
+// a self-check fails, which indicates that the computation was faulted, for
+// example by a voltage or clock glitch.
+var ErrFaultDetected = errors.New("nistec: fault detected in scalar multiplication")
+
+// testingFaultHook, if not nil, is called with the result of the Checked scalar
+// multiplication methods before it's checked, to let tests inject faults.
+var testingFaultHook func(p interface{})
+
+// ScalarMultChecked sets p = scalar * q, and returns p, like ScalarMult, but it
+// also checks for faults induced in the computation. It verifies that q and the
+// result are on the curve and, if rand is not nil, recomputes the result with
+// ScalarMultHardened and compares the two in constant time. If a check fails,
+// ScalarMultChecked returns ErrFaultDetected and the receiver is unchanged.
+func (p *P224Point) ScalarMultChecked(q *P224Point, scalar []byte, rand io.Reader) (*P224Point, error) {
+	if q.isOnCurve() != 1 {
+		return nil, ErrFaultDetected
+	}
+	r, err := NewP224Point().ScalarMult(q, scalar)
+	if err != nil {
+		return nil, err
+	}
+	return p.checkResult(r, q, scalar, rand)
+}
+
+// ScalarBaseMultChecked sets p = scalar * B, where B is the canonical generator,
+// and returns p, like ScalarBaseMult, but it also checks for faults induced in
+// the computation, like ScalarMultChecked.
+func (p *P224Point) ScalarBaseMultChecked(scalar []byte, rand io.Reader) (*P224Point, error) {
+	r, err := NewP224Point().ScalarBaseMult(scalar)
+	if err != nil {
+		return nil, err
+	}
+	return p.checkResult(r, NewP224Point().SetGenerator(), scalar, rand)
+}
+
+// checkResult sets p = r if r is on the curve and, if rand is not nil, equal to
+// scalar * q computed with ScalarMultHardened. Otherwise, it returns
+// ErrFaultDetected and p is unchanged.
+func (p *P224Point) checkResult(r, q *P224Point, scalar []byte, rand io.Reader) (*P224Point, error) {
+	if testingFaultHook != nil {
+		testingFaultHook(r)
+	}
+	ok := r.isOnCurve()
+	if rand != nil {
+		r1, err := NewP224Point().ScalarMultHardened(q, scalar, rand)
+		if err != nil {
+			return nil, err
+		}
+		ok &= r.equal(r1)
+	}
+	if ok != 1 {
+		return nil, ErrFaultDetected
+	}
+	return p.Set(r), nil
+}
+
+// isOnCurve returns 1 if the projective coordinates (X:Y:Z) of p satisfy the
+// curve equation Y²Z = X³ - 3XZ² + bZ³, and 0 otherwise. The point at infinity
+// is always (0:Y:0) with Y != 0.
+func (p *P224Point) isOnCurve() int {
+	lhs := new(fiat.P224Element).Square(p.y)
+	lhs.Mul(lhs, p.z)
+
+	z2 := new(fiat.P224Element).Square(p.z)
+	rhs := new(fiat.P224Element).Square(p.x)
+	threeZ2 := new(fiat.P224Element).Add(z2, z2)
+	threeZ2.Add(threeZ2, z2)
+	rhs.Sub(rhs, threeZ2)
+	rhs.Mul(rhs, p.x)
+	bZ3 := new(fiat.P224Element).Mul(p224B(), z2)
+	bZ3.Mul(bZ3, p.z)
+	rhs.Add(rhs, bZ3)
+
+	notAllZero := 1 ^ p.x.IsZero()&p.y.IsZero()&p.z.IsZero()
+	return lhs.Equal(rhs) & notAllZero
+}
+
+// equal returns 1 if p and q represent the same point, and 0 otherwise.
+func (p *P224Point) equal(q *P224Point) int {
+	// X1/Z1 = X2/Z2 and Y1/Z1 = Y2/Z2, which also holds if both are the point
+	// at infinity, and doesn't if only one of them is.
+	l := new(fiat.P224Element).Mul(p.x, q.z)
+	r := new(fiat.P224Element).Mul(q.x, p.z)
+	eq := l.Equal(r)
+	l.Mul(p.y, q.z)
+	r.Mul(q.y, p.z)
+	return eq & l.Equal(r)
+}
+
+// ScalarMultChecked sets p = scalar * q, and returns p, like ScalarMult, but it
+// also checks for faults induced in the computation. It verifies that q and the
+// result are on the curve and, if rand is not nil, recomputes the result with
+// ScalarMultHardened and compares the two in constant time. If a check fails,
+// ScalarMultChecked returns ErrFaultDetected and the receiver is unchanged.
+func (p *P384Point) ScalarMultChecked(q *P384Point, scalar []byte, rand io.Reader) (*P384Point, error) {
+	if q.isOnCurve() != 1 {
+		return nil, ErrFaultDetected
+	}
+	r, err := NewP384Point().ScalarMult(q, scalar)
+	if err != nil {
+		return nil, err
+	}
+	return p.checkResult(r, q, scalar, rand)
+}
+
+// ScalarBaseMultChecked sets p = scalar * B, where B is the canonical generator,
+// and returns p, like ScalarBaseMult, but it also checks for faults induced in
+// the computation, like ScalarMultChecked.
+func (p *P384Point) ScalarBaseMultChecked(scalar []byte, rand io.Reader) (*P384Point, error) {
+	r, err := NewP384Point().ScalarBaseMult(scalar)
+	if err != nil {
+		return nil, err
+	}
+	return p.checkResult(r, NewP384Point().SetGenerator(), scalar, rand)
+}
+
+// checkResult sets p = r if r is on the curve and, if rand is not nil, equal to
+// scalar * q computed with ScalarMultHardened. Otherwise, it returns
+// ErrFaultDetected and p is unchanged.
+func (p *P384Point) checkResult(r, q *P384Point, scalar []byte, rand io.Reader) (*P384Point, error) {
+	if testingFaultHook != nil {
+		testingFaultHook(r)
+	}
+	ok := r.isOnCurve()
+	if rand != nil {
+		r1, err := NewP384Point().ScalarMultHardened(q, scalar, rand)
+		if err != nil {
+			return nil, err
+		}
+		ok &= r.equal(r1)
+	}
+	if ok != 1 {
+		return nil, ErrFaultDetected
+	}
+	return p.Set(r), nil
+}
+
+// isOnCurve returns 1 if the projective coordinates (X:Y:Z) of p satisfy the
+// curve equation Y²Z = X³ - 3XZ² + bZ³, and 0 otherwise. The point at infinity
+// is always (0:Y:0) with Y != 0.
+func (p *P384Point) isOnCurve() int {
+	lhs := new(fiat.P384Element).Square(p.y)
+	lhs.Mul(lhs, p.z)
+
+	z2 := new(fiat.P384Element).Square(p.z)
+	rhs := new(fiat.P384Element).Square(p.x)
+	threeZ2 := new(fiat.P384Element).Add(z2, z2)
+	threeZ2.Add(threeZ2, z2)
+	rhs.Sub(rhs, threeZ2)
+	rhs.Mul(rhs, p.x)
+	bZ3 := new(fiat.P384Element).Mul(p384B(), z2)
+	bZ3.Mul(bZ3, p.z)
+	rhs.Add(rhs, bZ3)
+
+	notAllZero := 1 ^ p.x.IsZero()&p.y.IsZero()&p.z.IsZero()
+	return lhs.Equal(rhs) & notAllZero
+}
+
+// equal returns 1 if p and q represent the same point, and 0 otherwise.
+func (p *P384Point) equal(q *P384Point) int {
+	// X1/Z1 = X2/Z2 and Y1/Z1 = Y2/Z2, which also holds if both are the point
+	// at infinity, and doesn't if only one of them is.
+	l := new(fiat.P384Element).Mul(p.x, q.z)
+	r := new(fiat.P384Element).Mul(q.x, p.z)
+	eq := l.Equal(r)
+	l.Mul(p.y, q.z)
+	r.Mul(q.y, p.z)
+	return eq & l.Equal(r)
+}
+
+// ScalarMultChecked sets p = scalar * q, and returns p, like ScalarMult, but it
+// also checks for faults induced in the computation. It verifies that q and the
+// result are on the curve and, if rand is not nil, recomputes the result with
+// ScalarMultHardened and compares the two in constant time. If a check fails,
+// ScalarMultChecked returns ErrFaultDetected and the receiver is unchanged.
+func (p *P521Point) ScalarMultChecked(q *P521Point, scalar []byte, rand io.Reader) (*P521Point, error) {
+	if q.isOnCurve() != 1 {
+		return nil, ErrFaultDetected
+	}
+	r, err := NewP521Point().ScalarMult(q, scalar)
+	if err != nil {
+		return nil, err
+	}
+	return p.checkResult(r, q, scalar, rand)
+}
+
+// ScalarBaseMultChecked sets p = scalar * B, where B is the canonical generator,
+// and returns p, like ScalarBaseMult, but it also checks for faults induced in
+// the computation, like ScalarMultChecked.
+func (p *P521Point) ScalarBaseMultChecked(scalar []byte, rand io.Reader) (*P521Point, error) {
+	r, err := NewP521Point().ScalarBaseMult(scalar)
+	if err != nil {
+		return nil, err
+	}
+	return p.checkResult(r, NewP521Point().SetGenerator(), scalar, rand)
+}
+
+// checkResult sets p = r if r is on the curve and, if rand is not nil, equal to
+// scalar * q computed with ScalarMultHardened. Otherwise, it returns
+// ErrFaultDetected and p is unchanged.
+func (p *P521Point) checkResult(r, q *P521Point, scalar []byte, rand io.Reader) (*P521Point, error) {
+	if testingFaultHook != nil {
+		testingFaultHook(r)
+	}
+	ok := r.isOnCurve()
+	if rand != nil {
+		r1, err := NewP521Point().ScalarMultHardened(q, scalar, rand)
+		if err != nil {
+			return nil, err
+		}
+		ok &= r.equal(r1)
+	}
+	if ok != 1 {
+		return nil, ErrFaultDetected
+	}
+	return p.Set(r), nil
+}
+
+// isOnCurve returns 1 if the projective coordinates (X:Y:Z) of p satisfy the
+// curve equation Y²Z = X³ - 3XZ² + bZ³, and 0 otherwise. The point at infinity
+// is always (0:Y:0) with Y != 0.
+func (p *P521Point) isOnCurve() int {
+	lhs := new(fiat.P521Element).Square(p.y)
+	lhs.Mul(lhs, p.z)
+
+	z2 := new(fiat.P521Element).Square(p.z)
+	rhs := new(fiat.P521Element).Square(p.x)
+	threeZ2 := new(fiat.P521Element).Add(z2, z2)
+	threeZ2.Add(threeZ2, z2)
+	rhs.Sub(rhs, threeZ2)
+	rhs.Mul(rhs, p.x)
+	bZ3 := new(fiat.P521Element).Mul(p521B(), z2)
+	bZ3.Mul(bZ3, p.z)
+	rhs.Add(rhs, bZ3)
+
+	notAllZero := 1 ^ p.x.IsZero()&p.y.IsZero()&p.z.IsZero()
+	return lhs.Equal(rhs) & notAllZero
+}
+
+// equal returns 1 if p and q represent the same point, and 0 otherwise.
+func (p *P521Point) equal(q *P521Point) int {
+	// X1/Z1 = X2/Z2 and Y1/Z1 = Y2/Z2, which also holds if both are the point
+	// at infinity, and doesn't if only one of them is.
+	l := new(fiat.P521Element).Mul(p.x, q.z)
+	r := new(fiat.P521Element).Mul(q.x, p.z)
+	eq := l.Equal(r)
+	l.Mul(p.y, q.z)
+	r.Mul(q.y, p.z)
+	return eq & l.Equal(r)
+}
@@ -0,0 +1,110 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !purego && (amd64 || arm64 || (ppc64le && go1.19) || s390x)
+
+package nistec
+
+import "io"
+
+// ScalarMultChecked sets r = scalar * q, and returns r, like ScalarMult, but it
+// also checks for faults induced in the computation. It verifies that q and the
+// result are on the curve and, if rand is not nil, recomputes the result with
+// ScalarMultHardened and compares the two in constant time. If a check fails,
+// ScalarMultChecked returns ErrFaultDetected and the receiver is unchanged.
+func (r *P256Point) ScalarMultChecked(q *P256Point, scalar []byte, rand io.Reader) (*P256Point, error) {
+	if q.isOnCurve() != 1 {
+		return nil, ErrFaultDetected
+	}
+	var p P256Point
+	if _, err := p.ScalarMult(q, scalar); err != nil {
+		return nil, err
+	}
+	return r.checkResult(&p, q, scalar, rand)
+}
+
+// ScalarBaseMultChecked sets r = scalar * B, where B is the canonical generator,
+// and returns r, like ScalarBaseMult, but it also checks for faults induced in
+// the computation, like ScalarMultChecked.
+func (r *P256Point) ScalarBaseMultChecked(scalar []byte, rand io.Reader) (*P256Point, error) {
+	var p P256Point
+	if _, err := p.ScalarBaseMult(scalar); err != nil {
+		return nil, err
+	}
+	return r.checkResult(&p, NewP256Point().SetGenerator(), scalar, rand)
+}
+
+// checkResult sets r = p if p is on the curve and, if rand is not nil, equal to
+// scalar * q computed with ScalarMultHardened. Otherwise, it returns
+// ErrFaultDetected and r is unchanged.
+func (r *P256Point) checkResult(p, q *P256Point, scalar []byte, rand io.Reader) (*P256Point, error) {
+	if testingFaultHook != nil {
+		testingFaultHook(p)
+	}
+	ok := p.isOnCurve()
+	if rand != nil {
+		var p1 P256Point
+		if _, err := p1.ScalarMultHardened(q, scalar, rand); err != nil {
+			return nil, err
+		}
+		ok &= p.equal(&p1)
+	}
+	if ok != 1 {
+		return nil, ErrFaultDetected
+	}
+	return r.Set(p), nil
+}
+
+// isOnCurve returns 1 if p is the point at infinity (Z = 0) or if its Jacobian
+// coordinates (X:Y:Z) satisfy the curve equation Y² = X³ - 3XZ⁴ + bZ⁶, and 0
+// otherwise.
+func (p *P256Point) isOnCurve() int {
+	p256B := &p256Element{0xd89cdf6229c4bddf, 0xacf005cd78843090,
+		0xe5a220abf7212ed6, 0xdc30061d04874834}
+
+	lhs := new(p256Element)
+	p256Sqr(lhs, &p.y, 1)
+
+	z2, z4 := new(p256Element), new(p256Element)
+	p256Sqr(z2, &p.z, 1)
+	p256Sqr(z4, z2, 1)
+
+	// X³ - 3XZ⁴ = X(X² - 3Z⁴)
+	rhs := new(p256Element)
+	p256Sqr(rhs, &p.x, 1)
+	threeZ4 := new(p256Element)
+	p256Add(threeZ4, z4, z4)
+	p256Add(threeZ4, threeZ4, z4)
+	p256NegCond(threeZ4, 1)
+	p256Add(rhs, rhs, threeZ4)
+	p256Mul(rhs, rhs, &p.x)
+
+	bZ6 := new(p256Element)
+	p256Mul(bZ6, z4, z2)
+	p256Mul(bZ6, bZ6, p256B)
+	p256Add(rhs, rhs, bZ6)
+
+	return p256Equal(lhs, rhs) | p.isInfinity()
+}
+
+// equal returns 1 if p and q represent the same point, and 0 otherwise.
+func (p *P256Point) equal(q *P256Point) int {
+	pInf, qInf := p.isInfinity(), q.isInfinity()
+
+	// X1/Z1² = X2/Z2² and Y1/Z1³ = Y2/Z2³.
+	pz2, qz2 := new(p256Element), new(p256Element)
+	p256Sqr(pz2, &p.z, 1)
+	p256Sqr(qz2, &q.z, 1)
+	l, r := new(p256Element), new(p256Element)
+	p256Mul(l, &p.x, qz2)
+	p256Mul(r, &q.x, pz2)
+	eq := p256Equal(l, r)
+	p256Mul(qz2, qz2, &q.z)
+	p256Mul(pz2, pz2, &p.z)
+	p256Mul(l, &p.y, qz2)
+	p256Mul(r, &q.y, pz2)
+	eq &= p256Equal(l, r)
+
+	return eq&(1^pInf)&(1^qInf) | pInf&qInf
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build purego || (!amd64 && !arm64 && !(ppc64le && go1.19) && !s390x)
+
+package nistec
+
+import (
+	"io"
+
+	"github.com/magical/nistec-extra/internal/fiat"
+)
+
+// ScalarMultChecked sets p = scalar * q, and returns p, like ScalarMult, but it
+// also checks for faults induced in the computation. It verifies that q and the
+// result are on the curve and, if rand is not nil, recomputes the result with
+// ScalarMultHardened and compares the two in constant time. If a check fails,
+// ScalarMultChecked returns ErrFaultDetected and the receiver is unchanged.
+func (p *P256Point) ScalarMultChecked(q *P256Point, scalar []byte, rand io.Reader) (*P256Point, error) {
+	if q.isOnCurve() != 1 {
+		return nil, ErrFaultDetected
+	}
+	r, err := NewP256Point().ScalarMult(q, scalar)
+	if err != nil {
+		return nil, err
+	}
+	return p.checkResult(r, q, scalar, rand)
+}
+
+// ScalarBaseMultChecked sets p = scalar * B, where B is the canonical generator,
+// and returns p, like ScalarBaseMult, but it also checks for faults induced in
+// the computation, like ScalarMultChecked.
+func (p *P256Point) ScalarBaseMultChecked(scalar []byte, rand io.Reader) (*P256Point, error) {
+	r, err := NewP256Point().ScalarBaseMult(scalar)
+	if err != nil {
+		return nil, err
+	}
+	return p.checkResult(r, NewP256Point().SetGenerator(), scalar, rand)
+}
+
+// checkResult sets p = r if r is on the curve and, if rand is not nil, equal to
+// scalar * q computed with ScalarMultHardened. Otherwise, it returns
+// ErrFaultDetected and p is unchanged.
+func (p *P256Point) checkResult(r, q *P256Point, scalar []byte, rand io.Reader) (*P256Point, error) {
+	if testingFaultHook != nil {
+		testingFaultHook(r)
+	}
+	ok := r.isOnCurve()
+	if rand != nil {
+		r1, err := NewP256Point().ScalarMultHardened(q, scalar, rand)
+		if err != nil {
+			return nil, err
+		}
+		ok &= r.equal(r1)
+	}
+	if ok != 1 {
+		return nil, ErrFaultDetected
+	}
+	return p.Set(r), nil
+}
+
+// isOnCurve returns 1 if the projective coordinates (X:Y:Z) of p satisfy the
+// curve equation Y²Z = X³ - 3XZ² + bZ³, and 0 otherwise. The point at infinity
+// is always (0:Y:0) with Y != 0.
+func (p *P256Point) isOnCurve() int {
+	lhs := new(fiat.P256Element).Square(p.y)
+	lhs.Mul(lhs, p.z)
+
+	z2 := new(fiat.P256Element).Square(p.z)
+	rhs := new(fiat.P256Element).Square(p.x)
+	threeZ2 := new(fiat.P256Element).Add(z2, z2)
+	threeZ2.Add(threeZ2, z2)
+	rhs.Sub(rhs, threeZ2)
+	rhs.Mul(rhs, p.x)
+	bZ3 := new(fiat.P256Element).Mul(p256B(), z2)
+	bZ3.Mul(bZ3, p.z)
+	rhs.Add(rhs, bZ3)
+
+	notAllZero := 1 ^ p.x.IsZero()&p.y.IsZero()&p.z.IsZero()
+	return lhs.Equal(rhs) & notAllZero
+}
+
+// equal returns 1 if p and q represent the same point, and 0 otherwise.
+func (p *P256Point) equal(q *P256Point) int {
+	// X1/Z1 = X2/Z2 and Y1/Z1 = Y2/Z2, which also holds if both are the point
+	// at infinity, and doesn't if only one of them is.
+	l := new(fiat.P256Element).Mul(p.x, q.z)
+	r := new(fiat.P256Element).Mul(q.x, p.z)
+	eq := l.Equal(r)
+	l.Mul(p.y, q.z)
+	r.Mul(q.y, p.z)
+	return eq & l.Equal(r)
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+type checkedPoint[T any] interface {
+	Bytes() []byte
+	SetGenerator() T
+	Double(T) T
+	ScalarMult(T, []byte) (T, error)
+	ScalarBaseMult([]byte) (T, error)
+	ScalarMultChecked(T, []byte, io.Reader) (T, error)
+	ScalarBaseMultChecked([]byte, io.Reader) (T, error)
+}
+
+func TestFaultDetection(t *testing.T) {
+	t.Run("P224", func(t *testing.T) {
+		testFaultDetection(t, NewP224Point, 28, func(p *P224Point) {
+			p.x, p.y = p.y, p.x
+		})
+	})
+	t.Run("P256", func(t *testing.T) {
+		testFaultDetection(t, NewP256Point, 32, func(p *P256Point) {
+			p.x, p.y = p.y, p.x
+		})
+	})
+	t.Run("P384", func(t *testing.T) {
+		testFaultDetection(t, NewP384Point, 48, func(p *P384Point) {
+			p.x, p.y = p.y, p.x
+		})
+	})
+	t.Run("P521", func(t *testing.T) {
+		testFaultDetection(t, NewP521Point, 66, func(p *P521Point) {
+			p.x, p.y = p.y, p.x
+		})
+	})
+}
+
+// testFaultDetection checks the Checked methods with no faults, with an
+// off-curve fault injected by offCurve, and with an on-curve fault.
+func testFaultDetection[P checkedPoint[P]](t *testing.T, newPoint func() P, scalarSize int, offCurve func(P)) {
+	defer func() { testingFaultHook = nil }()
+
+	g := newPoint().SetGenerator()
+	scalar := make([]byte, scalarSize)
+	rand.Read(scalar)
+	want, err := newPoint().ScalarMult(g, scalar)
+	fatalIfErr(t, err)
+
+	for _, r := range []io.Reader{nil, rand.Reader} {
+		testingFaultHook = nil
+		got, err := newPoint().ScalarMultChecked(g, scalar, r)
+		fatalIfErr(t, err)
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Error("ScalarMultChecked != ScalarMult")
+		}
+		got, err = newPoint().ScalarBaseMultChecked(scalar, r)
+		fatalIfErr(t, err)
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Error("ScalarBaseMultChecked != ScalarBaseMult")
+		}
+
+		testingFaultHook = func(p interface{}) { offCurve(p.(P)) }
+		if _, err := newPoint().ScalarMultChecked(g, scalar, r); err != ErrFaultDetected {
+			t.Errorf("ScalarMultChecked with off-curve fault: got %v, want ErrFaultDetected", err)
+		}
+		if _, err := newPoint().ScalarBaseMultChecked(scalar, r); err != ErrFaultDetected {
+			t.Errorf("ScalarBaseMultChecked with off-curve fault: got %v, want ErrFaultDetected", err)
+		}
+	}
+
+	// A fault that yields a different valid point is only caught by the
+	// recomputation.
+	testingFaultHook = func(p interface{}) { p.(P).Double(p.(P)) }
+	if _, err := newPoint().ScalarMultChecked(g, scalar, rand.Reader); err != ErrFaultDetected {
+		t.Errorf("ScalarMultChecked with on-curve fault: got %v, want ErrFaultDetected", err)
+	}
+	if _, err := newPoint().ScalarBaseMultChecked(scalar, rand.Reader); err != ErrFaultDetected {
+		t.Errorf("ScalarBaseMultChecked with on-curve fault: got %v, want ErrFaultDetected", err)
+	}
+
+	// An off-curve input point is rejected.
+	testingFaultHook = nil
+	bad := newPoint().SetGenerator()
+	offCurve(bad)
+	if _, err := newPoint().ScalarMultChecked(bad, scalar, nil); err != ErrFaultDetected {
+		t.Errorf("ScalarMultChecked with off-curve input: got %v, want ErrFaultDetected", err)
+	}
+}
+
+func fatalIfErr(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
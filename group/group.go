@@ -0,0 +1,190 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package group exposes the NIST P curves as interchangeable prime order
+// groups, so that protocols can be written once against the Group, Element,
+// and Scalar interfaces and instantiated for each curve.
+//
+// Elements are backed by the constant-time point types of package nistec, and
+// scalars by a constant-time implementation of arithmetic modulo the group
+// order. Mixing elements or scalars of different groups causes a panic.
+package group
+
+import (
+	"encoding"
+	"io"
+)
+
+// Group is a prime order group.
+type Group interface {
+	// Name returns the name of the group, such as "P-256".
+	Name() string
+
+	// NewElement returns a new Element set to the identity.
+	NewElement() Element
+
+	// NewScalar returns a new Scalar set to zero.
+	NewScalar() Scalar
+
+	// ElementLength returns the length of the compressed encoding of an
+	// element other than the identity, as returned by BytesCompressed.
+	ElementLength() int
+
+	// ScalarLength returns the length of the encoding of a scalar.
+	ScalarLength() int
+
+	// Order returns the big-endian encoding of the order of the group, which
+	// is ScalarLength bytes long.
+	Order() []byte
+
+	// RandomScalar returns a uniformly random non-zero scalar, using rand as
+	// the source of randomness.
+	RandomScalar(rand io.Reader) (Scalar, error)
+
+	// HashToElement hashes msg to an element with the hash_to_curve function
+	// of RFC 9380, using the domain separation tag dst. The output is
+	// indistinguishable from a random element.
+	HashToElement(msg, dst []byte) Element
+
+	// EncodeToElement hashes msg to an element with the encode_to_curve
+	// function of RFC 9380, using the domain separation tag dst. It is faster
+	// than HashToElement, but the output is not uniformly distributed.
+	EncodeToElement(msg, dst []byte) Element
+
+	// HashToScalar hashes msg to a scalar with the hash_to_field function of
+	// RFC 9380, using the domain separation tag dst and the group order as
+	// the modulus.
+	HashToScalar(msg, dst []byte) Scalar
+}
+
+// Element is an element of a prime order Group.
+//
+// Methods that take other elements set the receiver to the result and return
+// it, like the point types of package nistec. Arguments may alias the receiver.
+type Element interface {
+	// Group returns the group the element belongs to.
+	Group() Group
+
+	// Set sets e = q, and returns e.
+	Set(q Element) Element
+
+	// SetGenerator sets e to the canonical generator, and returns e.
+	SetGenerator() Element
+
+	// SetBytes sets e to the value of b, which must be a SEC 1, Version 2.0
+	// uncompressed, compressed, or identity encoding. If b is not a valid
+	// encoding, SetBytes returns nil and an error, and e is unchanged.
+	SetBytes(b []byte) (Element, error)
+
+	// Bytes returns the uncompressed or identity encoding of e.
+	Bytes() []byte
+
+	// BytesCompressed returns the compressed or identity encoding of e.
+	BytesCompressed() []byte
+
+	// Add sets e = q1 + q2, and returns e.
+	Add(q1, q2 Element) Element
+
+	// Subtract sets e = q1 - q2, and returns e.
+	Subtract(q1, q2 Element) Element
+
+	// Double sets e = q + q, and returns e.
+	Double(q Element) Element
+
+	// Negate sets e = -q, and returns e.
+	Negate(q Element) Element
+
+	// ScalarMult sets e = s * q, and returns e.
+	ScalarMult(q Element, s Scalar) Element
+
+	// ScalarBaseMult sets e = s * G, where G is the generator, and returns e.
+	ScalarBaseMult(s Scalar) Element
+
+	// Select sets e to q1 if cond == 1, and to q2 if cond == 0, and returns e.
+	Select(q1, q2 Element, cond int) Element
+
+	// Equal returns 1 if e and q are equal, and 0 otherwise.
+	Equal(q Element) int
+
+	// IsIdentity returns 1 if e is the identity, and 0 otherwise.
+	IsIdentity() int
+
+	// MarshalBinary returns the same encoding as Bytes, and UnmarshalBinary
+	// accepts the same encodings as SetBytes.
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+
+	// AppendBinary appends the encoding returned by Bytes to b.
+	AppendBinary(b []byte) ([]byte, error)
+
+	// MarshalText and UnmarshalText use the lowercase hexadecimal form of the
+	// binary encoding. They also make elements marshal to JSON strings.
+	encoding.TextMarshaler
+	encoding.TextUnmarshaler
+}
+
+// Scalar is an integer modulo the order of a prime order Group.
+//
+// Methods that take other scalars set the receiver to the result and return
+// it. Arguments may alias the receiver.
+type Scalar interface {
+	// Group returns the group the scalar belongs to.
+	Group() Group
+
+	// Set sets s = x, and returns s.
+	Set(x Scalar) Scalar
+
+	// SetUint64 sets s = x mod N, where N is the group order, and returns s.
+	SetUint64(x uint64) Scalar
+
+	// SetBytes sets s to the value of b, the big-endian encoding of an integer
+	// in [0, N-1]. b must be ScalarLength bytes long. If b is not a valid
+	// encoding, SetBytes returns nil and an error, and s is unchanged.
+	SetBytes(b []byte) (Scalar, error)
+
+	// SetReducedBytes sets s to b mod N, where b is a big-endian integer of any
+	// length, and returns s.
+	SetReducedBytes(b []byte) Scalar
+
+	// Bytes returns the ScalarLength bytes big-endian encoding of s.
+	Bytes() []byte
+
+	// Add sets s = x + y mod N, and returns s.
+	Add(x, y Scalar) Scalar
+
+	// Subtract sets s = x - y mod N, and returns s.
+	Subtract(x, y Scalar) Scalar
+
+	// Multiply sets s = x * y mod N, and returns s.
+	Multiply(x, y Scalar) Scalar
+
+	// Negate sets s = -x mod N, and returns s.
+	Negate(x Scalar) Scalar
+
+	// Invert sets s = 1 / x mod N, and returns s. If x is zero, s is set to
+	// zero.
+	Invert(x Scalar) Scalar
+
+	// Select sets s to x if cond == 1, and to y if cond == 0, and returns s.
+	Select(x, y Scalar, cond int) Scalar
+
+	// Equal returns 1 if s and x are equal, and 0 otherwise.
+	Equal(x Scalar) int
+
+	// IsZero returns 1 if s is zero, and 0 otherwise.
+	IsZero() int
+
+	// MarshalBinary returns the same encoding as Bytes, and UnmarshalBinary
+	// accepts the same encodings as SetBytes.
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+
+	// AppendBinary appends the encoding returned by Bytes to b.
+	AppendBinary(b []byte) ([]byte, error)
+
+	// MarshalText and UnmarshalText use the lowercase hexadecimal form of the
+	// binary encoding. They also make scalars marshal to JSON strings.
+	encoding.TextMarshaler
+	encoding.TextUnmarshaler
+}
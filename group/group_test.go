@@ -0,0 +1,370 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package group_test
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/magical/nistec-extra/group"
+)
+
+var groups = []struct {
+	g group.Group
+	c elliptic.Curve
+}{
+	{group.P224(), elliptic.P224()},
+	{group.P256(), elliptic.P256()},
+	{group.P384(), elliptic.P384()},
+	{group.P521(), elliptic.P521()},
+}
+
+func fatalIfErr(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScalar(t *testing.T) {
+	for _, tt := range groups {
+		t.Run(tt.g.Name(), func(t *testing.T) {
+			testScalar(t, tt.g, tt.c)
+		})
+	}
+}
+
+func testScalar(t *testing.T, g group.Group, c elliptic.Curve) {
+	N := c.Params().N
+	if !bytes.Equal(g.Order(), N.FillBytes(make([]byte, g.ScalarLength()))) {
+		t.Fatalf("Order() = %x, want %x", g.Order(), N)
+	}
+	toBig := func(s group.Scalar) *big.Int { return new(big.Int).SetBytes(s.Bytes()) }
+	fromBig := func(x *big.Int) group.Scalar {
+		s, err := g.NewScalar().SetBytes(x.FillBytes(make([]byte, g.ScalarLength())))
+		fatalIfErr(t, err)
+		return s
+	}
+
+	values := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(2),
+		new(big.Int).Sub(N, big.NewInt(1)), new(big.Int).Sub(N, big.NewInt(2))}
+	for i := 0; i < 10; i++ {
+		s, err := g.RandomScalar(rand.Reader)
+		fatalIfErr(t, err)
+		values = append(values, toBig(s))
+	}
+
+	for _, x := range values {
+		for _, y := range values {
+			sx, sy := fromBig(x), fromBig(y)
+			check := func(op string, got group.Scalar, want *big.Int) {
+				t.Helper()
+				want.Mod(want, N)
+				if toBig(got).Cmp(want) != 0 {
+					t.Errorf("%x %s %x = %x, want %x", x, op, y, got.Bytes(), want)
+				}
+			}
+			check("+", g.NewScalar().Add(sx, sy), new(big.Int).Add(x, y))
+			check("-", g.NewScalar().Subtract(sx, sy), new(big.Int).Sub(x, y))
+			check("*", g.NewScalar().Multiply(sx, sy), new(big.Int).Mul(x, y))
+			if eq := sx.Equal(sy); eq != boolToInt(x.Cmp(y) == 0) {
+				t.Errorf("%x == %x = %d", x, y, eq)
+			}
+		}
+
+		sx := fromBig(x)
+		if got, want := toBig(g.NewScalar().Negate(sx)), new(big.Int).Mod(new(big.Int).Neg(x), N); got.Cmp(want) != 0 {
+			t.Errorf("-%x = %x, want %x", x, got, want)
+		}
+		want := new(big.Int).ModInverse(x, N)
+		if want == nil {
+			want = new(big.Int)
+		}
+		if got := toBig(g.NewScalar().Invert(sx)); got.Cmp(want) != 0 {
+			t.Errorf("1/%x = %x, want %x", x, got, want)
+		}
+		if sx.IsZero() != boolToInt(x.Sign() == 0) {
+			t.Errorf("IsZero(%x) = %d", x, sx.IsZero())
+		}
+
+		// Aliasing.
+		sx.Multiply(sx, sx)
+		if got, want := toBig(sx), new(big.Int).Mod(new(big.Int).Mul(x, x), N); got.Cmp(want) != 0 {
+			t.Errorf("%x² (aliasing) = %x, want %x", x, got, want)
+		}
+	}
+
+	for _, n := range []int{0, 1, 8, 33, 48, 64, 98, 200} {
+		b := make([]byte, n)
+		rand.Read(b)
+		want := new(big.Int).Mod(new(big.Int).SetBytes(b), N)
+		if got := toBig(g.NewScalar().SetReducedBytes(b)); got.Cmp(want) != 0 {
+			t.Errorf("SetReducedBytes(%x) = %x, want %x", b, got, want)
+		}
+	}
+
+	if _, err := g.NewScalar().SetBytes(N.FillBytes(make([]byte, g.ScalarLength()))); err == nil {
+		t.Error("SetBytes accepted N")
+	}
+	if _, err := g.NewScalar().SetBytes(make([]byte, g.ScalarLength()-1)); err == nil {
+		t.Error("SetBytes accepted a short scalar")
+	}
+	if got := toBig(g.NewScalar().SetUint64(1 << 63)); got.Cmp(new(big.Int).Lsh(big.NewInt(1), 63)) != 0 {
+		t.Errorf("SetUint64(1 << 63) = %x", got)
+	}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestElement(t *testing.T) {
+	for _, tt := range groups {
+		t.Run(tt.g.Name(), func(t *testing.T) {
+			testElement(t, tt.g, tt.c)
+		})
+	}
+}
+
+func testElement(t *testing.T, g group.Group, c elliptic.Curve) {
+	G := g.NewElement().SetGenerator()
+	id := g.NewElement()
+	if id.IsIdentity() != 1 || G.IsIdentity() != 0 {
+		t.Error("IsIdentity is wrong")
+	}
+	if !bytes.Equal(id.Bytes(), []byte{0}) {
+		t.Errorf("identity encodes to %x", id.Bytes())
+	}
+	gx, gy := c.Params().Gx, c.Params().Gy
+	if !bytes.Equal(G.Bytes(), elliptic.Marshal(c, gx, gy)) {
+		t.Errorf("generator encodes to %x", G.Bytes())
+	}
+	if len(G.BytesCompressed()) != g.ElementLength() {
+		t.Errorf("ElementLength() = %d, but compressed encoding is %d bytes", g.ElementLength(), len(G.BytesCompressed()))
+	}
+
+	a, err := g.RandomScalar(rand.Reader)
+	fatalIfErr(t, err)
+	b, err := g.RandomScalar(rand.Reader)
+	fatalIfErr(t, err)
+
+	// [a]G + [b]G = [a + b]G
+	aG := g.NewElement().ScalarBaseMult(a)
+	bG := g.NewElement().ScalarMult(G, b)
+	sum := g.NewElement().Add(aG, bG)
+	if want := g.NewElement().ScalarBaseMult(g.NewScalar().Add(a, b)); sum.Equal(want) != 1 {
+		t.Error("[a]G + [b]G != [a + b]G")
+	}
+	// [a]([b]G) = [a×b]G
+	abG := g.NewElement().ScalarMult(bG, a)
+	if want := g.NewElement().ScalarBaseMult(g.NewScalar().Multiply(a, b)); abG.Equal(want) != 1 {
+		t.Error("[a]([b]G) != [a×b]G")
+	}
+	// [a]G - [b]G = [a]G + [-b]G
+	diff := g.NewElement().Subtract(aG, bG)
+	if want := g.NewElement().Add(aG, g.NewElement().ScalarBaseMult(g.NewScalar().Negate(b))); diff.Equal(want) != 1 {
+		t.Error("[a]G - [b]G != [a - b]G")
+	}
+	if g.NewElement().Add(aG, g.NewElement().Negate(aG)).IsIdentity() != 1 {
+		t.Error("[a]G + -[a]G != identity")
+	}
+	if want := g.NewElement().Add(aG, aG); g.NewElement().Double(aG).Equal(want) != 1 {
+		t.Error("Double([a]G) != [a]G + [a]G")
+	}
+	if g.NewElement().ScalarMult(aG, g.NewScalar()).IsIdentity() != 1 {
+		t.Error("[0]([a]G) != identity")
+	}
+	if aG.Equal(bG) != 0 || aG.Equal(aG) != 1 || id.Equal(g.NewElement()) != 1 {
+		t.Error("Equal is wrong")
+	}
+	if sel := g.NewElement().Select(aG, bG, 1); sel.Equal(aG) != 1 {
+		t.Error("Select(aG, bG, 1) != aG")
+	}
+	if sel := g.NewElement().Select(aG, bG, 0); sel.Equal(bG) != 1 {
+		t.Error("Select(aG, bG, 0) != bG")
+	}
+
+	for _, enc := range [][]byte{aG.Bytes(), aG.BytesCompressed()} {
+		e, err := g.NewElement().SetBytes(enc)
+		fatalIfErr(t, err)
+		if e.Equal(aG) != 1 {
+			t.Errorf("SetBytes(%x) round trip failed", enc)
+		}
+	}
+	if _, err := g.NewElement().SetBytes(aG.Bytes()[1:]); err == nil {
+		t.Error("SetBytes accepted an invalid encoding")
+	}
+
+	for _, h := range []group.Element{
+		g.HashToElement([]byte("hello"), []byte("test DST")),
+		g.EncodeToElement([]byte("hello"), []byte("test DST")),
+		g.HashToElement(nil, []byte(strings.Repeat("long DST", 40))),
+	} {
+		if h.IsIdentity() == 1 {
+			t.Error("hash to element returned the identity")
+		}
+		if _, err := g.NewElement().SetBytes(h.Bytes()); err != nil {
+			t.Errorf("hash to element returned an invalid point: %v", err)
+		}
+	}
+	if s := g.HashToScalar([]byte("hello"), []byte("test DST")); s.IsZero() == 1 {
+		t.Error("HashToScalar returned zero")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	for _, tt := range groups {
+		t.Run(tt.g.Name(), func(t *testing.T) {
+			testMarshal(t, tt.g)
+		})
+	}
+}
+
+func testMarshal(t *testing.T, g group.Group) {
+	s, err := g.RandomScalar(rand.Reader)
+	fatalIfErr(t, err)
+	e := g.NewElement().ScalarBaseMult(s)
+
+	type pair struct {
+		S group.Scalar
+		E group.Element
+	}
+	j, err := json.Marshal(pair{s, e})
+	fatalIfErr(t, err)
+	want := `{"S":"` + hex.EncodeToString(s.Bytes()) + `","E":"` + hex.EncodeToString(e.Bytes()) + `"}`
+	if string(j) != want {
+		t.Errorf("json.Marshal() = %s, want %s", j, want)
+	}
+	out := pair{g.NewScalar(), g.NewElement()}
+	fatalIfErr(t, json.Unmarshal(j, &out))
+	if out.S.Equal(s) != 1 || out.E.Equal(e) != 1 {
+		t.Error("JSON round trip changed the values")
+	}
+
+	b, err := s.AppendBinary([]byte{0xff})
+	fatalIfErr(t, err)
+	if !bytes.Equal(b, append([]byte{0xff}, s.Bytes()...)) {
+		t.Errorf("Scalar.AppendBinary() = %x", b)
+	}
+	b, err = e.AppendBinary([]byte{0xff})
+	fatalIfErr(t, err)
+	if !bytes.Equal(b, append([]byte{0xff}, e.Bytes()...)) {
+		t.Errorf("Element.AppendBinary() = %x", b)
+	}
+
+	b, err = s.MarshalBinary()
+	fatalIfErr(t, err)
+	s2 := g.NewScalar()
+	fatalIfErr(t, s2.UnmarshalBinary(b))
+	if s2.Equal(s) != 1 {
+		t.Error("Scalar binary round trip changed the value")
+	}
+	b, err = e.MarshalBinary()
+	fatalIfErr(t, err)
+	e2 := g.NewElement()
+	fatalIfErr(t, e2.UnmarshalBinary(b))
+	if e2.Equal(e) != 1 {
+		t.Error("Element binary round trip changed the value")
+	}
+
+	if err := s2.UnmarshalText([]byte(hex.EncodeToString(g.Order()))); err == nil {
+		t.Error("UnmarshalText accepted the group order")
+	}
+	if err := s2.UnmarshalBinary(b); err == nil {
+		t.Error("Scalar.UnmarshalBinary accepted an element encoding")
+	}
+	if s2.Equal(s) != 1 {
+		t.Error("failed unmarshal modified the scalar")
+	}
+	b[len(b)-1] ^= 1
+	if err := e2.UnmarshalText([]byte(hex.EncodeToString(b))); err == nil {
+		t.Error("UnmarshalText accepted an invalid point")
+	}
+	if e2.Equal(e) != 1 {
+		t.Error("failed unmarshal modified the element")
+	}
+}
+
+func TestMixedGroups(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("mixing groups did not panic")
+		}
+	}()
+	group.P256().NewElement().Add(group.P256().NewElement(), group.P384().NewElement())
+}
+
+func TestHashToElement(t *testing.T) {
+	// Test vectors from RFC 9380, Appendix J.
+	for _, tt := range []struct {
+		g       group.Group
+		dst     string
+		encode  bool
+		msg, xy string
+	}{
+		{
+			g:   group.P256(),
+			dst: "QUUX-V01-CS02-with-P256_XMD:SHA-256_SSWU_RO_",
+			msg: "",
+			xy: "2c15230b26dbc6fc9a37051158c95b79656e17a1a920b11394ca91c44247d3e4" +
+				"8a7a74985cc5c776cdfe4b1f19884970453912e9d31528c060be9ab5c43e8415",
+		},
+		{
+			g:   group.P256(),
+			dst: "QUUX-V01-CS02-with-P256_XMD:SHA-256_SSWU_RO_",
+			msg: "abc",
+			xy: "0bb8b87485551aa43ed54f009230450b492fead5f1cc91658775dac4a3388a0f" +
+				"5c41b3d0731a27a7b14bc0bf0ccded2d8751f83493404c84a88e71ffd424212e",
+		},
+		{
+			g:   group.P256(),
+			dst: "QUUX-V01-CS02-with-P256_XMD:SHA-256_SSWU_RO_",
+			msg: "a512_" + strings.Repeat("a", 512),
+			xy: "457ae2981f70ca85d8e24c308b14db22f3e3862c5ea0f652ca38b5e49cd64bc5" +
+				"ecb9f0eadc9aeed232dabc53235368c1394c78de05dd96893eefa62b0f4757dc",
+		},
+		{
+			g:      group.P256(),
+			dst:    "QUUX-V01-CS02-with-P256_XMD:SHA-256_SSWU_NU_",
+			encode: true,
+			msg:    "",
+			xy: "f871caad25ea3b59c16cf87c1894902f7e7b2c822c3d3f73596c5ace8ddd14d1" +
+				"87b9ae23335bee057b99bac1e68588b18b5691af476234b8971bc4f011ddc99b",
+		},
+		{
+			g:   group.P384(),
+			dst: "QUUX-V01-CS02-with-P384_XMD:SHA-384_SSWU_RO_",
+			msg: "",
+			xy: "eb9fe1b4f4e14e7140803c1d99d0a93cd823d2b024040f9c067a8eca1f5a2eeac9ad604973527a356f3fa3aeff0e4d83" +
+				"0c21708cff382b7f4643c07b105c2eaec2cead93a917d825601e63c8f21f6abd9abc22c93c2bed6f235954b25048bb1a",
+		},
+		{
+			g:   group.P521(),
+			dst: "QUUX-V01-CS02-with-P521_XMD:SHA-512_SSWU_RO_",
+			msg: "",
+			xy: "00fd767cebb2452030358d0e9cf907f525f50920c8f607889a6a35680727f64f4d66b161fafeb2654bea0d35086bec0a10b30b14adef3556ed9f7f1bc23cecc9c088" +
+				"0169ba78d8d851e930680322596e39c78f4fe31b97e57629ef6460ddd68f8763fd7bd767a4e94a80d3d21a3c2ee98347e024fc73ee1c27166dc3fe5eeef782be411d",
+		},
+	} {
+		var e group.Element
+		if tt.encode {
+			e = tt.g.EncodeToElement([]byte(tt.msg), []byte(tt.dst))
+		} else {
+			e = tt.g.HashToElement([]byte(tt.msg), []byte(tt.dst))
+		}
+		if got := hex.EncodeToString(e.Bytes()[1:]); got != tt.xy {
+			t.Errorf("%s(%q) = %s, want %s", tt.dst, tt.msg, got, tt.xy)
+		}
+	}
+}
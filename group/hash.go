@@ -0,0 +1,247 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package group
+
+import (
+	"hash"
+	"math/big"
+)
+
+// expandMessageXMD implements expand_message_xmd from RFC 9380, Section 5.3.1.
+func expandMessageXMD(h func() hash.Hash, msg, dst []byte, length int) []byte {
+	H := h()
+	if len(dst) > 255 {
+		// Section 5.3.3. Using DSTs longer than 255 bytes
+		H.Write([]byte("H2C-OVERSIZE-DST-"))
+		H.Write(dst)
+		dst = H.Sum(nil)
+		H.Reset()
+	}
+	ell := (length + H.Size() - 1) / H.Size()
+	if ell > 255 || length > 65535 {
+		panic("group: expand_message_xmd output too long")
+	}
+	dstPrime := append(dst[:len(dst):len(dst)], byte(len(dst)))
+
+	H.Write(make([]byte, H.BlockSize()))
+	H.Write(msg)
+	H.Write([]byte{byte(length >> 8), byte(length), 0})
+	H.Write(dstPrime)
+	b0 := H.Sum(nil)
+
+	out := make([]byte, 0, ell*H.Size())
+	bi := make([]byte, H.Size())
+	for i := 1; i <= ell; i++ {
+		for j := range bi {
+			bi[j] ^= b0[j]
+		}
+		H.Reset()
+		H.Write(bi)
+		H.Write([]byte{byte(i)})
+		H.Write(dstPrime)
+		bi = H.Sum(bi[:0])
+		out = append(out, bi...)
+	}
+	return out[:length]
+}
+
+// fieldElement is the set of methods shared by the fiat field element types.
+type fieldElement[E any] interface {
+	*E
+	One() *E
+	Set(*E) *E
+	Add(*E, *E) *E
+	Sub(*E, *E) *E
+	Mul(*E, *E) *E
+	Square(*E) *E
+	Invert(*E) *E
+	Select(*E, *E, int) *E
+	Equal(*E) int
+	IsZero() int
+	Bytes() []byte
+	SetBytes([]byte) (*E, error)
+}
+
+// sswuMapper implements hash_to_curve and encode_to_curve from RFC 9380 with
+// the Simplified Shallue-van de Woestijne-Ulas map to a short Weierstrass
+// curve y² = x³ - 3x + b, over the base field of element type E. It's used for
+// the curves that package nistec doesn't implement hash-to-curve for.
+type sswuMapper[E any, F fieldElement[E]] struct {
+	hash func() hash.Hash
+	L    int // hash_to_field output length per element
+	size int // field element length
+
+	a, b, z E
+
+	// Constants for sqrt_ratio, from RFC 9380, Appendix F.2.1.1.
+	c1     int
+	c3, c5 *big.Int
+	c4     *big.Int
+	c6, c7 E
+
+	// shift is 2^(8×(size-1)), for reducing hash_to_field outputs.
+	shift E
+}
+
+func newSSWUMapper[E any, F fieldElement[E]](h func() hash.Hash, p, b *big.Int, z int64) *sswuMapper[E, F] {
+	m := &sswuMapper[E, F]{hash: h, size: (p.BitLen() + 7) / 8}
+	// L = ceil((ceil(log2(p)) + k) / 8), where k = ceil(log2(p)) / 2.
+	m.L = (p.BitLen() + (p.BitLen()+1)/2 + 7) / 8
+
+	setBig := func(e *E, x *big.Int) {
+		x = new(big.Int).Mod(x, p)
+		if _, err := F(e).SetBytes(x.FillBytes(make([]byte, m.size))); err != nil {
+			panic("group: internal error: invalid field constant")
+		}
+	}
+	setBig(&m.a, big.NewInt(-3))
+	setBig(&m.b, b)
+	Z := big.NewInt(z)
+	setBig(&m.z, Z)
+
+	pMinus1 := new(big.Int).Sub(p, big.NewInt(1))
+	m.c1 = int(pMinus1.TrailingZeroBits())
+	c2 := new(big.Int).Rsh(pMinus1, uint(m.c1))
+	m.c3 = new(big.Int).Rsh(c2, 1)
+	m.c4 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(m.c1)), big.NewInt(1))
+	m.c5 = new(big.Int).Lsh(big.NewInt(1), uint(m.c1-1))
+	Z.Mod(Z, p)
+	setBig(&m.c6, new(big.Int).Exp(Z, c2, p))
+	setBig(&m.c7, new(big.Int).Exp(Z, new(big.Int).Rsh(new(big.Int).Add(c2, big.NewInt(1)), 1), p))
+
+	setBig(&m.shift, new(big.Int).Lsh(big.NewInt(1), uint(8*(m.size-1))))
+	return m
+}
+
+// pow sets z = x^e. The exponent is public, so it's ok to branch on its bits.
+func (m *sswuMapper[E, F]) pow(z, x F, e *big.Int) {
+	var r F = new(E)
+	r.One()
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		r.Square(r)
+		if e.Bit(i) == 1 {
+			r.Mul(r, x)
+		}
+	}
+	z.Set(r)
+}
+
+// hashToField implements hash_to_field from RFC 9380, Section 5.2.
+func (m *sswuMapper[E, F]) hashToField(msg, dst []byte, count int) []F {
+	uniform := expandMessageXMD(m.hash, msg, dst, count*m.L)
+	out := make([]F, count)
+	for i := range out {
+		out[i] = m.reduce(uniform[i*m.L : (i+1)*m.L])
+	}
+	return out
+}
+
+// reduce returns the big-endian integer b modulo p.
+func (m *sswuMapper[E, F]) reduce(b []byte) F {
+	// Horner's method over chunks of size-1 bytes, each smaller than p.
+	var acc, t F = new(E), new(E)
+	buf := make([]byte, m.size)
+	for len(b) > 0 {
+		k := len(b) % (m.size - 1)
+		if k == 0 {
+			k = m.size - 1
+		}
+		for i := range buf {
+			buf[i] = 0
+		}
+		copy(buf[m.size-k:], b[:k])
+		b = b[k:]
+		if _, err := t.SetBytes(buf); err != nil {
+			panic("group: internal error: chunk larger than p")
+		}
+		acc.Mul(acc, &m.shift)
+		acc.Add(acc, t)
+	}
+	return acc
+}
+
+func (m *sswuMapper[E, F]) sgn0(x F) int {
+	b := x.Bytes()
+	return int(b[len(b)-1] & 1)
+}
+
+// sqrtRatio implements sqrt_ratio from RFC 9380, Appendix F.2.1.1. It sets z
+// to sqrt(u/v) and returns 1 if u/v is square, and sets z to sqrt(Z×u/v) and
+// returns 0 otherwise.
+func (m *sswuMapper[E, F]) sqrtRatio(z, u, v F) int {
+	var tv1, tv2, tv3, tv4, tv5 F = new(E), new(E), new(E), new(E), new(E)
+	tv1.Set(&m.c6)
+	m.pow(tv2, v, m.c4)
+	tv3.Square(tv2)
+	tv3.Mul(tv3, v)
+	tv5.Mul(u, tv3)
+	m.pow(tv5, tv5, m.c3)
+	tv5.Mul(tv5, tv2)
+	tv2.Mul(tv5, v)
+	tv3.Mul(tv5, u)
+	tv4.Mul(tv3, tv2)
+	m.pow(tv5, tv4, m.c5)
+	isQR := tv5.Equal(F(new(E)).One())
+	tv2.Mul(tv3, &m.c7)
+	tv5.Mul(tv4, tv1)
+	tv3.Select(tv3, tv2, isQR)
+	tv4.Select(tv4, tv5, isQR)
+	for i := m.c1; i >= 2; i-- {
+		tv5.Set(tv4)
+		for j := 0; j < i-2; j++ {
+			tv5.Square(tv5)
+		}
+		e1 := tv5.Equal(F(new(E)).One())
+		tv2.Mul(tv3, tv1)
+		tv1.Square(tv1)
+		tv5.Mul(tv4, tv1)
+		tv3.Select(tv3, tv2, e1)
+		tv4.Select(tv4, tv5, e1)
+	}
+	z.Set(tv3)
+	return isQR
+}
+
+// mapToCurve implements the Simplified SWU method from RFC 9380, Section
+// 6.6.2, and returns the uncompressed encoding of the resulting point.
+func (m *sswuMapper[E, F]) mapToCurve(u F) []byte {
+	var tv1, tv2, tv3, tv4, tv5, tv6 F = new(E), new(E), new(E), new(E), new(E), new(E)
+	var x, y, y1 F = new(E), new(E), new(E)
+	var A, B, Z F = &m.a, &m.b, &m.z
+
+	tv1.Square(u)
+	tv1.Mul(Z, tv1)
+	tv2.Square(tv1)
+	tv2.Add(tv2, tv1)
+	tv3.Add(tv2, F(new(E)).One())
+	tv3.Mul(B, tv3)
+	tv4.Sub(new(E), tv2)
+	tv4.Select(Z, tv4, tv2.IsZero())
+	tv4.Mul(A, tv4)
+	tv2.Square(tv3)
+	tv6.Square(tv4)
+	tv5.Mul(A, tv6)
+	tv2.Add(tv2, tv5)
+	tv2.Mul(tv2, tv3)
+	tv6.Mul(tv6, tv4)
+	tv5.Mul(B, tv6)
+	tv2.Add(tv2, tv5)
+	x.Mul(tv1, tv3)
+	isGx1Square := m.sqrtRatio(y1, tv2, tv6)
+	y.Mul(tv1, u)
+	y.Mul(y, y1)
+	x.Select(tv3, x, isGx1Square)
+	y.Select(y1, y, isGx1Square)
+	e1 := 1 ^ m.sgn0(u) ^ m.sgn0(y)
+	tv5.Sub(new(E), y)
+	y.Select(y, tv5, e1)
+	tv4.Invert(tv4)
+	x.Mul(x, tv4)
+
+	out := make([]byte, 1, 1+2*m.size)
+	out[0] = 4
+	out = append(out, x.Bytes()...)
+	return append(out, y.Bytes()...)
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package group
+
+import (
+	"encoding/hex"
+	"errors"
+)
+
+// appendHex appends the lowercase hexadecimal encoding of src to b.
+func appendHex(b, src []byte) []byte {
+	n := len(b)
+	b = append(b, make([]byte, hex.EncodedLen(len(src)))...)
+	hex.Encode(b[n:], src)
+	return b
+}
+
+func decodeHex(text []byte) ([]byte, error) {
+	b := make([]byte, hex.DecodedLen(len(text)))
+	if _, err := hex.Decode(b, text); err != nil {
+		return nil, errors.New("group: invalid text encoding: " + err.Error())
+	}
+	return b, nil
+}
+
+func (e *element[P]) MarshalBinary() ([]byte, error) {
+	return e.p.Bytes(), nil
+}
+
+func (e *element[P]) AppendBinary(b []byte) ([]byte, error) {
+	return e.p.AppendBinary(b)
+}
+
+func (e *element[P]) UnmarshalBinary(b []byte) error {
+	_, err := e.p.SetBytes(b)
+	return err
+}
+
+func (e *element[P]) MarshalText() ([]byte, error) {
+	return e.p.AppendText(nil)
+}
+
+func (e *element[P]) UnmarshalText(text []byte) error {
+	b, err := decodeHex(text)
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalBinary(b)
+}
+
+func (s *scalar) MarshalBinary() ([]byte, error) {
+	return s.Bytes(), nil
+}
+
+func (s *scalar) AppendBinary(b []byte) ([]byte, error) {
+	return s.g.sf.appendBytes(b, &s.v), nil
+}
+
+func (s *scalar) UnmarshalBinary(b []byte) error {
+	_, err := s.SetBytes(b)
+	return err
+}
+
+func (s *scalar) MarshalText() ([]byte, error) {
+	var out [maxLimbs * 8]byte
+	return appendHex(nil, s.g.sf.appendBytes(out[:0], &s.v)), nil
+}
+
+func (s *scalar) UnmarshalText(text []byte) error {
+	b, err := decodeHex(text)
+	if err != nil {
+		return err
+	}
+	return s.UnmarshalBinary(b)
+}
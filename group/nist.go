@@ -0,0 +1,292 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package group
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"hash"
+	"io"
+	"sync"
+
+	"github.com/magical/nistec-extra"
+	"github.com/magical/nistec-extra/internal/fiat"
+)
+
+// nistPoint is the set of methods shared by the nistec point types.
+type nistPoint[T any] interface {
+	Set(T) T
+	SetGenerator() T
+	SetBytes([]byte) (T, error)
+	Bytes() []byte
+	BytesCompressed() []byte
+	Add(T, T) T
+	Double(T) T
+	Negate(T) T
+	ScalarMult(T, []byte) (T, error)
+	ScalarBaseMult([]byte) (T, error)
+	Select(T, T, int) T
+	AppendBinary([]byte) ([]byte, error)
+	AppendText([]byte) ([]byte, error)
+}
+
+// wGroupParams holds the parts of a wGroup that don't depend on the point type.
+type wGroupParams struct {
+	name  string
+	group Group
+	sf    *scalarField
+
+	elementLength int
+
+	// mapToCurve hashes msg to count field elements with hash_to_field, and
+	// returns the uncompressed encodings of their images through the SSWU map.
+	mapToCurve func(msg, dst []byte, count int) [][]byte
+	hash       func() hash.Hash
+	hashLen    int // hash_to_field output length for scalars
+}
+
+// wGroup implements Group for a nistec point type.
+type wGroup[P nistPoint[P]] struct {
+	wGroupParams
+	newPoint func() P
+}
+
+var p224, p256, p384, p521 Group
+var p224Once, p256Once, p384Once, p521Once sync.Once
+
+// P224 returns a Group implementing P-224.
+//
+// P-224 is not one of the curves specified by RFC 9380. HashToElement and
+// EncodeToElement use the same construction as the other curves, with SHA-224
+// and the SSWU parameter Z = 31, selected with the procedure of RFC 9380,
+// Appendix H.2.
+func P224() Group {
+	p224Once.Do(func() {
+		p224 = newSSWUGroup("P-224", nistec.NewP224Point, elliptic.P224(),
+			newSSWUMapper[fiat.P224Element](sha256.New224, elliptic.P224().Params().P, elliptic.P224().Params().B, 31))
+	})
+	return p224
+}
+
+// P256 returns a Group implementing P-256. HashToElement implements the
+// P256_XMD:SHA-256_SSWU_RO_ suite of RFC 9380, and EncodeToElement implements
+// P256_XMD:SHA-256_SSWU_NU_.
+func P256() Group {
+	p256Once.Do(func() {
+		p256 = newGroup("P-256", nistec.NewP256Point, elliptic.P256(), sha256.New, 48, p256MapToCurve)
+	})
+	return p256
+}
+
+// P384 returns a Group implementing P-384. HashToElement implements the
+// P384_XMD:SHA-384_SSWU_RO_ suite of RFC 9380, and EncodeToElement implements
+// P384_XMD:SHA-384_SSWU_NU_.
+func P384() Group {
+	p384Once.Do(func() {
+		p384 = newSSWUGroup("P-384", nistec.NewP384Point, elliptic.P384(),
+			newSSWUMapper[fiat.P384Element](sha512.New384, elliptic.P384().Params().P, elliptic.P384().Params().B, -12))
+	})
+	return p384
+}
+
+// P521 returns a Group implementing P-521. HashToElement implements the
+// P521_XMD:SHA-512_SSWU_RO_ suite of RFC 9380, and EncodeToElement implements
+// P521_XMD:SHA-512_SSWU_NU_.
+func P521() Group {
+	p521Once.Do(func() {
+		p521 = newSSWUGroup("P-521", nistec.NewP521Point, elliptic.P521(),
+			newSSWUMapper[fiat.P521Element](sha512.New, elliptic.P521().Params().P, elliptic.P521().Params().B, -4))
+	})
+	return p521
+}
+
+// newSSWUGroup returns a Group whose hash-to-curve operations use the generic
+// SSWU implementation m.
+func newSSWUGroup[P nistPoint[P], E any, F fieldElement[E]](name string, newPoint func() P, c elliptic.Curve, m *sswuMapper[E, F]) Group {
+	return newGroup(name, newPoint, c, m.hash, m.L, func(msg, dst []byte, count int) [][]byte {
+		var out [][]byte
+		for _, u := range m.hashToField(msg, dst, count) {
+			out = append(out, m.mapToCurve(u))
+		}
+		return out
+	})
+}
+
+func newGroup[P nistPoint[P]](name string, newPoint func() P, c elliptic.Curve, h func() hash.Hash, L int, mapToCurve func(msg, dst []byte, count int) [][]byte) Group {
+	g := &wGroup[P]{newPoint: newPoint}
+	g.name = name
+	g.group = g
+	g.sf = newScalarField(c.Params().N)
+	g.elementLength = 1 + (c.Params().BitSize+7)/8
+	g.hash = h
+	g.hashLen = L
+	g.mapToCurve = mapToCurve
+	return g
+}
+
+// p256MapToCurve implements mapToCurve for P-256 with the SSWU map of package
+// nistec, which takes the 48-byte hash_to_field inputs directly.
+func p256MapToCurve(msg, dst []byte, count int) [][]byte {
+	uniform := expandMessageXMD(sha256.New, msg, dst, count*48)
+	out := make([][]byte, count)
+	for i := range out {
+		q, err := nistec.P256MapToCurve(uniform[i*48 : (i+1)*48])
+		if err != nil {
+			panic("group: internal error: P256MapToCurve failed")
+		}
+		out[i] = q.Bytes()
+	}
+	return out
+}
+
+func (g *wGroup[P]) Name() string { return g.name }
+
+func (g *wGroup[P]) NewElement() Element {
+	return &element[P]{g: g, p: g.newPoint()}
+}
+
+func (g *wGroup[P]) NewScalar() Scalar {
+	return &scalar{g: &g.wGroupParams}
+}
+
+func (g *wGroup[P]) ElementLength() int { return g.elementLength }
+
+func (g *wGroup[P]) ScalarLength() int { return g.sf.byteLen }
+
+func (g *wGroup[P]) Order() []byte {
+	return append([]byte(nil), g.sf.order...)
+}
+
+func (g *wGroup[P]) RandomScalar(rand io.Reader) (Scalar, error) {
+	s := &scalar{g: &g.wGroupParams}
+	buf := make([]byte, g.sf.byteLen)
+	for {
+		if _, err := io.ReadFull(rand, buf); err != nil {
+			return nil, err
+		}
+		// Mask off any excess bits, so that P-521 scalars aren't rejected most
+		// of the time.
+		if excess := len(buf)*8 - g.sf.bitLen; excess > 0 {
+			buf[0] &= 1<<(8-excess) - 1
+		}
+		if err := g.sf.setBytes(&s.v, buf); err == nil && s.IsZero() == 0 {
+			return s, nil
+		}
+	}
+}
+
+func (g *wGroup[P]) HashToElement(msg, dst []byte) Element {
+	var q0, q1 P = g.newPoint(), g.newPoint()
+	encs := g.mapToCurve(msg, dst, 2)
+	if _, err := q0.SetBytes(encs[0]); err != nil {
+		panic("group: internal error: SSWU map returned an invalid point")
+	}
+	if _, err := q1.SetBytes(encs[1]); err != nil {
+		panic("group: internal error: SSWU map returned an invalid point")
+	}
+	// The cofactor is 1, so clear_cofactor is a no-op.
+	return &element[P]{g: g, p: q0.Add(q0, q1)}
+}
+
+func (g *wGroup[P]) EncodeToElement(msg, dst []byte) Element {
+	q := g.newPoint()
+	if _, err := q.SetBytes(g.mapToCurve(msg, dst, 1)[0]); err != nil {
+		panic("group: internal error: SSWU map returned an invalid point")
+	}
+	return &element[P]{g: g, p: q}
+}
+
+func (g *wGroup[P]) HashToScalar(msg, dst []byte) Scalar {
+	s := &scalar{g: &g.wGroupParams}
+	g.sf.setReducedBytes(&s.v, expandMessageXMD(g.hash, msg, dst, g.hashLen))
+	return s
+}
+
+// element implements Element for a nistec point type.
+type element[P nistPoint[P]] struct {
+	g *wGroup[P]
+	p P
+}
+
+func (g *wGroup[P]) element(q Element) P {
+	e, ok := q.(*element[P])
+	if !ok || e.g != g {
+		panic("group: mixing elements of different groups")
+	}
+	return e.p
+}
+
+func (e *element[P]) Group() Group { return e.g }
+
+func (e *element[P]) Set(q Element) Element {
+	e.p.Set(e.g.element(q))
+	return e
+}
+
+func (e *element[P]) SetGenerator() Element {
+	e.p.SetGenerator()
+	return e
+}
+
+func (e *element[P]) SetBytes(b []byte) (Element, error) {
+	if _, err := e.p.SetBytes(b); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *element[P]) Bytes() []byte { return e.p.Bytes() }
+
+func (e *element[P]) BytesCompressed() []byte { return e.p.BytesCompressed() }
+
+func (e *element[P]) Add(q1, q2 Element) Element {
+	e.p.Add(e.g.element(q1), e.g.element(q2))
+	return e
+}
+
+func (e *element[P]) Subtract(q1, q2 Element) Element {
+	t := e.g.newPoint().Negate(e.g.element(q2))
+	e.p.Add(e.g.element(q1), t)
+	return e
+}
+
+func (e *element[P]) Double(q Element) Element {
+	e.p.Double(e.g.element(q))
+	return e
+}
+
+func (e *element[P]) Negate(q Element) Element {
+	e.p.Negate(e.g.element(q))
+	return e
+}
+
+func (e *element[P]) ScalarMult(q Element, s Scalar) Element {
+	if _, err := e.p.ScalarMult(e.g.element(q), e.g.scalar(s).Bytes()); err != nil {
+		panic("group: internal error: ScalarMult failed: " + err.Error())
+	}
+	return e
+}
+
+func (e *element[P]) ScalarBaseMult(s Scalar) Element {
+	if _, err := e.p.ScalarBaseMult(e.g.scalar(s).Bytes()); err != nil {
+		panic("group: internal error: ScalarBaseMult failed: " + err.Error())
+	}
+	return e
+}
+
+func (e *element[P]) Select(q1, q2 Element, cond int) Element {
+	e.p.Select(e.g.element(q1), e.g.element(q2), cond)
+	return e
+}
+
+func (e *element[P]) Equal(q Element) int {
+	return subtle.ConstantTimeCompare(e.p.Bytes(), e.g.element(q).Bytes())
+}
+
+func (e *element[P]) IsIdentity() int {
+	return subtle.ConstantTimeEq(int32(len(e.p.Bytes())), 1)
+}
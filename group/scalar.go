@@ -0,0 +1,326 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package group
+
+import (
+	"errors"
+	"math/big"
+	"math/bits"
+)
+
+// maxLimbs is the number of 64-bit limbs needed for the largest order, that of
+// P-521.
+const maxLimbs = 9
+
+type limbs [maxLimbs]uint64
+
+// scalarField implements constant-time arithmetic modulo an odd order n, using
+// Montgomery multiplication with R = 2^(64×nLimbs). Values are kept in
+// [0, n-1] as little-endian limbs, outside the Montgomery domain.
+type scalarField struct {
+	n       limbs
+	nLimbs  int
+	bitLen  int
+	byteLen int
+	order   []byte // n, big-endian, byteLen bytes
+	nMinus2 *big.Int
+
+	n0inv uint64 // -n⁻¹ mod 2⁶⁴
+	rr    limbs  // R² mod n
+	r64   limbs  // 2⁶⁴×R mod n, the Montgomery representation of 2⁶⁴
+	one   limbs
+}
+
+func newScalarField(n *big.Int) *scalarField {
+	f := &scalarField{
+		nLimbs:  (n.BitLen() + 63) / 64,
+		bitLen:  n.BitLen(),
+		byteLen: (n.BitLen() + 7) / 8,
+		nMinus2: new(big.Int).Sub(n, big.NewInt(2)),
+	}
+	f.order = n.FillBytes(make([]byte, f.byteLen))
+	f.n = bigToLimbs(n)
+
+	// Newton's iteration doubles the number of correct bits at each step,
+	// starting from three correct bits since n is odd.
+	inv := f.n[0]
+	for i := 0; i < 5; i++ {
+		inv *= 2 - f.n[0]*inv
+	}
+	f.n0inv = -inv
+
+	rr := new(big.Int).Lsh(big.NewInt(1), uint(2*64*f.nLimbs))
+	f.rr = bigToLimbs(rr.Mod(rr, n))
+	f.one[0] = 1
+	var x limbs
+	x[1] = 1
+	f.montMul(&f.r64, &x, &f.rr)
+	return f
+}
+
+func bigToLimbs(x *big.Int) limbs {
+	var l limbs
+	b := x.FillBytes(make([]byte, maxLimbs*8))
+	for i := range l {
+		for j := 0; j < 8; j++ {
+			l[i] |= uint64(b[len(b)-1-i*8-j]) << (8 * j)
+		}
+	}
+	return l
+}
+
+// add sets z = x + y mod n.
+func (f *scalarField) add(z, x, y *limbs) {
+	var t, u limbs
+	var c, b uint64
+	for i := 0; i < f.nLimbs; i++ {
+		t[i], c = bits.Add64(x[i], y[i], c)
+	}
+	for i := 0; i < f.nLimbs; i++ {
+		u[i], b = bits.Sub64(t[i], f.n[i], b)
+	}
+	// If x + y overflowed, then c and b are both 1, and u is correct. If it
+	// didn't, u is correct if x + y >= n, that is, if b is 0.
+	f.sel(z, &u, &t, int(1^c^b))
+}
+
+// sub sets z = x - y mod n.
+func (f *scalarField) sub(z, x, y *limbs) {
+	var b, c uint64
+	for i := 0; i < f.nLimbs; i++ {
+		z[i], b = bits.Sub64(x[i], y[i], b)
+	}
+	mask := -b
+	for i := 0; i < f.nLimbs; i++ {
+		z[i], c = bits.Add64(z[i], f.n[i]&mask, c)
+	}
+}
+
+// montMul sets z = x × y × R⁻¹ mod n, with the Coarsely Integrated Operand
+// Scanning method.
+func (f *scalarField) montMul(z, x, y *limbs) {
+	var t [maxLimbs + 2]uint64
+	L := f.nLimbs
+	for i := 0; i < L; i++ {
+		var c, cc, hi, lo uint64
+		for j := 0; j < L; j++ {
+			hi, lo = bits.Mul64(x[j], y[i])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j], c = lo, hi
+		}
+		t[L], cc = bits.Add64(t[L], c, 0)
+		t[L+1] = cc
+
+		m := t[0] * f.n0inv
+		hi, lo = bits.Mul64(m, f.n[0])
+		_, cc = bits.Add64(lo, t[0], 0)
+		c = hi + cc
+		for j := 1; j < L; j++ {
+			hi, lo = bits.Mul64(m, f.n[j])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j-1], c = lo, hi
+		}
+		t[L-1], cc = bits.Add64(t[L], c, 0)
+		t[L] = t[L+1] + cc
+	}
+
+	// t < 2n, so a single conditional subtraction is enough.
+	var u limbs
+	var b uint64
+	for i := 0; i < L; i++ {
+		u[i], b = bits.Sub64(t[i], f.n[i], b)
+	}
+	var r limbs
+	copy(r[:L], t[:L])
+	f.sel(z, &u, &r, int(1^t[L]^b))
+}
+
+// mul sets z = x × y mod n.
+func (f *scalarField) mul(z, x, y *limbs) {
+	f.montMul(z, x, y)
+	f.montMul(z, z, &f.rr)
+}
+
+// inv sets z = x⁻¹ mod n, or zero if x is zero.
+func (f *scalarField) inv(z, x *limbs) {
+	// Exponentiation by n - 2, per Fermat's little theorem. The exponent is
+	// public, so branching on its bits doesn't leak anything about x.
+	var a, r limbs
+	f.montMul(&a, x, &f.rr)
+	f.montMul(&r, &f.one, &f.rr)
+	for i := f.nMinus2.BitLen() - 1; i >= 0; i-- {
+		f.montMul(&r, &r, &r)
+		if f.nMinus2.Bit(i) == 1 {
+			f.montMul(&r, &r, &a)
+		}
+	}
+	f.montMul(z, &r, &f.one)
+}
+
+// sel sets z to a if cond == 1, and to b if cond == 0.
+func (f *scalarField) sel(z, a, b *limbs, cond int) {
+	mask := -uint64(cond)
+	for i := range z {
+		z[i] = a[i]&mask | b[i]&^mask
+	}
+}
+
+func (f *scalarField) equal(x, y *limbs) int {
+	var acc uint64
+	for i := range x {
+		acc |= x[i] ^ y[i]
+	}
+	return isZero(acc)
+}
+
+func isZero(x uint64) int {
+	return int(1 ^ (x|-x)>>63)
+}
+
+// setReducedBytes sets z to the big-endian integer b modulo n.
+func (f *scalarField) setReducedBytes(z *limbs, b []byte) {
+	// Horner's method over 64-bit words, each of which is smaller than n.
+	var acc, w limbs
+	for len(b) > 0 {
+		k := len(b) % 8
+		if k == 0 {
+			k = 8
+		}
+		w[0] = 0
+		for _, c := range b[:k] {
+			w[0] = w[0]<<8 | uint64(c)
+		}
+		b = b[k:]
+		f.montMul(&acc, &acc, &f.r64)
+		f.add(&acc, &acc, &w)
+	}
+	*z = acc
+}
+
+// setBytes sets z to the big-endian integer b, which must be byteLen bytes and
+// smaller than n.
+func (f *scalarField) setBytes(z *limbs, b []byte) error {
+	if len(b) != f.byteLen {
+		return errors.New("invalid scalar length")
+	}
+	var x limbs
+	for i, c := range b {
+		j := len(b) - 1 - i
+		x[j/8] |= uint64(c) << (8 * (j % 8))
+	}
+	var borrow uint64
+	for i := 0; i < f.nLimbs; i++ {
+		_, borrow = bits.Sub64(x[i], f.n[i], borrow)
+	}
+	if borrow == 0 {
+		return errors.New("invalid scalar encoding")
+	}
+	*z = x
+	return nil
+}
+
+func (f *scalarField) bytes(x *limbs) []byte {
+	return f.appendBytes(make([]byte, 0, f.byteLen), x)
+}
+
+// appendBytes appends the byteLen bytes big-endian encoding of x to b.
+func (f *scalarField) appendBytes(b []byte, x *limbs) []byte {
+	for i := 0; i < f.byteLen; i++ {
+		j := f.byteLen - 1 - i
+		b = append(b, byte(x[j/8]>>(8*(j%8))))
+	}
+	return b
+}
+
+// scalar implements Scalar for a wGroup.
+type scalar struct {
+	g *wGroupParams
+	v limbs
+}
+
+func (g *wGroupParams) scalar(x Scalar) *scalar {
+	s, ok := x.(*scalar)
+	if !ok || s.g != g {
+		panic("group: mixing scalars of different groups")
+	}
+	return s
+}
+
+func (s *scalar) Group() Group { return s.g.group }
+
+func (s *scalar) Set(x Scalar) Scalar {
+	s.v = s.g.scalar(x).v
+	return s
+}
+
+func (s *scalar) SetUint64(x uint64) Scalar {
+	var v limbs
+	v[0] = x
+	// Every order has more than 64 bits, so x is already reduced.
+	s.v = v
+	return s
+}
+
+func (s *scalar) SetBytes(b []byte) (Scalar, error) {
+	if err := s.g.sf.setBytes(&s.v, b); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *scalar) SetReducedBytes(b []byte) Scalar {
+	s.g.sf.setReducedBytes(&s.v, b)
+	return s
+}
+
+func (s *scalar) Bytes() []byte {
+	return s.g.sf.bytes(&s.v)
+}
+
+func (s *scalar) Add(x, y Scalar) Scalar {
+	s.g.sf.add(&s.v, &s.g.scalar(x).v, &s.g.scalar(y).v)
+	return s
+}
+
+func (s *scalar) Subtract(x, y Scalar) Scalar {
+	s.g.sf.sub(&s.v, &s.g.scalar(x).v, &s.g.scalar(y).v)
+	return s
+}
+
+func (s *scalar) Multiply(x, y Scalar) Scalar {
+	s.g.sf.mul(&s.v, &s.g.scalar(x).v, &s.g.scalar(y).v)
+	return s
+}
+
+func (s *scalar) Negate(x Scalar) Scalar {
+	var zero limbs
+	s.g.sf.sub(&s.v, &zero, &s.g.scalar(x).v)
+	return s
+}
+
+func (s *scalar) Invert(x Scalar) Scalar {
+	s.g.sf.inv(&s.v, &s.g.scalar(x).v)
+	return s
+}
+
+func (s *scalar) Select(x, y Scalar, cond int) Scalar {
+	s.g.sf.sel(&s.v, &s.g.scalar(x).v, &s.g.scalar(y).v, cond)
+	return s
+}
+
+func (s *scalar) Equal(x Scalar) int {
+	return s.g.sf.equal(&s.v, &s.g.scalar(x).v)
+}
+
+func (s *scalar) IsZero() int {
+	var zero limbs
+	return s.g.sf.equal(&s.v, &zero)
+}
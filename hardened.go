@@ -0,0 +1,317 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+
+	"github.com/magical/nistec-extra/internal/fiat"
+)
+
+// The orders of the prime order groups, as big-endian byte strings of the same
+// length as the scalars accepted by ScalarMult and ScalarBaseMult.
+var (
+	p224Order = []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x16, 0xa2, 0xe0, 0xb8, 0xf0, 0x3e, 0x13, 0xdd, 0x29, 0x45, 0x5c, 0x5c, 0x2a, 0x3d}
+	p256Order = []byte{0xff, 0xff, 0xff, 0xff, 0x0, 0x0, 0x0, 0x0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xbc, 0xe6, 0xfa, 0xad, 0xa7, 0x17, 0x9e, 0x84, 0xf3, 0xb9, 0xca, 0xc2, 0xfc, 0x63, 0x25, 0x51}
+	p384Order = []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xc7, 0x63, 0x4d, 0x81, 0xf4, 0x37, 0x2d, 0xdf, 0x58, 0x1a, 0xd, 0xb2, 0x48, 0xb0, 0xa7, 0x7a, 0xec, 0xec, 0x19, 0x6a, 0xcc, 0xc5, 0x29, 0x73}
+	p521Order = []byte{0x1, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfa, 0x51, 0x86, 0x87, 0x83, 0xbf, 0x2f, 0x96, 0x6b, 0x7f, 0xcc, 0x1, 0x48, 0xf7, 0x9, 0xa5, 0xd0, 0x3b, 0xb5, 0xc9, 0xb8, 0x89, 0x9c, 0x47, 0xae, 0xbb, 0x6f, 0xb7, 0x1e, 0x91, 0x38, 0x64, 0x9}
+)
+
+// blindScalar sets out to the big-endian encoding of k + r×n, where r is a
+// random 63-bit value read from rand. k and n must have the same length, and
+// out must be eight bytes longer. Since [n]P is the identity for every point P,
+// [k + r×n]P = [k]P, but the bits of k + r×n are unpredictable.
+func blindScalar(out, k, n []byte, rand io.Reader) error {
+	if len(k) != len(n) || len(out) != len(k)+8 {
+		panic("nistec: internal error: blindScalar called with wrong lengths")
+	}
+	var buf [8]byte
+	if _, err := io.ReadFull(rand, buf[:]); err != nil {
+		return err
+	}
+	r := binary.BigEndian.Uint64(buf[:]) >> 1
+
+	// k + r×n < 2^(8×len(k)) × (1 + r) <= 2^(8×len(k) + 63), so it fits. Each
+	// intermediate value is at most 2^63 × 255 + 255 + 2^64, so it fits in 72
+	// bits, and the carry into the next byte fits in 64 bits.
+	var carry uint64
+	for i := len(k) - 1; i >= 0; i-- {
+		hi, lo := bits.Mul64(r, uint64(n[i]))
+		var c uint64
+		lo, c = bits.Add64(lo, uint64(k[i]), 0)
+		hi += c
+		lo, c = bits.Add64(lo, carry, 0)
+		hi += c
+		out[i+8] = byte(lo)
+		carry = lo>>8 | hi<<56
+	}
+	binary.BigEndian.PutUint64(out[:8], carry)
+	return nil
+}
+
+// ScalarMultHardened sets p = scalar * q, and returns p. The result is the same
+// as ScalarMult, but the computation is blinded with randomness read from rand
+// as a countermeasure against differential power analysis and template attacks:
+// the projective coordinates of q and of every precomputed multiple are
+// randomized, and the scalar k is replaced by k + r×N for a random 63-bit r,
+// where N is the order of the group.
+//
+// If scalar is not 28 bytes long, or reading from rand fails, ScalarMultHardened
+// returns an error and the receiver is unchanged.
+func (p *P224Point) ScalarMultHardened(q *P224Point, scalar []byte, rand io.Reader) (*P224Point, error) {
+	if len(scalar) != p224ElementLength {
+		return nil, errors.New("invalid scalar length")
+	}
+	var blinded [p224ElementLength + 8]byte
+	if err := blindScalar(blinded[:], scalar, p224Order, rand); err != nil {
+		return nil, err
+	}
+
+	// Compute a p224Table for a randomized copy of the base point q, and then
+	// randomize each entry independently.
+	var table = p224Table{NewP224Point(), NewP224Point(), NewP224Point(),
+		NewP224Point(), NewP224Point(), NewP224Point(), NewP224Point(),
+		NewP224Point(), NewP224Point(), NewP224Point(), NewP224Point(),
+		NewP224Point(), NewP224Point(), NewP224Point(), NewP224Point()}
+	base := NewP224Point().Set(q)
+	if err := base.randomize(rand); err != nil {
+		return nil, err
+	}
+	table[0].Set(base)
+	for i := 1; i < 15; i += 2 {
+		table[i].Double(table[i/2])
+		table[i+1].Add(table[i], base)
+	}
+	for i := range table {
+		if err := table[i].randomize(rand); err != nil {
+			return nil, err
+		}
+	}
+
+	t := NewP224Point()
+	acc := NewP224Point()
+	for i, byte := range blinded {
+		if i != 0 {
+			acc.Double(acc)
+			acc.Double(acc)
+			acc.Double(acc)
+			acc.Double(acc)
+		}
+
+		windowValue := byte >> 4
+		table.Select(t, windowValue)
+		acc.Add(acc, t)
+
+		acc.Double(acc)
+		acc.Double(acc)
+		acc.Double(acc)
+		acc.Double(acc)
+
+		windowValue = byte & 0b1111
+		table.Select(t, windowValue)
+		acc.Add(acc, t)
+	}
+
+	return p.Set(acc), nil
+}
+
+// randomize sets the projective coordinates (X:Y:Z) of p to (λX:λY:λZ) for a
+// random non-zero λ read from rand, which represents the same point.
+func (p *P224Point) randomize(rand io.Reader) error {
+	var buf [p224ElementLength]byte
+	lambda := new(fiat.P224Element)
+	// Give up after a bounded number of attempts, which only fails if rand
+	// is broken, rather than looping forever.
+	for i := 0; i < 256; i++ {
+		if _, err := io.ReadFull(rand, buf[:]); err != nil {
+			return err
+		}
+		if _, err := lambda.SetBytes(buf[:]); err == nil && lambda.IsZero() == 0 {
+			p.x.Mul(p.x, lambda)
+			p.y.Mul(p.y, lambda)
+			p.z.Mul(p.z, lambda)
+			return nil
+		}
+	}
+	return errors.New("P224 point randomization failed")
+}
+
+// ScalarMultHardened sets p = scalar * q, and returns p. The result is the same
+// as ScalarMult, but the computation is blinded with randomness read from rand
+// as a countermeasure against differential power analysis and template attacks:
+// the projective coordinates of q and of every precomputed multiple are
+// randomized, and the scalar k is replaced by k + r×N for a random 63-bit r,
+// where N is the order of the group.
+//
+// If scalar is not 48 bytes long, or reading from rand fails, ScalarMultHardened
+// returns an error and the receiver is unchanged.
+func (p *P384Point) ScalarMultHardened(q *P384Point, scalar []byte, rand io.Reader) (*P384Point, error) {
+	if len(scalar) != p384ElementLength {
+		return nil, errors.New("invalid scalar length")
+	}
+	var blinded [p384ElementLength + 8]byte
+	if err := blindScalar(blinded[:], scalar, p384Order, rand); err != nil {
+		return nil, err
+	}
+
+	// Compute a p384Table for a randomized copy of the base point q, and then
+	// randomize each entry independently.
+	var table = p384Table{NewP384Point(), NewP384Point(), NewP384Point(),
+		NewP384Point(), NewP384Point(), NewP384Point(), NewP384Point(),
+		NewP384Point(), NewP384Point(), NewP384Point(), NewP384Point(),
+		NewP384Point(), NewP384Point(), NewP384Point(), NewP384Point()}
+	base := NewP384Point().Set(q)
+	if err := base.randomize(rand); err != nil {
+		return nil, err
+	}
+	table[0].Set(base)
+	for i := 1; i < 15; i += 2 {
+		table[i].Double(table[i/2])
+		table[i+1].Add(table[i], base)
+	}
+	for i := range table {
+		if err := table[i].randomize(rand); err != nil {
+			return nil, err
+		}
+	}
+
+	t := NewP384Point()
+	acc := NewP384Point()
+	for i, byte := range blinded {
+		if i != 0 {
+			acc.Double(acc)
+			acc.Double(acc)
+			acc.Double(acc)
+			acc.Double(acc)
+		}
+
+		windowValue := byte >> 4
+		table.Select(t, windowValue)
+		acc.Add(acc, t)
+
+		acc.Double(acc)
+		acc.Double(acc)
+		acc.Double(acc)
+		acc.Double(acc)
+
+		windowValue = byte & 0b1111
+		table.Select(t, windowValue)
+		acc.Add(acc, t)
+	}
+
+	return p.Set(acc), nil
+}
+
+// randomize sets the projective coordinates (X:Y:Z) of p to (λX:λY:λZ) for a
+// random non-zero λ read from rand, which represents the same point.
+func (p *P384Point) randomize(rand io.Reader) error {
+	var buf [p384ElementLength]byte
+	lambda := new(fiat.P384Element)
+	// Give up after a bounded number of attempts, which only fails if rand
+	// is broken, rather than looping forever.
+	for i := 0; i < 256; i++ {
+		if _, err := io.ReadFull(rand, buf[:]); err != nil {
+			return err
+		}
+		if _, err := lambda.SetBytes(buf[:]); err == nil && lambda.IsZero() == 0 {
+			p.x.Mul(p.x, lambda)
+			p.y.Mul(p.y, lambda)
+			p.z.Mul(p.z, lambda)
+			return nil
+		}
+	}
+	return errors.New("P384 point randomization failed")
+}
+
+// ScalarMultHardened sets p = scalar * q, and returns p. The result is the same
+// as ScalarMult, but the computation is blinded with randomness read from rand
+// as a countermeasure against differential power analysis and template attacks:
+// the projective coordinates of q and of every precomputed multiple are
+// randomized, and the scalar k is replaced by k + r×N for a random 63-bit r,
+// where N is the order of the group.
+//
+// If scalar is not 66 bytes long, or reading from rand fails, ScalarMultHardened
+// returns an error and the receiver is unchanged.
+func (p *P521Point) ScalarMultHardened(q *P521Point, scalar []byte, rand io.Reader) (*P521Point, error) {
+	if len(scalar) != p521ElementLength {
+		return nil, errors.New("invalid scalar length")
+	}
+	var blinded [p521ElementLength + 8]byte
+	if err := blindScalar(blinded[:], scalar, p521Order, rand); err != nil {
+		return nil, err
+	}
+
+	// Compute a p521Table for a randomized copy of the base point q, and then
+	// randomize each entry independently.
+	var table = p521Table{NewP521Point(), NewP521Point(), NewP521Point(),
+		NewP521Point(), NewP521Point(), NewP521Point(), NewP521Point(),
+		NewP521Point(), NewP521Point(), NewP521Point(), NewP521Point(),
+		NewP521Point(), NewP521Point(), NewP521Point(), NewP521Point()}
+	base := NewP521Point().Set(q)
+	if err := base.randomize(rand); err != nil {
+		return nil, err
+	}
+	table[0].Set(base)
+	for i := 1; i < 15; i += 2 {
+		table[i].Double(table[i/2])
+		table[i+1].Add(table[i], base)
+	}
+	for i := range table {
+		if err := table[i].randomize(rand); err != nil {
+			return nil, err
+		}
+	}
+
+	t := NewP521Point()
+	acc := NewP521Point()
+	for i, byte := range blinded {
+		if i != 0 {
+			acc.Double(acc)
+			acc.Double(acc)
+			acc.Double(acc)
+			acc.Double(acc)
+		}
+
+		windowValue := byte >> 4
+		table.Select(t, windowValue)
+		acc.Add(acc, t)
+
+		acc.Double(acc)
+		acc.Double(acc)
+		acc.Double(acc)
+		acc.Double(acc)
+
+		windowValue = byte & 0b1111
+		table.Select(t, windowValue)
+		acc.Add(acc, t)
+	}
+
+	return p.Set(acc), nil
+}
+
+// randomize sets the projective coordinates (X:Y:Z) of p to (λX:λY:λZ) for a
+// random non-zero λ read from rand, which represents the same point.
+func (p *P521Point) randomize(rand io.Reader) error {
+	var buf [p521ElementLength]byte
+	lambda := new(fiat.P521Element)
+	// Give up after a bounded number of attempts, which only fails if rand
+	// is broken, rather than looping forever.
+	for i := 0; i < 256; i++ {
+		if _, err := io.ReadFull(rand, buf[:]); err != nil {
+			return err
+		}
+		buf[0] &= 1
+		if _, err := lambda.SetBytes(buf[:]); err == nil && lambda.IsZero() == 0 {
+			p.x.Mul(p.x, lambda)
+			p.y.Mul(p.y, lambda)
+			p.z.Mul(p.z, lambda)
+			return nil
+		}
+	}
+	return errors.New("P521 point randomization failed")
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !purego && (amd64 || arm64 || (ppc64le && go1.19) || s390x)
+
+package nistec
+
+import (
+	"errors"
+	"io"
+)
+
+// ScalarMultHardened sets r = scalar * q, and returns r. The result is the same
+// as ScalarMult, but the computation is blinded with randomness read from rand
+// as a countermeasure against differential power analysis and template attacks:
+// the Jacobian coordinates of q and of every precomputed multiple are
+// randomized, and the scalar k is replaced by k + r×N for a random 63-bit r,
+// where N is the order of the group.
+//
+// If scalar is not 32 bytes long, or reading from rand fails, ScalarMultHardened
+// returns an error and the receiver is unchanged.
+func (r *P256Point) ScalarMultHardened(q *P256Point, scalar []byte, rand io.Reader) (*P256Point, error) {
+	if len(scalar) != p256ElementLength {
+		return nil, errors.New("invalid scalar length")
+	}
+	var blinded [p256ElementLength + 8]byte
+	if err := blindScalar(blinded[:], scalar, p256Order, rand); err != nil {
+		return nil, err
+	}
+
+	// The blinded scalar doesn't fit the fixed-size Booth windows of
+	// p256ScalarMult, so use a plain four-bit window, like the generic
+	// implementation. p256Select returns the zero point, which has Z = 0 and is
+	// handled by Add as the point at infinity, for index zero.
+	var base P256Point
+	base.Set(q)
+	if err := base.randomize(rand); err != nil {
+		return nil, err
+	}
+	var table p256Table
+	table[0] = base
+	for i := 1; i < 15; i += 2 {
+		table[i].Double(&table[i/2])
+		table[i+1].Add(&table[i], &base)
+	}
+	for i := range table[:15] {
+		if err := table[i].randomize(rand); err != nil {
+			return nil, err
+		}
+	}
+
+	var t, acc P256Point
+	acc.Set(NewP256Point())
+	for i, byte := range blinded {
+		if i != 0 {
+			acc.Double(&acc)
+			acc.Double(&acc)
+			acc.Double(&acc)
+			acc.Double(&acc)
+		}
+
+		p256Select(&t, &table, int(byte>>4))
+		acc.Add(&acc, &t)
+
+		acc.Double(&acc)
+		acc.Double(&acc)
+		acc.Double(&acc)
+		acc.Double(&acc)
+
+		p256Select(&t, &table, int(byte&0b1111))
+		acc.Add(&acc, &t)
+	}
+
+	return r.Set(&acc), nil
+}
+
+// randomize sets the Jacobian coordinates (X:Y:Z) of p to (λ²X:λ³Y:λZ) for a
+// random non-zero λ read from rand, which represents the same point.
+func (p *P256Point) randomize(rand io.Reader) error {
+	var buf [p256ElementLength]byte
+	lambda := new(p256Element)
+	// Give up after a bounded number of attempts, which only fails if rand
+	// is broken, rather than looping forever.
+	for i := 0; i < 256; i++ {
+		if _, err := io.ReadFull(rand, buf[:]); err != nil {
+			return err
+		}
+		p256BigToLittle(lambda, &buf)
+		// Any value in [1, p-1] is as good a random Montgomery domain
+		// element as any other.
+		if p256LessThanP(lambda) == 1 && p256Equal(lambda, &p256Zero) == 0 {
+			lambda2 := new(p256Element)
+			p256Sqr(lambda2, lambda, 1)
+			p256Mul(&p.x, &p.x, lambda2)
+			p256Mul(&p.y, &p.y, lambda2)
+			p256Mul(&p.y, &p.y, lambda)
+			p256Mul(&p.z, &p.z, lambda)
+			return nil
+		}
+	}
+	return errors.New("P256 point randomization failed")
+}
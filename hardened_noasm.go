@@ -0,0 +1,101 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build purego || (!amd64 && !arm64 && !(ppc64le && go1.19) && !s390x)
+
+package nistec
+
+import (
+	"errors"
+	"io"
+
+	"github.com/magical/nistec-extra/internal/fiat"
+)
+
+// ScalarMultHardened sets p = scalar * q, and returns p. The result is the same
+// as ScalarMult, but the computation is blinded with randomness read from rand
+// as a countermeasure against differential power analysis and template attacks:
+// the projective coordinates of q and of every precomputed multiple are
+// randomized, and the scalar k is replaced by k + r×N for a random 63-bit r,
+// where N is the order of the group.
+//
+// If scalar is not 32 bytes long, or reading from rand fails, ScalarMultHardened
+// returns an error and the receiver is unchanged.
+func (p *P256Point) ScalarMultHardened(q *P256Point, scalar []byte, rand io.Reader) (*P256Point, error) {
+	if len(scalar) != p256ElementLength {
+		return nil, errors.New("invalid scalar length")
+	}
+	var blinded [p256ElementLength + 8]byte
+	if err := blindScalar(blinded[:], scalar, p256Order, rand); err != nil {
+		return nil, err
+	}
+
+	// Compute a p256Table for a randomized copy of the base point q, and then
+	// randomize each entry independently.
+	var table = p256Table{NewP256Point(), NewP256Point(), NewP256Point(),
+		NewP256Point(), NewP256Point(), NewP256Point(), NewP256Point(),
+		NewP256Point(), NewP256Point(), NewP256Point(), NewP256Point(),
+		NewP256Point(), NewP256Point(), NewP256Point(), NewP256Point()}
+	base := NewP256Point().Set(q)
+	if err := base.randomize(rand); err != nil {
+		return nil, err
+	}
+	table[0].Set(base)
+	for i := 1; i < 15; i += 2 {
+		table[i].Double(table[i/2])
+		table[i+1].Add(table[i], base)
+	}
+	for i := range table {
+		if err := table[i].randomize(rand); err != nil {
+			return nil, err
+		}
+	}
+
+	t := NewP256Point()
+	acc := NewP256Point()
+	for i, byte := range blinded {
+		if i != 0 {
+			acc.Double(acc)
+			acc.Double(acc)
+			acc.Double(acc)
+			acc.Double(acc)
+		}
+
+		windowValue := byte >> 4
+		table.Select(t, windowValue)
+		acc.Add(acc, t)
+
+		acc.Double(acc)
+		acc.Double(acc)
+		acc.Double(acc)
+		acc.Double(acc)
+
+		windowValue = byte & 0b1111
+		table.Select(t, windowValue)
+		acc.Add(acc, t)
+	}
+
+	return p.Set(acc), nil
+}
+
+// randomize sets the projective coordinates (X:Y:Z) of p to (λX:λY:λZ) for a
+// random non-zero λ read from rand, which represents the same point.
+func (p *P256Point) randomize(rand io.Reader) error {
+	var buf [p256ElementLength]byte
+	lambda := new(fiat.P256Element)
+	// Give up after a bounded number of attempts, which only fails if rand
+	// is broken, rather than looping forever.
+	for i := 0; i < 256; i++ {
+		if _, err := io.ReadFull(rand, buf[:]); err != nil {
+			return err
+		}
+		if _, err := lambda.SetBytes(buf[:]); err == nil && lambda.IsZero() == 0 {
+			p.x.Mul(p.x, lambda)
+			p.y.Mul(p.y, lambda)
+			p.z.Mul(p.z, lambda)
+			return nil
+		}
+	}
+	return errors.New("P256 point randomization failed")
+}
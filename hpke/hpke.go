@@ -0,0 +1,360 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package hpke implements Hybrid Public Key Encryption, as specified in RFC
+// 9180, with the DHKEMs over P-256, P-384 and P-521.
+//
+// All four modes are supported: Base, PSK, in which both parties share a
+// pre-shared key, Auth, in which the sender is authenticated by a static key
+// pair, and AuthPSK, which combines the two. Each of them has a Setup function
+// for the sender, ending with S, and one for the recipient, ending with R.
+// The resulting contexts encrypt a sequence of messages, and export secrets.
+package hpke
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"hash"
+	"io"
+
+	"github.com/magical/nistec-extra/ecdh"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// KDF identifies a key derivation function.
+type KDF uint16
+
+const (
+	HKDFSHA256 KDF = 0x0001
+	HKDFSHA384 KDF = 0x0002
+	HKDFSHA512 KDF = 0x0003
+)
+
+func (k KDF) hash() (func() hash.Hash, error) {
+	switch k {
+	case HKDFSHA256:
+		return sha256.New, nil
+	case HKDFSHA384:
+		return sha512.New384, nil
+	case HKDFSHA512:
+		return sha512.New, nil
+	}
+	return nil, errors.New("hpke: unsupported KDF")
+}
+
+// AEAD identifies an authenticated encryption scheme.
+type AEAD uint16
+
+const (
+	AES128GCM        AEAD = 0x0001
+	AES256GCM        AEAD = 0x0002
+	ChaCha20Poly1305 AEAD = 0x0003
+	// ExportOnly contexts can only export secrets, and not encrypt.
+	ExportOnly AEAD = 0xFFFF
+)
+
+// keySize returns the length of the key of the AEAD.
+func (a AEAD) keySize() (int, error) {
+	switch a {
+	case AES128GCM:
+		return 16, nil
+	case AES256GCM, ChaCha20Poly1305:
+		return 32, nil
+	case ExportOnly:
+		return 0, nil
+	}
+	return 0, errors.New("hpke: unsupported AEAD")
+}
+
+func (a AEAD) new(key []byte) (cipher.AEAD, error) {
+	switch a {
+	case AES128GCM, AES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case ChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	}
+	return nil, errors.New("hpke: unsupported AEAD")
+}
+
+// nonceSize is the nonce length of all the supported AEADs.
+const nonceSize = 12
+
+// Suite is an HPKE ciphersuite.
+type Suite struct {
+	KEM  KEM
+	KDF  KDF
+	AEAD AEAD
+}
+
+const (
+	modeBase    = 0x00
+	modePSK     = 0x01
+	modeAuth    = 0x02
+	modeAuthPSK = 0x03
+)
+
+// suiteID returns prefix || I2OSP(id, 2) || ...
+func suiteID(prefix string, ids ...uint16) []byte {
+	b := []byte(prefix)
+	for _, id := range ids {
+		b = append(b, byte(id>>8), byte(id))
+	}
+	return b
+}
+
+func labeledExtract(h func() hash.Hash, suiteID, salt []byte, label string, ikm []byte) []byte {
+	labeledIKM := append([]byte("HPKE-v1"), suiteID...)
+	labeledIKM = append(labeledIKM, label...)
+	labeledIKM = append(labeledIKM, ikm...)
+	defer zeroise(labeledIKM)
+	return hkdf.Extract(h, labeledIKM, salt)
+}
+
+func labeledExpand(h func() hash.Hash, suiteID, prk []byte, label string, info []byte, length int) ([]byte, error) {
+	if length < 0 || length > 255*h().Size() || length > 0xffff {
+		return nil, errors.New("hpke: requested length too large")
+	}
+	labeledInfo := []byte{byte(length >> 8), byte(length)}
+	labeledInfo = append(labeledInfo, "HPKE-v1"...)
+	labeledInfo = append(labeledInfo, suiteID...)
+	labeledInfo = append(labeledInfo, label...)
+	labeledInfo = append(labeledInfo, info...)
+	out := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.Expand(h, prk, labeledInfo), out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func zeroise(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// SetupBaseS sets up a context to encrypt messages to pkR, and returns it with
+// the encapsulated key enc, to be sent to the recipient.
+func (s Suite) SetupBaseS(rand io.Reader, pkR *ecdh.PublicKey, info []byte) (enc []byte, ctx *Sender, err error) {
+	return s.setupS(rand, modeBase, pkR, info, nil, nil, nil)
+}
+
+// SetupBaseR sets up a context to decrypt messages encrypted to skR, from the
+// encapsulated key enc.
+func (s Suite) SetupBaseR(enc []byte, skR *ecdh.PrivateKey, info []byte) (*Receiver, error) {
+	return s.setupR(modeBase, enc, skR, info, nil, nil, nil)
+}
+
+// SetupPSKS is like SetupBaseS, but also authenticates the sender as a holder
+// of the pre-shared key psk, identified by pskID.
+func (s Suite) SetupPSKS(rand io.Reader, pkR *ecdh.PublicKey, info, psk, pskID []byte) (enc []byte, ctx *Sender, err error) {
+	return s.setupS(rand, modePSK, pkR, info, psk, pskID, nil)
+}
+
+// SetupPSKR is like SetupBaseR, for a context set up with SetupPSKS.
+func (s Suite) SetupPSKR(enc []byte, skR *ecdh.PrivateKey, info, psk, pskID []byte) (*Receiver, error) {
+	return s.setupR(modePSK, enc, skR, info, psk, pskID, nil)
+}
+
+// SetupAuthS is like SetupBaseS, but also authenticates the sender as the
+// holder of the static private key skS.
+func (s Suite) SetupAuthS(rand io.Reader, pkR *ecdh.PublicKey, info []byte, skS *ecdh.PrivateKey) (enc []byte, ctx *Sender, err error) {
+	if skS == nil {
+		return nil, nil, errors.New("hpke: missing sender key")
+	}
+	return s.setupS(rand, modeAuth, pkR, info, nil, nil, skS)
+}
+
+// SetupAuthR is like SetupBaseR, for a context set up with SetupAuthS by the
+// holder of the private key of pkS.
+func (s Suite) SetupAuthR(enc []byte, skR *ecdh.PrivateKey, info []byte, pkS *ecdh.PublicKey) (*Receiver, error) {
+	if pkS == nil {
+		return nil, errors.New("hpke: missing sender key")
+	}
+	return s.setupR(modeAuth, enc, skR, info, nil, nil, pkS)
+}
+
+// SetupAuthPSKS combines SetupAuthS and SetupPSKS.
+func (s Suite) SetupAuthPSKS(rand io.Reader, pkR *ecdh.PublicKey, info, psk, pskID []byte, skS *ecdh.PrivateKey) (enc []byte, ctx *Sender, err error) {
+	if skS == nil {
+		return nil, nil, errors.New("hpke: missing sender key")
+	}
+	return s.setupS(rand, modeAuthPSK, pkR, info, psk, pskID, skS)
+}
+
+// SetupAuthPSKR combines SetupAuthR and SetupPSKR.
+func (s Suite) SetupAuthPSKR(enc []byte, skR *ecdh.PrivateKey, info, psk, pskID []byte, pkS *ecdh.PublicKey) (*Receiver, error) {
+	if pkS == nil {
+		return nil, errors.New("hpke: missing sender key")
+	}
+	return s.setupR(modeAuthPSK, enc, skR, info, psk, pskID, pkS)
+}
+
+func (s Suite) setupS(rand io.Reader, mode byte, pkR *ecdh.PublicKey, info, psk, pskID []byte, skS *ecdh.PrivateKey) ([]byte, *Sender, error) {
+	kem, err := s.KEM.dhkem()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.check(mode, psk, pskID); err != nil {
+		return nil, nil, err
+	}
+	ss, enc, err := kem.encap(rand, pkR, skS)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zeroise(ss)
+	ctx, err := s.keySchedule(mode, ss, info, psk, pskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return enc, &Sender{*ctx}, nil
+}
+
+func (s Suite) setupR(mode byte, enc []byte, skR *ecdh.PrivateKey, info, psk, pskID []byte, pkS *ecdh.PublicKey) (*Receiver, error) {
+	kem, err := s.KEM.dhkem()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.check(mode, psk, pskID); err != nil {
+		return nil, err
+	}
+	ss, err := kem.decap(enc, skR, pkS)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroise(ss)
+	ctx, err := s.keySchedule(mode, ss, info, psk, pskID)
+	if err != nil {
+		return nil, err
+	}
+	return &Receiver{*ctx}, nil
+}
+
+// check checks the suite, and implements VerifyPSKInputs.
+func (s Suite) check(mode byte, psk, pskID []byte) error {
+	if _, err := s.KDF.hash(); err != nil {
+		return err
+	}
+	if _, err := s.AEAD.keySize(); err != nil {
+		return err
+	}
+	gotPSK, gotPSKID := len(psk) > 0, len(pskID) > 0
+	if gotPSK != gotPSKID {
+		return errors.New("hpke: inconsistent PSK inputs")
+	}
+	if gotPSK != (mode == modePSK || mode == modeAuthPSK) {
+		return errors.New("hpke: PSK inputs don't match the mode")
+	}
+	return nil
+}
+
+func (s Suite) keySchedule(mode byte, sharedSecret, info, psk, pskID []byte) (*context, error) {
+	h, _ := s.KDF.hash()
+	nk, _ := s.AEAD.keySize()
+	id := suiteID("HPKE", uint16(s.KEM), uint16(s.KDF), uint16(s.AEAD))
+
+	ksc := []byte{mode}
+	ksc = append(ksc, labeledExtract(h, id, nil, "psk_id_hash", pskID)...)
+	ksc = append(ksc, labeledExtract(h, id, nil, "info_hash", info)...)
+	secret := labeledExtract(h, id, sharedSecret, "secret", psk)
+	defer zeroise(secret)
+
+	ctx := &context{suite: s, h: h, id: id}
+	var err error
+	if ctx.exporterSecret, err = labeledExpand(h, id, secret, "exp", ksc, h().Size()); err != nil {
+		return nil, err
+	}
+	if s.AEAD == ExportOnly {
+		return ctx, nil
+	}
+	key, err := labeledExpand(h, id, secret, "key", ksc, nk)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroise(key)
+	if ctx.baseNonce, err = labeledExpand(h, id, secret, "base_nonce", ksc, nonceSize); err != nil {
+		return nil, err
+	}
+	if ctx.aead, err = s.AEAD.new(key); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// context is the state shared by Sender and Receiver.
+type context struct {
+	suite          Suite
+	h              func() hash.Hash
+	id             []byte
+	aead           cipher.AEAD // nil for ExportOnly
+	baseNonce      []byte
+	seq            uint64
+	exporterSecret []byte
+}
+
+// Sender is an encryption context, returned by the Setup functions ending
+// with S.
+type Sender struct{ context }
+
+// Receiver is a decryption context, returned by the Setup functions ending
+// with R.
+type Receiver struct{ context }
+
+// nonce returns base_nonce XOR I2OSP(seq, Nn).
+func (c *context) nonce() ([]byte, error) {
+	if c.aead == nil {
+		return nil, errors.New("hpke: export-only context can't encrypt")
+	}
+	// Nn is 12 for all the AEADs, so seq can't reach 2^(8 × Nn) - 1, but it
+	// mustn't wrap around either.
+	if c.seq == 1<<64-1 {
+		return nil, errors.New("hpke: message limit reached")
+	}
+	nonce := append([]byte(nil), c.baseNonce...)
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-1-i] ^= byte(c.seq >> (8 * i))
+	}
+	return nonce, nil
+}
+
+// Seal encrypts and authenticates pt, and authenticates aad, with the next
+// nonce of the context.
+func (c *Sender) Seal(aad, pt []byte) ([]byte, error) {
+	nonce, err := c.nonce()
+	if err != nil {
+		return nil, err
+	}
+	ct := c.aead.Seal(nil, nonce, pt, aad)
+	c.seq++
+	return ct, nil
+}
+
+// Open decrypts ct and checks the authenticity of ct and aad, with the next
+// nonce of the context. If Open fails, the nonce is not consumed, so that the
+// next message can still be decrypted.
+func (c *Receiver) Open(aad, ct []byte) ([]byte, error) {
+	nonce, err := c.nonce()
+	if err != nil {
+		return nil, err
+	}
+	pt, err := c.aead.Open(nil, nonce, ct, aad)
+	if err != nil {
+		return nil, errors.New("hpke: decryption failed")
+	}
+	c.seq++
+	return pt, nil
+}
+
+// Export returns a secret of the given length, derived from the context and
+// exporterContext. Both parties obtain the same secrets.
+func (c *context) Export(exporterContext []byte, length int) ([]byte, error) {
+	return labeledExpand(c.h, c.id, c.exporterSecret, "sec", exporterContext, length)
+}
@@ -0,0 +1,272 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpke_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/magical/nistec-extra/ecdh"
+	"github.com/magical/nistec-extra/hpke"
+)
+
+func fatalIfErr(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// hexBytes is a []byte encoded as a hex string in JSON.
+type hexBytes []byte
+
+func (b *hexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := hex.DecodeString(s)
+	*b = v
+	return err
+}
+
+type vector struct {
+	Mode        byte      `json:"mode"`
+	KEM         hpke.KEM  `json:"kem_id"`
+	KDF         hpke.KDF  `json:"kdf_id"`
+	AEAD        hpke.AEAD `json:"aead_id"`
+	Info        hexBytes  `json:"info"`
+	IKMR        hexBytes  `json:"ikmR"`
+	IKMS        hexBytes  `json:"ikmS"`
+	IKME        hexBytes  `json:"ikmE"`
+	SKRm        hexBytes  `json:"skRm"`
+	PKRm        hexBytes  `json:"pkRm"`
+	PKSm        hexBytes  `json:"pkSm"`
+	PKEm        hexBytes  `json:"pkEm"`
+	PSK         hexBytes  `json:"psk"`
+	PSKID       hexBytes  `json:"psk_id"`
+	Enc         hexBytes  `json:"enc"`
+	Encryptions []struct {
+		AAD hexBytes `json:"aad"`
+		CT  hexBytes `json:"ct"`
+		PT  hexBytes `json:"pt"`
+	} `json:"encryptions"`
+	Exports []struct {
+		Context hexBytes `json:"exporter_context"`
+		L       int      `json:"L"`
+		Value   hexBytes `json:"exported_value"`
+	} `json:"exports"`
+}
+
+// TestVectors checks the test vectors of RFC 9180 for the DHKEMs over the P
+// curves, from the test-vectors.json file of the draft repository at commit
+// 5f503c5, with only the first five encryptions of each vector.
+func TestVectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/rfc9180.json")
+	fatalIfErr(t, err)
+	var vectors []vector
+	fatalIfErr(t, json.Unmarshal(data, &vectors))
+	if len(vectors) == 0 {
+		t.Fatal("no vectors")
+	}
+	for i, v := range vectors {
+		testVector(t, i, &v)
+	}
+}
+
+func testVector(t *testing.T, i int, v *vector) {
+	s := hpke.Suite{KEM: v.KEM, KDF: v.KDF, AEAD: v.AEAD}
+	skR, err := v.KEM.DeriveKeyPair(v.IKMR)
+	fatalIfErr(t, err)
+	if !bytes.Equal(skR.Bytes(), v.SKRm) || !bytes.Equal(skR.PublicKey().Bytes(), v.PKRm) {
+		t.Errorf("#%d: DeriveKeyPair(ikmR) = %x", i, skR.Bytes())
+	}
+	var skS *ecdh.PrivateKey
+	if v.Mode == 2 || v.Mode == 3 {
+		skS, err = v.KEM.DeriveKeyPair(v.IKMS)
+		fatalIfErr(t, err)
+		if !bytes.Equal(skS.PublicKey().Bytes(), v.PKSm) {
+			t.Errorf("#%d: DeriveKeyPair(ikmS) = %x", i, skS.Bytes())
+		}
+	}
+
+	rand := bytes.NewReader(v.IKME)
+	var enc []byte
+	var sender *hpke.Sender
+	var receiver *hpke.Receiver
+	switch v.Mode {
+	case 0:
+		enc, sender, err = s.SetupBaseS(rand, skR.PublicKey(), v.Info)
+		fatalIfErr(t, err)
+		receiver, err = s.SetupBaseR(v.Enc, skR, v.Info)
+	case 1:
+		enc, sender, err = s.SetupPSKS(rand, skR.PublicKey(), v.Info, v.PSK, v.PSKID)
+		fatalIfErr(t, err)
+		receiver, err = s.SetupPSKR(v.Enc, skR, v.Info, v.PSK, v.PSKID)
+	case 2:
+		enc, sender, err = s.SetupAuthS(rand, skR.PublicKey(), v.Info, skS)
+		fatalIfErr(t, err)
+		receiver, err = s.SetupAuthR(v.Enc, skR, v.Info, skS.PublicKey())
+	case 3:
+		enc, sender, err = s.SetupAuthPSKS(rand, skR.PublicKey(), v.Info, v.PSK, v.PSKID, skS)
+		fatalIfErr(t, err)
+		receiver, err = s.SetupAuthPSKR(v.Enc, skR, v.Info, v.PSK, v.PSKID, skS.PublicKey())
+	}
+	fatalIfErr(t, err)
+	if !bytes.Equal(enc, v.Enc) {
+		t.Errorf("#%d: enc = %x, want %x", i, enc, v.Enc)
+	}
+
+	for j, e := range v.Encryptions {
+		ct, err := sender.Seal(e.AAD, e.PT)
+		fatalIfErr(t, err)
+		if !bytes.Equal(ct, e.CT) {
+			t.Errorf("#%d: encryption %d = %x, want %x", i, j, ct, e.CT)
+		}
+		pt, err := receiver.Open(e.AAD, e.CT)
+		if err != nil {
+			t.Errorf("#%d: decryption %d: %v", i, j, err)
+		} else if !bytes.Equal(pt, e.PT) {
+			t.Errorf("#%d: decryption %d = %x, want %x", i, j, pt, e.PT)
+		}
+	}
+	for j, e := range v.Exports {
+		for _, exp := range []interface {
+			Export([]byte, int) ([]byte, error)
+		}{sender, receiver} {
+			got, err := exp.Export(e.Context, e.L)
+			fatalIfErr(t, err)
+			if !bytes.Equal(got, e.Value) {
+				t.Errorf("#%d: export %d = %x, want %x", i, j, got, e.Value)
+			}
+		}
+	}
+}
+
+func TestModes(t *testing.T) {
+	for _, s := range []hpke.Suite{
+		{hpke.DHKEMP256, hpke.HKDFSHA256, hpke.AES128GCM},
+		{hpke.DHKEMP384, hpke.HKDFSHA384, hpke.AES256GCM},
+		{hpke.DHKEMP521, hpke.HKDFSHA512, hpke.ChaCha20Poly1305},
+	} {
+		t.Run(s.KEM.Curve().Name(), func(t *testing.T) {
+			testModes(t, s)
+		})
+	}
+}
+
+func testModes(t *testing.T, s hpke.Suite) {
+	skR, err := s.KEM.GenerateKeyPair(rand.Reader)
+	fatalIfErr(t, err)
+	skS, err := s.KEM.GenerateKeyPair(rand.Reader)
+	fatalIfErr(t, err)
+	other, err := s.KEM.GenerateKeyPair(rand.Reader)
+	fatalIfErr(t, err)
+	info, psk, pskID := []byte("info"), []byte("0123456789abcdef0123456789abcdef"), []byte("id")
+
+	type setup struct {
+		name string
+		s    func() ([]byte, *hpke.Sender, error)
+		r    func(enc []byte) (*hpke.Receiver, error)
+		bad  func(enc []byte) (*hpke.Receiver, error)
+	}
+	for _, m := range []setup{
+		{"Base",
+			func() ([]byte, *hpke.Sender, error) { return s.SetupBaseS(rand.Reader, skR.PublicKey(), info) },
+			func(enc []byte) (*hpke.Receiver, error) { return s.SetupBaseR(enc, skR, info) },
+			func(enc []byte) (*hpke.Receiver, error) { return s.SetupBaseR(enc, other, info) }},
+		{"PSK",
+			func() ([]byte, *hpke.Sender, error) {
+				return s.SetupPSKS(rand.Reader, skR.PublicKey(), info, psk, pskID)
+			},
+			func(enc []byte) (*hpke.Receiver, error) { return s.SetupPSKR(enc, skR, info, psk, pskID) },
+			func(enc []byte) (*hpke.Receiver, error) { return s.SetupPSKR(enc, skR, info, psk[1:], pskID) }},
+		{"Auth",
+			func() ([]byte, *hpke.Sender, error) { return s.SetupAuthS(rand.Reader, skR.PublicKey(), info, skS) },
+			func(enc []byte) (*hpke.Receiver, error) { return s.SetupAuthR(enc, skR, info, skS.PublicKey()) },
+			func(enc []byte) (*hpke.Receiver, error) { return s.SetupAuthR(enc, skR, info, other.PublicKey()) }},
+		{"AuthPSK",
+			func() ([]byte, *hpke.Sender, error) {
+				return s.SetupAuthPSKS(rand.Reader, skR.PublicKey(), info, psk, pskID, skS)
+			},
+			func(enc []byte) (*hpke.Receiver, error) {
+				return s.SetupAuthPSKR(enc, skR, info, psk, pskID, skS.PublicKey())
+			},
+			func(enc []byte) (*hpke.Receiver, error) {
+				return s.SetupAuthPSKR(enc, skR, info, psk, []byte("other"), skS.PublicKey())
+			}},
+	} {
+		enc, sender, err := m.s()
+		fatalIfErr(t, err)
+		receiver, err := m.r(enc)
+		fatalIfErr(t, err)
+		for i := 0; i < 3; i++ {
+			ct, err := sender.Seal([]byte("aad"), []byte("hello"))
+			fatalIfErr(t, err)
+			if _, err := receiver.Open([]byte("bad"), ct); err == nil {
+				t.Errorf("%s: Open accepted the wrong aad", m.name)
+			}
+			// A failed Open doesn't consume a nonce.
+			pt, err := receiver.Open([]byte("aad"), ct)
+			if err != nil || string(pt) != "hello" {
+				t.Errorf("%s: Open = %q, %v", m.name, pt, err)
+			}
+		}
+		e1, err := sender.Export([]byte("ctx"), 32)
+		fatalIfErr(t, err)
+		e2, err := receiver.Export([]byte("ctx"), 32)
+		fatalIfErr(t, err)
+		if !bytes.Equal(e1, e2) {
+			t.Errorf("%s: exported secrets differ", m.name)
+		}
+
+		ct, err := sender.Seal(nil, []byte("hello"))
+		fatalIfErr(t, err)
+		if bad, err := m.bad(enc); err == nil {
+			if _, err := bad.Open(nil, ct); err == nil {
+				t.Errorf("%s: Open succeeded with the wrong keys", m.name)
+			}
+		}
+	}
+
+	// Mismatched PSK inputs are rejected.
+	if _, _, err := s.SetupPSKS(rand.Reader, skR.PublicKey(), info, psk, nil); err == nil {
+		t.Error("SetupPSKS accepted a PSK without an id")
+	}
+	if _, _, err := s.SetupPSKS(rand.Reader, skR.PublicKey(), info, nil, nil); err == nil {
+		t.Error("SetupPSKS accepted an empty PSK")
+	}
+	// The encapsulated key must be uncompressed and valid.
+	if _, err := s.SetupBaseR(skS.PublicKey().BytesCompressed(), skR, info); err == nil {
+		t.Error("SetupBaseR accepted a compressed enc")
+	}
+	if _, err := s.SetupBaseR([]byte{0}, skR, info); err == nil {
+		t.Error("SetupBaseR accepted the point at infinity")
+	}
+}
+
+func TestExportOnly(t *testing.T) {
+	s := hpke.Suite{KEM: hpke.DHKEMP256, KDF: hpke.HKDFSHA256, AEAD: hpke.ExportOnly}
+	skR, err := s.KEM.GenerateKeyPair(rand.Reader)
+	fatalIfErr(t, err)
+	_, sender, err := s.SetupBaseS(rand.Reader, skR.PublicKey(), nil)
+	fatalIfErr(t, err)
+	if _, err := sender.Seal(nil, nil); err == nil {
+		t.Error("export-only context encrypted")
+	}
+	if _, err := sender.Export(nil, 255*32+1); err == nil {
+		t.Error("Export accepted an excessive length")
+	}
+	if _, err := (hpke.Suite{KEM: 0x20, KDF: 1, AEAD: 1}).SetupBaseR(nil, skR, nil); err == nil {
+		t.Error("unsupported KEM accepted")
+	}
+	if _, err := (hpke.Suite{KEM: hpke.DHKEMP384, KDF: 1, AEAD: 1}).SetupBaseR(nil, skR, nil); err == nil {
+		t.Error("key on the wrong curve accepted")
+	}
+}
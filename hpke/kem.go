@@ -0,0 +1,211 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpke
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"hash"
+	"io"
+
+	"github.com/magical/nistec-extra/ecdh"
+)
+
+// KEM identifies a key encapsulation mechanism.
+type KEM uint16
+
+const (
+	DHKEMP256 KEM = 0x0010 // DHKEM(P-256, HKDF-SHA256)
+	DHKEMP384 KEM = 0x0011 // DHKEM(P-384, HKDF-SHA384)
+	DHKEMP521 KEM = 0x0012 // DHKEM(P-521, HKDF-SHA512)
+)
+
+// dhkem holds the parameters of a DHKEM, from RFC 9180, Section 7.1.
+type dhkem struct {
+	id      []byte // suite_id, "KEM" || I2OSP(kem_id, 2)
+	curve   *ecdh.Curve
+	hash    func() hash.Hash
+	nSecret int
+	bitmask byte
+}
+
+var (
+	p256KEM = &dhkem{suiteID("KEM", 0x0010), ecdh.P256(), sha256.New, 32, 0xff}
+	p384KEM = &dhkem{suiteID("KEM", 0x0011), ecdh.P384(), sha512.New384, 48, 0xff}
+	p521KEM = &dhkem{suiteID("KEM", 0x0012), ecdh.P521(), sha512.New, 64, 0x01}
+)
+
+func (k KEM) dhkem() (*dhkem, error) {
+	switch k {
+	case DHKEMP256:
+		return p256KEM, nil
+	case DHKEMP384:
+		return p384KEM, nil
+	case DHKEMP521:
+		return p521KEM, nil
+	}
+	return nil, errors.New("hpke: unsupported KEM")
+}
+
+// Curve returns the curve of the KEM, or nil if k is not supported.
+func (k KEM) Curve() *ecdh.Curve {
+	kem, err := k.dhkem()
+	if err != nil {
+		return nil
+	}
+	return kem.curve
+}
+
+// DeriveKeyPair deterministically derives a key pair from the input keying
+// material ikm, with the rejection sampling method of RFC 9180, Section 7.1.3.
+// ikm should be at least ScalarLength bytes long, and uniformly random.
+func (k KEM) DeriveKeyPair(ikm []byte) (*ecdh.PrivateKey, error) {
+	kem, err := k.dhkem()
+	if err != nil {
+		return nil, err
+	}
+	return kem.deriveKeyPair(ikm)
+}
+
+func (kem *dhkem) deriveKeyPair(ikm []byte) (*ecdh.PrivateKey, error) {
+	prk := labeledExtract(kem.hash, kem.id, nil, "dkp_prk", ikm)
+	defer zeroise(prk)
+	nsk := kem.curve.ScalarLength()
+	for counter := 0; counter < 256; counter++ {
+		b, err := labeledExpand(kem.hash, kem.id, prk, "candidate", []byte{byte(counter)}, nsk)
+		if err != nil {
+			return nil, err
+		}
+		b[0] &= kem.bitmask
+		// NewPrivateKey rejects zero and values not below the order.
+		sk, err := ecdh.NewPrivateKey(kem.curve, b)
+		zeroise(b)
+		if err == nil {
+			return sk, nil
+		}
+	}
+	return nil, errors.New("hpke: DeriveKeyPair failed")
+}
+
+// GenerateKeyPair returns a new key pair, derived with DeriveKeyPair from
+// ScalarLength bytes read from rand.
+func (k KEM) GenerateKeyPair(rand io.Reader) (*ecdh.PrivateKey, error) {
+	kem, err := k.dhkem()
+	if err != nil {
+		return nil, err
+	}
+	return kem.generateKeyPair(rand)
+}
+
+func (kem *dhkem) generateKeyPair(rand io.Reader) (*ecdh.PrivateKey, error) {
+	ikm := make([]byte, kem.curve.ScalarLength())
+	defer zeroise(ikm)
+	if _, err := io.ReadFull(rand, ikm); err != nil {
+		return nil, err
+	}
+	return kem.deriveKeyPair(ikm)
+}
+
+// ParsePublicKey implements DeserializePublicKey, and only accepts the
+// uncompressed encoding of a point, as returned by ecdh.PublicKey.Bytes.
+func (k KEM) ParsePublicKey(b []byte) (*ecdh.PublicKey, error) {
+	kem, err := k.dhkem()
+	if err != nil {
+		return nil, err
+	}
+	return kem.parsePublicKey(b)
+}
+
+func (kem *dhkem) parsePublicKey(b []byte) (*ecdh.PublicKey, error) {
+	if len(b) != 1+2*kem.curve.SharedSecretLength() || b[0] != 4 {
+		return nil, errors.New("hpke: invalid public key encoding")
+	}
+	return ecdh.NewPublicKey(kem.curve, b)
+}
+
+// encap implements Encap, or AuthEncap if skS is not nil, and returns the
+// shared secret and the encapsulated key.
+func (kem *dhkem) encap(rand io.Reader, pkR *ecdh.PublicKey, skS *ecdh.PrivateKey) (ss, enc []byte, err error) {
+	if pkR.Curve() != kem.curve {
+		return nil, nil, errors.New("hpke: key is not on the KEM curve")
+	}
+	skE, err := kem.generateKeyPair(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer skE.Destroy()
+	enc = skE.PublicKey().Bytes()
+	kemContext := append(append([]byte(nil), enc...), pkR.Bytes()...)
+	var dh [][]byte
+	defer func() {
+		for _, z := range dh {
+			zeroise(z)
+		}
+	}()
+	z, err := ecdh.SharedSecret(skE, pkR)
+	if err != nil {
+		return nil, nil, err
+	}
+	dh = append(dh, z)
+	if skS != nil {
+		z, err := ecdh.SharedSecret(skS, pkR)
+		if err != nil {
+			return nil, nil, err
+		}
+		dh = append(dh, z)
+		kemContext = append(kemContext, skS.PublicKey().Bytes()...)
+	}
+	ss, err = kem.extractAndExpand(dh, kemContext)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ss, enc, nil
+}
+
+// decap implements Decap, or AuthDecap if pkS is not nil.
+func (kem *dhkem) decap(enc []byte, skR *ecdh.PrivateKey, pkS *ecdh.PublicKey) ([]byte, error) {
+	if skR.Curve() != kem.curve {
+		return nil, errors.New("hpke: key is not on the KEM curve")
+	}
+	pkE, err := kem.parsePublicKey(enc)
+	if err != nil {
+		return nil, err
+	}
+	kemContext := append(append([]byte(nil), enc...), skR.PublicKey().Bytes()...)
+	var dh [][]byte
+	defer func() {
+		for _, z := range dh {
+			zeroise(z)
+		}
+	}()
+	z, err := ecdh.SharedSecret(skR, pkE)
+	if err != nil {
+		return nil, err
+	}
+	dh = append(dh, z)
+	if pkS != nil {
+		z, err := ecdh.SharedSecret(skR, pkS)
+		if err != nil {
+			return nil, err
+		}
+		dh = append(dh, z)
+		kemContext = append(kemContext, pkS.Bytes()...)
+	}
+	return kem.extractAndExpand(dh, kemContext)
+}
+
+// extractAndExpand returns the shared secret derived from the concatenation
+// of the Diffie-Hellman outputs dh and from kem_context.
+func (kem *dhkem) extractAndExpand(dh [][]byte, kemContext []byte) ([]byte, error) {
+	var ikm []byte
+	for _, z := range dh {
+		ikm = append(ikm, z...)
+	}
+	defer zeroise(ikm)
+	prk := labeledExtract(kem.hash, kem.id, nil, "eae_prk", ikm)
+	defer zeroise(prk)
+	return labeledExpand(kem.hash, kem.id, prk, "shared_secret", kemContext, kem.nSecret)
+}
@@ -0,0 +1,119 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec
+
+import "errors"
+
+// ParseMode is a set of flags that modify the encodings accepted by
+// SetBytesWithMode.
+type ParseMode uint
+
+const (
+	// AllowHybrid accepts the hybrid encodings (0x06 and 0x07) of ANSI
+	// X9.62-1998, Section 4.3.6, in addition to the encodings accepted by
+	// SetBytes. The least significant bit of the first byte must match the
+	// least significant bit of y.
+	AllowHybrid ParseMode = 1 << iota
+
+	// RejectInfinity rejects the single-byte encoding of the point at
+	// infinity, which is never a valid public key in key agreement.
+	RejectInfinity
+)
+
+// bytesHybrid converts the output of Bytes to the hybrid encoding.
+func bytesHybrid(b []byte) []byte {
+	if len(b) == 1 {
+		return b
+	}
+	b[0] = 6 | b[len(b)-1]&1
+	return b
+}
+
+type settableBytes[T any] interface {
+	Set(T) T
+	SetBytes([]byte) (T, error)
+	Bytes() []byte
+}
+
+func setBytesWithMode[P settableBytes[P]](p P, newPoint func() P, b []byte, mode ParseMode) (P, error) {
+	var zero P
+	if mode&RejectInfinity != 0 && len(b) == 1 && b[0] == 0 {
+		return zero, errors.New("invalid point encoding: point at infinity")
+	}
+	if len(b) == 0 || b[0] != 6 && b[0] != 7 {
+		return p.SetBytes(b)
+	}
+	if mode&AllowHybrid == 0 {
+		return zero, errors.New("invalid point encoding: hybrid encoding not allowed")
+	}
+
+	// Parse the uncompressed encoding, and then check the parity of y, which
+	// is the last byte of the canonical uncompressed encoding.
+	u := append([]byte{4}, b[1:]...)
+	q, err := newPoint().SetBytes(u)
+	if err != nil {
+		return zero, err
+	}
+	if b[0]&1 != b[len(b)-1]&1 {
+		return zero, errors.New("invalid hybrid point encoding: wrong y parity")
+	}
+	return p.Set(q), nil
+}
+
+// BytesHybrid returns the hybrid or infinity encoding of p, as specified in
+// ANSI X9.62-1998, Section 4.3.6. The hybrid encoding is the uncompressed
+// encoding with the first byte set to 0x06 or 0x07 according to the least
+// significant bit of y. It's only needed for compatibility with legacy systems.
+func (p *P224Point) BytesHybrid() []byte {
+	return bytesHybrid(p.Bytes())
+}
+
+// SetBytesWithMode is like SetBytes, but accepts or rejects additional
+// encodings according to mode.
+func (p *P224Point) SetBytesWithMode(b []byte, mode ParseMode) (*P224Point, error) {
+	return setBytesWithMode(p, NewP224Point, b, mode)
+}
+
+// BytesHybrid returns the hybrid or infinity encoding of p, as specified in
+// ANSI X9.62-1998, Section 4.3.6. The hybrid encoding is the uncompressed
+// encoding with the first byte set to 0x06 or 0x07 according to the least
+// significant bit of y. It's only needed for compatibility with legacy systems.
+func (p *P256Point) BytesHybrid() []byte {
+	return bytesHybrid(p.Bytes())
+}
+
+// SetBytesWithMode is like SetBytes, but accepts or rejects additional
+// encodings according to mode.
+func (p *P256Point) SetBytesWithMode(b []byte, mode ParseMode) (*P256Point, error) {
+	return setBytesWithMode(p, NewP256Point, b, mode)
+}
+
+// BytesHybrid returns the hybrid or infinity encoding of p, as specified in
+// ANSI X9.62-1998, Section 4.3.6. The hybrid encoding is the uncompressed
+// encoding with the first byte set to 0x06 or 0x07 according to the least
+// significant bit of y. It's only needed for compatibility with legacy systems.
+func (p *P384Point) BytesHybrid() []byte {
+	return bytesHybrid(p.Bytes())
+}
+
+// SetBytesWithMode is like SetBytes, but accepts or rejects additional
+// encodings according to mode.
+func (p *P384Point) SetBytesWithMode(b []byte, mode ParseMode) (*P384Point, error) {
+	return setBytesWithMode(p, NewP384Point, b, mode)
+}
+
+// BytesHybrid returns the hybrid or infinity encoding of p, as specified in
+// ANSI X9.62-1998, Section 4.3.6. The hybrid encoding is the uncompressed
+// encoding with the first byte set to 0x06 or 0x07 according to the least
+// significant bit of y. It's only needed for compatibility with legacy systems.
+func (p *P521Point) BytesHybrid() []byte {
+	return bytesHybrid(p.Bytes())
+}
+
+// SetBytesWithMode is like SetBytes, but accepts or rejects additional
+// encodings according to mode.
+func (p *P521Point) SetBytesWithMode(b []byte, mode ParseMode) (*P521Point, error) {
+	return setBytesWithMode(p, NewP521Point, b, mode)
+}
@@ -5,6 +5,8 @@
 package fiat_test
 
 import (
+	"bytes"
+	"math/rand"
 	"testing"
 
 	"github.com/magical/nistec-extra/internal/fiat"
@@ -27,6 +29,14 @@ func BenchmarkMul(b *testing.B) {
 			v.Mul(v, v)
 		}
 	})
+	b.Run("P384Solinas", func(b *testing.B) {
+		v := new(fiat.P384SolinasElement).One()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			v.Mul(v, v)
+		}
+	})
 	b.Run("P521", func(b *testing.B) {
 		v := new(fiat.P521Element).One()
 		b.ReportAllocs()
@@ -54,6 +64,14 @@ func BenchmarkSquare(b *testing.B) {
 			v.Square(v)
 		}
 	})
+	b.Run("P384Solinas", func(b *testing.B) {
+		v := new(fiat.P384SolinasElement).One()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			v.Square(v)
+		}
+	})
 	b.Run("P521", func(b *testing.B) {
 		v := new(fiat.P521Element).One()
 		b.ReportAllocs()
@@ -63,3 +81,75 @@ func BenchmarkSquare(b *testing.B) {
 		}
 	})
 }
+
+func TestP384Solinas(t *testing.T) {
+	minusOne := new(fiat.P384Element).Sub(
+		new(fiat.P384Element), new(fiat.P384Element).One()).Bytes()
+	minusTwo := new(fiat.P384Element).Sub(
+		new(fiat.P384Element), new(fiat.P384Element).One())
+	minusTwo.Sub(minusTwo, new(fiat.P384Element).One())
+	inputs := [][]byte{
+		make([]byte, 48),
+		new(fiat.P384Element).One().Bytes(),
+		minusOne,
+		minusTwo.Bytes(),
+		append([]byte{0x80}, make([]byte, 47)...),
+		bytes.Repeat([]byte{0xfe}, 48),
+	}
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 100; i++ {
+		b := make([]byte, 48)
+		r.Read(b)
+		b[0] &= 0xfe
+		inputs = append(inputs, b)
+	}
+
+	for _, a := range inputs {
+		for _, b := range inputs {
+			ma, err := new(fiat.P384Element).SetBytes(a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mb, err := new(fiat.P384Element).SetBytes(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sa, err := new(fiat.P384SolinasElement).SetBytes(a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sb, err := new(fiat.P384SolinasElement).SetBytes(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := new(fiat.P384SolinasElement).Mul(sa, sb).Bytes(),
+				new(fiat.P384Element).Mul(ma, mb).Bytes(); !bytes.Equal(got, want) {
+				t.Errorf("%x * %x = %x, want %x", a, b, got, want)
+			}
+			if got, want := new(fiat.P384SolinasElement).Add(sa, sb).Bytes(),
+				new(fiat.P384Element).Add(ma, mb).Bytes(); !bytes.Equal(got, want) {
+				t.Errorf("%x + %x = %x, want %x", a, b, got, want)
+			}
+			if got, want := new(fiat.P384SolinasElement).Sub(sa, sb).Bytes(),
+				new(fiat.P384Element).Sub(ma, mb).Bytes(); !bytes.Equal(got, want) {
+				t.Errorf("%x - %x = %x, want %x", a, b, got, want)
+			}
+		}
+
+		ma, _ := new(fiat.P384Element).SetBytes(a)
+		sa, _ := new(fiat.P384SolinasElement).SetBytes(a)
+		if got, want := new(fiat.P384SolinasElement).Square(sa).Bytes(),
+			new(fiat.P384Element).Square(ma).Bytes(); !bytes.Equal(got, want) {
+			t.Errorf("%x² = %x, want %x", a, got, want)
+		}
+		if got, want := new(fiat.P384SolinasElement).Invert(sa).Bytes(),
+			new(fiat.P384Element).Invert(ma).Bytes(); !bytes.Equal(got, want) {
+			t.Errorf("1/%x = %x, want %x", a, got, want)
+		}
+	}
+
+	if _, err := new(fiat.P384SolinasElement).SetBytes(bytes.Repeat([]byte{0xff}, 48)); err == nil {
+		t.Error("SetBytes accepted a non-canonical encoding")
+	}
+}
@@ -0,0 +1,451 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fiat
+
+import (
+	"crypto/subtle"
+	"errors"
+	"math/bits"
+)
+
+// P384SolinasElement is an integer modulo 2^384 - 2^128 - 2^96 + 2^32 - 1.
+//
+// It is an alternative to P384Element that keeps values outside the Montgomery
+// domain and reduces products with the special form of p (Solinas, or "NIST
+// fast", reduction) instead of word-by-word Montgomery reduction.
+//
+// On amd64, Mul is about as fast as the fiat-crypto Montgomery multiplication
+// and Square is slightly faster (see BenchmarkMul and BenchmarkSquare), which
+// is not enough of a difference to switch the point arithmetic over to it.
+//
+// The zero value is a valid zero element.
+type P384SolinasElement struct {
+	// Values are always fully reduced, in little-endian 64-bit limbs.
+	x p384UntypedFieldElement
+}
+
+// One sets e = 1, and returns e.
+func (e *P384SolinasElement) One() *P384SolinasElement {
+	e.x = p384UntypedFieldElement{1}
+	return e
+}
+
+// Equal returns 1 if e == t, and zero otherwise.
+func (e *P384SolinasElement) Equal(t *P384SolinasElement) int {
+	eBytes := e.Bytes()
+	tBytes := t.Bytes()
+	return subtle.ConstantTimeCompare(eBytes, tBytes)
+}
+
+// IsZero returns 1 if e == 0, and zero otherwise.
+func (e *P384SolinasElement) IsZero() int {
+	zero := make([]byte, p384ElementLen)
+	eBytes := e.Bytes()
+	return subtle.ConstantTimeCompare(eBytes, zero)
+}
+
+// Set sets e = t, and returns e.
+func (e *P384SolinasElement) Set(t *P384SolinasElement) *P384SolinasElement {
+	e.x = t.x
+	return e
+}
+
+// Bytes returns the 48-byte big-endian encoding of e.
+func (e *P384SolinasElement) Bytes() []byte {
+	// This function is outlined to make the allocations inline in the caller
+	// rather than happen on the heap.
+	var out [p384ElementLen]byte
+	return e.bytes(&out)
+}
+
+func (e *P384SolinasElement) bytes(out *[p384ElementLen]byte) []byte {
+	p384ToBytes(out, &e.x)
+	p384InvertEndianness(out[:])
+	return out[:]
+}
+
+// SetBytes sets e = v, where v is a big-endian 48-byte encoding, and returns e.
+// If v is not 48 bytes or it encodes a value higher than 2^384 - 2^128 - 2^96 + 2^32 - 1,
+// SetBytes returns nil and an error, and e is unchanged.
+func (e *P384SolinasElement) SetBytes(v []byte) (*P384SolinasElement, error) {
+	if len(v) != p384ElementLen {
+		return nil, errors.New("invalid P384SolinasElement encoding")
+	}
+
+	// Check for non-canonical encodings (p + k, 2p + k, etc.) by comparing to
+	// the encoding of -1 mod p, so p - 1, the highest canonical encoding.
+	var minusOneEncoding = new(P384SolinasElement).Sub(
+		new(P384SolinasElement), new(P384SolinasElement).One()).Bytes()
+	for i := range v {
+		if v[i] < minusOneEncoding[i] {
+			break
+		}
+		if v[i] > minusOneEncoding[i] {
+			return nil, errors.New("invalid P384SolinasElement encoding")
+		}
+	}
+
+	var in [p384ElementLen]byte
+	copy(in[:], v)
+	p384InvertEndianness(in[:])
+	p384FromBytes(&e.x, &in)
+	return e, nil
+}
+
+// Add sets e = t1 + t2, and returns e.
+func (e *P384SolinasElement) Add(t1, t2 *P384SolinasElement) *P384SolinasElement {
+	// Modular addition and subtraction don't depend on the representation, so
+	// the fiat-crypto Montgomery implementations can be reused as-is.
+	p384Add((*p384MontgomeryDomainFieldElement)(&e.x),
+		(*p384MontgomeryDomainFieldElement)(&t1.x),
+		(*p384MontgomeryDomainFieldElement)(&t2.x))
+	return e
+}
+
+// Sub sets e = t1 - t2, and returns e.
+func (e *P384SolinasElement) Sub(t1, t2 *P384SolinasElement) *P384SolinasElement {
+	p384Sub((*p384MontgomeryDomainFieldElement)(&e.x),
+		(*p384MontgomeryDomainFieldElement)(&t1.x),
+		(*p384MontgomeryDomainFieldElement)(&t2.x))
+	return e
+}
+
+// Mul sets e = t1 * t2, and returns e.
+func (e *P384SolinasElement) Mul(t1, t2 *P384SolinasElement) *P384SolinasElement {
+	var t [12]uint64
+	p384SolinasMulWide(&t, &t1.x, &t2.x)
+	p384SolinasReduce(&e.x, &t)
+	return e
+}
+
+// Square sets e = t * t, and returns e.
+func (e *P384SolinasElement) Square(t *P384SolinasElement) *P384SolinasElement {
+	var w [12]uint64
+	p384SolinasSquareWide(&w, &t.x)
+	p384SolinasReduce(&e.x, &w)
+	return e
+}
+
+// Select sets v to a if cond == 1, and to b if cond == 0.
+func (v *P384SolinasElement) Select(a, b *P384SolinasElement, cond int) *P384SolinasElement {
+	p384Selectznz(&v.x, p384Uint1(cond), &b.x, &a.x)
+	return v
+}
+
+// p384SolinasMac returns (acc0, acc1, acc2) + a * b, where acc0, acc1, and
+// acc2 are the limbs of a 192-bit accumulator.
+func p384SolinasMac(a, b, acc0, acc1, acc2 uint64) (uint64, uint64, uint64) {
+	hi, lo := bits.Mul64(a, b)
+	var c uint64
+	acc0, c = bits.Add64(acc0, lo, 0)
+	acc1, c = bits.Add64(acc1, hi, c)
+	return acc0, acc1, acc2 + c
+}
+
+// p384SolinasMac2 returns (acc0, acc1, acc2) + 2 * a * b.
+func p384SolinasMac2(a, b, acc0, acc1, acc2 uint64) (uint64, uint64, uint64) {
+	hi, lo := bits.Mul64(a, b)
+	var c uint64
+	acc0, c = bits.Add64(acc0, lo, 0)
+	acc1, c = bits.Add64(acc1, hi, c)
+	acc2 += c
+	acc0, c = bits.Add64(acc0, lo, 0)
+	acc1, c = bits.Add64(acc1, hi, c)
+	return acc0, acc1, acc2 + c
+}
+
+// p384SolinasMulWide sets out to the 768-bit product a * b.
+func p384SolinasMulWide(out *[12]uint64, a, b *p384UntypedFieldElement) {
+	// The product is computed column by column (product scanning), summing
+	// all a[i] * b[j] with i + j = k into a three-limb accumulator.
+	a0, a1, a2, a3, a4, a5 := a[0], a[1], a[2], a[3], a[4], a[5]
+	b0, b1, b2, b3, b4, b5 := b[0], b[1], b[2], b[3], b[4], b[5]
+
+	var t0, t1, t2, t3, t4, t5, t6, t7, t8, t9, t10, t11 uint64
+	var acc0, acc1, acc2 uint64
+	acc0, acc1, acc2 = p384SolinasMac(a0, b0, acc0, acc1, acc2)
+	t0, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac(a0, b1, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a1, b0, acc0, acc1, acc2)
+	t1, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac(a0, b2, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a1, b1, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a2, b0, acc0, acc1, acc2)
+	t2, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac(a0, b3, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a1, b2, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a2, b1, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a3, b0, acc0, acc1, acc2)
+	t3, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac(a0, b4, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a1, b3, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a2, b2, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a3, b1, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a4, b0, acc0, acc1, acc2)
+	t4, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac(a0, b5, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a1, b4, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a2, b3, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a3, b2, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a4, b1, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a5, b0, acc0, acc1, acc2)
+	t5, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac(a1, b5, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a2, b4, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a3, b3, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a4, b2, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a5, b1, acc0, acc1, acc2)
+	t6, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac(a2, b5, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a3, b4, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a4, b3, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a5, b2, acc0, acc1, acc2)
+	t7, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac(a3, b5, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a4, b4, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a5, b3, acc0, acc1, acc2)
+	t8, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac(a4, b5, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a5, b4, acc0, acc1, acc2)
+	t9, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac(a5, b5, acc0, acc1, acc2)
+	t10, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	t11 = acc0
+
+	*out = [12]uint64{t0, t1, t2, t3, t4, t5, t6, t7, t8, t9, t10, t11}
+}
+
+// p384SolinasSquareWide sets out to the 768-bit product a * a.
+func p384SolinasSquareWide(out *[12]uint64, a *p384UntypedFieldElement) {
+	// Like p384SolinasMulWide, but each off-diagonal product a[i] * a[j] is
+	// computed only once and added twice.
+	a0, a1, a2, a3, a4, a5 := a[0], a[1], a[2], a[3], a[4], a[5]
+
+	var t0, t1, t2, t3, t4, t5, t6, t7, t8, t9, t10, t11 uint64
+	var acc0, acc1, acc2 uint64
+	acc0, acc1, acc2 = p384SolinasMac(a0, a0, acc0, acc1, acc2)
+	t0, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac2(a0, a1, acc0, acc1, acc2)
+	t1, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac2(a0, a2, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a1, a1, acc0, acc1, acc2)
+	t2, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac2(a0, a3, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac2(a1, a2, acc0, acc1, acc2)
+	t3, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac2(a0, a4, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac2(a1, a3, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a2, a2, acc0, acc1, acc2)
+	t4, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac2(a0, a5, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac2(a1, a4, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac2(a2, a3, acc0, acc1, acc2)
+	t5, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac2(a1, a5, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac2(a2, a4, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a3, a3, acc0, acc1, acc2)
+	t6, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac2(a2, a5, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac2(a3, a4, acc0, acc1, acc2)
+	t7, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac2(a3, a5, acc0, acc1, acc2)
+	acc0, acc1, acc2 = p384SolinasMac(a4, a4, acc0, acc1, acc2)
+	t8, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac2(a4, a5, acc0, acc1, acc2)
+	t9, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	acc0, acc1, acc2 = p384SolinasMac(a5, a5, acc0, acc1, acc2)
+	t10, acc0, acc1, acc2 = acc0, acc1, acc2, 0
+	t11 = acc0
+
+	*out = [12]uint64{t0, t1, t2, t3, t4, t5, t6, t7, t8, t9, t10, t11}
+}
+
+// p384SolinasReduce sets out = t mod p, fully reduced, where t is a 768-bit
+// value, using 2^384 ≡ 2^128 + 2^96 - 2^32 + 1 (mod p).
+func p384SolinasReduce(out *p384UntypedFieldElement, t *[12]uint64) {
+	t0, t1, t2, t3, t4, t5 := t[0], t[1], t[2], t[3], t[4], t[5]
+	h0, h1, h2, h3, h4, h5 := t[6], t[7], t[8], t[9], t[10], t[11]
+
+	// First, fold the high 384 bits h into the low ones, using
+	//
+	//   h * 2^384 ≡ h + h << 128 + h << 96 - h << 32 (mod p).
+	//
+	// The result r fits in 513 bits.
+	s0 := h0 << 32
+	s1 := h1<<32 | h0>>32
+	s2 := h2<<32 | h1>>32
+	s3 := h3<<32 | h2>>32
+	s4 := h4<<32 | h3>>32
+	s5 := h5<<32 | h4>>32
+	s6 := h5 >> 32
+
+	var r0, r1, r2, r3, r4, r5, r6, r7, r8, c uint64
+	r0, c = bits.Add64(t0, h0, 0)
+	r1, c = bits.Add64(t1, h1, c)
+	r2, c = bits.Add64(t2, h2, c)
+	r3, c = bits.Add64(t3, h3, c)
+	r4, c = bits.Add64(t4, h4, c)
+	r5, c = bits.Add64(t5, h5, c)
+	r6 = c
+	r2, c = bits.Add64(r2, h0, 0)
+	r3, c = bits.Add64(r3, h1, c)
+	r4, c = bits.Add64(r4, h2, c)
+	r5, c = bits.Add64(r5, h3, c)
+	r6, c = bits.Add64(r6, h4, c)
+	r7, c = bits.Add64(r7, h5, c)
+	r8 = c
+	r1, c = bits.Add64(r1, s0, 0)
+	r2, c = bits.Add64(r2, s1, c)
+	r3, c = bits.Add64(r3, s2, c)
+	r4, c = bits.Add64(r4, s3, c)
+	r5, c = bits.Add64(r5, s4, c)
+	r6, c = bits.Add64(r6, s5, c)
+	r7, c = bits.Add64(r7, s6, c)
+	r8 += c
+	r0, c = bits.Sub64(r0, s0, 0)
+	r1, c = bits.Sub64(r1, s1, c)
+	r2, c = bits.Sub64(r2, s2, c)
+	r3, c = bits.Sub64(r3, s3, c)
+	r4, c = bits.Sub64(r4, s4, c)
+	r5, c = bits.Sub64(r5, s5, c)
+	r6, c = bits.Sub64(r6, s6, c)
+	r7, c = bits.Sub64(r7, 0, c)
+	r8 -= c
+
+	// Fold the high 129 bits (r6, r7, r8) again the same way. This time the
+	// result is at most 2^384 + 2^259, so it overflows by at most one bit, u6.
+	s0 = r6 << 32
+	s1 = r7<<32 | r6>>32
+	s2 = r8<<32 | r7>>32
+
+	var u0, u1, u2, u3, u4, u5, u6 uint64
+	u0, c = bits.Add64(r0, r6, 0)
+	u1, c = bits.Add64(r1, r7, c)
+	u2, c = bits.Add64(r2, r8, c)
+	u3, c = bits.Add64(r3, 0, c)
+	u4, c = bits.Add64(r4, 0, c)
+	u5, c = bits.Add64(r5, 0, c)
+	u6 = c
+	u2, c = bits.Add64(u2, r6, 0)
+	u3, c = bits.Add64(u3, r7, c)
+	u4, c = bits.Add64(u4, r8, c)
+	u5, c = bits.Add64(u5, 0, c)
+	u6 += c
+	u1, c = bits.Add64(u1, s0, 0)
+	u2, c = bits.Add64(u2, s1, c)
+	u3, c = bits.Add64(u3, s2, c)
+	u4, c = bits.Add64(u4, 0, c)
+	u5, c = bits.Add64(u5, 0, c)
+	u6 += c
+	u0, c = bits.Sub64(u0, s0, 0)
+	u1, c = bits.Sub64(u1, s1, c)
+	u2, c = bits.Sub64(u2, s2, c)
+	u3, c = bits.Sub64(u3, 0, c)
+	u4, c = bits.Sub64(u4, 0, c)
+	u5, c = bits.Sub64(u5, 0, c)
+	u6 -= c
+
+	// If u6 is set, the low part is at most 2^259, so adding 2^384 mod p to it
+	// can't carry out again.
+	mask := -u6
+	u0, c = bits.Add64(u0, 0xffffffff00000001&mask, 0)
+	u1, c = bits.Add64(u1, 0x00000000ffffffff&mask, c)
+	u2, c = bits.Add64(u2, 0x0000000000000001&mask, c)
+	u3, c = bits.Add64(u3, 0, c)
+	u4, c = bits.Add64(u4, 0, c)
+	u5, _ = bits.Add64(u5, 0, c)
+
+	// The result is now less than 2^384 < 2p, so a single conditional
+	// subtraction of p fully reduces it.
+	var d0, d1, d2, d3, d4, d5, b uint64
+	d0, b = bits.Sub64(u0, 0x00000000ffffffff, 0)
+	d1, b = bits.Sub64(u1, 0xffffffff00000000, b)
+	d2, b = bits.Sub64(u2, 0xfffffffffffffffe, b)
+	d3, b = bits.Sub64(u3, 0xffffffffffffffff, b)
+	d4, b = bits.Sub64(u4, 0xffffffffffffffff, b)
+	d5, b = bits.Sub64(u5, 0xffffffffffffffff, b)
+
+	p384CmovznzU64(&out[0], p384Uint1(b), d0, u0)
+	p384CmovznzU64(&out[1], p384Uint1(b), d1, u1)
+	p384CmovznzU64(&out[2], p384Uint1(b), d2, u2)
+	p384CmovznzU64(&out[3], p384Uint1(b), d3, u3)
+	p384CmovznzU64(&out[4], p384Uint1(b), d4, u4)
+	p384CmovznzU64(&out[5], p384Uint1(b), d5, u5)
+}
+
+// Invert sets e = 1/x, and returns e.
+//
+// If x == 0, Invert returns e = 0.
+func (e *P384SolinasElement) Invert(x *P384SolinasElement) *P384SolinasElement {
+	// Inversion is implemented as exponentiation with exponent p − 2, using
+	// the same addition chain as P384Element.Invert.
+
+	var z = new(P384SolinasElement).Set(e)
+	var t0 = new(P384SolinasElement)
+	var t1 = new(P384SolinasElement)
+	var t2 = new(P384SolinasElement)
+	var t3 = new(P384SolinasElement)
+
+	z.Square(x)
+	z.Mul(x, z)
+	z.Square(z)
+	t1.Mul(x, z)
+	z.Square(t1)
+	for s := 1; s < 3; s++ {
+		z.Square(z)
+	}
+	z.Mul(t1, z)
+	t0.Square(z)
+	for s := 1; s < 6; s++ {
+		t0.Square(t0)
+	}
+	t0.Mul(z, t0)
+	t2.Square(t0)
+	for s := 1; s < 12; s++ {
+		t2.Square(t2)
+	}
+	t0.Mul(t0, t2)
+	for s := 0; s < 6; s++ {
+		t0.Square(t0)
+	}
+	z.Mul(z, t0)
+	t0.Square(z)
+	t2.Mul(x, t0)
+	t0.Square(t2)
+	t0.Mul(x, t0)
+	t3.Square(t0)
+	for s := 1; s < 31; s++ {
+		t3.Square(t3)
+	}
+	t2.Mul(t2, t3)
+	t3.Square(t2)
+	for s := 1; s < 63; s++ {
+		t3.Square(t3)
+	}
+	t2.Mul(t2, t3)
+	t3.Square(t2)
+	for s := 1; s < 126; s++ {
+		t3.Square(t3)
+	}
+	t2.Mul(t2, t3)
+	for s := 0; s < 3; s++ {
+		t2.Square(t2)
+	}
+	t1.Mul(t1, t2)
+	for s := 0; s < 33; s++ {
+		t1.Square(t1)
+	}
+	t0.Mul(t0, t1)
+	for s := 0; s < 94; s++ {
+		t0.Square(t0)
+	}
+	z.Mul(z, t0)
+	for s := 0; s < 2; s++ {
+		z.Square(z)
+	}
+	z.Mul(x, z)
+
+	return e.Set(z)
+}
@@ -0,0 +1,298 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash"
+)
+
+// joseCurve holds the JOSE and COSE identifiers of a curve. P-224 is not
+// registered for either.
+type joseCurve struct {
+	name    string // JWK "crv" value, from RFC 7518, Section 6.2.1.1
+	coseCrv int64  // COSE "crv" value, from RFC 8152, Section 13.1
+	coseAlg int64  // COSE ECDSA algorithm, from RFC 8152, Section 8.1
+
+	byteLen int
+	n       []byte
+
+	// publicKey returns the uncompressed encoding of [scalar]G.
+	publicKey func(scalar []byte) ([]byte, error)
+}
+
+var (
+	p256JOSE = &joseCurve{name: "P-256", coseCrv: 1, coseAlg: -7, byteLen: p256ElementLength, n: p256Order,
+		publicKey: func(scalar []byte) ([]byte, error) { return scalarBaseMultBytes(NewP256Point, scalar) }}
+	p384JOSE = &joseCurve{name: "P-384", coseCrv: 2, coseAlg: -35, byteLen: p384ElementLength, n: p384Order,
+		publicKey: func(scalar []byte) ([]byte, error) { return scalarBaseMultBytes(NewP384Point, scalar) }}
+	p521JOSE = &joseCurve{name: "P-521", coseCrv: 3, coseAlg: -36, byteLen: p521ElementLength, n: p521Order,
+		publicKey: func(scalar []byte) ([]byte, error) { return scalarBaseMultBytes(NewP521Point, scalar) }}
+)
+
+// privateKeyPoint checks scalar and returns the uncompressed encoding of the
+// corresponding public key.
+func (c *joseCurve) privateKeyPoint(scalar []byte) ([]byte, error) {
+	if err := checkPrivateScalar(scalar, c.n); err != nil {
+		return nil, err
+	}
+	return c.publicKey(scalar)
+}
+
+// checkPrivateKey checks that scalar is valid and matches the public key with
+// uncompressed encoding point.
+func (c *joseCurve) checkPrivateKey(scalar, point []byte) error {
+	pub, err := c.privateKeyPoint(scalar)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(pub, point) != 1 {
+		return errors.New("public key doesn't match the private key")
+	}
+	return nil
+}
+
+var b64 = base64.RawURLEncoding.Strict()
+
+// marshalJWK returns the JWK encoding of the public key with uncompressed
+// encoding point and, if scalar is not nil, of the private key scalar.
+func marshalJWK(c *joseCurve, point, scalar []byte) ([]byte, error) {
+	if len(point) == 1 {
+		return nil, errors.New(c.name + " point is the point at infinity")
+	}
+	s := `{"kty":"EC","crv":"` + c.name +
+		`","x":"` + b64.EncodeToString(point[1:1+c.byteLen]) +
+		`","y":"` + b64.EncodeToString(point[1+c.byteLen:]) + `"`
+	if scalar != nil {
+		s += `,"d":"` + b64.EncodeToString(scalar) + `"`
+	}
+	return []byte(s + "}"), nil
+}
+
+// jwkThumbprint returns the RFC 7638 thumbprint of the public key with
+// uncompressed encoding point.
+func jwkThumbprint(c *joseCurve, point []byte, h func() hash.Hash) ([]byte, error) {
+	if len(point) == 1 {
+		return nil, errors.New(c.name + " point is the point at infinity")
+	}
+	// The required members in lexicographic order, with no whitespace, as
+	// specified in RFC 7638, Section 3.2.
+	H := h()
+	H.Write([]byte(`{"crv":"` + c.name +
+		`","kty":"EC","x":"` + b64.EncodeToString(point[1:1+c.byteLen]) +
+		`","y":"` + b64.EncodeToString(point[1+c.byteLen:]) + `"}`))
+	return H.Sum(nil), nil
+}
+
+// parseJWK parses a JWK, and returns the uncompressed encoding of its public
+// key, which is not yet validated, and its private key if present. The key
+// must be of type "EC" on curve c.
+func parseJWK(c *joseCurve, data []byte) (point, scalar []byte, err error) {
+	// Member names are case-sensitive, so don't unmarshal into a struct.
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, nil, errors.New("invalid JWK encoding: " + err.Error())
+	}
+	if kty, _ := m["kty"].(string); kty != "EC" {
+		return nil, nil, errors.New("JWK is not an EC key")
+	}
+	if crv, _ := m["crv"].(string); crv != c.name {
+		return nil, nil, errors.New("JWK is not on " + c.name)
+	}
+	point = make([]byte, 1, 1+2*c.byteLen)
+	point[0] = 4
+	for _, name := range []string{"x", "y"} {
+		// RFC 7518, Section 6.2.1.2 and 6.2.1.3 require the full coordinate
+		// length, even if there are leading zeroes.
+		v, err := jwkMember(m, name, c.byteLen)
+		if err != nil {
+			return nil, nil, err
+		}
+		if v == nil {
+			return nil, nil, errors.New("JWK is missing the " + name + " member")
+		}
+		point = append(point, v...)
+	}
+	// RFC 7518, Section 6.2.2.1 requires d to be as long as the order.
+	scalar, err = jwkMember(m, "d", len(c.n))
+	if err != nil {
+		return nil, nil, err
+	}
+	return point, scalar, nil
+}
+
+// jwkMember returns the base64url decoded value of m[name], which must be
+// exactly length bytes long, or nil if it's not present.
+func jwkMember(m map[string]any, name string, length int) ([]byte, error) {
+	v, ok := m[name]
+	if !ok {
+		return nil, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, errors.New("invalid JWK " + name + " member")
+	}
+	b, err := b64.DecodeString(s)
+	if err != nil || len(b) != length {
+		return nil, errors.New("invalid JWK " + name + " member")
+	}
+	return b, nil
+}
+
+// BytesJWK returns the JSON Web Key encoding of p, as specified in RFC 7518,
+// Section 6.2.1, or an error if p is the point at infinity.
+func (p *P256Point) BytesJWK() ([]byte, error) {
+	return marshalJWK(p256JOSE, p.Bytes(), nil)
+}
+
+// SetBytesJWK sets p to the public key in the JSON Web Key jwk, and returns p.
+// The key must be of type "EC" on curve "P-256", and any private key member is
+// ignored. If jwk is not a valid P-256 public key, SetBytesJWK returns nil and
+// an error, and p is unchanged.
+func (p *P256Point) SetBytesJWK(jwk []byte) (*P256Point, error) {
+	b, _, err := parseJWK(p256JOSE, jwk)
+	if err != nil {
+		return nil, err
+	}
+	return p.SetBytes(b)
+}
+
+// JWKThumbprint returns the RFC 7638 thumbprint of p, computed with the hash
+// function h, usually sha256.New. It returns an error if p is the point at
+// infinity.
+func (p *P256Point) JWKThumbprint(h func() hash.Hash) ([]byte, error) {
+	return jwkThumbprint(p256JOSE, p.Bytes(), h)
+}
+
+// P256PrivateKeyJWK returns the JSON Web Key encoding of the private key
+// scalar, as specified in RFC 7518, Section 6.2.2, including the public key.
+func P256PrivateKeyJWK(scalar []byte) ([]byte, error) {
+	b, err := p256JOSE.privateKeyPoint(scalar)
+	if err != nil {
+		return nil, err
+	}
+	return marshalJWK(p256JOSE, b, scalar)
+}
+
+// P256ScalarFromJWK returns the scalar of the private key in the JSON Web Key
+// jwk. The public key in jwk must match the scalar.
+func P256ScalarFromJWK(jwk []byte) ([]byte, error) {
+	b, scalar, err := parseJWK(p256JOSE, jwk)
+	if err != nil {
+		return nil, err
+	}
+	if scalar == nil {
+		return nil, errors.New("JWK is not a private key")
+	}
+	if err := p256JOSE.checkPrivateKey(scalar, b); err != nil {
+		return nil, err
+	}
+	return scalar, nil
+}
+
+// BytesJWK returns the JSON Web Key encoding of p, as specified in RFC 7518,
+// Section 6.2.1, or an error if p is the point at infinity.
+func (p *P384Point) BytesJWK() ([]byte, error) {
+	return marshalJWK(p384JOSE, p.Bytes(), nil)
+}
+
+// SetBytesJWK sets p to the public key in the JSON Web Key jwk, and returns p.
+// The key must be of type "EC" on curve "P-384", and any private key member is
+// ignored. If jwk is not a valid P-384 public key, SetBytesJWK returns nil and
+// an error, and p is unchanged.
+func (p *P384Point) SetBytesJWK(jwk []byte) (*P384Point, error) {
+	b, _, err := parseJWK(p384JOSE, jwk)
+	if err != nil {
+		return nil, err
+	}
+	return p.SetBytes(b)
+}
+
+// JWKThumbprint returns the RFC 7638 thumbprint of p, computed with the hash
+// function h, usually sha256.New. It returns an error if p is the point at
+// infinity.
+func (p *P384Point) JWKThumbprint(h func() hash.Hash) ([]byte, error) {
+	return jwkThumbprint(p384JOSE, p.Bytes(), h)
+}
+
+// P384PrivateKeyJWK returns the JSON Web Key encoding of the private key
+// scalar, as specified in RFC 7518, Section 6.2.2, including the public key.
+func P384PrivateKeyJWK(scalar []byte) ([]byte, error) {
+	b, err := p384JOSE.privateKeyPoint(scalar)
+	if err != nil {
+		return nil, err
+	}
+	return marshalJWK(p384JOSE, b, scalar)
+}
+
+// P384ScalarFromJWK returns the scalar of the private key in the JSON Web Key
+// jwk. The public key in jwk must match the scalar.
+func P384ScalarFromJWK(jwk []byte) ([]byte, error) {
+	b, scalar, err := parseJWK(p384JOSE, jwk)
+	if err != nil {
+		return nil, err
+	}
+	if scalar == nil {
+		return nil, errors.New("JWK is not a private key")
+	}
+	if err := p384JOSE.checkPrivateKey(scalar, b); err != nil {
+		return nil, err
+	}
+	return scalar, nil
+}
+
+// BytesJWK returns the JSON Web Key encoding of p, as specified in RFC 7518,
+// Section 6.2.1, or an error if p is the point at infinity.
+func (p *P521Point) BytesJWK() ([]byte, error) {
+	return marshalJWK(p521JOSE, p.Bytes(), nil)
+}
+
+// SetBytesJWK sets p to the public key in the JSON Web Key jwk, and returns p.
+// The key must be of type "EC" on curve "P-521", and any private key member is
+// ignored. If jwk is not a valid P-521 public key, SetBytesJWK returns nil and
+// an error, and p is unchanged.
+func (p *P521Point) SetBytesJWK(jwk []byte) (*P521Point, error) {
+	b, _, err := parseJWK(p521JOSE, jwk)
+	if err != nil {
+		return nil, err
+	}
+	return p.SetBytes(b)
+}
+
+// JWKThumbprint returns the RFC 7638 thumbprint of p, computed with the hash
+// function h, usually sha256.New. It returns an error if p is the point at
+// infinity.
+func (p *P521Point) JWKThumbprint(h func() hash.Hash) ([]byte, error) {
+	return jwkThumbprint(p521JOSE, p.Bytes(), h)
+}
+
+// P521PrivateKeyJWK returns the JSON Web Key encoding of the private key
+// scalar, as specified in RFC 7518, Section 6.2.2, including the public key.
+func P521PrivateKeyJWK(scalar []byte) ([]byte, error) {
+	b, err := p521JOSE.privateKeyPoint(scalar)
+	if err != nil {
+		return nil, err
+	}
+	return marshalJWK(p521JOSE, b, scalar)
+}
+
+// P521ScalarFromJWK returns the scalar of the private key in the JSON Web Key
+// jwk. The public key in jwk must match the scalar.
+func P521ScalarFromJWK(jwk []byte) ([]byte, error) {
+	b, scalar, err := parseJWK(p521JOSE, jwk)
+	if err != nil {
+		return nil, err
+	}
+	if scalar == nil {
+		return nil, errors.New("JWK is not a private key")
+	}
+	if err := p521JOSE.checkPrivateKey(scalar, b); err != nil {
+		return nil, err
+	}
+	return scalar, nil
+}
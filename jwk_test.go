@@ -0,0 +1,156 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"hash"
+	"strings"
+	"testing"
+
+	"github.com/magical/nistec-extra"
+)
+
+// The P-256 key from RFC 7517, Appendix A.1 and A.2.
+const (
+	rfc7517Public  = `{"kty":"EC", "crv":"P-256", "x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4", "y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM", "use":"enc", "kid":"1"}`
+	rfc7517Private = `{"kty":"EC", "crv":"P-256", "x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4", "y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM", "d":"870MB6gfuTJ4HtUnUvYMyJpr5eUZNP4Bk43bVdj3eAE", "use":"enc", "kid":"1"}`
+
+	// rfc7517Thumbprint is the SHA-256 RFC 7638 thumbprint of the key above,
+	// that is, of {"crv":"P-256","kty":"EC","x":"MKBC...","y":"4Etl..."}.
+	rfc7517Thumbprint = "cn-I_WNMClehiVp51i_0VpOENW1upEerA8sEam5hn-s"
+)
+
+func TestJWKVectors(t *testing.T) {
+	p, err := nistec.NewP256Point().SetBytesJWK([]byte(rfc7517Public))
+	fatalIfErr(t, err)
+	scalar, err := nistec.P256ScalarFromJWK([]byte(rfc7517Private))
+	fatalIfErr(t, err)
+	q, err := nistec.NewP256Point().ScalarBaseMult(scalar)
+	fatalIfErr(t, err)
+	if !bytes.Equal(p.Bytes(), q.Bytes()) {
+		t.Errorf("public key %x doesn't match private key %x", p.Bytes(), q.Bytes())
+	}
+	thumb, err := p.JWKThumbprint(sha256.New)
+	fatalIfErr(t, err)
+	if got := base64.RawURLEncoding.EncodeToString(thumb); got != rfc7517Thumbprint {
+		t.Errorf("JWKThumbprint() = %s, want %s", got, rfc7517Thumbprint)
+	}
+
+	out, err := nistec.P256PrivateKeyJWK(scalar)
+	fatalIfErr(t, err)
+	want := `{"kty":"EC","crv":"P-256","x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4","y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM","d":"870MB6gfuTJ4HtUnUvYMyJpr5eUZNP4Bk43bVdj3eAE"}`
+	if string(out) != want {
+		t.Errorf("P256PrivateKeyJWK() = %s, want %s", out, want)
+	}
+
+	for name, jwk := range map[string]string{
+		"wrong kty":     strings.Replace(rfc7517Public, `"EC"`, `"RSA"`, 1),
+		"wrong crv":     strings.Replace(rfc7517Public, `"P-256"`, `"P-384"`, 1),
+		"missing y":     strings.Replace(rfc7517Public, `"y"`, `"z"`, 1),
+		"uppercase X":   strings.Replace(rfc7517Public, `"x"`, `"X"`, 1),
+		"short x":       strings.Replace(rfc7517Public, `"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4"`, `"`+base64.RawURLEncoding.EncodeToString(make([]byte, 31))+`"`, 1),
+		"padded x":      strings.Replace(rfc7517Public, `7D4"`, `7D4="`, 1),
+		"x is a number": strings.Replace(rfc7517Public, `"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4"`, `1`, 1),
+		"not on curve":  strings.Replace(rfc7517Public, `"MKBC`, `"MKBD`, 1),
+		"not JSON":      rfc7517Public[1:],
+	} {
+		if _, err := nistec.NewP256Point().SetBytesJWK([]byte(jwk)); err == nil {
+			t.Errorf("SetBytesJWK accepted a JWK with %s", name)
+		}
+	}
+	for name, jwk := range map[string]string{
+		"no d":       rfc7517Public,
+		"short d":    strings.Replace(rfc7517Private, `"870MB6gfuTJ4HtUnUvYMyJpr5eUZNP4Bk43bVdj3eAE"`, `"`+base64.RawURLEncoding.EncodeToString(scalar[1:])+`"`, 1),
+		"another d":  strings.Replace(rfc7517Private, `"870M`, `"871M`, 1),
+		"wrong kty":  strings.Replace(rfc7517Private, `"EC"`, `"oct"`, 1),
+		"d is bytes": strings.Replace(rfc7517Private, `"870MB6gfuTJ4HtUnUvYMyJpr5eUZNP4Bk43bVdj3eAE"`, `[1]`, 1),
+	} {
+		if _, err := nistec.P256ScalarFromJWK([]byte(jwk)); err == nil {
+			t.Errorf("P256ScalarFromJWK accepted a JWK with %s", name)
+		}
+	}
+}
+
+type jwkPoint[T any] interface {
+	nistPoint[T]
+	BytesJWK() ([]byte, error)
+	SetBytesJWK([]byte) (T, error)
+	JWKThumbprint(func() hash.Hash) ([]byte, error)
+}
+
+func TestJWK(t *testing.T) {
+	t.Run("P256", func(t *testing.T) {
+		testJWK(t, nistec.NewP256Point, "P-256", 32, nistec.P256PrivateKeyJWK, nistec.P256ScalarFromJWK)
+	})
+	t.Run("P384", func(t *testing.T) {
+		testJWK(t, nistec.NewP384Point, "P-384", 48, nistec.P384PrivateKeyJWK, nistec.P384ScalarFromJWK)
+	})
+	t.Run("P521", func(t *testing.T) {
+		testJWK(t, nistec.NewP521Point, "P-521", 66, nistec.P521PrivateKeyJWK, nistec.P521ScalarFromJWK)
+	})
+}
+
+func testJWK[P jwkPoint[P]](t *testing.T, newPoint func() P, crv string, byteLen int,
+	privateKeyJWK, scalarFromJWK func([]byte) ([]byte, error)) {
+	scalar := make([]byte, byteLen)
+	rand.Read(scalar)
+	scalar[0] = 0 // make sure it's less than the order, and test leading zeroes
+	p, err := newPoint().ScalarBaseMult(scalar)
+	fatalIfErr(t, err)
+
+	pub, err := p.BytesJWK()
+	fatalIfErr(t, err)
+	var m map[string]string
+	fatalIfErr(t, json.Unmarshal(pub, &m))
+	x, _ := base64.RawURLEncoding.DecodeString(m["x"])
+	y, _ := base64.RawURLEncoding.DecodeString(m["y"])
+	if m["kty"] != "EC" || m["crv"] != crv || len(m) != 4 ||
+		!bytes.Equal(append(append([]byte{4}, x...), y...), p.Bytes()) {
+		t.Errorf("BytesJWK() = %s", pub)
+	}
+	q, err := newPoint().SetBytesJWK(pub)
+	fatalIfErr(t, err)
+	if !bytes.Equal(q.Bytes(), p.Bytes()) {
+		t.Errorf("SetBytesJWK() = %x, want %x", q.Bytes(), p.Bytes())
+	}
+
+	priv, err := privateKeyJWK(scalar)
+	fatalIfErr(t, err)
+	fatalIfErr(t, json.Unmarshal(priv, &m))
+	if d, _ := base64.RawURLEncoding.DecodeString(m["d"]); !bytes.Equal(d, scalar) {
+		t.Errorf("PrivateKeyJWK() = %s", priv)
+	}
+	s, err := scalarFromJWK(priv)
+	fatalIfErr(t, err)
+	if !bytes.Equal(s, scalar) {
+		t.Errorf("ScalarFromJWK() = %x, want %x", s, scalar)
+	}
+	// SetBytesJWK accepts private keys too.
+	if _, err := newPoint().SetBytesJWK(priv); err != nil {
+		t.Errorf("SetBytesJWK rejected a private key: %v", err)
+	}
+
+	thumb, err := p.JWKThumbprint(sha256.New)
+	fatalIfErr(t, err)
+	h := sha256.Sum256([]byte(`{"crv":"` + crv + `","kty":"EC","x":"` + m["x"] + `","y":"` + m["y"] + `"}`))
+	if !bytes.Equal(thumb, h[:]) {
+		t.Errorf("JWKThumbprint() = %x, want %x", thumb, h)
+	}
+
+	if _, err := newPoint().BytesJWK(); err == nil {
+		t.Error("BytesJWK accepted the point at infinity")
+	}
+	if _, err := newPoint().JWKThumbprint(sha256.New); err == nil {
+		t.Error("JWKThumbprint accepted the point at infinity")
+	}
+	if _, err := privateKeyJWK(make([]byte, byteLen)); err == nil {
+		t.Error("PrivateKeyJWK accepted a zero scalar")
+	}
+}
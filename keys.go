@@ -0,0 +1,342 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package nistec
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+)
+
+// ecdsaPublicKeyBytes returns the uncompressed encoding of k, with coordinates
+// of length byteLen, or an error if they don't fit.
+func ecdsaPublicKeyBytes(k *ecdsa.PublicKey, byteLen int) ([]byte, error) {
+	if k.X == nil || k.Y == nil || k.X.Sign() < 0 || k.Y.Sign() < 0 ||
+		k.X.BitLen() > 8*byteLen || k.Y.BitLen() > 8*byteLen {
+		return nil, errors.New("invalid ecdsa public key coordinates")
+	}
+	b := make([]byte, 1+2*byteLen)
+	b[0] = 4
+	k.X.FillBytes(b[1 : 1+byteLen])
+	k.Y.FillBytes(b[1+byteLen:])
+	return b, nil
+}
+
+// ecdsaPublicKey returns the public key with uncompressed encoding b.
+func ecdsaPublicKey(c elliptic.Curve, b []byte) (*ecdsa.PublicKey, error) {
+	if len(b) == 1 {
+		return nil, errors.New("public key is the point at infinity")
+	}
+	byteLen := (len(b) - 1) / 2
+	return &ecdsa.PublicKey{
+		Curve: c,
+		X:     new(big.Int).SetBytes(b[1 : 1+byteLen]),
+		Y:     new(big.Int).SetBytes(b[1+byteLen:]),
+	}, nil
+}
+
+// ecdsaScalar returns the private scalar of k as a big-endian byte string of
+// the same length as n.
+func ecdsaScalar(k *ecdsa.PrivateKey, n []byte) ([]byte, error) {
+	if k.D == nil || k.D.Sign() <= 0 || k.D.Cmp(new(big.Int).SetBytes(n)) >= 0 {
+		return nil, errors.New("invalid ecdsa private key: out of range")
+	}
+	return k.D.FillBytes(make([]byte, len(n))), nil
+}
+
+// SetECDSAPublicKey sets p to the public key k, and returns p. If k is not
+// a valid P-224 public key, it returns nil and an error, and p is unchanged.
+func (p *P224Point) SetECDSAPublicKey(k *ecdsa.PublicKey) (*P224Point, error) {
+	if k.Curve != elliptic.P224() && k.Curve != p224Curve {
+		return nil, errors.New("ecdsa public key is not on P-224")
+	}
+	b, err := ecdsaPublicKeyBytes(k, p224ElementLength)
+	if err != nil {
+		return nil, err
+	}
+	return p.SetBytes(b)
+}
+
+// ECDSAPublicKey returns p as a crypto/ecdsa public key, or an error if p is
+// the point at infinity.
+func (p *P224Point) ECDSAPublicKey() (*ecdsa.PublicKey, error) {
+	return ecdsaPublicKey(elliptic.P224(), p.Bytes())
+}
+
+// P224ScalarFromECDSA returns the 28-byte big-endian private scalar of k, or an
+// error if k is not a valid P-224 private key.
+func P224ScalarFromECDSA(k *ecdsa.PrivateKey) ([]byte, error) {
+	if k.Curve != elliptic.P224() && k.Curve != p224Curve {
+		return nil, errors.New("ecdsa private key is not on P-224")
+	}
+	return ecdsaScalar(k, p224Order)
+}
+
+// P224ECDSAPrivateKey returns the crypto/ecdsa private key with the 28-byte
+// big-endian private scalar, or an error if scalar is not in [1, N-1].
+func P224ECDSAPrivateKey(scalar []byte) (*ecdsa.PrivateKey, error) {
+	if err := checkPrivateScalar(scalar, p224Order); err != nil {
+		return nil, err
+	}
+	q, err := NewP224Point().ScalarBaseMult(scalar)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := ecdsaPublicKey(elliptic.P224(), q.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return &ecdsa.PrivateKey{PublicKey: *pub, D: new(big.Int).SetBytes(scalar)}, nil
+}
+
+// SetECDSAPublicKey sets p to the public key k, and returns p. If k is not
+// a valid P-256 public key, it returns nil and an error, and p is unchanged.
+func (p *P256Point) SetECDSAPublicKey(k *ecdsa.PublicKey) (*P256Point, error) {
+	if k.Curve != elliptic.P256() && k.Curve != p256Curve {
+		return nil, errors.New("ecdsa public key is not on P-256")
+	}
+	b, err := ecdsaPublicKeyBytes(k, p256ElementLength)
+	if err != nil {
+		return nil, err
+	}
+	return p.SetBytes(b)
+}
+
+// ECDSAPublicKey returns p as a crypto/ecdsa public key, or an error if p is
+// the point at infinity.
+func (p *P256Point) ECDSAPublicKey() (*ecdsa.PublicKey, error) {
+	return ecdsaPublicKey(elliptic.P256(), p.Bytes())
+}
+
+// P256ScalarFromECDSA returns the 32-byte big-endian private scalar of k, or an
+// error if k is not a valid P-256 private key.
+func P256ScalarFromECDSA(k *ecdsa.PrivateKey) ([]byte, error) {
+	if k.Curve != elliptic.P256() && k.Curve != p256Curve {
+		return nil, errors.New("ecdsa private key is not on P-256")
+	}
+	return ecdsaScalar(k, p256Order)
+}
+
+// P256ECDSAPrivateKey returns the crypto/ecdsa private key with the 32-byte
+// big-endian private scalar, or an error if scalar is not in [1, N-1].
+func P256ECDSAPrivateKey(scalar []byte) (*ecdsa.PrivateKey, error) {
+	if err := checkPrivateScalar(scalar, p256Order); err != nil {
+		return nil, err
+	}
+	q, err := NewP256Point().ScalarBaseMult(scalar)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := ecdsaPublicKey(elliptic.P256(), q.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return &ecdsa.PrivateKey{PublicKey: *pub, D: new(big.Int).SetBytes(scalar)}, nil
+}
+
+// SetECDHPublicKey sets p to the public key k, and returns p. If k is not a
+// P-256 public key, it returns nil and an error, and p is unchanged.
+func (p *P256Point) SetECDHPublicKey(k *ecdh.PublicKey) (*P256Point, error) {
+	if k.Curve() != ecdh.P256() {
+		return nil, errors.New("ecdh public key is not on P-256")
+	}
+	return p.SetBytes(k.Bytes())
+}
+
+// ECDHPublicKey returns p as a crypto/ecdh public key, or an error if p is the
+// point at infinity.
+func (p *P256Point) ECDHPublicKey() (*ecdh.PublicKey, error) {
+	b := p.Bytes()
+	if len(b) == 1 {
+		return nil, errors.New("P-256 point is the point at infinity")
+	}
+	return ecdh.P256().NewPublicKey(b)
+}
+
+// P256ScalarFromECDH returns the 32-byte big-endian private scalar of k, or an
+// error if k is not a P-256 private key.
+func P256ScalarFromECDH(k *ecdh.PrivateKey) ([]byte, error) {
+	if k.Curve() != ecdh.P256() {
+		return nil, errors.New("ecdh private key is not on P-256")
+	}
+	return k.Bytes(), nil
+}
+
+// P256ECDHPrivateKey returns the crypto/ecdh private key with the 32-byte
+// big-endian private scalar, or an error if scalar is not in [1, N-1].
+func P256ECDHPrivateKey(scalar []byte) (*ecdh.PrivateKey, error) {
+	if err := checkPrivateScalar(scalar, p256Order); err != nil {
+		return nil, err
+	}
+	return ecdh.P256().NewPrivateKey(scalar)
+}
+
+// SetECDSAPublicKey sets p to the public key k, and returns p. If k is not
+// a valid P-384 public key, it returns nil and an error, and p is unchanged.
+func (p *P384Point) SetECDSAPublicKey(k *ecdsa.PublicKey) (*P384Point, error) {
+	if k.Curve != elliptic.P384() && k.Curve != p384Curve {
+		return nil, errors.New("ecdsa public key is not on P-384")
+	}
+	b, err := ecdsaPublicKeyBytes(k, p384ElementLength)
+	if err != nil {
+		return nil, err
+	}
+	return p.SetBytes(b)
+}
+
+// ECDSAPublicKey returns p as a crypto/ecdsa public key, or an error if p is
+// the point at infinity.
+func (p *P384Point) ECDSAPublicKey() (*ecdsa.PublicKey, error) {
+	return ecdsaPublicKey(elliptic.P384(), p.Bytes())
+}
+
+// P384ScalarFromECDSA returns the 48-byte big-endian private scalar of k, or an
+// error if k is not a valid P-384 private key.
+func P384ScalarFromECDSA(k *ecdsa.PrivateKey) ([]byte, error) {
+	if k.Curve != elliptic.P384() && k.Curve != p384Curve {
+		return nil, errors.New("ecdsa private key is not on P-384")
+	}
+	return ecdsaScalar(k, p384Order)
+}
+
+// P384ECDSAPrivateKey returns the crypto/ecdsa private key with the 48-byte
+// big-endian private scalar, or an error if scalar is not in [1, N-1].
+func P384ECDSAPrivateKey(scalar []byte) (*ecdsa.PrivateKey, error) {
+	if err := checkPrivateScalar(scalar, p384Order); err != nil {
+		return nil, err
+	}
+	q, err := NewP384Point().ScalarBaseMult(scalar)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := ecdsaPublicKey(elliptic.P384(), q.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return &ecdsa.PrivateKey{PublicKey: *pub, D: new(big.Int).SetBytes(scalar)}, nil
+}
+
+// SetECDHPublicKey sets p to the public key k, and returns p. If k is not a
+// P-384 public key, it returns nil and an error, and p is unchanged.
+func (p *P384Point) SetECDHPublicKey(k *ecdh.PublicKey) (*P384Point, error) {
+	if k.Curve() != ecdh.P384() {
+		return nil, errors.New("ecdh public key is not on P-384")
+	}
+	return p.SetBytes(k.Bytes())
+}
+
+// ECDHPublicKey returns p as a crypto/ecdh public key, or an error if p is the
+// point at infinity.
+func (p *P384Point) ECDHPublicKey() (*ecdh.PublicKey, error) {
+	b := p.Bytes()
+	if len(b) == 1 {
+		return nil, errors.New("P-384 point is the point at infinity")
+	}
+	return ecdh.P384().NewPublicKey(b)
+}
+
+// P384ScalarFromECDH returns the 48-byte big-endian private scalar of k, or an
+// error if k is not a P-384 private key.
+func P384ScalarFromECDH(k *ecdh.PrivateKey) ([]byte, error) {
+	if k.Curve() != ecdh.P384() {
+		return nil, errors.New("ecdh private key is not on P-384")
+	}
+	return k.Bytes(), nil
+}
+
+// P384ECDHPrivateKey returns the crypto/ecdh private key with the 48-byte
+// big-endian private scalar, or an error if scalar is not in [1, N-1].
+func P384ECDHPrivateKey(scalar []byte) (*ecdh.PrivateKey, error) {
+	if err := checkPrivateScalar(scalar, p384Order); err != nil {
+		return nil, err
+	}
+	return ecdh.P384().NewPrivateKey(scalar)
+}
+
+// SetECDSAPublicKey sets p to the public key k, and returns p. If k is not
+// a valid P-521 public key, it returns nil and an error, and p is unchanged.
+func (p *P521Point) SetECDSAPublicKey(k *ecdsa.PublicKey) (*P521Point, error) {
+	if k.Curve != elliptic.P521() && k.Curve != p521Curve {
+		return nil, errors.New("ecdsa public key is not on P-521")
+	}
+	b, err := ecdsaPublicKeyBytes(k, p521ElementLength)
+	if err != nil {
+		return nil, err
+	}
+	return p.SetBytes(b)
+}
+
+// ECDSAPublicKey returns p as a crypto/ecdsa public key, or an error if p is
+// the point at infinity.
+func (p *P521Point) ECDSAPublicKey() (*ecdsa.PublicKey, error) {
+	return ecdsaPublicKey(elliptic.P521(), p.Bytes())
+}
+
+// P521ScalarFromECDSA returns the 66-byte big-endian private scalar of k, or an
+// error if k is not a valid P-521 private key.
+func P521ScalarFromECDSA(k *ecdsa.PrivateKey) ([]byte, error) {
+	if k.Curve != elliptic.P521() && k.Curve != p521Curve {
+		return nil, errors.New("ecdsa private key is not on P-521")
+	}
+	return ecdsaScalar(k, p521Order)
+}
+
+// P521ECDSAPrivateKey returns the crypto/ecdsa private key with the 66-byte
+// big-endian private scalar, or an error if scalar is not in [1, N-1].
+func P521ECDSAPrivateKey(scalar []byte) (*ecdsa.PrivateKey, error) {
+	if err := checkPrivateScalar(scalar, p521Order); err != nil {
+		return nil, err
+	}
+	q, err := NewP521Point().ScalarBaseMult(scalar)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := ecdsaPublicKey(elliptic.P521(), q.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return &ecdsa.PrivateKey{PublicKey: *pub, D: new(big.Int).SetBytes(scalar)}, nil
+}
+
+// SetECDHPublicKey sets p to the public key k, and returns p. If k is not a
+// P-521 public key, it returns nil and an error, and p is unchanged.
+func (p *P521Point) SetECDHPublicKey(k *ecdh.PublicKey) (*P521Point, error) {
+	if k.Curve() != ecdh.P521() {
+		return nil, errors.New("ecdh public key is not on P-521")
+	}
+	return p.SetBytes(k.Bytes())
+}
+
+// ECDHPublicKey returns p as a crypto/ecdh public key, or an error if p is the
+// point at infinity.
+func (p *P521Point) ECDHPublicKey() (*ecdh.PublicKey, error) {
+	b := p.Bytes()
+	if len(b) == 1 {
+		return nil, errors.New("P-521 point is the point at infinity")
+	}
+	return ecdh.P521().NewPublicKey(b)
+}
+
+// P521ScalarFromECDH returns the 66-byte big-endian private scalar of k, or an
+// error if k is not a P-521 private key.
+func P521ScalarFromECDH(k *ecdh.PrivateKey) ([]byte, error) {
+	if k.Curve() != ecdh.P521() {
+		return nil, errors.New("ecdh private key is not on P-521")
+	}
+	return k.Bytes(), nil
+}
+
+// P521ECDHPrivateKey returns the crypto/ecdh private key with the 66-byte
+// big-endian private scalar, or an error if scalar is not in [1, N-1].
+func P521ECDHPrivateKey(scalar []byte) (*ecdh.PrivateKey, error) {
+	if err := checkPrivateScalar(scalar, p521Order); err != nil {
+		return nil, err
+	}
+	return ecdh.P521().NewPrivateKey(scalar)
+}
@@ -0,0 +1,172 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+
+package nistec_test
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/magical/nistec-extra"
+)
+
+type nistPointKeys[T any] interface {
+	nistPoint[T]
+	SetECDSAPublicKey(*ecdsa.PublicKey) (T, error)
+	ECDSAPublicKey() (*ecdsa.PublicKey, error)
+}
+
+type nistPointECDH[T any] interface {
+	nistPointKeys[T]
+	BytesX() ([]byte, error)
+	SetECDHPublicKey(*ecdh.PublicKey) (T, error)
+	ECDHPublicKey() (*ecdh.PublicKey, error)
+}
+
+func TestECDHKeys(t *testing.T) {
+	t.Run("P256", func(t *testing.T) {
+		testECDHKeys(t, nistec.NewP256Point, ecdh.P256(), ecdh.P384(),
+			nistec.P256ScalarFromECDH, nistec.P256ECDHPrivateKey)
+	})
+	t.Run("P384", func(t *testing.T) {
+		testECDHKeys(t, nistec.NewP384Point, ecdh.P384(), ecdh.P521(),
+			nistec.P384ScalarFromECDH, nistec.P384ECDHPrivateKey)
+	})
+	t.Run("P521", func(t *testing.T) {
+		testECDHKeys(t, nistec.NewP521Point, ecdh.P521(), ecdh.P256(),
+			nistec.P521ScalarFromECDH, nistec.P521ECDHPrivateKey)
+	})
+}
+
+func testECDHKeys[P nistPointECDH[P]](t *testing.T, newPoint func() P, c, other ecdh.Curve,
+	scalarFromECDH func(*ecdh.PrivateKey) ([]byte, error), ecdhPrivateKey func([]byte) (*ecdh.PrivateKey, error)) {
+	priv, err := c.GenerateKey(rand.Reader)
+	fatalIfErr(t, err)
+	peer, err := c.GenerateKey(rand.Reader)
+	fatalIfErr(t, err)
+
+	// Private key → scalar → private key.
+	scalar, err := scalarFromECDH(priv)
+	fatalIfErr(t, err)
+	priv2, err := ecdhPrivateKey(scalar)
+	fatalIfErr(t, err)
+	if !priv2.Equal(priv) {
+		t.Error("private key round trip failed")
+	}
+
+	// Public key → point → public key.
+	pub, err := newPoint().ScalarBaseMult(scalar)
+	fatalIfErr(t, err)
+	p, err := newPoint().SetECDHPublicKey(priv.PublicKey())
+	fatalIfErr(t, err)
+	if !bytes.Equal(p.Bytes(), pub.Bytes()) {
+		t.Error("SetECDHPublicKey doesn't match ScalarBaseMult")
+	}
+	pub2, err := p.ECDHPublicKey()
+	fatalIfErr(t, err)
+	if !pub2.Equal(priv.PublicKey()) {
+		t.Error("public key round trip failed")
+	}
+
+	// The shared secrets agree.
+	want, err := priv.ECDH(peer.PublicKey())
+	fatalIfErr(t, err)
+	q, err := newPoint().SetECDHPublicKey(peer.PublicKey())
+	fatalIfErr(t, err)
+	q.ScalarMult(q, scalar)
+	got, err := q.BytesX()
+	fatalIfErr(t, err)
+	if !bytes.Equal(got, want) {
+		t.Error("ECDH shared secret mismatch")
+	}
+
+	otherKey, err := other.GenerateKey(rand.Reader)
+	fatalIfErr(t, err)
+	if _, err := newPoint().SetECDHPublicKey(otherKey.PublicKey()); err == nil {
+		t.Error("SetECDHPublicKey accepted a key on the wrong curve")
+	}
+	if _, err := scalarFromECDH(otherKey); err == nil {
+		t.Error("ScalarFromECDH accepted a key on the wrong curve")
+	}
+	if _, err := newPoint().ECDHPublicKey(); err == nil {
+		t.Error("ECDHPublicKey accepted the point at infinity")
+	}
+	if _, err := ecdhPrivateKey(make([]byte, len(scalar))); err == nil {
+		t.Error("ECDHPrivateKey accepted a zero scalar")
+	}
+}
+
+func TestECDSAKeys(t *testing.T) {
+	t.Run("P224", func(t *testing.T) {
+		testECDSAKeys(t, nistec.NewP224Point, elliptic.P224(), elliptic.P256(),
+			nistec.P224ScalarFromECDSA, nistec.P224ECDSAPrivateKey)
+	})
+	t.Run("P256", func(t *testing.T) {
+		testECDSAKeys(t, nistec.NewP256Point, elliptic.P256(), elliptic.P384(),
+			nistec.P256ScalarFromECDSA, nistec.P256ECDSAPrivateKey)
+	})
+	t.Run("P384", func(t *testing.T) {
+		testECDSAKeys(t, nistec.NewP384Point, elliptic.P384(), elliptic.P521(),
+			nistec.P384ScalarFromECDSA, nistec.P384ECDSAPrivateKey)
+	})
+	t.Run("P521", func(t *testing.T) {
+		testECDSAKeys(t, nistec.NewP521Point, elliptic.P521(), elliptic.P224(),
+			nistec.P521ScalarFromECDSA, nistec.P521ECDSAPrivateKey)
+	})
+}
+
+func testECDSAKeys[P nistPointKeys[P]](t *testing.T, newPoint func() P, c, other elliptic.Curve,
+	scalarFromECDSA func(*ecdsa.PrivateKey) ([]byte, error), ecdsaPrivateKey func([]byte) (*ecdsa.PrivateKey, error)) {
+	priv, err := ecdsa.GenerateKey(c, rand.Reader)
+	fatalIfErr(t, err)
+
+	scalar, err := scalarFromECDSA(priv)
+	fatalIfErr(t, err)
+	priv2, err := ecdsaPrivateKey(scalar)
+	fatalIfErr(t, err)
+	if !priv2.Equal(priv) {
+		t.Error("private key round trip failed")
+	}
+
+	p, err := newPoint().SetECDSAPublicKey(&priv.PublicKey)
+	fatalIfErr(t, err)
+	pub, err := p.ECDSAPublicKey()
+	fatalIfErr(t, err)
+	if !pub.Equal(&priv.PublicKey) {
+		t.Error("public key round trip failed")
+	}
+
+	hash := sha256.Sum256([]byte("hello"))
+	sig, err := ecdsa.SignASN1(rand.Reader, priv2, hash[:])
+	fatalIfErr(t, err)
+	if !ecdsa.VerifyASN1(pub, hash[:], sig) {
+		t.Error("signature with converted keys failed to verify")
+	}
+
+	otherKey, err := ecdsa.GenerateKey(other, rand.Reader)
+	fatalIfErr(t, err)
+	if _, err := newPoint().SetECDSAPublicKey(&otherKey.PublicKey); err == nil {
+		t.Error("SetECDSAPublicKey accepted a key on the wrong curve")
+	}
+	if _, err := scalarFromECDSA(otherKey); err == nil {
+		t.Error("ScalarFromECDSA accepted a key on the wrong curve")
+	}
+	if _, err := newPoint().ECDSAPublicKey(); err == nil {
+		t.Error("ECDSAPublicKey accepted the point at infinity")
+	}
+	if _, err := ecdsaPrivateKey(make([]byte, len(scalar))); err == nil {
+		t.Error("ECDSAPrivateKey accepted a zero scalar")
+	}
+	offCurve := &ecdsa.PublicKey{Curve: c, X: priv.X, Y: priv.X}
+	if _, err := newPoint().SetECDSAPublicKey(offCurve); err == nil {
+		t.Error("SetECDSAPublicKey accepted a point not on the curve")
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec
+
+import (
+	"encoding/hex"
+	"errors"
+)
+
+// appendHex appends the lowercase hexadecimal encoding of src to b.
+func appendHex(b, src []byte) []byte {
+	n := len(b)
+	b = append(b, make([]byte, hex.EncodedLen(len(src)))...)
+	hex.Encode(b[n:], src)
+	return b
+}
+
+// decodeHex decodes the hexadecimal text representation of a point.
+func decodeHex(text []byte, curve string) ([]byte, error) {
+	b := make([]byte, hex.DecodedLen(len(text)))
+	if _, err := hex.Decode(b, text); err != nil {
+		return nil, errors.New("invalid " + curve + " point text encoding: " + err.Error())
+	}
+	return b, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. It returns the same
+// encoding as Bytes.
+func (p *P224Point) MarshalBinary() ([]byte, error) {
+	return p.Bytes(), nil
+}
+
+// AppendBinary appends the encoding returned by Bytes to b. It doesn't
+// allocate if b has enough spare capacity.
+func (p *P224Point) AppendBinary(b []byte) ([]byte, error) {
+	var out [1 + 2*p224ElementLength]byte
+	return append(b, p.bytes(&out)...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It accepts the same
+// encodings as SetBytes, and leaves p unchanged if b is invalid. Unlike
+// SetBytes, it can be called on the zero value of P224Point.
+func (p *P224Point) UnmarshalBinary(b []byte) error {
+	q, err := NewP224Point().SetBytes(b)
+	if err != nil {
+		return err
+	}
+	*p = *q
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler. It returns the lowercase
+// hexadecimal form of the encoding returned by Bytes. Since P224Point
+// implements encoding.TextMarshaler, encoding/json marshals it as a string.
+func (p *P224Point) MarshalText() ([]byte, error) {
+	return p.AppendText(nil)
+}
+
+// AppendText appends the result of MarshalText to b. It doesn't allocate if b
+// has enough spare capacity.
+func (p *P224Point) AppendText(b []byte) ([]byte, error) {
+	var out [1 + 2*p224ElementLength]byte
+	return appendHex(b, p.bytes(&out)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts the
+// hexadecimal form of any encoding accepted by SetBytes, and leaves p
+// unchanged if text is invalid.
+func (p *P224Point) UnmarshalText(text []byte) error {
+	b, err := decodeHex(text, "P224")
+	if err != nil {
+		return err
+	}
+	return p.UnmarshalBinary(b)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. It returns the same
+// encoding as Bytes.
+func (p *P256Point) MarshalBinary() ([]byte, error) {
+	return p.Bytes(), nil
+}
+
+// AppendBinary appends the encoding returned by Bytes to b. It doesn't
+// allocate if b has enough spare capacity.
+func (p *P256Point) AppendBinary(b []byte) ([]byte, error) {
+	var out [1 + 2*p256ElementLength]byte
+	return append(b, p.bytes(&out)...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It accepts the same
+// encodings as SetBytes, and leaves p unchanged if b is invalid. Unlike
+// SetBytes, it can be called on the zero value of P256Point.
+func (p *P256Point) UnmarshalBinary(b []byte) error {
+	q, err := NewP256Point().SetBytes(b)
+	if err != nil {
+		return err
+	}
+	*p = *q
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler. It returns the lowercase
+// hexadecimal form of the encoding returned by Bytes. Since P256Point
+// implements encoding.TextMarshaler, encoding/json marshals it as a string.
+func (p *P256Point) MarshalText() ([]byte, error) {
+	return p.AppendText(nil)
+}
+
+// AppendText appends the result of MarshalText to b. It doesn't allocate if b
+// has enough spare capacity.
+func (p *P256Point) AppendText(b []byte) ([]byte, error) {
+	var out [1 + 2*p256ElementLength]byte
+	return appendHex(b, p.bytes(&out)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts the
+// hexadecimal form of any encoding accepted by SetBytes, and leaves p
+// unchanged if text is invalid.
+func (p *P256Point) UnmarshalText(text []byte) error {
+	b, err := decodeHex(text, "P256")
+	if err != nil {
+		return err
+	}
+	return p.UnmarshalBinary(b)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. It returns the same
+// encoding as Bytes.
+func (p *P384Point) MarshalBinary() ([]byte, error) {
+	return p.Bytes(), nil
+}
+
+// AppendBinary appends the encoding returned by Bytes to b. It doesn't
+// allocate if b has enough spare capacity.
+func (p *P384Point) AppendBinary(b []byte) ([]byte, error) {
+	var out [1 + 2*p384ElementLength]byte
+	return append(b, p.bytes(&out)...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It accepts the same
+// encodings as SetBytes, and leaves p unchanged if b is invalid. Unlike
+// SetBytes, it can be called on the zero value of P384Point.
+func (p *P384Point) UnmarshalBinary(b []byte) error {
+	q, err := NewP384Point().SetBytes(b)
+	if err != nil {
+		return err
+	}
+	*p = *q
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler. It returns the lowercase
+// hexadecimal form of the encoding returned by Bytes. Since P384Point
+// implements encoding.TextMarshaler, encoding/json marshals it as a string.
+func (p *P384Point) MarshalText() ([]byte, error) {
+	return p.AppendText(nil)
+}
+
+// AppendText appends the result of MarshalText to b. It doesn't allocate if b
+// has enough spare capacity.
+func (p *P384Point) AppendText(b []byte) ([]byte, error) {
+	var out [1 + 2*p384ElementLength]byte
+	return appendHex(b, p.bytes(&out)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts the
+// hexadecimal form of any encoding accepted by SetBytes, and leaves p
+// unchanged if text is invalid.
+func (p *P384Point) UnmarshalText(text []byte) error {
+	b, err := decodeHex(text, "P384")
+	if err != nil {
+		return err
+	}
+	return p.UnmarshalBinary(b)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. It returns the same
+// encoding as Bytes.
+func (p *P521Point) MarshalBinary() ([]byte, error) {
+	return p.Bytes(), nil
+}
+
+// AppendBinary appends the encoding returned by Bytes to b. It doesn't
+// allocate if b has enough spare capacity.
+func (p *P521Point) AppendBinary(b []byte) ([]byte, error) {
+	var out [1 + 2*p521ElementLength]byte
+	return append(b, p.bytes(&out)...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It accepts the same
+// encodings as SetBytes, and leaves p unchanged if b is invalid. Unlike
+// SetBytes, it can be called on the zero value of P521Point.
+func (p *P521Point) UnmarshalBinary(b []byte) error {
+	q, err := NewP521Point().SetBytes(b)
+	if err != nil {
+		return err
+	}
+	*p = *q
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler. It returns the lowercase
+// hexadecimal form of the encoding returned by Bytes. Since P521Point
+// implements encoding.TextMarshaler, encoding/json marshals it as a string.
+func (p *P521Point) MarshalText() ([]byte, error) {
+	return p.AppendText(nil)
+}
+
+// AppendText appends the result of MarshalText to b. It doesn't allocate if b
+// has enough spare capacity.
+func (p *P521Point) AppendText(b []byte) ([]byte, error) {
+	var out [1 + 2*p521ElementLength]byte
+	return appendHex(b, p.bytes(&out)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts the
+// hexadecimal form of any encoding accepted by SetBytes, and leaves p
+// unchanged if text is invalid.
+func (p *P521Point) UnmarshalText(text []byte) error {
+	b, err := decodeHex(text, "P521")
+	if err != nil {
+		return err
+	}
+	return p.UnmarshalBinary(b)
+}
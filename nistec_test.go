@@ -31,6 +31,9 @@ func TestAllocations(t *testing.T) {
 			if _, err := p.SetBytes(out); err != nil {
 				t.Fatal(err)
 			}
+			buf := make([]byte, 0, 512)
+			buf, _ = p.AppendBinary(buf)
+			buf, _ = p.AppendText(buf[:0])
 		}); allocs > 0 {
 			t.Errorf("expected zero allocations, got %0.1f", allocs)
 		}
@@ -50,6 +53,9 @@ func TestAllocations(t *testing.T) {
 			if _, err := p.SetBytes(out); err != nil {
 				t.Fatal(err)
 			}
+			buf := make([]byte, 0, 512)
+			buf, _ = p.AppendBinary(buf)
+			buf, _ = p.AppendText(buf[:0])
 		}); allocs > 0 {
 			t.Errorf("expected zero allocations, got %0.1f", allocs)
 		}
@@ -69,6 +75,9 @@ func TestAllocations(t *testing.T) {
 			if _, err := p.SetBytes(out); err != nil {
 				t.Fatal(err)
 			}
+			buf := make([]byte, 0, 512)
+			buf, _ = p.AppendBinary(buf)
+			buf, _ = p.AppendText(buf[:0])
 		}); allocs > 0 {
 			t.Errorf("expected zero allocations, got %0.1f", allocs)
 		}
@@ -88,6 +97,9 @@ func TestAllocations(t *testing.T) {
 			if _, err := p.SetBytes(out); err != nil {
 				t.Fatal(err)
 			}
+			buf := make([]byte, 0, 512)
+			buf, _ = p.AppendBinary(buf)
+			buf, _ = p.AppendText(buf[:0])
 		}); allocs > 0 {
 			t.Errorf("expected zero allocations, got %0.1f", allocs)
 		}
@@ -307,3 +319,35 @@ func benchmarkScalarBaseMult[P nistPoint[P]](b *testing.B, p P, scalarSize int)
 		p.ScalarBaseMult(scalar)
 	}
 }
+
+type nistPointCompressed[T any] interface {
+	nistPoint[T]
+	BytesCompressed() []byte
+}
+
+func BenchmarkSetBytesCompressed(b *testing.B) {
+	b.Run("P224", func(b *testing.B) {
+		benchmarkSetBytesCompressed(b, nistec.NewP224Point().SetGenerator(), 28)
+	})
+	b.Run("P256", func(b *testing.B) {
+		benchmarkSetBytesCompressed(b, nistec.NewP256Point().SetGenerator(), 32)
+	})
+	b.Run("P384", func(b *testing.B) {
+		benchmarkSetBytesCompressed(b, nistec.NewP384Point().SetGenerator(), 48)
+	})
+	b.Run("P521", func(b *testing.B) {
+		benchmarkSetBytesCompressed(b, nistec.NewP521Point().SetGenerator(), 66)
+	})
+}
+
+func benchmarkSetBytesCompressed[P nistPointCompressed[P]](b *testing.B, p P, scalarSize int) {
+	scalar := make([]byte, scalarSize)
+	rand.Read(scalar)
+	p.ScalarBaseMult(scalar)
+	compressed := p.BytesCompressed()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.SetBytes(compressed)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oprf
+
+import (
+	"errors"
+	"io"
+
+	"github.com/magical/nistec-extra/group"
+)
+
+// The VOPRF and POPRF modes prove that the server evaluated the elements with
+// the key k of its public key B = [k]A, with a batched discrete logarithm
+// equality proof that D[i] = [k]C[i] for all i, from RFC 9497, Section 2.2.
+// The elements are first combined into M = Σ dᵢ × C[i] and Z = Σ dᵢ × D[i] =
+// [k]M, with coefficients dᵢ derived from all the elements, and the proof is
+// then a Schnorr proof that B = [k]A and Z = [k]M.
+//
+// The generator A is always the generator of the group.
+
+// computeComposites returns M and Z. If k is not nil, Z is computed as [k]M,
+// as in ComputeCompositesFast.
+func (s *Suite) computeComposites(mode Mode, k group.Scalar, B group.Element, C, D []group.Element) (M, Z group.Element) {
+	seedDST := append([]byte("Seed-"), s.contextString(mode)...)
+	h := s.hash()
+	h.Write(lengthPrefixed(B.BytesCompressed()))
+	h.Write(lengthPrefixed(seedDST))
+	seed := h.Sum(nil)
+
+	dst := append([]byte("HashToScalar-"), s.contextString(mode)...)
+	M, Z = s.g.NewElement(), s.g.NewElement()
+	t := s.g.NewElement()
+	for i := range C {
+		transcript := lengthPrefixed(seed)
+		transcript = append(transcript, byte(i>>8), byte(i))
+		transcript = append(transcript, lengthPrefixed(C[i].BytesCompressed())...)
+		transcript = append(transcript, lengthPrefixed(D[i].BytesCompressed())...)
+		transcript = append(transcript, "Composite"...)
+		di := s.g.HashToScalar(transcript, dst)
+		M.Add(M, t.ScalarMult(C[i], di))
+		if k == nil {
+			Z.Add(Z, t.ScalarMult(D[i], di))
+		}
+	}
+	if k != nil {
+		Z.ScalarMult(M, k)
+	}
+	return M, Z
+}
+
+// challenge returns the challenge scalar c of the proof.
+func (s *Suite) challenge(mode Mode, B, M, Z, t2, t3 group.Element) group.Scalar {
+	var transcript []byte
+	for _, e := range []group.Element{B, M, Z, t2, t3} {
+		transcript = append(transcript, lengthPrefixed(e.BytesCompressed())...)
+	}
+	transcript = append(transcript, "Challenge"...)
+	return s.g.HashToScalar(transcript, append([]byte("HashToScalar-"), s.contextString(mode)...))
+}
+
+// generateProof implements GenerateProof, proving that B = [k]G and D[i] =
+// [k]C[i], with the nonce r read from rand. It returns the encodings of c and
+// s.
+func (s *Suite) generateProof(rand io.Reader, mode Mode, k group.Scalar, B group.Element, C, D []group.Element) ([]byte, error) {
+	if len(C) != len(D) || len(C) > 0xffff {
+		return nil, errors.New("oprf: invalid number of elements")
+	}
+	M, Z := s.computeComposites(mode, k, B, C, D)
+	r, err := s.g.RandomScalar(rand)
+	if err != nil {
+		return nil, err
+	}
+	t2 := s.g.NewElement().ScalarBaseMult(r)
+	t3 := s.g.NewElement().ScalarMult(M, r)
+	c := s.challenge(mode, B, M, Z, t2, t3)
+	// s = r - c × k
+	ss := s.g.NewScalar().Multiply(c, k)
+	ss.Subtract(r, ss)
+	return append(c.Bytes(), ss.Bytes()...), nil
+}
+
+// verifyProof implements VerifyProof, and reports whether proof proves that
+// B = [k]G and D[i] = [k]C[i] for the same k.
+func (s *Suite) verifyProof(mode Mode, B group.Element, C, D []group.Element, proof []byte) bool {
+	n := s.g.ScalarLength()
+	if len(proof) != 2*n || len(C) != len(D) || len(C) > 0xffff {
+		return false
+	}
+	c, err := s.g.NewScalar().SetBytes(proof[:n])
+	if err != nil {
+		return false
+	}
+	ss, err := s.g.NewScalar().SetBytes(proof[n:])
+	if err != nil {
+		return false
+	}
+	M, Z := s.computeComposites(mode, nil, B, C, D)
+	// t2 = [s]G + [c]B, t3 = [s]M + [c]Z
+	t2 := s.g.NewElement().ScalarBaseMult(ss)
+	t2.Add(t2, s.g.NewElement().ScalarMult(B, c))
+	t3 := s.g.NewElement().ScalarMult(M, ss)
+	t3.Add(t3, s.g.NewElement().ScalarMult(Z, c))
+	return s.challenge(mode, B, M, Z, t2, t3).Equal(c) == 1
+}
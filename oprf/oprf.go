@@ -0,0 +1,407 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package oprf implements the Oblivious Pseudorandom Functions of RFC 9497,
+// over the groups of package group.
+//
+// In all three modes, the client blinds its inputs with Client.Blind, the
+// server evaluates the blinded elements with Server.BlindEvaluate, and the
+// client unblinds the result with Client.Finalize, obtaining the same outputs
+// as Server.Evaluate would, without the server learning the inputs. In the
+// VOPRF mode, the server also proves that it used the key of its public key,
+// and in the POPRF mode, the evaluation additionally depends on a public info
+// string known to both parties.
+//
+// Inputs and info strings must be shorter than 2¹⁶ bytes.
+package oprf
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"hash"
+	"io"
+
+	"github.com/magical/nistec-extra"
+	"github.com/magical/nistec-extra/group"
+)
+
+// Mode is the mode of the protocol.
+type Mode byte
+
+const (
+	ModeOPRF  Mode = 0x00 // OPRF, Section 3.3.1
+	ModeVOPRF Mode = 0x01 // VOPRF, Section 3.3.2
+	ModePOPRF Mode = 0x02 // POPRF, Section 3.3.3
+)
+
+// Suite is an OPRF ciphersuite.
+type Suite struct {
+	identifier string
+	g          group.Group
+	hash       func() hash.Hash
+
+	// inverse, if not nil, is a faster constant-time inversion modulo the
+	// order. It may fail, in which case the group inversion is used instead.
+	inverse func(k []byte) ([]byte, error)
+}
+
+var (
+	p256SHA256 = &Suite{"P256-SHA256", group.P256(), sha256.New, nistec.P256OrdInverse}
+	p384SHA384 = &Suite{"P384-SHA384", group.P384(), sha512.New384, nil}
+	p521SHA512 = &Suite{"P521-SHA512", group.P521(), sha512.New, nil}
+)
+
+// P256SHA256 returns the P256-SHA256 ciphersuite.
+func P256SHA256() *Suite { return p256SHA256 }
+
+// P384SHA384 returns the P384-SHA384 ciphersuite.
+func P384SHA384() *Suite { return p384SHA384 }
+
+// P521SHA512 returns the P521-SHA512 ciphersuite.
+func P521SHA512() *Suite { return p521SHA512 }
+
+// Identifier returns the identifier of the suite, such as "P256-SHA256".
+func (s *Suite) Identifier() string { return s.identifier }
+
+// Group returns the prime order group of the suite.
+func (s *Suite) Group() group.Group { return s.g }
+
+// ParseElement implements DeserializeElement, decoding a compressed element
+// and rejecting the identity.
+func (s *Suite) ParseElement(b []byte) (group.Element, error) {
+	// ElementLength is the length of a compressed element, so this also
+	// rejects uncompressed encodings.
+	if len(b) != s.g.ElementLength() {
+		return nil, errors.New("oprf: invalid element length")
+	}
+	e, err := s.g.NewElement().SetBytes(b)
+	if err != nil {
+		return nil, errors.New("oprf: invalid element: " + err.Error())
+	}
+	if e.IsIdentity() == 1 {
+		return nil, errors.New("oprf: invalid element: identity")
+	}
+	return e, nil
+}
+
+// contextString returns "OPRFV1-" || I2OSP(mode, 1) || "-" || identifier.
+func (s *Suite) contextString(mode Mode) []byte {
+	return append([]byte{'O', 'P', 'R', 'F', 'V', '1', '-', byte(mode), '-'}, s.identifier...)
+}
+
+// invert returns k⁻¹ mod N in constant time.
+func (s *Suite) invert(k group.Scalar) group.Scalar {
+	if s.inverse != nil {
+		if b, err := s.inverse(k.Bytes()); err == nil {
+			if kInv, err := s.g.NewScalar().SetBytes(b); err == nil {
+				return kInv
+			}
+		}
+	}
+	return s.g.NewScalar().Invert(k)
+}
+
+// PrivateKey is a server private key.
+type PrivateKey struct {
+	pub PublicKey
+	k   group.Scalar
+}
+
+// PublicKey is a server public key, used by the VOPRF and POPRF modes.
+type PublicKey struct {
+	s *Suite
+	e group.Element
+}
+
+// GenerateKey returns a new private key, using rand as the source of
+// randomness.
+func (s *Suite) GenerateKey(rand io.Reader) (*PrivateKey, error) {
+	k, err := s.g.RandomScalar(rand)
+	if err != nil {
+		return nil, err
+	}
+	return s.newPrivateKey(k), nil
+}
+
+// DeriveKeyPair deterministically derives a private key for mode from a
+// secret seed, which should be at least ScalarLength bytes long, and from
+// info, as in RFC 9497, Section 3.2.1.
+func (s *Suite) DeriveKeyPair(mode Mode, seed, info []byte) (*PrivateKey, error) {
+	if len(info) > 0xffff {
+		return nil, errors.New("oprf: info too long")
+	}
+	deriveInput := append(append([]byte(nil), seed...), lengthPrefixed(info)...)
+	dst := append([]byte("DeriveKeyPair"), s.contextString(mode)...)
+	for counter := 0; counter < 256; counter++ {
+		k := s.g.HashToScalar(append(deriveInput, byte(counter)), dst)
+		if k.IsZero() == 0 {
+			return s.newPrivateKey(k), nil
+		}
+	}
+	return nil, errors.New("oprf: DeriveKeyPair failed")
+}
+
+// NewPrivateKey returns the private key with scalar k, which must be the
+// big-endian encoding of an integer in [1, N-1], ScalarLength bytes long.
+func (s *Suite) NewPrivateKey(k []byte) (*PrivateKey, error) {
+	sk, err := s.g.NewScalar().SetBytes(k)
+	if err != nil {
+		return nil, errors.New("oprf: invalid private key: " + err.Error())
+	}
+	if sk.IsZero() == 1 {
+		return nil, errors.New("oprf: invalid private key: zero scalar")
+	}
+	return s.newPrivateKey(sk), nil
+}
+
+func (s *Suite) newPrivateKey(k group.Scalar) *PrivateKey {
+	return &PrivateKey{pub: PublicKey{s: s, e: s.g.NewElement().ScalarBaseMult(k)}, k: k}
+}
+
+// NewPublicKey returns the public key encoded as b, a compressed element.
+func (s *Suite) NewPublicKey(b []byte) (*PublicKey, error) {
+	e, err := s.ParseElement(b)
+	if err != nil {
+		return nil, err
+	}
+	return &PublicKey{s: s, e: e}, nil
+}
+
+// Bytes returns the encoding of the private scalar.
+func (k *PrivateKey) Bytes() []byte { return k.k.Bytes() }
+
+// PublicKey returns the public key corresponding to k.
+func (k *PrivateKey) PublicKey() *PublicKey { return &k.pub }
+
+// Bytes returns the compressed encoding of the public key, as used by
+// RFC 9497.
+func (k *PublicKey) Bytes() []byte { return k.e.BytesCompressed() }
+
+// lengthPrefixed returns I2OSP(len(b), 2) || b.
+func lengthPrefixed(b []byte) []byte {
+	return append([]byte{byte(len(b) >> 8), byte(len(b))}, b...)
+}
+
+// checkInfo checks that info is only used in the POPRF mode.
+func checkInfo(mode Mode, info []byte) error {
+	if mode != ModePOPRF && info != nil {
+		return errors.New("oprf: info is only supported by the POPRF mode")
+	}
+	if len(info) > 0xffff {
+		return errors.New("oprf: info too long")
+	}
+	return nil
+}
+
+// tweak returns the scalar m = HashToScalar("Info" || I2OSP(len(info), 2)
+// || info), with which the POPRF mode tweaks the key.
+func (s *Suite) tweak(info []byte) group.Scalar {
+	framedInfo := append([]byte("Info"), lengthPrefixed(info)...)
+	return s.g.HashToScalar(framedInfo, append([]byte("HashToScalar-"), s.contextString(ModePOPRF)...))
+}
+
+// finalize returns Hash(I2OSP(len(input), 2) || input || [I2OSP(len(info), 2)
+// || info] || I2OSP(len(element), 2) || element || "Finalize"), where info is
+// only included in the POPRF mode.
+func (s *Suite) finalize(mode Mode, input, info []byte, e group.Element) []byte {
+	h := s.hash()
+	h.Write(lengthPrefixed(input))
+	if mode == ModePOPRF {
+		h.Write(lengthPrefixed(info))
+	}
+	h.Write(lengthPrefixed(e.BytesCompressed()))
+	h.Write([]byte("Finalize"))
+	return h.Sum(nil)
+}
+
+// hashToGroup hashes input to an element, failing if it's the identity.
+func (s *Suite) hashToGroup(mode Mode, input []byte) (group.Element, error) {
+	if len(input) > 0xffff {
+		return nil, errors.New("oprf: input too long")
+	}
+	e := s.g.HashToElement(input, append([]byte("HashToGroup-"), s.contextString(mode)...))
+	if e.IsIdentity() == 1 {
+		return nil, errors.New("oprf: input hashes to the identity")
+	}
+	return e, nil
+}
+
+// Client is the client side of the protocol.
+type Client struct {
+	s    *Suite
+	mode Mode
+	pkS  *PublicKey
+}
+
+// NewClient returns a client for mode. pkS is the public key of the server,
+// which is required in the VOPRF and POPRF modes, and must be nil otherwise.
+func NewClient(s *Suite, mode Mode, pkS *PublicKey) (*Client, error) {
+	if mode > ModePOPRF {
+		return nil, errors.New("oprf: unknown mode")
+	}
+	if (mode == ModeOPRF) != (pkS == nil) {
+		return nil, errors.New("oprf: a public key is required by the VOPRF and POPRF modes only")
+	}
+	if pkS != nil && pkS.s != s {
+		return nil, errors.New("oprf: public key of another suite")
+	}
+	return &Client{s: s, mode: mode, pkS: pkS}, nil
+}
+
+// FinalizeData is the client state between Blind and Finalize. It must be
+// kept secret.
+type FinalizeData struct {
+	inputs  [][]byte
+	blinds  []group.Scalar
+	blinded []group.Element
+}
+
+// Blind blinds each of inputs with a random scalar read from rand, and
+// returns the blinded elements to be sent to the server, and the state needed
+// by Finalize.
+func (c *Client) Blind(rand io.Reader, inputs [][]byte) (*FinalizeData, []group.Element, error) {
+	f := &FinalizeData{}
+	for _, input := range inputs {
+		blind, err := c.s.g.RandomScalar(rand)
+		if err != nil {
+			return nil, nil, err
+		}
+		e, err := c.s.hashToGroup(c.mode, input)
+		if err != nil {
+			return nil, nil, err
+		}
+		f.inputs = append(f.inputs, append([]byte(nil), input...))
+		f.blinds = append(f.blinds, blind)
+		f.blinded = append(f.blinded, e.ScalarMult(e, blind))
+	}
+	return f, f.blinded, nil
+}
+
+// Evaluation is the response of the server to a set of blinded elements.
+type Evaluation struct {
+	// Elements are the evaluated elements, in the same order as the blinded
+	// elements.
+	Elements []group.Element
+	// Proof is the batched DLEQ proof in the VOPRF and POPRF modes, the
+	// encodings of the scalars c and s, and nil in the OPRF mode.
+	Proof []byte
+}
+
+// Finalize checks the proof of ev, if any, and returns the outputs of the
+// PRF for the inputs passed to Blind. info must be the same info that was
+// passed to BlindEvaluate in the POPRF mode, and nil otherwise.
+func (c *Client) Finalize(f *FinalizeData, ev *Evaluation, info []byte) ([][]byte, error) {
+	if err := checkInfo(c.mode, info); err != nil {
+		return nil, err
+	}
+	if len(ev.Elements) != len(f.blinded) {
+		return nil, errors.New("oprf: wrong number of evaluated elements")
+	}
+	for _, e := range ev.Elements {
+		if e.Group() != c.s.g || e.IsIdentity() == 1 {
+			return nil, errors.New("oprf: invalid evaluated element")
+		}
+	}
+	switch c.mode {
+	case ModeVOPRF:
+		if !c.s.verifyProof(c.mode, c.pkS.e, f.blinded, ev.Elements, ev.Proof) {
+			return nil, errors.New("oprf: invalid proof")
+		}
+	case ModePOPRF:
+		// tweakedKey = [m]G + pkS
+		tweakedKey := c.s.g.NewElement().ScalarBaseMult(c.s.tweak(info))
+		tweakedKey.Add(tweakedKey, c.pkS.e)
+		if tweakedKey.IsIdentity() == 1 {
+			return nil, errors.New("oprf: invalid info for this key")
+		}
+		if !c.s.verifyProof(c.mode, tweakedKey, ev.Elements, f.blinded, ev.Proof) {
+			return nil, errors.New("oprf: invalid proof")
+		}
+	}
+	outputs := make([][]byte, len(f.inputs))
+	for i, input := range f.inputs {
+		n := c.s.g.NewElement().ScalarMult(ev.Elements[i], c.s.invert(f.blinds[i]))
+		outputs[i] = c.s.finalize(c.mode, input, info, n)
+	}
+	return outputs, nil
+}
+
+// Server is the server side of the protocol.
+type Server struct {
+	s    *Suite
+	mode Mode
+	skS  *PrivateKey
+}
+
+// NewServer returns a server for mode, using the private key skS.
+func NewServer(s *Suite, mode Mode, skS *PrivateKey) (*Server, error) {
+	if mode > ModePOPRF {
+		return nil, errors.New("oprf: unknown mode")
+	}
+	if skS.pub.s != s {
+		return nil, errors.New("oprf: private key of another suite")
+	}
+	return &Server{s: s, mode: mode, skS: skS}, nil
+}
+
+// key returns the evaluation key, skS, or (skS + m)⁻¹ in the POPRF mode,
+// along with the key proven by the DLEQ proof, skS or skS + m.
+func (s *Server) key(info []byte) (eval, proof group.Scalar, err error) {
+	if err := checkInfo(s.mode, info); err != nil {
+		return nil, nil, err
+	}
+	if s.mode != ModePOPRF {
+		return s.skS.k, s.skS.k, nil
+	}
+	t := s.s.tweak(info)
+	t.Add(t, s.skS.k)
+	if t.IsZero() == 1 {
+		return nil, nil, errors.New("oprf: invalid info for this key")
+	}
+	return s.s.invert(t), t, nil
+}
+
+// BlindEvaluate evaluates the blinded elements received from the client, and
+// in the VOPRF and POPRF modes proves it with randomness read from rand.
+// info must be nil outside of the POPRF mode.
+func (s *Server) BlindEvaluate(rand io.Reader, blinded []group.Element, info []byte) (*Evaluation, error) {
+	k, t, err := s.key(info)
+	if err != nil {
+		return nil, err
+	}
+	ev := &Evaluation{}
+	for _, b := range blinded {
+		if b.Group() != s.s.g || b.IsIdentity() == 1 {
+			return nil, errors.New("oprf: invalid blinded element")
+		}
+		ev.Elements = append(ev.Elements, s.s.g.NewElement().ScalarMult(b, k))
+	}
+	switch s.mode {
+	case ModeVOPRF:
+		ev.Proof, err = s.s.generateProof(rand, s.mode, t, s.skS.pub.e, blinded, ev.Elements)
+	case ModePOPRF:
+		tweakedKey := s.s.g.NewElement().ScalarBaseMult(t)
+		ev.Proof, err = s.s.generateProof(rand, s.mode, t, tweakedKey, ev.Elements, blinded)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// Evaluate returns the output of the PRF for input, as the client would obtain
+// it through Blind, BlindEvaluate and Finalize. info must be nil outside of
+// the POPRF mode.
+func (s *Server) Evaluate(input, info []byte) ([]byte, error) {
+	k, _, err := s.key(info)
+	if err != nil {
+		return nil, err
+	}
+	e, err := s.s.hashToGroup(s.mode, input)
+	if err != nil {
+		return nil, err
+	}
+	return s.s.finalize(s.mode, input, info, e.ScalarMult(e, k)), nil
+}
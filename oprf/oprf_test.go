@@ -0,0 +1,225 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oprf_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/magical/nistec-extra/group"
+	"github.com/magical/nistec-extra/oprf"
+)
+
+func fatalIfErr(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	fatalIfErr(t, err)
+	return b
+}
+
+// decodeList decodes a comma-separated list of hex strings.
+func decodeList(t *testing.T, s string) [][]byte {
+	t.Helper()
+	var out [][]byte
+	for _, item := range strings.Split(s, ",") {
+		out = append(out, decodeHex(t, item))
+	}
+	return out
+}
+
+var suites = map[string]*oprf.Suite{
+	"P256-SHA256": oprf.P256SHA256(),
+	"P384-SHA384": oprf.P384SHA384(),
+	"P521-SHA512": oprf.P521SHA512(),
+}
+
+// TestVectors checks the test vectors of RFC 9497, Appendix A, for the
+// P-256, P-384 and P-521 suites.
+func TestVectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/rfc9497.json")
+	fatalIfErr(t, err)
+	var sets []struct {
+		Identifier string
+		Mode       oprf.Mode
+		Seed       string
+		KeyInfo    string
+		SkSm, PkSm string
+		Vectors    []struct {
+			Batch             int
+			Blind             string
+			BlindedElement    string
+			EvaluationElement string
+			Info              *string
+			Input             string
+			Output            string
+			Proof             struct{ Proof, R string }
+		}
+	}
+	fatalIfErr(t, json.Unmarshal(data, &sets))
+	if len(sets) != 9 {
+		t.Fatalf("got %d sets of vectors, want 9", len(sets))
+	}
+	for _, set := range sets {
+		s := suites[set.Identifier]
+		skS, err := s.DeriveKeyPair(set.Mode, decodeHex(t, set.Seed), decodeHex(t, set.KeyInfo))
+		fatalIfErr(t, err)
+		if got := skS.Bytes(); !bytes.Equal(got, decodeHex(t, set.SkSm)) {
+			t.Errorf("%s/%d: DeriveKeyPair = %x, want %s", set.Identifier, set.Mode, got, set.SkSm)
+		}
+		var pkS *oprf.PublicKey
+		if set.Mode != oprf.ModeOPRF {
+			pkS, err = s.NewPublicKey(decodeHex(t, set.PkSm))
+			fatalIfErr(t, err)
+			if !bytes.Equal(skS.PublicKey().Bytes(), pkS.Bytes()) {
+				t.Errorf("%s/%d: public key = %x, want %s", set.Identifier, set.Mode, skS.PublicKey().Bytes(), set.PkSm)
+			}
+		}
+		client, err := oprf.NewClient(s, set.Mode, pkS)
+		fatalIfErr(t, err)
+		server, err := oprf.NewServer(s, set.Mode, skS)
+		fatalIfErr(t, err)
+
+		for i, v := range set.Vectors {
+			name := set.Identifier + "/" + string('0'+byte(set.Mode))
+			var info []byte
+			if v.Info != nil {
+				info = decodeHex(t, *v.Info)
+			}
+			inputs := decodeList(t, v.Input)
+			if len(inputs) != v.Batch {
+				t.Fatalf("%s #%d: got %d inputs, want %d", name, i, len(inputs), v.Batch)
+			}
+
+			f, blinded, err := client.Blind(bytes.NewReader(bytes.Join(decodeList(t, v.Blind), nil)), inputs)
+			fatalIfErr(t, err)
+			for j, want := range decodeList(t, v.BlindedElement) {
+				if got := blinded[j].BytesCompressed(); !bytes.Equal(got, want) {
+					t.Errorf("%s #%d: blinded element %d = %x, want %x", name, i, j, got, want)
+				}
+			}
+
+			var r []byte
+			if set.Mode != oprf.ModeOPRF {
+				r = decodeHex(t, v.Proof.R)
+			}
+			ev, err := server.BlindEvaluate(bytes.NewReader(r), blinded, info)
+			fatalIfErr(t, err)
+			for j, want := range decodeList(t, v.EvaluationElement) {
+				if got := ev.Elements[j].BytesCompressed(); !bytes.Equal(got, want) {
+					t.Errorf("%s #%d: evaluated element %d = %x, want %x", name, i, j, got, want)
+				}
+			}
+			if set.Mode != oprf.ModeOPRF {
+				if want := decodeHex(t, v.Proof.Proof); !bytes.Equal(ev.Proof, want) {
+					t.Errorf("%s #%d: proof = %x, want %x", name, i, ev.Proof, want)
+				}
+			}
+
+			outputs, err := client.Finalize(f, ev, info)
+			fatalIfErr(t, err)
+			for j, want := range decodeList(t, v.Output) {
+				if !bytes.Equal(outputs[j], want) {
+					t.Errorf("%s #%d: output %d = %x, want %x", name, i, j, outputs[j], want)
+				}
+				got, err := server.Evaluate(inputs[j], info)
+				fatalIfErr(t, err)
+				if !bytes.Equal(got, want) {
+					t.Errorf("%s #%d: Evaluate %d = %x, want %x", name, i, j, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestModes(t *testing.T) {
+	for _, s := range []*oprf.Suite{oprf.P256SHA256(), oprf.P384SHA384(), oprf.P521SHA512()} {
+		for _, mode := range []oprf.Mode{oprf.ModeOPRF, oprf.ModeVOPRF, oprf.ModePOPRF} {
+			testMode(t, s, mode)
+		}
+	}
+}
+
+func testMode(t *testing.T, s *oprf.Suite, mode oprf.Mode) {
+	skS, err := s.GenerateKey(rand.Reader)
+	fatalIfErr(t, err)
+	var pkS *oprf.PublicKey
+	var info []byte
+	if mode != oprf.ModeOPRF {
+		pkS = skS.PublicKey()
+	}
+	if mode == oprf.ModePOPRF {
+		info = []byte("info")
+	}
+	client, err := oprf.NewClient(s, mode, pkS)
+	fatalIfErr(t, err)
+	server, err := oprf.NewServer(s, mode, skS)
+	fatalIfErr(t, err)
+
+	inputs := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	f, blinded, err := client.Blind(rand.Reader, inputs)
+	fatalIfErr(t, err)
+	ev, err := server.BlindEvaluate(rand.Reader, blinded, info)
+	fatalIfErr(t, err)
+	outputs, err := client.Finalize(f, ev, info)
+	fatalIfErr(t, err)
+	for i, input := range inputs {
+		want, err := server.Evaluate(input, info)
+		fatalIfErr(t, err)
+		if !bytes.Equal(outputs[i], want) {
+			t.Errorf("%s/%d: output %d doesn't match Evaluate", s.Identifier(), mode, i)
+		}
+	}
+
+	if mode == oprf.ModeOPRF {
+		if _, err := server.Evaluate(inputs[0], []byte("info")); err == nil {
+			t.Errorf("%s/%d: Evaluate accepted an info", s.Identifier(), mode)
+		}
+		return
+	}
+
+	// A proof for another key, other elements, or another info is rejected.
+	other, err := s.GenerateKey(rand.Reader)
+	fatalIfErr(t, err)
+	otherServer, err := oprf.NewServer(s, mode, other)
+	fatalIfErr(t, err)
+	bad, err := otherServer.BlindEvaluate(rand.Reader, blinded, info)
+	fatalIfErr(t, err)
+	if _, err := client.Finalize(f, bad, info); err == nil {
+		t.Errorf("%s/%d: Finalize accepted an evaluation with another key", s.Identifier(), mode)
+	}
+	swapped := &oprf.Evaluation{Elements: []group.Element{ev.Elements[1], ev.Elements[0], ev.Elements[2]}, Proof: ev.Proof}
+	if _, err := client.Finalize(f, swapped, info); err == nil {
+		t.Errorf("%s/%d: Finalize accepted swapped elements", s.Identifier(), mode)
+	}
+	if mode == oprf.ModePOPRF {
+		if _, err := client.Finalize(f, ev, []byte("other")); err == nil {
+			t.Errorf("%s/%d: Finalize accepted another info", s.Identifier(), mode)
+		}
+	}
+	if _, err := client.Finalize(f, &oprf.Evaluation{Elements: ev.Elements[:2], Proof: ev.Proof}, info); err == nil {
+		t.Errorf("%s/%d: Finalize accepted too few elements", s.Identifier(), mode)
+	}
+	if _, err := server.BlindEvaluate(rand.Reader, []group.Element{s.Group().NewElement()}, info); err == nil {
+		t.Errorf("%s/%d: BlindEvaluate accepted the identity", s.Identifier(), mode)
+	}
+	if _, err := oprf.NewClient(s, mode, nil); err == nil {
+		t.Errorf("%s/%d: NewClient accepted a nil public key", s.Identifier(), mode)
+	}
+	if _, err := s.ParseElement(skS.PublicKey().Bytes()[1:]); err == nil {
+		t.Errorf("%s/%d: ParseElement accepted a truncated element", s.Identifier(), mode)
+	}
+}
@@ -5,6 +5,7 @@
 package nistec
 
 import (
+	"encoding/binary"
 	"sync"
 
 	"github.com/magical/nistec-extra/internal/fiat"
@@ -17,7 +18,7 @@ var p224GGOnce sync.Once
 func p224SqrtCandidate(r, x *fiat.P224Element) {
 	// Since p = 1 mod 4, we can't use the exponentiation by (p + 1) / 4 like
 	// for the other primes. Instead, implement a variation of Tonelli–Shanks.
-	// The constant-time implementation is adapted from Thomas Pornin's ecGFp5.
+	// The constant-time setup is adapted from Thomas Pornin's ecGFp5.
 	//
 	// https://github.com/pornin/ecgfp5/blob/82325b965/rust/src/field.rs#L337-L385
 
@@ -112,22 +113,117 @@ func p224SqrtCandidate(r, x *fiat.P224Element) {
 	// r = x^(2^127-1) * x
 	r.Mul(r, x)
 
-	// for i = n-1 down to 1:
-	//     w = v^(2^(i-1))
-	//     if w == -1 then:
-	//         v <- v*GG[n-i]
-	//         r <- r*GG[n-i-1]
+	// Now find e such that v * g^e = 1. If x is a square, e is even, and
+	// (r * g^(e/2))² = x^(q+1) * g^e = x * v * g^e = x.
+	//
+	// Instead of the classic Tonelli–Shanks loop, which recovers the bits of e
+	// one at a time at a cost of O(n²) squarings, solve the discrete logarithm
+	// in the subgroup of order 2^n recursively, splitting it in halves each
+	// time, at a cost of O(n log n) operations. See p224Dlog.
+	var e0, e1 uint64
+	p224Dlog2(&e0, &e1, v)
+
+	// r <- r * g^(e/2)
+	p224MulPow(r, e0>>1, 0, 63)
+	p224MulPow(r, e1, 63, 32)
+}
+
+// p224DlogBase is the size of the subgroups in which p224Dlog performs the
+// discrete logarithm by table lookup.
+const p224DlogBase = 6
+
+var p224DlogTable *[1 << p224DlogBase]uint64
+var p224DlogTableOnce sync.Once
+
+// p224Dlog2 sets (e0, e1) to the 96-bit e = e0 + e1 * 2^64 such that h * g^e = 1,
+// where g = GG[0] generates the subgroup of order 2^96, which contains h.
+func p224Dlog2(e0, e1 *uint64, h *fiat.P224Element) {
+	// Split e = a + b * 2^48. First, h^(2^48) * (g^(2^48))^a = 1 determines a.
+	t := new(fiat.P224Element).Set(h)
+	for i := 0; i < 48; i++ {
+		t.Square(t)
+	}
+	a := p224Dlog(t, 48)
 
-	var p224MinusOne = new(fiat.P224Element).Sub(
-		new(fiat.P224Element), new(fiat.P224Element).One())
+	// Then, h * g^a * (g^(2^48))^b = 1 determines b.
+	t.Set(h)
+	p224MulPow(t, a, 0, 48)
+	b := p224Dlog(t, 48)
+
+	*e0 = a | b<<48
+	*e1 = b >> 16
+}
+
+// p224Dlog returns e < 2^n such that h * γ^e = 1, where γ = GG[96-n] generates
+// the subgroup of order 2^n, which contains h. n must be p224DlogBase times a
+// power of two, and at most 48.
+func p224Dlog(h *fiat.P224Element, n int) uint64 {
+	if n == p224DlogBase {
+		return p224DlogLookup(h)
+	}
+
+	// Split e = a + b * 2^(n/2). h^(2^(n/2)) is in the subgroup of order
+	// 2^(n/2), generated by γ^(2^(n/2)), and its logarithm is a.
+	half := n / 2
+	t := new(fiat.P224Element).Set(h)
+	for i := 0; i < half; i++ {
+		t.Square(t)
+	}
+	a := p224Dlog(t, half)
 
-	for i := 96 - 1; i >= 1; i-- {
-		w := new(fiat.P224Element).Set(v)
-		for j := 0; j < i-1; j++ {
-			w.Square(w)
+	// h * γ^a = γ^(-b * 2^(n/2)) is also in that subgroup, and its
+	// logarithm is b.
+	t.Set(h)
+	p224MulPow(t, a, 96-n, half)
+	b := p224Dlog(t, half)
+
+	return a | b<<half
+}
+
+// p224MulPow sets t = t * GG[k]^a, where a < 2^bits, in constant time.
+func p224MulPow(t *fiat.P224Element, a uint64, k, bits int) {
+	u := new(fiat.P224Element)
+	for i := 0; i < bits; i++ {
+		u.Mul(t, &p224GG[k+i])
+		t.Select(u, t, int(a>>i&1))
+	}
+}
+
+// p224DlogLookup returns e < 2^p224DlogBase such that h * γ^e = 1, where
+// γ = GG[96-p224DlogBase] generates the subgroup of order 2^p224DlogBase, which
+// contains h.
+func p224DlogLookup(h *fiat.P224Element) uint64 {
+	p224DlogTableOnce.Do(func() {
+		// p224DlogTable[e] is the key of γ^(-e).
+		p224DlogTable = new([1 << p224DlogBase]uint64)
+		inv := new(fiat.P224Element).Invert(&p224GG[96-p224DlogBase])
+		t := new(fiat.P224Element).One()
+		for e := range p224DlogTable {
+			p224DlogTable[e] = p224DlogKey(t)
+			t.Mul(t, inv)
 		}
-		cond := w.Equal(p224MinusOne)
-		v.Select(t0.Mul(v, &p224GG[96-i]), v, cond)
-		r.Select(t0.Mul(r, &p224GG[96-i-1]), r, cond)
+		for i := range p224DlogTable {
+			for j := 0; j < i; j++ {
+				if p224DlogTable[i] == p224DlogTable[j] {
+					panic("nistec: internal error: p224DlogTable keys are not unique")
+				}
+			}
+		}
+	})
+
+	// The table is scanned in full, so the lookup is constant time.
+	key := p224DlogKey(h)
+	var e uint64
+	for i, k := range p224DlogTable {
+		d := k ^ key
+		eq := (d|-d)>>63 ^ 1
+		e |= uint64(i) & -eq
 	}
+	return e
+}
+
+// p224DlogKey returns the least significant 64 bits of x, which are enough to
+// tell apart the elements of the subgroup of order 2^p224DlogBase.
+func p224DlogKey(x *fiat.P224Element) uint64 {
+	return binary.BigEndian.Uint64(x.Bytes()[p224ElementLength-8:])
 }
@@ -0,0 +1,48 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec
+
+import (
+	"crypto/elliptic"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/magical/nistec-extra/internal/fiat"
+)
+
+func TestP224Sqrt(t *testing.T) {
+	p := elliptic.P224().Params().P
+	exp := new(big.Int).Rsh(new(big.Int).Sub(p, big.NewInt(1)), 1)
+
+	check := func(t *testing.T, xInt *big.Int) {
+		x, err := new(fiat.P224Element).SetBytes(xInt.FillBytes(make([]byte, p224ElementLength)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Euler's criterion.
+		isSquare := new(big.Int).Exp(xInt, exp, p).Cmp(big.NewInt(1)) == 0 || xInt.Sign() == 0
+
+		r := new(fiat.P224Element)
+		if got := p224Sqrt(r, x); got != isSquare {
+			t.Fatalf("p224Sqrt(%x) = %v, want %v", xInt, got, isSquare)
+		}
+		if isSquare && new(fiat.P224Element).Square(r).Equal(x) != 1 {
+			t.Errorf("p224Sqrt(%x) = %x, which is not a square root", xInt, r.Bytes())
+		}
+	}
+
+	check(t, big.NewInt(0))
+	check(t, big.NewInt(1))
+	check(t, big.NewInt(11))
+	check(t, new(big.Int).Sub(p, big.NewInt(1)))
+
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 200; i++ {
+		x := new(big.Int).Rand(r, p)
+		check(t, x)
+		check(t, x.Exp(x, big.NewInt(2), p))
+	}
+}
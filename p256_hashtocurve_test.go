@@ -229,7 +229,7 @@ func expandMessage(msg []byte, DST []byte, size int) (out []byte) {
 	h := sha256.New()
 	h.Write(make([]byte, h.BlockSize()))
 	h.Write(msg)
-	h.Write([]byte{byte(size) >> 8, byte(size)})
+	h.Write([]byte{byte(size >> 8), byte(size)})
 	h.Write([]byte{0})
 	h.Write(DST)
 	dstLen := []byte{byte(len(DST))}
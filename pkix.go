@@ -0,0 +1,696 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// This file implements the DER encodings of public and private keys used by
+// crypto/x509 and OpenSSL: SubjectPublicKeyInfo from RFC 5280 and RFC 5480,
+// PKCS #8 PrivateKeyInfo from RFC 5208 and RFC 5958, and ECPrivateKey from RFC
+// 5915. They are usually found in PEM blocks of type "PUBLIC KEY", "PRIVATE
+// KEY", and "EC PRIVATE KEY" respectively.
+//
+// Private keys are represented as big-endian scalars, like in the rest of the
+// package, and public keys as points.
+
+var (
+	oidPublicKeyECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidPrimeField     = asn1.ObjectIdentifier{1, 2, 840, 10045, 1, 1}
+)
+
+// pkixCurve holds the identifier and domain parameters of a curve, as encoded
+// in ECParameters. All values are big-endian and of fixed length.
+type pkixCurve struct {
+	name       string
+	oid        asn1.ObjectIdentifier
+	p, a, b, g []byte
+	n          []byte
+
+	// parsePoint returns the point with encoding b, and its uncompressed or
+	// infinity encoding.
+	parsePoint func(b []byte) (any, []byte, error)
+	// publicKey returns the uncompressed encoding of [scalar]G.
+	publicKey func(scalar []byte) ([]byte, error)
+}
+
+// parsePointAny returns the point with encoding b, and its uncompressed or
+// infinity encoding.
+func parsePointAny[P nistPoint[P]](newPoint func() P, b []byte) (any, []byte, error) {
+	p, err := newPoint().SetBytes(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, p.Bytes(), nil
+}
+
+// scalarBaseMultBytes returns the uncompressed encoding of [scalar]G.
+func scalarBaseMultBytes[P nistPoint[P]](newPoint func() P, scalar []byte) ([]byte, error) {
+	p, err := newPoint().ScalarBaseMult(scalar)
+	if err != nil {
+		return nil, err
+	}
+	return p.Bytes(), nil
+}
+
+var (
+	p224PKIX = &pkixCurve{
+		name:       "P-224",
+		oid:        asn1.ObjectIdentifier{1, 3, 132, 0, 33},
+		p:          []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1},
+		a:          []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe},
+		b:          []byte{0xb4, 0x5, 0xa, 0x85, 0xc, 0x4, 0xb3, 0xab, 0xf5, 0x41, 0x32, 0x56, 0x50, 0x44, 0xb0, 0xb7, 0xd7, 0xbf, 0xd8, 0xba, 0x27, 0xb, 0x39, 0x43, 0x23, 0x55, 0xff, 0xb4},
+		g:          []byte{0x4, 0xb7, 0xe, 0xc, 0xbd, 0x6b, 0xb4, 0xbf, 0x7f, 0x32, 0x13, 0x90, 0xb9, 0x4a, 0x3, 0xc1, 0xd3, 0x56, 0xc2, 0x11, 0x22, 0x34, 0x32, 0x80, 0xd6, 0x11, 0x5c, 0x1d, 0x21, 0xbd, 0x37, 0x63, 0x88, 0xb5, 0xf7, 0x23, 0xfb, 0x4c, 0x22, 0xdf, 0xe6, 0xcd, 0x43, 0x75, 0xa0, 0x5a, 0x7, 0x47, 0x64, 0x44, 0xd5, 0x81, 0x99, 0x85, 0x0, 0x7e, 0x34},
+		n:          p224Order,
+		parsePoint: func(b []byte) (any, []byte, error) { return parsePointAny(NewP224Point, b) },
+		publicKey:  func(scalar []byte) ([]byte, error) { return scalarBaseMultBytes(NewP224Point, scalar) },
+	}
+	p256PKIX = &pkixCurve{
+		name:       "P-256",
+		oid:        asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7},
+		p:          []byte{0xff, 0xff, 0xff, 0xff, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		a:          []byte{0xff, 0xff, 0xff, 0xff, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfc},
+		b:          []byte{0x5a, 0xc6, 0x35, 0xd8, 0xaa, 0x3a, 0x93, 0xe7, 0xb3, 0xeb, 0xbd, 0x55, 0x76, 0x98, 0x86, 0xbc, 0x65, 0x1d, 0x6, 0xb0, 0xcc, 0x53, 0xb0, 0xf6, 0x3b, 0xce, 0x3c, 0x3e, 0x27, 0xd2, 0x60, 0x4b},
+		g:          []byte{0x4, 0x6b, 0x17, 0xd1, 0xf2, 0xe1, 0x2c, 0x42, 0x47, 0xf8, 0xbc, 0xe6, 0xe5, 0x63, 0xa4, 0x40, 0xf2, 0x77, 0x3, 0x7d, 0x81, 0x2d, 0xeb, 0x33, 0xa0, 0xf4, 0xa1, 0x39, 0x45, 0xd8, 0x98, 0xc2, 0x96, 0x4f, 0xe3, 0x42, 0xe2, 0xfe, 0x1a, 0x7f, 0x9b, 0x8e, 0xe7, 0xeb, 0x4a, 0x7c, 0xf, 0x9e, 0x16, 0x2b, 0xce, 0x33, 0x57, 0x6b, 0x31, 0x5e, 0xce, 0xcb, 0xb6, 0x40, 0x68, 0x37, 0xbf, 0x51, 0xf5},
+		n:          p256Order,
+		parsePoint: func(b []byte) (any, []byte, error) { return parsePointAny(NewP256Point, b) },
+		publicKey:  func(scalar []byte) ([]byte, error) { return scalarBaseMultBytes(NewP256Point, scalar) },
+	}
+	p384PKIX = &pkixCurve{
+		name:       "P-384",
+		oid:        asn1.ObjectIdentifier{1, 3, 132, 0, 34},
+		p:          []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe, 0xff, 0xff, 0xff, 0xff, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xff, 0xff, 0xff, 0xff},
+		a:          []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe, 0xff, 0xff, 0xff, 0xff, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xff, 0xff, 0xff, 0xfc},
+		b:          []byte{0xb3, 0x31, 0x2f, 0xa7, 0xe2, 0x3e, 0xe7, 0xe4, 0x98, 0x8e, 0x5, 0x6b, 0xe3, 0xf8, 0x2d, 0x19, 0x18, 0x1d, 0x9c, 0x6e, 0xfe, 0x81, 0x41, 0x12, 0x3, 0x14, 0x8, 0x8f, 0x50, 0x13, 0x87, 0x5a, 0xc6, 0x56, 0x39, 0x8d, 0x8a, 0x2e, 0xd1, 0x9d, 0x2a, 0x85, 0xc8, 0xed, 0xd3, 0xec, 0x2a, 0xef},
+		g:          []byte{0x4, 0xaa, 0x87, 0xca, 0x22, 0xbe, 0x8b, 0x5, 0x37, 0x8e, 0xb1, 0xc7, 0x1e, 0xf3, 0x20, 0xad, 0x74, 0x6e, 0x1d, 0x3b, 0x62, 0x8b, 0xa7, 0x9b, 0x98, 0x59, 0xf7, 0x41, 0xe0, 0x82, 0x54, 0x2a, 0x38, 0x55, 0x2, 0xf2, 0x5d, 0xbf, 0x55, 0x29, 0x6c, 0x3a, 0x54, 0x5e, 0x38, 0x72, 0x76, 0xa, 0xb7, 0x36, 0x17, 0xde, 0x4a, 0x96, 0x26, 0x2c, 0x6f, 0x5d, 0x9e, 0x98, 0xbf, 0x92, 0x92, 0xdc, 0x29, 0xf8, 0xf4, 0x1d, 0xbd, 0x28, 0x9a, 0x14, 0x7c, 0xe9, 0xda, 0x31, 0x13, 0xb5, 0xf0, 0xb8, 0xc0, 0xa, 0x60, 0xb1, 0xce, 0x1d, 0x7e, 0x81, 0x9d, 0x7a, 0x43, 0x1d, 0x7c, 0x90, 0xea, 0xe, 0x5f},
+		n:          p384Order,
+		parsePoint: func(b []byte) (any, []byte, error) { return parsePointAny(NewP384Point, b) },
+		publicKey:  func(scalar []byte) ([]byte, error) { return scalarBaseMultBytes(NewP384Point, scalar) },
+	}
+	p521PKIX = &pkixCurve{
+		name:       "P-521",
+		oid:        asn1.ObjectIdentifier{1, 3, 132, 0, 35},
+		p:          []byte{0x1, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		a:          []byte{0x1, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfc},
+		b:          []byte{0x0, 0x51, 0x95, 0x3e, 0xb9, 0x61, 0x8e, 0x1c, 0x9a, 0x1f, 0x92, 0x9a, 0x21, 0xa0, 0xb6, 0x85, 0x40, 0xee, 0xa2, 0xda, 0x72, 0x5b, 0x99, 0xb3, 0x15, 0xf3, 0xb8, 0xb4, 0x89, 0x91, 0x8e, 0xf1, 0x9, 0xe1, 0x56, 0x19, 0x39, 0x51, 0xec, 0x7e, 0x93, 0x7b, 0x16, 0x52, 0xc0, 0xbd, 0x3b, 0xb1, 0xbf, 0x7, 0x35, 0x73, 0xdf, 0x88, 0x3d, 0x2c, 0x34, 0xf1, 0xef, 0x45, 0x1f, 0xd4, 0x6b, 0x50, 0x3f, 0x0},
+		g:          []byte{0x4, 0x0, 0xc6, 0x85, 0x8e, 0x6, 0xb7, 0x4, 0x4, 0xe9, 0xcd, 0x9e, 0x3e, 0xcb, 0x66, 0x23, 0x95, 0xb4, 0x42, 0x9c, 0x64, 0x81, 0x39, 0x5, 0x3f, 0xb5, 0x21, 0xf8, 0x28, 0xaf, 0x60, 0x6b, 0x4d, 0x3d, 0xba, 0xa1, 0x4b, 0x5e, 0x77, 0xef, 0xe7, 0x59, 0x28, 0xfe, 0x1d, 0xc1, 0x27, 0xa2, 0xff, 0xa8, 0xde, 0x33, 0x48, 0xb3, 0xc1, 0x85, 0x6a, 0x42, 0x9b, 0xf9, 0x7e, 0x7e, 0x31, 0xc2, 0xe5, 0xbd, 0x66, 0x1, 0x18, 0x39, 0x29, 0x6a, 0x78, 0x9a, 0x3b, 0xc0, 0x4, 0x5c, 0x8a, 0x5f, 0xb4, 0x2c, 0x7d, 0x1b, 0xd9, 0x98, 0xf5, 0x44, 0x49, 0x57, 0x9b, 0x44, 0x68, 0x17, 0xaf, 0xbd, 0x17, 0x27, 0x3e, 0x66, 0x2c, 0x97, 0xee, 0x72, 0x99, 0x5e, 0xf4, 0x26, 0x40, 0xc5, 0x50, 0xb9, 0x1, 0x3f, 0xad, 0x7, 0x61, 0x35, 0x3c, 0x70, 0x86, 0xa2, 0x72, 0xc2, 0x40, 0x88, 0xbe, 0x94, 0x76, 0x9f, 0xd1, 0x66, 0x50},
+		n:          p521Order,
+		parsePoint: func(b []byte) (any, []byte, error) { return parsePointAny(NewP521Point, b) },
+		publicKey:  func(scalar []byte) ([]byte, error) { return scalarBaseMultBytes(NewP521Point, scalar) },
+	}
+)
+
+var pkixCurves = []*pkixCurve{p224PKIX, p256PKIX, p384PKIX, p521PKIX}
+
+func addECAlgorithm(b *cryptobyte.Builder, c *pkixCurve) {
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1ObjectIdentifier(oidPublicKeyECDSA)
+		b.AddASN1ObjectIdentifier(c.oid)
+	})
+}
+
+// marshalPKIX returns the SubjectPublicKeyInfo encoding of the point with
+// uncompressed encoding point.
+func marshalPKIX(c *pkixCurve, point []byte) ([]byte, error) {
+	if len(point) == 1 {
+		return nil, errors.New(c.name + " point is the point at infinity")
+	}
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		addECAlgorithm(b, c)
+		b.AddASN1BitString(point)
+	})
+	return b.Bytes()
+}
+
+// parsePKIX parses a SubjectPublicKeyInfo, and returns the curve and the
+// encoding of the public key point, which is not yet validated.
+func parsePKIX(der []byte) (*pkixCurve, []byte, error) {
+	input := cryptobyte.String(der)
+	var spki, alg cryptobyte.String
+	var point asn1.BitString
+	if !input.ReadASN1(&spki, cryptobyte_asn1.SEQUENCE) || !input.Empty() ||
+		!spki.ReadASN1(&alg, cryptobyte_asn1.SEQUENCE) ||
+		!spki.ReadASN1BitString(&point) || !spki.Empty() ||
+		point.BitLength%8 != 0 {
+		return nil, nil, errors.New("invalid SubjectPublicKeyInfo encoding")
+	}
+	c, err := parseECAlgorithm(alg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(point.Bytes) == 1 {
+		return nil, nil, errors.New(c.name + " public key is the point at infinity")
+	}
+	return c, point.Bytes, nil
+}
+
+// parseECAlgorithm parses the contents of an AlgorithmIdentifier, which must
+// be id-ecPublicKey with ECParameters.
+func parseECAlgorithm(alg cryptobyte.String) (*pkixCurve, error) {
+	var oid asn1.ObjectIdentifier
+	if !alg.ReadASN1ObjectIdentifier(&oid) {
+		return nil, errors.New("invalid AlgorithmIdentifier encoding")
+	}
+	if !oid.Equal(oidPublicKeyECDSA) {
+		return nil, errors.New("unsupported public key algorithm " + oid.String())
+	}
+	c, err := parseECParameters(&alg)
+	if err != nil {
+		return nil, err
+	}
+	if !alg.Empty() {
+		return nil, errors.New("invalid AlgorithmIdentifier encoding")
+	}
+	return c, nil
+}
+
+// parseECParameters reads an ECParameters value from RFC 3279, Section 2.3.5,
+// and returns the matching curve. Explicit parameters are accepted only if
+// they match one of the supported named curves.
+func parseECParameters(s *cryptobyte.String) (*pkixCurve, error) {
+	switch {
+	case s.PeekASN1Tag(cryptobyte_asn1.OBJECT_IDENTIFIER):
+		var oid asn1.ObjectIdentifier
+		if !s.ReadASN1ObjectIdentifier(&oid) {
+			return nil, errors.New("invalid ECParameters encoding")
+		}
+		for _, c := range pkixCurves {
+			if oid.Equal(c.oid) {
+				return c, nil
+			}
+		}
+		return nil, errors.New("unsupported named curve " + oid.String())
+	case s.PeekASN1Tag(cryptobyte_asn1.SEQUENCE):
+		var params cryptobyte.String
+		if !s.ReadASN1(&params, cryptobyte_asn1.SEQUENCE) {
+			return nil, errors.New("invalid ECParameters encoding")
+		}
+		return parseSpecifiedECDomain(params)
+	default:
+		return nil, errors.New("unsupported ECParameters")
+	}
+}
+
+// parseSpecifiedECDomain parses the contents of a SpecifiedECDomain from SEC 1,
+// Version 2.0, Appendix C.2, and returns the named curve it describes.
+func parseSpecifiedECDomain(s cryptobyte.String) (*pkixCurve, error) {
+	var version int
+	var fieldID, curve cryptobyte.String
+	var fieldType asn1.ObjectIdentifier
+	var p, a, b, base, n []byte
+	if !s.ReadASN1Integer(&version) ||
+		!s.ReadASN1(&fieldID, cryptobyte_asn1.SEQUENCE) ||
+		!fieldID.ReadASN1ObjectIdentifier(&fieldType) ||
+		!s.ReadASN1(&curve, cryptobyte_asn1.SEQUENCE) ||
+		!curve.ReadASN1Bytes(&a, cryptobyte_asn1.OCTET_STRING) ||
+		!curve.ReadASN1Bytes(&b, cryptobyte_asn1.OCTET_STRING) ||
+		!curve.SkipOptionalASN1(cryptobyte_asn1.BIT_STRING) || !curve.Empty() ||
+		!s.ReadASN1Bytes(&base, cryptobyte_asn1.OCTET_STRING) ||
+		!s.ReadASN1Integer(&n) {
+		return nil, errors.New("invalid explicit ECParameters encoding")
+	}
+	if version < 1 || version > 3 {
+		return nil, errors.New("unsupported explicit ECParameters version")
+	}
+	if !fieldType.Equal(oidPrimeField) {
+		return nil, errors.New("unsupported explicit ECParameters field type " + fieldType.String())
+	}
+	if !fieldID.ReadASN1Integer(&p) || !fieldID.Empty() {
+		return nil, errors.New("invalid explicit ECParameters encoding")
+	}
+	h := []byte{1}
+	if s.PeekASN1Tag(cryptobyte_asn1.INTEGER) && !s.ReadASN1Integer(&h) {
+		return nil, errors.New("invalid explicit ECParameters encoding")
+	}
+	// The optional hash algorithm used to generate the seed is ignored.
+	if !s.SkipOptionalASN1(cryptobyte_asn1.SEQUENCE) || !s.Empty() {
+		return nil, errors.New("invalid explicit ECParameters encoding")
+	}
+
+	for _, c := range pkixCurves {
+		// Field elements are octet strings of the field length, but some
+		// encoders strip their leading zeroes, so compare them as integers.
+		if !bytes.Equal(p, c.p) || !bytes.Equal(n, c.n) ||
+			!bytes.Equal(trimLeadingZeros(a), trimLeadingZeros(c.a)) ||
+			!bytes.Equal(trimLeadingZeros(b), trimLeadingZeros(c.b)) {
+			continue
+		}
+		if !bytes.Equal(h, []byte{1}) {
+			break
+		}
+		if _, g, err := c.parsePoint(base); err != nil || !bytes.Equal(g, c.g) {
+			break
+		}
+		return c, nil
+	}
+	return nil, errors.New("explicit ECParameters don't match a supported named curve")
+}
+
+func trimLeadingZeros(b []byte) []byte {
+	for len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+	return b
+}
+
+// marshalECPrivateKey returns the ECPrivateKey encoding of scalar, with the
+// namedCurve parameters if withParams is true.
+func marshalECPrivateKey(c *pkixCurve, scalar []byte, withParams bool) ([]byte, error) {
+	if err := checkPrivateScalar(scalar, c.n); err != nil {
+		return nil, err
+	}
+	pub, err := c.publicKey(scalar)
+	if err != nil {
+		return nil, err
+	}
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1Int64(1) // ecPrivkeyVer1
+		b.AddASN1OctetString(scalar)
+		if withParams {
+			b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+				b.AddASN1ObjectIdentifier(c.oid)
+			})
+		}
+		b.AddASN1(cryptobyte_asn1.Tag(1).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+			b.AddASN1BitString(pub)
+		})
+	})
+	return b.Bytes()
+}
+
+// parseECPrivateKey parses an ECPrivateKey, and returns its curve, scalar, and
+// public key point. If c is not nil, it's the curve from the enclosing PKCS #8
+// structure, and the parameters field may be omitted.
+//
+// If the public key field is present, it must match the scalar.
+func parseECPrivateKey(c *pkixCurve, der []byte) (*pkixCurve, []byte, any, error) {
+	input := cryptobyte.String(der)
+	var s, params, pubField cryptobyte.String
+	var version int
+	var key []byte
+	var hasParams, hasPub bool
+	if !input.ReadASN1(&s, cryptobyte_asn1.SEQUENCE) || !input.Empty() ||
+		!s.ReadASN1Integer(&version) ||
+		!s.ReadASN1Bytes(&key, cryptobyte_asn1.OCTET_STRING) ||
+		!s.ReadOptionalASN1(&params, &hasParams, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) ||
+		!s.ReadOptionalASN1(&pubField, &hasPub, cryptobyte_asn1.Tag(1).Constructed().ContextSpecific()) ||
+		!s.Empty() {
+		return nil, nil, nil, errors.New("invalid ECPrivateKey encoding")
+	}
+	if version != 1 {
+		return nil, nil, nil, errors.New("unsupported ECPrivateKey version")
+	}
+	if hasParams {
+		pc, err := parseECParameters(&params)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if !params.Empty() {
+			return nil, nil, nil, errors.New("invalid ECPrivateKey encoding")
+		}
+		if c != nil && c != pc {
+			return nil, nil, nil, errors.New("ECPrivateKey parameters don't match the PKCS #8 algorithm")
+		}
+		c = pc
+	}
+	if c == nil {
+		return nil, nil, nil, errors.New("ECPrivateKey is missing the curve parameters")
+	}
+
+	// The private key should be exactly as long as the order, but some
+	// encoders strip or add leading zeroes, as tolerated by crypto/x509.
+	for len(key) > len(c.n) && key[0] == 0 {
+		key = key[1:]
+	}
+	if len(key) > len(c.n) {
+		return nil, nil, nil, errors.New("invalid " + c.name + " private key length")
+	}
+	scalar := make([]byte, len(c.n))
+	copy(scalar[len(scalar)-len(key):], key)
+	if err := checkPrivateScalar(scalar, c.n); err != nil {
+		return nil, nil, nil, err
+	}
+
+	pub, err := c.publicKey(scalar)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if hasPub {
+		var bits asn1.BitString
+		if !pubField.ReadASN1BitString(&bits) || !pubField.Empty() || bits.BitLength%8 != 0 {
+			return nil, nil, nil, errors.New("invalid ECPrivateKey encoding")
+		}
+		if _, q, err := c.parsePoint(bits.Bytes); err != nil || !bytes.Equal(q, pub) {
+			return nil, nil, nil, errors.New("ECPrivateKey public key doesn't match the private key")
+		}
+	}
+	point, _, err := c.parsePoint(pub)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return c, scalar, point, nil
+}
+
+// marshalPKCS8 returns the PKCS #8 PrivateKeyInfo encoding of scalar. Like
+// crypto/x509, it omits the redundant parameters from the inner ECPrivateKey.
+func marshalPKCS8(c *pkixCurve, scalar []byte) ([]byte, error) {
+	key, err := marshalECPrivateKey(c, scalar, false)
+	if err != nil {
+		return nil, err
+	}
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1Int64(0) // v1
+		addECAlgorithm(b, c)
+		b.AddASN1OctetString(key)
+	})
+	return b.Bytes()
+}
+
+// parsePKCS8 parses a PKCS #8 PrivateKeyInfo or OneAsymmetricKey.
+func parsePKCS8(der []byte) (*pkixCurve, []byte, any, error) {
+	input := cryptobyte.String(der)
+	var s, alg cryptobyte.String
+	var version int
+	var key []byte
+	if !input.ReadASN1(&s, cryptobyte_asn1.SEQUENCE) || !input.Empty() ||
+		!s.ReadASN1Integer(&version) ||
+		!s.ReadASN1(&alg, cryptobyte_asn1.SEQUENCE) ||
+		!s.ReadASN1Bytes(&key, cryptobyte_asn1.OCTET_STRING) ||
+		// The attributes and, in version 2, the public key are ignored.
+		!s.SkipOptionalASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) ||
+		!s.SkipOptionalASN1(cryptobyte_asn1.Tag(1).ContextSpecific()) ||
+		!s.Empty() {
+		return nil, nil, nil, errors.New("invalid PKCS #8 encoding")
+	}
+	if version != 0 && version != 1 {
+		return nil, nil, nil, errors.New("unsupported PKCS #8 version")
+	}
+	c, err := parseECAlgorithm(alg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return parseECPrivateKey(c, key)
+}
+
+// ParsePKIXPublicKey parses a DER encoded SubjectPublicKeyInfo, and returns
+// the public key as a *P224Point, *P256Point, *P384Point, or *P521Point,
+// depending on the curve. Explicit curve parameters are accepted if they match
+// one of those curves.
+func ParsePKIXPublicKey(der []byte) (any, error) {
+	c, b, err := parsePKIX(der)
+	if err != nil {
+		return nil, err
+	}
+	p, _, err := c.parsePoint(b)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ParsePKCS8PrivateKey parses a DER encoded PKCS #8 private key, and returns
+// the scalar and the public key, whose type identifies the curve like for
+// ParsePKIXPublicKey.
+func ParsePKCS8PrivateKey(der []byte) (scalar []byte, pub any, err error) {
+	_, scalar, pub, err = parsePKCS8(der)
+	return scalar, pub, err
+}
+
+// ParseECPrivateKey parses a DER encoded RFC 5915 ECPrivateKey, and returns the
+// scalar and the public key, whose type identifies the curve like for
+// ParsePKIXPublicKey.
+func ParseECPrivateKey(der []byte) (scalar []byte, pub any, err error) {
+	_, scalar, pub, err = parseECPrivateKey(nil, der)
+	return scalar, pub, err
+}
+
+// ParsePEMKey parses the first PEM block in data, which must be of type
+// "PUBLIC KEY", "PRIVATE KEY", or "EC PRIVATE KEY", and returns the key and the
+// remainder of data. For public keys, scalar is nil.
+//
+// To encode a key as PEM, use encoding/pem with one of those block types and
+// the output of BytesPKIX, PKCS8PrivateKey, or ECPrivateKey.
+func ParsePEMKey(data []byte) (scalar []byte, pub any, rest []byte, err error) {
+	block, rest := pem.Decode(data)
+	if block == nil {
+		return nil, nil, data, errors.New("no PEM block found")
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		pub, err = ParsePKIXPublicKey(block.Bytes)
+	case "PRIVATE KEY":
+		scalar, pub, err = ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		scalar, pub, err = ParseECPrivateKey(block.Bytes)
+	default:
+		err = errors.New("unsupported PEM block type " + block.Type)
+	}
+	if err != nil {
+		return nil, nil, rest, err
+	}
+	return scalar, pub, rest, nil
+}
+
+// BytesPKIX returns the DER encoding of p as a SubjectPublicKeyInfo with the
+// namedCurve parameters, or an error if p is the point at infinity.
+func (p *P224Point) BytesPKIX() ([]byte, error) {
+	return marshalPKIX(p224PKIX, p.Bytes())
+}
+
+// SetBytesPKIX sets p to the public key in the DER encoded SubjectPublicKeyInfo
+// der, and returns p. If der is not a valid P-224 public key, SetBytesPKIX
+// returns nil and an error, and p is unchanged.
+func (p *P224Point) SetBytesPKIX(der []byte) (*P224Point, error) {
+	c, b, err := parsePKIX(der)
+	if err != nil {
+		return nil, err
+	}
+	if c != p224PKIX {
+		return nil, errors.New("PKIX public key is not on P-224")
+	}
+	return p.SetBytes(b)
+}
+
+// P224PKCS8PrivateKey returns the DER encoding of scalar as a PKCS #8
+// PrivateKeyInfo, which also includes the public key.
+func P224PKCS8PrivateKey(scalar []byte) ([]byte, error) {
+	return marshalPKCS8(p224PKIX, scalar)
+}
+
+// P224ScalarFromPKCS8 returns the scalar of the DER encoded PKCS #8 P-224
+// private key der.
+func P224ScalarFromPKCS8(der []byte) ([]byte, error) {
+	c, scalar, _, err := parsePKCS8(der)
+	if err != nil {
+		return nil, err
+	}
+	if c != p224PKIX {
+		return nil, errors.New("PKCS #8 private key is not on P-224")
+	}
+	return scalar, nil
+}
+
+// P224ECPrivateKey returns the DER encoding of scalar as an RFC 5915
+// ECPrivateKey, with the namedCurve parameters and the public key.
+func P224ECPrivateKey(scalar []byte) ([]byte, error) {
+	return marshalECPrivateKey(p224PKIX, scalar, true)
+}
+
+// P224ScalarFromECPrivateKey returns the scalar of the DER encoded RFC 5915
+// P-224 private key der.
+func P224ScalarFromECPrivateKey(der []byte) ([]byte, error) {
+	c, scalar, _, err := parseECPrivateKey(nil, der)
+	if err != nil {
+		return nil, err
+	}
+	if c != p224PKIX {
+		return nil, errors.New("ECPrivateKey is not on P-224")
+	}
+	return scalar, nil
+}
+
+// BytesPKIX returns the DER encoding of p as a SubjectPublicKeyInfo with the
+// namedCurve parameters, or an error if p is the point at infinity.
+func (p *P256Point) BytesPKIX() ([]byte, error) {
+	return marshalPKIX(p256PKIX, p.Bytes())
+}
+
+// SetBytesPKIX sets p to the public key in the DER encoded SubjectPublicKeyInfo
+// der, and returns p. If der is not a valid P-256 public key, SetBytesPKIX
+// returns nil and an error, and p is unchanged.
+func (p *P256Point) SetBytesPKIX(der []byte) (*P256Point, error) {
+	c, b, err := parsePKIX(der)
+	if err != nil {
+		return nil, err
+	}
+	if c != p256PKIX {
+		return nil, errors.New("PKIX public key is not on P-256")
+	}
+	return p.SetBytes(b)
+}
+
+// P256PKCS8PrivateKey returns the DER encoding of scalar as a PKCS #8
+// PrivateKeyInfo, which also includes the public key.
+func P256PKCS8PrivateKey(scalar []byte) ([]byte, error) {
+	return marshalPKCS8(p256PKIX, scalar)
+}
+
+// P256ScalarFromPKCS8 returns the scalar of the DER encoded PKCS #8 P-256
+// private key der.
+func P256ScalarFromPKCS8(der []byte) ([]byte, error) {
+	c, scalar, _, err := parsePKCS8(der)
+	if err != nil {
+		return nil, err
+	}
+	if c != p256PKIX {
+		return nil, errors.New("PKCS #8 private key is not on P-256")
+	}
+	return scalar, nil
+}
+
+// P256ECPrivateKey returns the DER encoding of scalar as an RFC 5915
+// ECPrivateKey, with the namedCurve parameters and the public key.
+func P256ECPrivateKey(scalar []byte) ([]byte, error) {
+	return marshalECPrivateKey(p256PKIX, scalar, true)
+}
+
+// P256ScalarFromECPrivateKey returns the scalar of the DER encoded RFC 5915
+// P-256 private key der.
+func P256ScalarFromECPrivateKey(der []byte) ([]byte, error) {
+	c, scalar, _, err := parseECPrivateKey(nil, der)
+	if err != nil {
+		return nil, err
+	}
+	if c != p256PKIX {
+		return nil, errors.New("ECPrivateKey is not on P-256")
+	}
+	return scalar, nil
+}
+
+// BytesPKIX returns the DER encoding of p as a SubjectPublicKeyInfo with the
+// namedCurve parameters, or an error if p is the point at infinity.
+func (p *P384Point) BytesPKIX() ([]byte, error) {
+	return marshalPKIX(p384PKIX, p.Bytes())
+}
+
+// SetBytesPKIX sets p to the public key in the DER encoded SubjectPublicKeyInfo
+// der, and returns p. If der is not a valid P-384 public key, SetBytesPKIX
+// returns nil and an error, and p is unchanged.
+func (p *P384Point) SetBytesPKIX(der []byte) (*P384Point, error) {
+	c, b, err := parsePKIX(der)
+	if err != nil {
+		return nil, err
+	}
+	if c != p384PKIX {
+		return nil, errors.New("PKIX public key is not on P-384")
+	}
+	return p.SetBytes(b)
+}
+
+// P384PKCS8PrivateKey returns the DER encoding of scalar as a PKCS #8
+// PrivateKeyInfo, which also includes the public key.
+func P384PKCS8PrivateKey(scalar []byte) ([]byte, error) {
+	return marshalPKCS8(p384PKIX, scalar)
+}
+
+// P384ScalarFromPKCS8 returns the scalar of the DER encoded PKCS #8 P-384
+// private key der.
+func P384ScalarFromPKCS8(der []byte) ([]byte, error) {
+	c, scalar, _, err := parsePKCS8(der)
+	if err != nil {
+		return nil, err
+	}
+	if c != p384PKIX {
+		return nil, errors.New("PKCS #8 private key is not on P-384")
+	}
+	return scalar, nil
+}
+
+// P384ECPrivateKey returns the DER encoding of scalar as an RFC 5915
+// ECPrivateKey, with the namedCurve parameters and the public key.
+func P384ECPrivateKey(scalar []byte) ([]byte, error) {
+	return marshalECPrivateKey(p384PKIX, scalar, true)
+}
+
+// P384ScalarFromECPrivateKey returns the scalar of the DER encoded RFC 5915
+// P-384 private key der.
+func P384ScalarFromECPrivateKey(der []byte) ([]byte, error) {
+	c, scalar, _, err := parseECPrivateKey(nil, der)
+	if err != nil {
+		return nil, err
+	}
+	if c != p384PKIX {
+		return nil, errors.New("ECPrivateKey is not on P-384")
+	}
+	return scalar, nil
+}
+
+// BytesPKIX returns the DER encoding of p as a SubjectPublicKeyInfo with the
+// namedCurve parameters, or an error if p is the point at infinity.
+func (p *P521Point) BytesPKIX() ([]byte, error) {
+	return marshalPKIX(p521PKIX, p.Bytes())
+}
+
+// SetBytesPKIX sets p to the public key in the DER encoded SubjectPublicKeyInfo
+// der, and returns p. If der is not a valid P-521 public key, SetBytesPKIX
+// returns nil and an error, and p is unchanged.
+func (p *P521Point) SetBytesPKIX(der []byte) (*P521Point, error) {
+	c, b, err := parsePKIX(der)
+	if err != nil {
+		return nil, err
+	}
+	if c != p521PKIX {
+		return nil, errors.New("PKIX public key is not on P-521")
+	}
+	return p.SetBytes(b)
+}
+
+// P521PKCS8PrivateKey returns the DER encoding of scalar as a PKCS #8
+// PrivateKeyInfo, which also includes the public key.
+func P521PKCS8PrivateKey(scalar []byte) ([]byte, error) {
+	return marshalPKCS8(p521PKIX, scalar)
+}
+
+// P521ScalarFromPKCS8 returns the scalar of the DER encoded PKCS #8 P-521
+// private key der.
+func P521ScalarFromPKCS8(der []byte) ([]byte, error) {
+	c, scalar, _, err := parsePKCS8(der)
+	if err != nil {
+		return nil, err
+	}
+	if c != p521PKIX {
+		return nil, errors.New("PKCS #8 private key is not on P-521")
+	}
+	return scalar, nil
+}
+
+// P521ECPrivateKey returns the DER encoding of scalar as an RFC 5915
+// ECPrivateKey, with the namedCurve parameters and the public key.
+func P521ECPrivateKey(scalar []byte) ([]byte, error) {
+	return marshalECPrivateKey(p521PKIX, scalar, true)
+}
+
+// P521ScalarFromECPrivateKey returns the scalar of the DER encoded RFC 5915
+// P-521 private key der.
+func P521ScalarFromECPrivateKey(der []byte) ([]byte, error) {
+	c, scalar, _, err := parseECPrivateKey(nil, der)
+	if err != nil {
+		return nil, err
+	}
+	if c != p521PKIX {
+		return nil, errors.New("ECPrivateKey is not on P-521")
+	}
+	return scalar, nil
+}
@@ -0,0 +1,359 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"testing"
+
+	"github.com/magical/nistec-extra"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+type pkixPoint[T any] interface {
+	nistPoint[T]
+	BytesPKIX() ([]byte, error)
+	SetBytesPKIX([]byte) (T, error)
+}
+
+type pkixFuncs struct {
+	pkcs8            func([]byte) ([]byte, error)
+	scalarFromPKCS8  func([]byte) ([]byte, error)
+	ecPrivateKey     func([]byte) ([]byte, error)
+	scalarFromECPriv func([]byte) ([]byte, error)
+}
+
+func TestPKIX(t *testing.T) {
+	t.Run("P224", func(t *testing.T) {
+		testPKIX(t, nistec.NewP224Point, elliptic.P224(), pkixFuncs{
+			nistec.P224PKCS8PrivateKey, nistec.P224ScalarFromPKCS8,
+			nistec.P224ECPrivateKey, nistec.P224ScalarFromECPrivateKey})
+	})
+	t.Run("P256", func(t *testing.T) {
+		testPKIX(t, nistec.NewP256Point, elliptic.P256(), pkixFuncs{
+			nistec.P256PKCS8PrivateKey, nistec.P256ScalarFromPKCS8,
+			nistec.P256ECPrivateKey, nistec.P256ScalarFromECPrivateKey})
+	})
+	t.Run("P384", func(t *testing.T) {
+		testPKIX(t, nistec.NewP384Point, elliptic.P384(), pkixFuncs{
+			nistec.P384PKCS8PrivateKey, nistec.P384ScalarFromPKCS8,
+			nistec.P384ECPrivateKey, nistec.P384ScalarFromECPrivateKey})
+	})
+	t.Run("P521", func(t *testing.T) {
+		testPKIX(t, nistec.NewP521Point, elliptic.P521(), pkixFuncs{
+			nistec.P521PKCS8PrivateKey, nistec.P521ScalarFromPKCS8,
+			nistec.P521ECPrivateKey, nistec.P521ScalarFromECPrivateKey})
+	})
+}
+
+func testPKIX[P pkixPoint[P]](t *testing.T, newPoint func() P, c elliptic.Curve, f pkixFuncs) {
+	k, err := ecdsa.GenerateKey(c, rand.Reader)
+	fatalIfErr(t, err)
+	byteLen := (c.Params().BitSize + 7) / 8
+	scalar := k.D.FillBytes(make([]byte, byteLen))
+	pub := elliptic.Marshal(c, k.X, k.Y)
+
+	// The encodings must match crypto/x509 byte for byte.
+	spki, err := x509.MarshalPKIXPublicKey(&k.PublicKey)
+	fatalIfErr(t, err)
+	p, err := newPoint().SetBytesPKIX(spki)
+	fatalIfErr(t, err)
+	if !bytes.Equal(p.Bytes(), pub) {
+		t.Errorf("SetBytesPKIX() = %x, want %x", p.Bytes(), pub)
+	}
+	out, err := p.BytesPKIX()
+	fatalIfErr(t, err)
+	if !bytes.Equal(out, spki) {
+		t.Errorf("BytesPKIX() = %x, want %x", out, spki)
+	}
+	if q, err := nistec.ParsePKIXPublicKey(spki); err != nil {
+		t.Errorf("ParsePKIXPublicKey: %v", err)
+	} else if q, ok := q.(P); !ok || !bytes.Equal(q.Bytes(), pub) {
+		t.Errorf("ParsePKIXPublicKey() = %T", q)
+	}
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(k)
+	fatalIfErr(t, err)
+	out, err = f.pkcs8(scalar)
+	fatalIfErr(t, err)
+	if !bytes.Equal(out, pkcs8) {
+		t.Errorf("PKCS8PrivateKey() = %x, want %x", out, pkcs8)
+	}
+	s, err := f.scalarFromPKCS8(pkcs8)
+	fatalIfErr(t, err)
+	if !bytes.Equal(s, scalar) {
+		t.Errorf("ScalarFromPKCS8() = %x, want %x", s, scalar)
+	}
+
+	sec1, err := x509.MarshalECPrivateKey(k)
+	fatalIfErr(t, err)
+	out, err = f.ecPrivateKey(scalar)
+	fatalIfErr(t, err)
+	if !bytes.Equal(out, sec1) {
+		t.Errorf("ECPrivateKey() = %x, want %x", out, sec1)
+	}
+	s, err = f.scalarFromECPriv(sec1)
+	fatalIfErr(t, err)
+	if !bytes.Equal(s, scalar) {
+		t.Errorf("ScalarFromECPrivateKey() = %x, want %x", s, scalar)
+	}
+
+	for _, tt := range []struct {
+		typ string
+		der []byte
+	}{{"PUBLIC KEY", spki}, {"PRIVATE KEY", pkcs8}, {"EC PRIVATE KEY", sec1}} {
+		data := pem.EncodeToMemory(&pem.Block{Type: tt.typ, Bytes: tt.der})
+		s, q, rest, err := nistec.ParsePEMKey(append(data, "rest"...))
+		if err != nil {
+			t.Errorf("ParsePEMKey(%s): %v", tt.typ, err)
+			continue
+		}
+		if q, ok := q.(P); !ok || !bytes.Equal(q.Bytes(), pub) {
+			t.Errorf("ParsePEMKey(%s) returned the wrong public key", tt.typ)
+		}
+		if tt.typ != "PUBLIC KEY" && !bytes.Equal(s, scalar) || tt.typ == "PUBLIC KEY" && s != nil {
+			t.Errorf("ParsePEMKey(%s) returned scalar %x", tt.typ, s)
+		}
+		if string(rest) != "rest" {
+			t.Errorf("ParsePEMKey(%s) returned rest %q", tt.typ, rest)
+		}
+	}
+
+	// Explicit parameters matching the curve must be accepted, with either
+	// encoding of the base point.
+	for _, compressed := range []bool{false, true} {
+		params := explicitParams(c, compressed, nil)
+		q, err := newPoint().SetBytesPKIX(spkiWithParams(params, pub))
+		if err != nil {
+			t.Errorf("SetBytesPKIX with explicit parameters: %v", err)
+		} else if !bytes.Equal(q.Bytes(), pub) {
+			t.Errorf("SetBytesPKIX with explicit parameters = %x", q.Bytes())
+		}
+	}
+	for name, mutate := range map[string]func(*explicitCurve){
+		"b":        func(e *explicitCurve) { e.b[len(e.b)-1] ^= 1 },
+		"order":    func(e *explicitCurve) { e.n.Add(e.n, big.NewInt(2)) },
+		"cofactor": func(e *explicitCurve) { e.h = 2 },
+		"base":     func(e *explicitCurve) { e.g = pub },
+		"field":    func(e *explicitCurve) { e.fieldType = asn1.ObjectIdentifier{1, 2, 840, 10045, 1, 2} },
+	} {
+		params := explicitParams(c, false, mutate)
+		if _, err := newPoint().SetBytesPKIX(spkiWithParams(params, pub)); err == nil {
+			t.Errorf("SetBytesPKIX accepted explicit parameters with the wrong %s", name)
+		}
+	}
+
+	// Keys for other curves or unknown curves must be rejected.
+	other := elliptic.P256()
+	if c == other {
+		other = elliptic.P384()
+	}
+	k2, err := ecdsa.GenerateKey(other, rand.Reader)
+	fatalIfErr(t, err)
+	spki2, err := x509.MarshalPKIXPublicKey(&k2.PublicKey)
+	fatalIfErr(t, err)
+	if _, err := newPoint().SetBytesPKIX(spki2); err == nil {
+		t.Error("SetBytesPKIX accepted a key on a different curve")
+	}
+	pkcs8Other, err := x509.MarshalPKCS8PrivateKey(k2)
+	fatalIfErr(t, err)
+	if _, err := f.scalarFromPKCS8(pkcs8Other); err == nil {
+		t.Error("ScalarFromPKCS8 accepted a key on a different curve")
+	}
+	secp256k1 := cryptobyte.NewBuilder(nil)
+	secp256k1.AddASN1ObjectIdentifier(asn1.ObjectIdentifier{1, 3, 132, 0, 10})
+	if _, err := nistec.ParsePKIXPublicKey(spkiWithParams(secp256k1.BytesOrPanic(), pub)); err == nil {
+		t.Error("ParsePKIXPublicKey accepted an unknown named curve")
+	}
+
+	// An ECPrivateKey whose public key doesn't match the scalar must be
+	// rejected, as must out of range scalars.
+	k3 := *k
+	k3.PublicKey = k2.PublicKey
+	k3.Curve = c
+	k3.X, k3.Y = c.ScalarBaseMult([]byte{2})
+	mismatched, err := x509.MarshalECPrivateKey(&k3)
+	fatalIfErr(t, err)
+	if _, err := f.scalarFromECPriv(mismatched); err == nil {
+		t.Error("ScalarFromECPrivateKey accepted a mismatched public key")
+	}
+	if _, err := f.ecPrivateKey(make([]byte, byteLen)); err == nil {
+		t.Error("ECPrivateKey accepted a zero scalar")
+	}
+	if _, err := f.pkcs8(c.Params().N.FillBytes(make([]byte, byteLen))); err == nil {
+		t.Error("PKCS8PrivateKey accepted a scalar equal to the order")
+	}
+	if _, err := newPoint().BytesPKIX(); err == nil {
+		t.Error("BytesPKIX accepted the point at infinity")
+	}
+}
+
+type explicitCurve struct {
+	fieldType asn1.ObjectIdentifier
+	p, n      *big.Int
+	a, b, g   []byte
+	h         int64
+}
+
+// explicitParams returns the SpecifiedECDomain encoding of c.
+func explicitParams(c elliptic.Curve, compressed bool, mutate func(*explicitCurve)) []byte {
+	params := c.Params()
+	byteLen := (params.BitSize + 7) / 8
+	e := &explicitCurve{
+		fieldType: asn1.ObjectIdentifier{1, 2, 840, 10045, 1, 1},
+		p:         params.P,
+		a:         new(big.Int).Sub(params.P, big.NewInt(3)).FillBytes(make([]byte, byteLen)),
+		b:         params.B.FillBytes(make([]byte, byteLen)),
+		g:         elliptic.Marshal(c, params.Gx, params.Gy),
+		n:         new(big.Int).Set(params.N),
+		h:         1,
+	}
+	if compressed {
+		e.g = elliptic.MarshalCompressed(c, params.Gx, params.Gy)
+	}
+	if mutate != nil {
+		mutate(e)
+	}
+	b := cryptobyte.NewBuilder(nil)
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1Int64(1)
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1ObjectIdentifier(e.fieldType)
+			b.AddASN1BigInt(e.p)
+		})
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1OctetString(e.a)
+			b.AddASN1OctetString(e.b)
+		})
+		b.AddASN1OctetString(e.g)
+		b.AddASN1BigInt(e.n)
+		b.AddASN1Int64(e.h)
+	})
+	return b.BytesOrPanic()
+}
+
+func spkiWithParams(params, point []byte) []byte {
+	b := cryptobyte.NewBuilder(nil)
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1ObjectIdentifier(asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1})
+			b.AddBytes(params)
+		})
+		b.AddASN1BitString(point)
+	})
+	return b.BytesOrPanic()
+}
+
+// pkixSeeds returns DER encodings of keys on all curves for seeding the fuzzers.
+func pkixSeeds(f *testing.F, marshal func(*ecdsa.PrivateKey) ([]byte, error)) {
+	for _, c := range []elliptic.Curve{elliptic.P224(), elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		k, err := ecdsa.GenerateKey(c, rand.Reader)
+		if err != nil {
+			f.Fatal(err)
+		}
+		der, err := marshal(k)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(der)
+	}
+}
+
+func FuzzParsePKIXPublicKey(f *testing.F) {
+	pkixSeeds(f, func(k *ecdsa.PrivateKey) ([]byte, error) {
+		return x509.MarshalPKIXPublicKey(&k.PublicKey)
+	})
+	f.Add(spkiWithParams(explicitParams(elliptic.P256(), true, nil), elliptic.Marshal(elliptic.P256(), elliptic.P256().Params().Gx, elliptic.P256().Params().Gy)))
+	f.Fuzz(func(t *testing.T, der []byte) {
+		pub, err := nistec.ParsePKIXPublicKey(der)
+		if err != nil {
+			return
+		}
+		type pkixEncoder interface{ BytesPKIX() ([]byte, error) }
+		out, err := pub.(pkixEncoder).BytesPKIX()
+		if err != nil {
+			t.Fatalf("BytesPKIX failed on a parsed key: %v", err)
+		}
+		if _, err := x509.ParsePKIXPublicKey(out); err != nil {
+			t.Fatalf("crypto/x509 rejected re-encoded key: %v", err)
+		}
+	})
+}
+
+func FuzzParsePKCS8PrivateKey(f *testing.F) {
+	pkixSeeds(f, func(k *ecdsa.PrivateKey) ([]byte, error) {
+		return x509.MarshalPKCS8PrivateKey(k)
+	})
+	f.Fuzz(func(t *testing.T, der []byte) {
+		scalar, pub, err := nistec.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return
+		}
+		checkParsedPrivateKey(t, scalar, pub)
+	})
+}
+
+func FuzzParseECPrivateKey(f *testing.F) {
+	pkixSeeds(f, x509.MarshalECPrivateKey)
+	f.Fuzz(func(t *testing.T, der []byte) {
+		scalar, pub, err := nistec.ParseECPrivateKey(der)
+		if err != nil {
+			return
+		}
+		checkParsedPrivateKey(t, scalar, pub)
+	})
+}
+
+func checkParsedPrivateKey(t *testing.T, scalar []byte, pub any) {
+	var want []byte
+	var err error
+	switch pub := pub.(type) {
+	case *nistec.P224Point:
+		var q *nistec.P224Point
+		q, err = nistec.NewP224Point().ScalarBaseMult(scalar)
+		want = pub.Bytes()
+		if err == nil && !bytes.Equal(q.Bytes(), want) {
+			t.Fatal("public key doesn't match scalar")
+		}
+		_, err = nistec.P224ECPrivateKey(scalar)
+	case *nistec.P256Point:
+		var q *nistec.P256Point
+		q, err = nistec.NewP256Point().ScalarBaseMult(scalar)
+		want = pub.Bytes()
+		if err == nil && !bytes.Equal(q.Bytes(), want) {
+			t.Fatal("public key doesn't match scalar")
+		}
+		_, err = nistec.P256ECPrivateKey(scalar)
+	case *nistec.P384Point:
+		var q *nistec.P384Point
+		q, err = nistec.NewP384Point().ScalarBaseMult(scalar)
+		want = pub.Bytes()
+		if err == nil && !bytes.Equal(q.Bytes(), want) {
+			t.Fatal("public key doesn't match scalar")
+		}
+		_, err = nistec.P384ECPrivateKey(scalar)
+	case *nistec.P521Point:
+		var q *nistec.P521Point
+		q, err = nistec.NewP521Point().ScalarBaseMult(scalar)
+		want = pub.Bytes()
+		if err == nil && !bytes.Equal(q.Bytes(), want) {
+			t.Fatal("public key doesn't match scalar")
+		}
+		_, err = nistec.P521ECPrivateKey(scalar)
+	default:
+		t.Fatalf("unexpected public key type %T", pub)
+	}
+	if err != nil {
+		t.Fatalf("parsed private key can't be re-encoded: %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package privacypass
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"io"
+
+	"github.com/magical/nistec-extra/group"
+	"github.com/magical/nistec-extra/oprf"
+)
+
+// suite is the OPRF ciphersuite of token type 0x0001.
+var suite = oprf.P384SHA384()
+
+// tokenKeyID returns the token key ID of the public key pkI, the SHA-256
+// digest of its compressed encoding.
+func tokenKeyID(pkI *oprf.PublicKey) [digestSize]byte {
+	return sha256.Sum256(pkI.Bytes())
+}
+
+// Issuer issues and verifies tokens with a P384-SHA384 VOPRF key.
+type Issuer struct {
+	sk     *oprf.PrivateKey
+	server *oprf.Server
+	keyID  [digestSize]byte
+}
+
+// NewIssuer returns an issuer using the private key sk, which must be a key
+// of the oprf.P384SHA384 suite, generated with GenerateKey or DeriveKeyPair.
+func NewIssuer(sk *oprf.PrivateKey) (*Issuer, error) {
+	server, err := oprf.NewServer(suite, oprf.ModeVOPRF, sk)
+	if err != nil {
+		return nil, err
+	}
+	return &Issuer{sk: sk, server: server, keyID: tokenKeyID(sk.PublicKey())}, nil
+}
+
+// PublicKey returns the encoding of the issuer public key, as published in
+// the issuer directory.
+func (i *Issuer) PublicKey() []byte { return i.sk.PublicKey().Bytes() }
+
+// TokenKeyID returns the token key ID of the issuer public key.
+func (i *Issuer) TokenKeyID() []byte { return append([]byte(nil), i.keyID[:]...) }
+
+// parseBlinded decodes the blinded elements of a request for the key with
+// truncated ID truncatedKeyID.
+func (i *Issuer) parseBlinded(truncatedKeyID byte, encoded [][]byte) ([]group.Element, error) {
+	if truncatedKeyID != i.keyID[digestSize-1] {
+		return nil, errors.New("privacypass: request for an unknown token key")
+	}
+	var blinded []group.Element
+	for _, b := range encoded {
+		e, err := suite.ParseElement(b)
+		if err != nil {
+			return nil, errors.New("privacypass: invalid blinded element")
+		}
+		blinded = append(blinded, e)
+	}
+	return blinded, nil
+}
+
+// Issue evaluates the blinded element of req, and proves it with randomness
+// read from rand.
+func (i *Issuer) Issue(rand io.Reader, req *TokenRequest) (*TokenResponse, error) {
+	blinded, err := i.parseBlinded(req.TruncatedTokenKeyID, [][]byte{req.BlindedMsg})
+	if err != nil {
+		return nil, err
+	}
+	ev, err := i.server.BlindEvaluate(rand, blinded, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenResponse{
+		EvaluateMsg:   ev.Elements[0].BytesCompressed(),
+		EvaluateProof: ev.Proof,
+	}, nil
+}
+
+// IssueBatch evaluates all the blinded elements of req, and proves them with
+// a single proof, using randomness read from rand.
+func (i *Issuer) IssueBatch(rand io.Reader, req *BatchTokenRequest) (*BatchTokenResponse, error) {
+	if len(req.BlindedElements) == 0 {
+		return nil, errors.New("privacypass: empty BatchTokenRequest")
+	}
+	blinded, err := i.parseBlinded(req.TruncatedTokenKeyID, req.BlindedElements)
+	if err != nil {
+		return nil, err
+	}
+	ev, err := i.server.BlindEvaluate(rand, blinded, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp := &BatchTokenResponse{EvaluatedProof: ev.Proof}
+	for _, e := range ev.Elements {
+		resp.EvaluatedElements = append(resp.EvaluatedElements, e.BytesCompressed())
+	}
+	return resp, nil
+}
+
+// Verify reports whether t is a valid token for the issuer key. The
+// authenticator is compared in constant time.
+//
+// The caller is responsible for checking that t.ChallengeDigest matches the
+// challenge of the origin, and for preventing double spending.
+func (i *Issuer) Verify(t *Token) bool {
+	if subtle.ConstantTimeCompare(t.TokenKeyID[:], i.keyID[:]) != 1 {
+		return false
+	}
+	authenticator, err := i.server.Evaluate(t.input(), nil)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(authenticator, t.Authenticator) == 1
+}
+
+// Client requests tokens from an issuer.
+type Client struct {
+	client *oprf.Client
+	keyID  [digestSize]byte
+}
+
+// NewClient returns a client for the issuer with the public key pkI, encoded
+// as returned by Issuer.PublicKey.
+func NewClient(pkI []byte) (*Client, error) {
+	pk, err := suite.NewPublicKey(pkI)
+	if err != nil {
+		return nil, err
+	}
+	client, err := oprf.NewClient(suite, oprf.ModeVOPRF, pk)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{client: client, keyID: tokenKeyID(pk)}, nil
+}
+
+// TokenRequestState is the client state between the creation of a request and
+// the finalization of the tokens. It must be kept secret.
+type TokenRequestState struct {
+	f      *oprf.FinalizeData
+	tokens []Token
+}
+
+// blind creates n tokens without authenticators for challenge, with nonces
+// read from rand, and blinds their inputs.
+func (c *Client) blind(rand io.Reader, challenge []byte, n int) (*TokenRequestState, [][]byte, error) {
+	st := &TokenRequestState{tokens: make([]Token, n)}
+	inputs := make([][]byte, n)
+	for i := range st.tokens {
+		t := &st.tokens[i]
+		if _, err := io.ReadFull(rand, t.Nonce[:]); err != nil {
+			return nil, nil, err
+		}
+		t.ChallengeDigest = ChallengeDigest(challenge)
+		t.TokenKeyID = c.keyID
+		inputs[i] = t.input()
+	}
+	f, blinded, err := c.client.Blind(rand, inputs)
+	if err != nil {
+		return nil, nil, err
+	}
+	st.f = f
+	encoded := make([][]byte, n)
+	for i, e := range blinded {
+		encoded[i] = e.BytesCompressed()
+	}
+	return st, encoded, nil
+}
+
+// CreateTokenRequest returns a request for a token for the encoded
+// TokenChallenge challenge, using randomness read from rand.
+func (c *Client) CreateTokenRequest(rand io.Reader, challenge []byte) (*TokenRequestState, *TokenRequest, error) {
+	st, blinded, err := c.blind(rand, challenge, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	return st, &TokenRequest{
+		TruncatedTokenKeyID: c.keyID[digestSize-1],
+		BlindedMsg:          blinded[0],
+	}, nil
+}
+
+// CreateBatchTokenRequest returns a request for n tokens for the encoded
+// TokenChallenge challenge, using randomness read from rand.
+func (c *Client) CreateBatchTokenRequest(rand io.Reader, challenge []byte, n int) (*TokenRequestState, *BatchTokenRequest, error) {
+	// The elements must fit in a list with a two bytes length prefix.
+	if n <= 0 || n*elementSize > 0xffff {
+		return nil, nil, errors.New("privacypass: invalid number of tokens")
+	}
+	st, blinded, err := c.blind(rand, challenge, n)
+	if err != nil {
+		return nil, nil, err
+	}
+	return st, &BatchTokenRequest{
+		TruncatedTokenKeyID: c.keyID[digestSize-1],
+		BlindedElements:     blinded,
+	}, nil
+}
+
+// finalize checks the proof for the encoded evaluated elements, and returns
+// the tokens of st with their authenticators.
+func (c *Client) finalize(st *TokenRequestState, encoded [][]byte, proof []byte) ([]*Token, error) {
+	if len(encoded) != len(st.tokens) {
+		return nil, errors.New("privacypass: wrong number of evaluated elements")
+	}
+	ev := &oprf.Evaluation{Proof: proof}
+	for _, b := range encoded {
+		e, err := suite.ParseElement(b)
+		if err != nil {
+			return nil, errors.New("privacypass: invalid evaluated element")
+		}
+		ev.Elements = append(ev.Elements, e)
+	}
+	outputs, err := c.client.Finalize(st.f, ev, nil)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]*Token, len(outputs))
+	for i, out := range outputs {
+		t := st.tokens[i]
+		t.Authenticator = out
+		tokens[i] = &t
+	}
+	return tokens, nil
+}
+
+// FinalizeToken checks the proof of resp, and returns the token requested
+// with st.
+func (c *Client) FinalizeToken(st *TokenRequestState, resp *TokenResponse) (*Token, error) {
+	tokens, err := c.finalize(st, [][]byte{resp.EvaluateMsg}, resp.EvaluateProof)
+	if err != nil {
+		return nil, err
+	}
+	return tokens[0], nil
+}
+
+// FinalizeBatch checks the proof of resp, and returns the tokens requested
+// with st, in order.
+func (c *Client) FinalizeBatch(st *TokenRequestState, resp *BatchTokenResponse) ([]*Token, error) {
+	return c.finalize(st, resp.EvaluatedElements, resp.EvaluatedProof)
+}
@@ -0,0 +1,238 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package privacypass implements the issuance protocol for privately
+// verifiable Privacy Pass tokens, token type 0x0001 of RFC 9578, Section 5,
+// which is based on the VOPRF mode of RFC 9497 with the P384-SHA384 suite.
+//
+// The client blinds a token input with Client.CreateTokenRequest, the issuer
+// evaluates it with Issuer.Issue, and the client unblinds the evaluation and
+// checks its proof with Client.FinalizeToken, obtaining a Token. The token is
+// redeemed to an origin that shares the issuer key, and that checks it with
+// Issuer.Verify.
+//
+// Batched issuance, which evaluates several token inputs for the same
+// challenge with a single proof, follows the BatchTokenRequest and
+// BatchTokenResponse formats of the Privacy Pass batched token issuance
+// protocol.
+package privacypass
+
+import (
+	"crypto/sha256"
+	"errors"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// TokenType is the token type of privately verifiable tokens.
+const TokenType uint16 = 0x0001
+
+const (
+	nonceSize         = 32
+	digestSize        = sha256.Size
+	elementSize       = 49 // Ne, a compressed P-384 point
+	proofSize         = 2 * 48
+	authenticatorSize = 48 // Nk, the output length of P384-SHA384
+)
+
+// TokenRequest is the message sent by the client to the issuer.
+type TokenRequest struct {
+	// TruncatedTokenKeyID is the last byte of the token key ID of the issuer.
+	TruncatedTokenKeyID byte
+	// BlindedMsg is the blinded element, a compressed point.
+	BlindedMsg []byte
+}
+
+// Marshal returns the wire encoding of r.
+func (r *TokenRequest) Marshal() []byte {
+	var b cryptobyte.Builder
+	b.AddUint16(TokenType)
+	b.AddUint8(r.TruncatedTokenKeyID)
+	b.AddBytes(r.BlindedMsg)
+	return b.BytesOrPanic()
+}
+
+// ParseTokenRequest decodes a TokenRequest, and checks its token type.
+func ParseTokenRequest(b []byte) (*TokenRequest, error) {
+	s := cryptobyte.String(b)
+	var tokenType uint16
+	r := &TokenRequest{}
+	if !s.ReadUint16(&tokenType) || !s.ReadUint8(&r.TruncatedTokenKeyID) ||
+		!s.ReadBytes(&r.BlindedMsg, elementSize) || !s.Empty() {
+		return nil, errors.New("privacypass: invalid TokenRequest encoding")
+	}
+	if tokenType != TokenType {
+		return nil, errors.New("privacypass: unsupported token type")
+	}
+	return r, nil
+}
+
+// TokenResponse is the message sent by the issuer to the client.
+type TokenResponse struct {
+	// EvaluateMsg is the evaluated element, a compressed point.
+	EvaluateMsg []byte
+	// EvaluateProof is the DLEQ proof, the encodings of the scalars c and s.
+	EvaluateProof []byte
+}
+
+// Marshal returns the wire encoding of r.
+func (r *TokenResponse) Marshal() []byte {
+	return append(append([]byte(nil), r.EvaluateMsg...), r.EvaluateProof...)
+}
+
+// ParseTokenResponse decodes a TokenResponse.
+func ParseTokenResponse(b []byte) (*TokenResponse, error) {
+	s := cryptobyte.String(b)
+	r := &TokenResponse{}
+	if !s.ReadBytes(&r.EvaluateMsg, elementSize) ||
+		!s.ReadBytes(&r.EvaluateProof, proofSize) || !s.Empty() {
+		return nil, errors.New("privacypass: invalid TokenResponse encoding")
+	}
+	return r, nil
+}
+
+// BatchTokenRequest is the message sent by the client to the issuer to
+// request several tokens at once.
+type BatchTokenRequest struct {
+	// TruncatedTokenKeyID is the last byte of the token key ID of the issuer.
+	TruncatedTokenKeyID byte
+	// BlindedElements are the blinded elements, compressed points.
+	BlindedElements [][]byte
+}
+
+// Marshal returns the wire encoding of r.
+func (r *BatchTokenRequest) Marshal() []byte {
+	var b cryptobyte.Builder
+	b.AddUint16(TokenType)
+	b.AddUint8(r.TruncatedTokenKeyID)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, e := range r.BlindedElements {
+			b.AddBytes(e)
+		}
+	})
+	return b.BytesOrPanic()
+}
+
+// ParseBatchTokenRequest decodes a BatchTokenRequest, and checks its token
+// type.
+func ParseBatchTokenRequest(b []byte) (*BatchTokenRequest, error) {
+	s := cryptobyte.String(b)
+	var tokenType uint16
+	var elements cryptobyte.String
+	r := &BatchTokenRequest{}
+	if !s.ReadUint16(&tokenType) || !s.ReadUint8(&r.TruncatedTokenKeyID) ||
+		!s.ReadUint16LengthPrefixed(&elements) || !s.Empty() {
+		return nil, errors.New("privacypass: invalid BatchTokenRequest encoding")
+	}
+	if tokenType != TokenType {
+		return nil, errors.New("privacypass: unsupported token type")
+	}
+	var err error
+	r.BlindedElements, err = readElements(&elements)
+	if err != nil {
+		return nil, errors.New("privacypass: invalid BatchTokenRequest encoding")
+	}
+	return r, nil
+}
+
+// BatchTokenResponse is the message sent by the issuer to the client in
+// response to a BatchTokenRequest.
+type BatchTokenResponse struct {
+	// EvaluatedElements are the evaluated elements, compressed points, in the
+	// same order as the blinded elements.
+	EvaluatedElements [][]byte
+	// EvaluatedProof is the DLEQ proof for all the elements.
+	EvaluatedProof []byte
+}
+
+// Marshal returns the wire encoding of r.
+func (r *BatchTokenResponse) Marshal() []byte {
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, e := range r.EvaluatedElements {
+			b.AddBytes(e)
+		}
+	})
+	b.AddBytes(r.EvaluatedProof)
+	return b.BytesOrPanic()
+}
+
+// ParseBatchTokenResponse decodes a BatchTokenResponse.
+func ParseBatchTokenResponse(b []byte) (*BatchTokenResponse, error) {
+	s := cryptobyte.String(b)
+	var elements cryptobyte.String
+	r := &BatchTokenResponse{}
+	if !s.ReadUint16LengthPrefixed(&elements) ||
+		!s.ReadBytes(&r.EvaluatedProof, proofSize) || !s.Empty() {
+		return nil, errors.New("privacypass: invalid BatchTokenResponse encoding")
+	}
+	var err error
+	r.EvaluatedElements, err = readElements(&elements)
+	if err != nil {
+		return nil, errors.New("privacypass: invalid BatchTokenResponse encoding")
+	}
+	return r, nil
+}
+
+// readElements splits s into one or more elements of elementSize bytes.
+func readElements(s *cryptobyte.String) ([][]byte, error) {
+	if s.Empty() || len(*s)%elementSize != 0 {
+		return nil, errors.New("invalid list of elements")
+	}
+	var out [][]byte
+	for !s.Empty() {
+		var e []byte
+		s.ReadBytes(&e, elementSize)
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// Token is a privately verifiable token.
+type Token struct {
+	Nonce           [nonceSize]byte
+	ChallengeDigest [digestSize]byte
+	TokenKeyID      [digestSize]byte
+	// Authenticator is the output of the PRF for the token input, the
+	// encoding of the token without the authenticator.
+	Authenticator []byte
+}
+
+// input returns the token_input, the authenticator input of RFC 9577,
+// Section 2.2.
+func (t *Token) input() []byte {
+	var b cryptobyte.Builder
+	b.AddUint16(TokenType)
+	b.AddBytes(t.Nonce[:])
+	b.AddBytes(t.ChallengeDigest[:])
+	b.AddBytes(t.TokenKeyID[:])
+	return b.BytesOrPanic()
+}
+
+// Marshal returns the wire encoding of t.
+func (t *Token) Marshal() []byte {
+	return append(t.input(), t.Authenticator...)
+}
+
+// ParseToken decodes a Token, and checks its token type.
+func ParseToken(b []byte) (*Token, error) {
+	s := cryptobyte.String(b)
+	var tokenType uint16
+	t := &Token{}
+	if !s.ReadUint16(&tokenType) || !s.CopyBytes(t.Nonce[:]) ||
+		!s.CopyBytes(t.ChallengeDigest[:]) || !s.CopyBytes(t.TokenKeyID[:]) ||
+		!s.ReadBytes(&t.Authenticator, authenticatorSize) || !s.Empty() {
+		return nil, errors.New("privacypass: invalid Token encoding")
+	}
+	if tokenType != TokenType {
+		return nil, errors.New("privacypass: unsupported token type")
+	}
+	return t, nil
+}
+
+// ChallengeDigest returns the SHA-256 digest of the encoded TokenChallenge
+// challenge, which origins compare with Token.ChallengeDigest.
+func ChallengeDigest(challenge []byte) [digestSize]byte {
+	return sha256.Sum256(challenge)
+}
@@ -0,0 +1,401 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package privacypass_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/magical/nistec-extra/oprf"
+	"github.com/magical/nistec-extra/privacypass"
+)
+
+func fatalIfErr(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	fatalIfErr(t, err)
+	return b
+}
+
+// hexBytes is a []byte encoded as a hex string in JSON.
+type hexBytes []byte
+
+func (b *hexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := hex.DecodeString(s)
+	*b = v
+	return err
+}
+
+// vectors.json was generated with the VOPRF of github.com/cloudflare/circl
+// v1.3.7, independently of this package, and uses the field names of RFC 9578,
+// Appendix A.1, plus proof_randomness, the nonce of the DLEQ proof.
+type vectors struct {
+	Vectors []struct {
+		SkS             hexBytes `json:"skS"`
+		PkS             hexBytes `json:"pkS"`
+		TokenChallenge  hexBytes `json:"token_challenge"`
+		Nonce           hexBytes `json:"nonce"`
+		Blind           hexBytes `json:"blind"`
+		ProofRandomness hexBytes `json:"proof_randomness"`
+		TokenRequest    hexBytes `json:"token_request"`
+		TokenResponse   hexBytes `json:"token_response"`
+		Token           hexBytes `json:"token"`
+	} `json:"vectors"`
+	Batched []struct {
+		SkS                hexBytes   `json:"skS"`
+		PkS                hexBytes   `json:"pkS"`
+		TokenChallenge     hexBytes   `json:"token_challenge"`
+		Nonces             []hexBytes `json:"nonces"`
+		Blinds             []hexBytes `json:"blinds"`
+		ProofRandomness    hexBytes   `json:"proof_randomness"`
+		BatchTokenRequest  hexBytes   `json:"batch_token_request"`
+		BatchTokenResponse hexBytes   `json:"batch_token_response"`
+		Tokens             []hexBytes `json:"tokens"`
+	} `json:"batched"`
+}
+
+func loadVectors(t *testing.T) *vectors {
+	t.Helper()
+	data, err := os.ReadFile("testdata/vectors.json")
+	fatalIfErr(t, err)
+	var v vectors
+	fatalIfErr(t, json.Unmarshal(data, &v))
+	return &v
+}
+
+func issuerAndClient(t *testing.T, skS, pkS []byte) (*privacypass.Issuer, *privacypass.Client) {
+	t.Helper()
+	sk, err := oprf.P384SHA384().NewPrivateKey(skS)
+	fatalIfErr(t, err)
+	issuer, err := privacypass.NewIssuer(sk)
+	fatalIfErr(t, err)
+	if !bytes.Equal(issuer.PublicKey(), pkS) {
+		t.Fatalf("PublicKey = %x, want %x", issuer.PublicKey(), pkS)
+	}
+	client, err := privacypass.NewClient(pkS)
+	fatalIfErr(t, err)
+	return issuer, client
+}
+
+// TestVectors runs the issuance protocol with the nonce, blind and proof
+// randomness of each vector read from deterministic readers, and compares
+// every message with the vector.
+func TestVectors(t *testing.T) {
+	for i, v := range loadVectors(t).Vectors {
+		issuer, client := issuerAndClient(t, v.SkS, v.PkS)
+
+		rand := bytes.NewReader(append(append([]byte(nil), v.Nonce...), v.Blind...))
+		st, req, err := client.CreateTokenRequest(rand, v.TokenChallenge)
+		fatalIfErr(t, err)
+		if got := req.Marshal(); !bytes.Equal(got, v.TokenRequest) {
+			t.Errorf("%d: token_request = %x, want %x", i, got, v.TokenRequest)
+		}
+		req, err = privacypass.ParseTokenRequest(v.TokenRequest)
+		fatalIfErr(t, err)
+
+		resp, err := issuer.Issue(bytes.NewReader(v.ProofRandomness), req)
+		fatalIfErr(t, err)
+		if got := resp.Marshal(); !bytes.Equal(got, v.TokenResponse) {
+			t.Errorf("%d: token_response = %x, want %x", i, got, v.TokenResponse)
+		}
+		resp, err = privacypass.ParseTokenResponse(v.TokenResponse)
+		fatalIfErr(t, err)
+
+		token, err := client.FinalizeToken(st, resp)
+		fatalIfErr(t, err)
+		if got := token.Marshal(); !bytes.Equal(got, v.Token) {
+			t.Errorf("%d: token = %x, want %x", i, got, v.Token)
+		}
+
+		token, err = privacypass.ParseToken(v.Token)
+		fatalIfErr(t, err)
+		if !issuer.Verify(token) {
+			t.Errorf("%d: vector token rejected", i)
+		}
+	}
+}
+
+func TestBatchedVectors(t *testing.T) {
+	for i, v := range loadVectors(t).Batched {
+		issuer, client := issuerAndClient(t, v.SkS, v.PkS)
+
+		// The client reads all the nonces, and then all the blinds.
+		var r []byte
+		for _, n := range v.Nonces {
+			r = append(r, n...)
+		}
+		for _, b := range v.Blinds {
+			r = append(r, b...)
+		}
+		st, req, err := client.CreateBatchTokenRequest(bytes.NewReader(r), v.TokenChallenge, len(v.Nonces))
+		fatalIfErr(t, err)
+		if got := req.Marshal(); !bytes.Equal(got, v.BatchTokenRequest) {
+			t.Errorf("%d: batch_token_request = %x, want %x", i, got, v.BatchTokenRequest)
+		}
+		req, err = privacypass.ParseBatchTokenRequest(v.BatchTokenRequest)
+		fatalIfErr(t, err)
+
+		resp, err := issuer.IssueBatch(bytes.NewReader(v.ProofRandomness), req)
+		fatalIfErr(t, err)
+		if got := resp.Marshal(); !bytes.Equal(got, v.BatchTokenResponse) {
+			t.Errorf("%d: batch_token_response = %x, want %x", i, got, v.BatchTokenResponse)
+		}
+		resp, err = privacypass.ParseBatchTokenResponse(v.BatchTokenResponse)
+		fatalIfErr(t, err)
+
+		tokens, err := client.FinalizeBatch(st, resp)
+		fatalIfErr(t, err)
+		if len(tokens) != len(v.Tokens) {
+			t.Fatalf("%d: got %d tokens, want %d", i, len(tokens), len(v.Tokens))
+		}
+		for j, token := range tokens {
+			if got := token.Marshal(); !bytes.Equal(got, v.Tokens[j]) {
+				t.Errorf("%d: token %d = %x, want %x", i, j, got, v.Tokens[j])
+			}
+			token, err := privacypass.ParseToken(v.Tokens[j])
+			fatalIfErr(t, err)
+			if !issuer.Verify(token) {
+				t.Errorf("%d: vector token %d rejected", i, j)
+			}
+		}
+	}
+}
+
+// testIssuer returns an issuer with the key of the P384-SHA384 VOPRF test
+// vectors of RFC 9497, Appendix A.4.2.
+func testIssuer(t *testing.T) *privacypass.Issuer {
+	t.Helper()
+	seed := decodeHex(t, "a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3a3")
+	sk, err := oprf.P384SHA384().DeriveKeyPair(oprf.ModeVOPRF, seed, []byte("test key"))
+	fatalIfErr(t, err)
+	issuer, err := privacypass.NewIssuer(sk)
+	fatalIfErr(t, err)
+	return issuer
+}
+
+// testChallenge is an encoded TokenChallenge for token type 0x0001, issuer
+// "issuer.example", no redemption context, and origin "origin.example".
+var testChallenge = append(append([]byte{0x00, 0x01, 0x00, 0x0e}, "issuer.example"...),
+	append([]byte{0x00, 0x00, 0x0e}, "origin.example"...)...)
+
+func TestIssuerKey(t *testing.T) {
+	issuer := testIssuer(t)
+	pkI := decodeHex(t, "031d689686c611991b55f1a1d8f4305ccd6cb719446f660a30db61b7aa87b46acf59b7c0d4a9077b3da21c25dd482229a0")
+	if !bytes.Equal(issuer.PublicKey(), pkI) {
+		t.Errorf("PublicKey = %x, want %x", issuer.PublicKey(), pkI)
+	}
+	keyID := sha256.Sum256(pkI)
+	if !bytes.Equal(issuer.TokenKeyID(), keyID[:]) {
+		t.Errorf("TokenKeyID = %x, want %x", issuer.TokenKeyID(), keyID)
+	}
+}
+
+func TestIssuance(t *testing.T) {
+	issuer := testIssuer(t)
+	client, err := privacypass.NewClient(issuer.PublicKey())
+	fatalIfErr(t, err)
+
+	st, req, err := client.CreateTokenRequest(rand.Reader, testChallenge)
+	fatalIfErr(t, err)
+	reqBytes := req.Marshal()
+	if len(reqBytes) != 2+1+49 {
+		t.Fatalf("TokenRequest is %d bytes, want %d", len(reqBytes), 2+1+49)
+	}
+	if reqBytes[2] != issuer.TokenKeyID()[31] {
+		t.Errorf("truncated_token_key_id = %x, want %x", reqBytes[2], issuer.TokenKeyID()[31])
+	}
+	req, err = privacypass.ParseTokenRequest(reqBytes)
+	fatalIfErr(t, err)
+
+	resp, err := issuer.Issue(rand.Reader, req)
+	fatalIfErr(t, err)
+	respBytes := resp.Marshal()
+	if len(respBytes) != 49+2*48 {
+		t.Fatalf("TokenResponse is %d bytes, want %d", len(respBytes), 49+2*48)
+	}
+	resp, err = privacypass.ParseTokenResponse(respBytes)
+	fatalIfErr(t, err)
+
+	token, err := client.FinalizeToken(st, resp)
+	fatalIfErr(t, err)
+	tokenBytes := token.Marshal()
+	if len(tokenBytes) != 2+32+32+32+48 {
+		t.Fatalf("Token is %d bytes, want %d", len(tokenBytes), 2+32+32+32+48)
+	}
+	digest := sha256.Sum256(testChallenge)
+	if !bytes.Equal(tokenBytes[:2], []byte{0x00, 0x01}) ||
+		!bytes.Equal(tokenBytes[34:66], digest[:]) ||
+		!bytes.Equal(tokenBytes[66:98], issuer.TokenKeyID()) {
+		t.Errorf("unexpected Token encoding %x", tokenBytes)
+	}
+	if token.ChallengeDigest != privacypass.ChallengeDigest(testChallenge) {
+		t.Errorf("ChallengeDigest = %x, want %x", token.ChallengeDigest, digest)
+	}
+
+	// The authenticator is the VOPRF output for the token input.
+	sk, err := oprf.P384SHA384().DeriveKeyPair(oprf.ModeVOPRF,
+		bytes.Repeat([]byte{0xa3}, 32), []byte("test key"))
+	fatalIfErr(t, err)
+	server, err := oprf.NewServer(oprf.P384SHA384(), oprf.ModeVOPRF, sk)
+	fatalIfErr(t, err)
+	authenticator, err := server.Evaluate(tokenBytes[:98], nil)
+	fatalIfErr(t, err)
+	if !bytes.Equal(token.Authenticator, authenticator) {
+		t.Errorf("Authenticator = %x, want %x", token.Authenticator, authenticator)
+	}
+
+	token, err = privacypass.ParseToken(tokenBytes)
+	fatalIfErr(t, err)
+	if !issuer.Verify(token) {
+		t.Error("valid token rejected")
+	}
+}
+
+func TestBatchIssuance(t *testing.T) {
+	issuer := testIssuer(t)
+	client, err := privacypass.NewClient(issuer.PublicKey())
+	fatalIfErr(t, err)
+
+	const n = 5
+	st, req, err := client.CreateBatchTokenRequest(rand.Reader, testChallenge, n)
+	fatalIfErr(t, err)
+	reqBytes := req.Marshal()
+	if len(reqBytes) != 2+1+2+n*49 {
+		t.Fatalf("BatchTokenRequest is %d bytes, want %d", len(reqBytes), 2+1+2+n*49)
+	}
+	req, err = privacypass.ParseBatchTokenRequest(reqBytes)
+	fatalIfErr(t, err)
+	if len(req.BlindedElements) != n {
+		t.Fatalf("parsed %d blinded elements, want %d", len(req.BlindedElements), n)
+	}
+
+	resp, err := issuer.IssueBatch(rand.Reader, req)
+	fatalIfErr(t, err)
+	respBytes := resp.Marshal()
+	if len(respBytes) != 2+n*49+2*48 {
+		t.Fatalf("BatchTokenResponse is %d bytes, want %d", len(respBytes), 2+n*49+2*48)
+	}
+	resp, err = privacypass.ParseBatchTokenResponse(respBytes)
+	fatalIfErr(t, err)
+
+	tokens, err := client.FinalizeBatch(st, resp)
+	fatalIfErr(t, err)
+	if len(tokens) != n {
+		t.Fatalf("got %d tokens, want %d", len(tokens), n)
+	}
+	for i, token := range tokens {
+		if !issuer.Verify(token) {
+			t.Errorf("token %d rejected", i)
+		}
+		if i > 0 && token.Nonce == tokens[0].Nonce {
+			t.Errorf("token %d reuses the nonce of token 0", i)
+		}
+	}
+
+	// Swapping two evaluated elements invalidates the proof.
+	resp.EvaluatedElements[0], resp.EvaluatedElements[1] = resp.EvaluatedElements[1], resp.EvaluatedElements[0]
+	if _, err := client.FinalizeBatch(st, resp); err == nil {
+		t.Error("reordered evaluated elements accepted")
+	}
+	resp.EvaluatedElements = resp.EvaluatedElements[1:]
+	if _, err := client.FinalizeBatch(st, resp); err == nil {
+		t.Error("missing evaluated element accepted")
+	}
+	if _, _, err := client.CreateBatchTokenRequest(rand.Reader, testChallenge, 0); err == nil {
+		t.Error("empty batch accepted")
+	}
+}
+
+func TestRejections(t *testing.T) {
+	issuer := testIssuer(t)
+	client, err := privacypass.NewClient(issuer.PublicKey())
+	fatalIfErr(t, err)
+	st, req, err := client.CreateTokenRequest(rand.Reader, testChallenge)
+	fatalIfErr(t, err)
+
+	wrongKey := *req
+	wrongKey.TruncatedTokenKeyID ^= 1
+	if _, err := issuer.Issue(rand.Reader, &wrongKey); err == nil {
+		t.Error("request for another key accepted")
+	}
+	notOnCurve := *req
+	notOnCurve.BlindedMsg = append([]byte{0x02}, bytes.Repeat([]byte{0xff}, 48)...)
+	if _, err := issuer.Issue(rand.Reader, &notOnCurve); err == nil {
+		t.Error("invalid blinded element accepted")
+	}
+
+	// A response from another issuer fails the proof.
+	sk, err := oprf.P384SHA384().GenerateKey(rand.Reader)
+	fatalIfErr(t, err)
+	other, err := privacypass.NewIssuer(sk)
+	fatalIfErr(t, err)
+	otherReq := *req
+	otherReq.TruncatedTokenKeyID = other.TokenKeyID()[31]
+	resp, err := other.Issue(rand.Reader, &otherReq)
+	fatalIfErr(t, err)
+	if _, err := client.FinalizeToken(st, resp); err == nil {
+		t.Error("response from another issuer accepted")
+	}
+
+	resp, err = issuer.Issue(rand.Reader, req)
+	fatalIfErr(t, err)
+	token, err := client.FinalizeToken(st, resp)
+	fatalIfErr(t, err)
+	if other.Verify(token) {
+		t.Error("token verified by another issuer")
+	}
+	for i := range token.Marshal() {
+		b := token.Marshal()
+		b[i] ^= 1
+		tampered, err := privacypass.ParseToken(b)
+		if err != nil {
+			if i >= 2 {
+				t.Errorf("byte %d: %v", i, err)
+			}
+			continue
+		}
+		if issuer.Verify(tampered) {
+			t.Errorf("token with byte %d modified accepted", i)
+		}
+	}
+
+	if _, err := privacypass.ParseToken(append(token.Marshal(), 0)); err == nil {
+		t.Error("token with trailing data accepted")
+	}
+	if _, err := privacypass.ParseTokenRequest(req.Marshal()[1:]); err == nil {
+		t.Error("truncated TokenRequest accepted")
+	}
+	b := req.Marshal()
+	b[1] = 2
+	if _, err := privacypass.ParseTokenRequest(b); err == nil {
+		t.Error("TokenRequest of type 0x0002 accepted")
+	}
+	if _, err := privacypass.ParseTokenResponse(resp.Marshal()[:100]); err == nil {
+		t.Error("truncated TokenResponse accepted")
+	}
+	batch := &privacypass.BatchTokenRequest{TruncatedTokenKeyID: req.TruncatedTokenKeyID}
+	if _, err := privacypass.ParseBatchTokenRequest(batch.Marshal()); err == nil {
+		t.Error("empty BatchTokenRequest accepted")
+	}
+	if _, err := issuer.IssueBatch(rand.Reader, batch); err == nil {
+		t.Error("empty BatchTokenRequest issued")
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package schnorr implements the EC-SDSA and EC-FSDSA elliptic curve Schnorr
+// signature schemes of ISO/IEC 14888-3:2018, over the groups of package group.
+//
+// Both schemes commit to a random point W = [k]G, and compute the challenge
+// e = H(Wx || Wy || M) mod N and the response s = k + e × x mod N, where x is
+// the private key. EC-SDSA signatures are the hash H(Wx || Wy || M) followed
+// by s, and EC-FSDSA ("full") signatures are the uncompressed coordinates of W
+// followed by s. EC-FSDSA signatures are longer, but can't be forged by
+// finding hash collisions.
+//
+// Signing is constant-time. Verification is variable-time, as it only handles
+// public values.
+package schnorr
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"hash"
+	"io"
+
+	"github.com/magical/nistec-extra/group"
+)
+
+// PrivateKey is an EC-SDSA and EC-FSDSA private key.
+type PrivateKey struct {
+	pub PublicKey
+	x   group.Scalar
+}
+
+// PublicKey is an EC-SDSA and EC-FSDSA public key, Y = [x]G.
+type PublicKey struct {
+	g group.Group
+	y group.Element
+}
+
+// GenerateKey returns a new private key in g, using rand as the source of
+// randomness.
+func GenerateKey(g group.Group, rand io.Reader) (*PrivateKey, error) {
+	x, err := g.RandomScalar(rand)
+	if err != nil {
+		return nil, err
+	}
+	return newPrivateKey(g, x), nil
+}
+
+// NewPrivateKey returns the private key with scalar x, which must be the
+// big-endian encoding of an integer in [1, N-1], ScalarLength bytes long.
+func NewPrivateKey(g group.Group, x []byte) (*PrivateKey, error) {
+	s, err := g.NewScalar().SetBytes(x)
+	if err != nil {
+		return nil, errors.New("schnorr: invalid private key: " + err.Error())
+	}
+	if s.IsZero() == 1 {
+		return nil, errors.New("schnorr: invalid private key: zero scalar")
+	}
+	return newPrivateKey(g, s), nil
+}
+
+func newPrivateKey(g group.Group, x group.Scalar) *PrivateKey {
+	y := g.NewElement().ScalarBaseMult(x)
+	return &PrivateKey{pub: PublicKey{g: g, y: y}, x: x}
+}
+
+// NewPublicKey returns the public key encoded as y, an uncompressed or
+// compressed point. The identity is rejected.
+func NewPublicKey(g group.Group, y []byte) (*PublicKey, error) {
+	p, err := g.NewElement().SetBytes(y)
+	if err != nil {
+		return nil, errors.New("schnorr: invalid public key: " + err.Error())
+	}
+	if p.IsIdentity() == 1 {
+		return nil, errors.New("schnorr: invalid public key: identity element")
+	}
+	return &PublicKey{g: g, y: p}, nil
+}
+
+// Bytes returns the ScalarLength bytes big-endian encoding of the private
+// scalar.
+func (k *PrivateKey) Bytes() []byte { return k.x.Bytes() }
+
+// PublicKey returns the public key corresponding to k.
+func (k *PrivateKey) PublicKey() *PublicKey { return &k.pub }
+
+// Bytes returns the uncompressed encoding of the public point.
+func (k *PublicKey) Bytes() []byte { return k.y.Bytes() }
+
+// Group returns the group of the key.
+func (k *PublicKey) Group() group.Group { return k.g }
+
+// SignSDSA returns the EC-SDSA signature of msg, the hash H(Wx || Wy || msg)
+// computed with h followed by the ScalarLength bytes encoding of s. The nonce
+// is read from rand.
+func SignSDSA(rand io.Reader, priv *PrivateKey, h func() hash.Hash, msg []byte) ([]byte, error) {
+	_, r, s, err := sign(rand, priv, h, msg)
+	if err != nil {
+		return nil, err
+	}
+	return append(r, s...), nil
+}
+
+// SignFSDSA returns the EC-FSDSA signature of msg, the uncompressed
+// coordinates of W, without the leading 0x04, followed by the ScalarLength
+// bytes encoding of s. The nonce is read from rand.
+func SignFSDSA(rand io.Reader, priv *PrivateKey, h func() hash.Hash, msg []byte) ([]byte, error) {
+	w, _, s, err := sign(rand, priv, h, msg)
+	if err != nil {
+		return nil, err
+	}
+	return append(w, s...), nil
+}
+
+// sign returns the coordinates Wx || Wy of the commitment, the hash
+// r = H(Wx || Wy || msg), and the encoding of s.
+func sign(rand io.Reader, priv *PrivateKey, h func() hash.Hash, msg []byte) (w, r, s []byte, err error) {
+	g := priv.pub.g
+	W := g.NewElement()
+	for {
+		k, err := g.RandomScalar(rand)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		W.ScalarBaseMult(k)
+		w = W.Bytes()[1:]
+		var e group.Scalar
+		r, e = challenge(g, h, w, msg)
+		if e.IsZero() == 1 {
+			continue
+		}
+		ss := g.NewScalar().Multiply(e, priv.x)
+		ss.Add(ss, k)
+		if ss.IsZero() == 1 {
+			continue
+		}
+		return w, r, ss.Bytes(), nil
+	}
+}
+
+// challenge returns r = H(w || msg) and e = r mod N.
+func challenge(g group.Group, h func() hash.Hash, w, msg []byte) (r []byte, e group.Scalar) {
+	H := h()
+	H.Write(w)
+	H.Write(msg)
+	r = H.Sum(nil)
+	return r, g.NewScalar().SetReducedBytes(r)
+}
+
+// verify returns Wx || Wy for W = [s]G - [e]Y, or nil if the values are out of
+// range or W is the identity.
+func verify(pub *PublicKey, e group.Scalar, sb []byte) []byte {
+	g := pub.g
+	s, err := g.NewScalar().SetBytes(sb)
+	if err != nil || s.IsZero() == 1 || e.IsZero() == 1 {
+		return nil
+	}
+	W := g.NewElement().ScalarBaseMult(s)
+	W.Subtract(W, g.NewElement().ScalarMult(pub.y, e))
+	if W.IsIdentity() == 1 {
+		return nil
+	}
+	return W.Bytes()[1:]
+}
+
+// VerifySDSA reports whether sig is a valid EC-SDSA signature of msg by pub,
+// computed with h.
+func VerifySDSA(pub *PublicKey, h func() hash.Hash, msg, sig []byte) bool {
+	rLen := h().Size()
+	if len(sig) != rLen+pub.g.ScalarLength() {
+		return false
+	}
+	r, s := sig[:rLen], sig[rLen:]
+	e := pub.g.NewScalar().SetReducedBytes(r)
+	w := verify(pub, e, s)
+	if w == nil {
+		return false
+	}
+	rr, _ := challenge(pub.g, h, w, msg)
+	return subtle.ConstantTimeCompare(rr, r) == 1
+}
+
+// VerifyFSDSA reports whether sig is a valid EC-FSDSA signature of msg by pub,
+// computed with h.
+func VerifyFSDSA(pub *PublicKey, h func() hash.Hash, msg, sig []byte) bool {
+	wLen := pub.g.ElementLength() - 1
+	if len(sig) != 2*wLen+pub.g.ScalarLength() {
+		return false
+	}
+	r, s := sig[:2*wLen], sig[2*wLen:]
+	_, e := challenge(pub.g, h, r, msg)
+	w := verify(pub, e, s)
+	return w != nil && bytes.Equal(w, r)
+}
@@ -0,0 +1,179 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schnorr_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"os"
+	"testing"
+
+	"github.com/magical/nistec-extra/group"
+	"github.com/magical/nistec-extra/schnorr"
+)
+
+func fatalIfErr(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	fatalIfErr(t, err)
+	return b
+}
+
+// hexBytes is a []byte encoded as a hex string in JSON.
+type hexBytes []byte
+
+func (b *hexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := hex.DecodeString(s)
+	*b = v
+	return err
+}
+
+// TestKnownAnswer checks signatures with a fixed nonce, read from a
+// deterministic rand, against testdata/vectors.json. The vectors are
+// generated by testdata/gen.py from the definitions of ISO/IEC 14888-3:2018,
+// using the elliptic curve arithmetic of pyca/cryptography.
+func TestKnownAnswer(t *testing.T) {
+	data, err := os.ReadFile("testdata/vectors.json")
+	fatalIfErr(t, err)
+	var vectors []struct {
+		Curve, Hash string
+		X, Y, K     hexBytes
+		Msg         hexBytes
+		SDSA, FSDSA hexBytes
+	}
+	fatalIfErr(t, json.Unmarshal(data, &vectors))
+	groups := map[string]group.Group{
+		"P-224": group.P224(), "P-256": group.P256(),
+		"P-384": group.P384(), "P-521": group.P521(),
+	}
+	hashes := map[string]func() hash.Hash{
+		"SHA-224": sha256.New224, "SHA-256": sha256.New,
+		"SHA-384": sha512.New384, "SHA-512": sha512.New,
+	}
+	for i, v := range vectors {
+		g, h := groups[v.Curve], hashes[v.Hash]
+		priv, err := schnorr.NewPrivateKey(g, v.X)
+		fatalIfErr(t, err)
+		if got := priv.PublicKey().Bytes(); !bytes.Equal(got, v.Y) {
+			t.Errorf("%d: public key = %x, want %x", i, got, v.Y)
+		}
+		pub, err := schnorr.NewPublicKey(g, v.Y)
+		fatalIfErr(t, err)
+
+		sig, err := schnorr.SignSDSA(bytes.NewReader(v.K), priv, h, v.Msg)
+		fatalIfErr(t, err)
+		if !bytes.Equal(sig, v.SDSA) {
+			t.Errorf("%d: EC-SDSA signature = %x, want %x", i, sig, v.SDSA)
+		}
+		if !schnorr.VerifySDSA(pub, h, v.Msg, v.SDSA) {
+			t.Errorf("%d: VerifySDSA failed", i)
+		}
+
+		sig, err = schnorr.SignFSDSA(bytes.NewReader(v.K), priv, h, v.Msg)
+		fatalIfErr(t, err)
+		if !bytes.Equal(sig, v.FSDSA) {
+			t.Errorf("%d: EC-FSDSA signature = %x, want %x", i, sig, v.FSDSA)
+		}
+		if !schnorr.VerifyFSDSA(pub, h, v.Msg, v.FSDSA) {
+			t.Errorf("%d: VerifyFSDSA failed", i)
+		}
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	for _, g := range []group.Group{group.P224(), group.P256(), group.P384(), group.P521()} {
+		t.Run(g.Name(), func(t *testing.T) {
+			testSignVerify(t, g)
+		})
+	}
+}
+
+func testSignVerify(t *testing.T, g group.Group) {
+	priv, err := schnorr.GenerateKey(g, rand.Reader)
+	fatalIfErr(t, err)
+	pub, err := schnorr.NewPublicKey(g, priv.PublicKey().Bytes())
+	fatalIfErr(t, err)
+	other, err := schnorr.GenerateKey(g, rand.Reader)
+	fatalIfErr(t, err)
+	msg := []byte("hello")
+
+	for _, scheme := range []struct {
+		name   string
+		sign   func(*schnorr.PrivateKey, []byte) ([]byte, error)
+		verify func(*schnorr.PublicKey, []byte, []byte) bool
+	}{
+		{"EC-SDSA", func(k *schnorr.PrivateKey, m []byte) ([]byte, error) {
+			return schnorr.SignSDSA(rand.Reader, k, sha256.New, m)
+		}, func(k *schnorr.PublicKey, m, sig []byte) bool {
+			return schnorr.VerifySDSA(k, sha256.New, m, sig)
+		}},
+		{"EC-FSDSA", func(k *schnorr.PrivateKey, m []byte) ([]byte, error) {
+			return schnorr.SignFSDSA(rand.Reader, k, sha256.New, m)
+		}, func(k *schnorr.PublicKey, m, sig []byte) bool {
+			return schnorr.VerifyFSDSA(k, sha256.New, m, sig)
+		}},
+	} {
+		sig, err := scheme.sign(priv, msg)
+		fatalIfErr(t, err)
+		if !scheme.verify(pub, msg, sig) {
+			t.Errorf("%s: valid signature rejected", scheme.name)
+		}
+		sig2, err := scheme.sign(priv, msg)
+		fatalIfErr(t, err)
+		if bytes.Equal(sig, sig2) {
+			t.Errorf("%s: two signatures are equal", scheme.name)
+		}
+		if scheme.verify(other.PublicKey(), msg, sig) {
+			t.Errorf("%s: signature valid for another key", scheme.name)
+		}
+		if scheme.verify(pub, []byte("hellp"), sig) {
+			t.Errorf("%s: signature valid for another message", scheme.name)
+		}
+		for i := range sig {
+			bad := append([]byte(nil), sig...)
+			bad[i] ^= 0x10
+			if scheme.verify(pub, msg, bad) {
+				t.Errorf("%s: signature with byte %d flipped accepted", scheme.name, i)
+			}
+		}
+		if scheme.verify(pub, msg, sig[:len(sig)-1]) || scheme.verify(pub, msg, append(sig, 0)) {
+			t.Errorf("%s: signature with wrong length accepted", scheme.name)
+		}
+		// s = 0 and s = N are rejected.
+		zero := append([]byte(nil), sig...)
+		copy(zero[len(zero)-g.ScalarLength():], make([]byte, g.ScalarLength()))
+		order := append([]byte(nil), sig...)
+		copy(order[len(order)-g.ScalarLength():], g.Order())
+		if scheme.verify(pub, msg, zero) || scheme.verify(pub, msg, order) {
+			t.Errorf("%s: out of range s accepted", scheme.name)
+		}
+	}
+
+	if _, err := schnorr.SignSDSA(bytes.NewReader(nil), priv, sha256.New, msg); err == nil {
+		t.Error("SignSDSA succeeded with an empty rand")
+	}
+	if _, err := schnorr.NewPrivateKey(g, make([]byte, g.ScalarLength())); err == nil {
+		t.Error("NewPrivateKey accepted zero")
+	}
+	if _, err := schnorr.NewPublicKey(g, []byte{0}); err == nil {
+		t.Error("NewPublicKey accepted the identity")
+	}
+}
@@ -0,0 +1,85 @@
+"""Generates vectors.json, EC-SDSA and EC-FSDSA known-answer vectors following
+the definitions of ISO/IEC 14888-3:2018, Sections 6.8 and 6.9, with the
+elliptic curve arithmetic of pyca/cryptography (OpenSSL), independently of
+this repository. Run with: python3 gen.py > vectors.json
+
+For a private key x, nonce k, and message M, with W = [k]G:
+
+  EC-SDSA:  r = H(Wx || Wy || M), e = OS2I(r) mod n, s = (k + e*x) mod n,
+            and the signature is r || s.
+  EC-FSDSA: r = Wx || Wy, e = OS2I(H(r || M)) mod n, s = (k + e*x) mod n,
+            and the signature is r || s.
+
+Coordinates and s are encoded big-endian, with the length of the field and of
+the order respectively, which are the same for the NIST curves.
+"""
+
+import hashlib
+import json
+
+from cryptography.hazmat.primitives.asymmetric import ec
+
+CURVES = [
+    # name, curve, order, length, hash
+    ('P-224', ec.SECP224R1(),
+     0xffffffffffffffffffffffffffff16a2e0b8f03e13dd29455c5c2a3d, 28, 'sha224'),
+    ('P-256', ec.SECP256R1(),
+     0xffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632551, 32, 'sha256'),
+    ('P-384', ec.SECP384R1(),
+     0xffffffffffffffffffffffffffffffffffffffffffffffffc7634d81f4372ddf581a0db248b0a77aecec196accc52973, 48, 'sha384'),
+    ('P-521', ec.SECP521R1(),
+     0x01fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffa51868783bf2f966b7fcc0148f709a5d03bb5c9b8899c47aebb6fb71e91386409, 66, 'sha512'),
+]
+
+HASH_NAMES = {'sha224': 'SHA-224', 'sha256': 'SHA-256', 'sha384': 'SHA-384', 'sha512': 'SHA-512'}
+
+MESSAGES = [b'', b'abc', bytes(range(256)) * 4]
+
+
+def scalar(label, n, length):
+    """Derives a deterministic scalar in [1, n-1] from label."""
+    h = hashlib.sha512(label).digest() + hashlib.sha512(b'\x01' + label).digest()
+    return int.from_bytes(h[:length], 'big') % (n - 1) + 1
+
+
+def point(curve, k, length):
+    """Returns the uncompressed coordinates of [k]G."""
+    pub = ec.derive_private_key(k, curve).public_key().public_numbers()
+    return pub.x.to_bytes(length, 'big'), pub.y.to_bytes(length, 'big')
+
+
+def main():
+    vectors = []
+    for name, curve, n, length, hname in CURVES:
+        # Check the order: [n-1]G = -G.
+        gx, gy = point(curve, 1, length)
+        assert point(curve, n - 1, length)[0] == gx, name
+        x = scalar(b'schnorr key ' + name.encode(), n, length)
+        yx, yy = point(curve, x, length)
+        for i, msg in enumerate(MESSAGES):
+            k = scalar(b'schnorr nonce %s %d' % (name.encode(), i), n, length)
+            wx, wy = point(curve, k, length)
+
+            r = hashlib.new(hname, wx + wy + msg).digest()
+            e = int.from_bytes(r, 'big') % n
+            sdsa = r + ((k + e * x) % n).to_bytes(length, 'big')
+
+            r = wx + wy
+            e = int.from_bytes(hashlib.new(hname, r + msg).digest(), 'big') % n
+            fsdsa = r + ((k + e * x) % n).to_bytes(length, 'big')
+
+            vectors.append({
+                'curve': name,
+                'hash': HASH_NAMES[hname],
+                'x': x.to_bytes(length, 'big').hex(),
+                'y': (b'\x04' + yx + yy).hex(),
+                'k': k.to_bytes(length, 'big').hex(),
+                'msg': msg.hex(),
+                'sdsa': sdsa.hex(),
+                'fsdsa': fsdsa.hex(),
+            })
+    print(json.dumps(vectors, indent=2))
+
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,569 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// This file implements the OpenSSH encodings of ECDSA keys and signatures: the
+// public key and signature blobs of RFC 5656, Section 3.1, the public key line
+// of authorized_keys and .pub files, and the unencrypted "openssh-key-v1"
+// private key format from PROTOCOL.key in the OpenSSH sources.
+
+// sshCurve holds the SSH identifiers of a curve, from RFC 5656, Section 6.1.
+// P-224 has no registered identifier.
+type sshCurve struct {
+	id      string // curve identifier, such as "nistp256"
+	byteLen int
+	n       []byte
+
+	// parsePoint returns the point with encoding b, and its uncompressed or
+	// infinity encoding.
+	parsePoint func(b []byte) (any, []byte, error)
+	// publicKey returns the uncompressed encoding of [scalar]G.
+	publicKey func(scalar []byte) ([]byte, error)
+}
+
+// keyType returns the SSH public key algorithm name, such as
+// "ecdsa-sha2-nistp256".
+func (c *sshCurve) keyType() string { return "ecdsa-sha2-" + c.id }
+
+var (
+	p256SSH = &sshCurve{id: "nistp256", byteLen: p256ElementLength, n: p256Order,
+		parsePoint: func(b []byte) (any, []byte, error) { return parsePointAny(NewP256Point, b) },
+		publicKey:  func(scalar []byte) ([]byte, error) { return scalarBaseMultBytes(NewP256Point, scalar) }}
+	p384SSH = &sshCurve{id: "nistp384", byteLen: p384ElementLength, n: p384Order,
+		parsePoint: func(b []byte) (any, []byte, error) { return parsePointAny(NewP384Point, b) },
+		publicKey:  func(scalar []byte) ([]byte, error) { return scalarBaseMultBytes(NewP384Point, scalar) }}
+	p521SSH = &sshCurve{id: "nistp521", byteLen: p521ElementLength, n: p521Order,
+		parsePoint: func(b []byte) (any, []byte, error) { return parsePointAny(NewP521Point, b) },
+		publicKey:  func(scalar []byte) ([]byte, error) { return scalarBaseMultBytes(NewP521Point, scalar) }}
+)
+
+var sshCurves = []*sshCurve{p256SSH, p384SSH, p521SSH}
+
+const sshPrivateKeyMagic = "openssh-key-v1\x00"
+
+func addSSHString(b *cryptobyte.Builder, s []byte) {
+	b.AddUint32LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(s)
+	})
+}
+
+// readSSHString reads a string with a uint32 length prefix, as specified in
+// RFC 4251, Section 5.
+func readSSHString(s *cryptobyte.String, out *cryptobyte.String) bool {
+	var length uint32
+	var v []byte
+	if !s.ReadUint32(&length) || !s.ReadBytes(&v, int(length)) {
+		return false
+	}
+	*out = v
+	return true
+}
+
+// addSSHMPInt adds the non-negative big-endian integer v as an mpint, as
+// specified in RFC 4251, Section 5.
+func addSSHMPInt(b *cryptobyte.Builder, v []byte) {
+	v = trimLeadingZeros(v)
+	b.AddUint32LengthPrefixed(func(b *cryptobyte.Builder) {
+		if len(v) > 0 && v[0]&0x80 != 0 {
+			b.AddUint8(0)
+		}
+		b.AddBytes(v)
+	})
+}
+
+// readSSHMPInt reads a non-negative, minimally encoded mpint into out, which
+// must be large enough to hold it.
+func readSSHMPInt(s *cryptobyte.String, out []byte) bool {
+	var v cryptobyte.String
+	if !readSSHString(s, &v) {
+		return false
+	}
+	if len(v) > 0 && v[0]&0x80 != 0 {
+		return false // negative
+	}
+	if len(v) > 0 && v[0] == 0 && (len(v) == 1 || v[1]&0x80 == 0) {
+		return false // not minimal
+	}
+	if len(v) > 0 && v[0] == 0 {
+		v = v[1:]
+	}
+	if len(v) > len(out) {
+		return false
+	}
+	for i := range out {
+		out[i] = 0
+	}
+	copy(out[len(out)-len(v):], v)
+	return true
+}
+
+func addSSHPublicKey(b *cryptobyte.Builder, c *sshCurve, point []byte) {
+	addSSHString(b, []byte(c.keyType()))
+	addSSHString(b, []byte(c.id))
+	addSSHString(b, point)
+}
+
+// marshalSSHPublicKey returns the public key blob of the point with
+// uncompressed encoding point.
+func marshalSSHPublicKey(c *sshCurve, point []byte) ([]byte, error) {
+	if len(point) == 1 {
+		return nil, errors.New("SSH public key is the point at infinity")
+	}
+	var b cryptobyte.Builder
+	addSSHPublicKey(&b, c, point)
+	return b.Bytes()
+}
+
+// readSSHPublicKey reads the fields of a public key blob, and returns the
+// curve and the encoding of the point, which is not yet validated.
+func readSSHPublicKey(s *cryptobyte.String) (*sshCurve, []byte, error) {
+	var keyType, id, point cryptobyte.String
+	if !readSSHString(s, &keyType) || !readSSHString(s, &id) ||
+		!readSSHString(s, &point) {
+		return nil, nil, errors.New("invalid SSH public key encoding")
+	}
+	var c *sshCurve
+	for _, cc := range sshCurves {
+		if string(keyType) == cc.keyType() {
+			c = cc
+		}
+	}
+	if c == nil {
+		return nil, nil, errors.New("unsupported SSH public key type")
+	}
+	if string(id) != c.id {
+		return nil, nil, errors.New("SSH public key curve doesn't match the key type")
+	}
+	if len(point) == 1 {
+		return nil, nil, errors.New("SSH public key is the point at infinity")
+	}
+	return c, point, nil
+}
+
+func parseSSHPublicKey(blob []byte) (*sshCurve, []byte, error) {
+	s := cryptobyte.String(blob)
+	c, point, err := readSSHPublicKey(&s)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !s.Empty() {
+		return nil, nil, errors.New("invalid SSH public key encoding")
+	}
+	return c, point, nil
+}
+
+// marshalSSHAuthorizedKey returns the authorized_keys line of the point with
+// uncompressed encoding point.
+func marshalSSHAuthorizedKey(c *sshCurve, point []byte, comment string) ([]byte, error) {
+	blob, err := marshalSSHPublicKey(c, point)
+	if err != nil {
+		return nil, err
+	}
+	line := c.keyType() + " " + base64.StdEncoding.EncodeToString(blob)
+	if comment != "" {
+		line += " " + comment
+	}
+	return []byte(line + "\n"), nil
+}
+
+// parseSSHAuthorizedKey parses the first line of data, which must be of the
+// form "<key type> <base64 blob> [comment]".
+func parseSSHAuthorizedKey(data []byte) (c *sshCurve, point []byte, comment string, err error) {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	line = bytes.TrimSpace(line)
+	keyType, rest, _ := bytes.Cut(line, []byte(" "))
+	b64Blob, rest, _ := bytes.Cut(bytes.TrimLeft(rest, " \t"), []byte(" "))
+	blob, err := base64.StdEncoding.DecodeString(string(b64Blob))
+	if err != nil {
+		return nil, nil, "", errors.New("invalid SSH public key line")
+	}
+	c, point, err = parseSSHPublicKey(blob)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if string(keyType) != c.keyType() {
+		return nil, nil, "", errors.New("SSH public key line type doesn't match the key")
+	}
+	return c, point, string(bytes.TrimSpace(rest)), nil
+}
+
+// marshalSSHSignature returns the signature blob of the ECDSA signature (r, s).
+func marshalSSHSignature(c *sshCurve, r, s []byte) ([]byte, error) {
+	if checkPrivateScalar(r, c.n) != nil || checkPrivateScalar(s, c.n) != nil {
+		return nil, errors.New("invalid ECDSA signature values")
+	}
+	var b cryptobyte.Builder
+	addSSHString(&b, []byte(c.keyType()))
+	b.AddUint32LengthPrefixed(func(b *cryptobyte.Builder) {
+		addSSHMPInt(b, r)
+		addSSHMPInt(b, s)
+	})
+	return b.Bytes()
+}
+
+// parseSSHSignature parses a signature blob, and returns r and s as big-endian
+// values of the same length as the order.
+func parseSSHSignature(c *sshCurve, sig []byte) (r, s []byte, err error) {
+	input := cryptobyte.String(sig)
+	var keyType, blob cryptobyte.String
+	r, s = make([]byte, len(c.n)), make([]byte, len(c.n))
+	if !readSSHString(&input, &keyType) ||
+		!readSSHString(&input, &blob) || !input.Empty() ||
+		!readSSHMPInt(&blob, r) || !readSSHMPInt(&blob, s) || !blob.Empty() {
+		return nil, nil, errors.New("invalid SSH signature encoding")
+	}
+	if string(keyType) != c.keyType() {
+		return nil, nil, errors.New("SSH signature is not " + c.keyType())
+	}
+	if checkPrivateScalar(r, c.n) != nil || checkPrivateScalar(s, c.n) != nil {
+		return nil, nil, errors.New("invalid ECDSA signature values")
+	}
+	return r, s, nil
+}
+
+// marshalOpenSSHPrivateKey returns the PEM encoded, unencrypted openssh-key-v1
+// encoding of scalar.
+func marshalOpenSSHPrivateKey(c *sshCurve, scalar []byte, comment string) ([]byte, error) {
+	if err := checkPrivateScalar(scalar, c.n); err != nil {
+		return nil, err
+	}
+	pub, err := c.publicKey(scalar)
+	if err != nil {
+		return nil, err
+	}
+	// The check bytes are meant to detect a wrong passphrase, and are random
+	// even for unencrypted keys.
+	check := make([]byte, 4)
+	if _, err := io.ReadFull(rand.Reader, check); err != nil {
+		return nil, err
+	}
+	var priv cryptobyte.Builder
+	priv.AddBytes(check)
+	priv.AddBytes(check)
+	addSSHPublicKey(&priv, c, pub)
+	addSSHMPInt(&priv, scalar)
+	addSSHString(&priv, []byte(comment))
+	body, err := priv.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	// Pad to the block size of the "none" cipher, with bytes 1, 2, 3, ...
+	for i := 1; len(body)%8 != 0; i++ {
+		body = append(body, byte(i))
+	}
+
+	var b cryptobyte.Builder
+	b.AddBytes([]byte(sshPrivateKeyMagic))
+	addSSHString(&b, []byte("none")) // ciphername
+	addSSHString(&b, []byte("none")) // kdfname
+	addSSHString(&b, nil)            // kdfoptions
+	b.AddUint32(1)                   // number of keys
+	b.AddUint32LengthPrefixed(func(b *cryptobyte.Builder) {
+		addSSHPublicKey(b, c, pub)
+	})
+	addSSHString(&b, body)
+	der, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: der}), nil
+}
+
+// parseOpenSSHPrivateKey parses the first PEM block of type "OPENSSH PRIVATE
+// KEY" in data, which must hold a single unencrypted ECDSA key.
+func parseOpenSSHPrivateKey(data []byte) (c *sshCurve, scalar []byte, pub any, comment string, err error) {
+	var block *pem.Block
+	for {
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, nil, nil, "", errors.New("no OPENSSH PRIVATE KEY PEM block found")
+		}
+		if block.Type == "OPENSSH PRIVATE KEY" {
+			break
+		}
+	}
+	s := cryptobyte.String(block.Bytes)
+	var magic []byte
+	var cipher, kdf, kdfOptions, pubBlob, priv cryptobyte.String
+	var nKeys uint32
+	if !s.ReadBytes(&magic, len(sshPrivateKeyMagic)) || string(magic) != sshPrivateKeyMagic ||
+		!readSSHString(&s, &cipher) || !readSSHString(&s, &kdf) ||
+		!readSSHString(&s, &kdfOptions) || !s.ReadUint32(&nKeys) ||
+		!readSSHString(&s, &pubBlob) || !readSSHString(&s, &priv) ||
+		!s.Empty() {
+		return nil, nil, nil, "", errors.New("invalid OpenSSH private key encoding")
+	}
+	if string(cipher) != "none" || string(kdf) != "none" {
+		return nil, nil, nil, "", errors.New("encrypted OpenSSH private keys are not supported")
+	}
+	if nKeys != 1 {
+		return nil, nil, nil, "", errors.New("OpenSSH private keys with multiple keys are not supported")
+	}
+	c, point, err := parseSSHPublicKey(pubBlob)
+	if err != nil {
+		return nil, nil, nil, "", err
+	}
+
+	var check1, check2 uint32
+	var commentBytes cryptobyte.String
+	scalar = make([]byte, len(c.n))
+	if len(priv)%8 != 0 || !priv.ReadUint32(&check1) || !priv.ReadUint32(&check2) {
+		return nil, nil, nil, "", errors.New("invalid OpenSSH private key encoding")
+	}
+	if check1 != check2 {
+		return nil, nil, nil, "", errors.New("invalid OpenSSH private key check bytes")
+	}
+	privCurve, privPoint, err := readSSHPublicKey(&priv)
+	if err != nil {
+		return nil, nil, nil, "", err
+	}
+	if !readSSHMPInt(&priv, scalar) || !readSSHString(&priv, &commentBytes) || len(priv) >= 8 {
+		return nil, nil, nil, "", errors.New("invalid OpenSSH private key encoding")
+	}
+	for i, b := range priv {
+		if b != byte(i+1) {
+			return nil, nil, nil, "", errors.New("invalid OpenSSH private key padding")
+		}
+	}
+	if privCurve != c || !bytes.Equal(privPoint, point) {
+		return nil, nil, nil, "", errors.New("OpenSSH private key doesn't match its public key")
+	}
+	if err := checkPrivateScalar(scalar, c.n); err != nil {
+		return nil, nil, nil, "", err
+	}
+	want, err := c.publicKey(scalar)
+	if err != nil {
+		return nil, nil, nil, "", err
+	}
+	pub, got, err := c.parsePoint(point)
+	if err != nil || !bytes.Equal(got, want) {
+		return nil, nil, nil, "", errors.New("OpenSSH private key doesn't match its public key")
+	}
+	return c, scalar, pub, string(commentBytes), nil
+}
+
+// ParseSSHPublicKey parses an SSH ECDSA public key blob, as specified in RFC
+// 5656, Section 3.1, and returns a *P256Point, *P384Point, or *P521Point,
+// depending on the curve.
+func ParseSSHPublicKey(blob []byte) (any, error) {
+	c, point, err := parseSSHPublicKey(blob)
+	if err != nil {
+		return nil, err
+	}
+	p, _, err := c.parsePoint(point)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ParseSSHAuthorizedKey parses the first line of data, which must be an
+// OpenSSH public key line of the form "ecdsa-sha2-nistp256 AAAA... comment",
+// as found in .pub and authorized_keys files. Key options are not supported.
+// The public key is returned like for ParseSSHPublicKey.
+func ParseSSHAuthorizedKey(data []byte) (pub any, comment string, err error) {
+	c, point, comment, err := parseSSHAuthorizedKey(data)
+	if err != nil {
+		return nil, "", err
+	}
+	pub, _, err = c.parsePoint(point)
+	if err != nil {
+		return nil, "", err
+	}
+	return pub, comment, nil
+}
+
+// ParseOpenSSHPrivateKey parses a PEM encoded OpenSSH private key, as produced
+// by ssh-keygen, and returns its scalar, public key, and comment. The public key
+// is returned like for ParseSSHPublicKey. Encrypted keys are not supported.
+func ParseOpenSSHPrivateKey(data []byte) (scalar []byte, pub any, comment string, err error) {
+	_, scalar, pub, comment, err = parseOpenSSHPrivateKey(data)
+	return scalar, pub, comment, err
+}
+
+// BytesSSH returns the SSH public key blob of p, as specified in RFC 5656,
+// Section 3.1, or an error if p is the point at infinity.
+func (p *P256Point) BytesSSH() ([]byte, error) {
+	return marshalSSHPublicKey(p256SSH, p.Bytes())
+}
+
+// SetBytesSSH sets p to the public key in the SSH public key blob b, and
+// returns p. The key type must be "ecdsa-sha2-nist256". If b is not a valid
+// P-256 public key, SetBytesSSH returns nil and an error, and p is unchanged.
+func (p *P256Point) SetBytesSSH(b []byte) (*P256Point, error) {
+	c, point, err := parseSSHPublicKey(b)
+	if err != nil {
+		return nil, err
+	}
+	if c != p256SSH {
+		return nil, errors.New("SSH public key is not on P-256")
+	}
+	return p.SetBytes(point)
+}
+
+// SSHAuthorizedKey returns the OpenSSH public key line of p, with the given
+// comment, suitable for .pub and authorized_keys files.
+func (p *P256Point) SSHAuthorizedKey(comment string) ([]byte, error) {
+	return marshalSSHAuthorizedKey(p256SSH, p.Bytes(), comment)
+}
+
+// P256OpenSSHPrivateKey returns the PEM encoded, unencrypted OpenSSH private
+// key for scalar, in the format produced by ssh-keygen.
+func P256OpenSSHPrivateKey(scalar []byte, comment string) ([]byte, error) {
+	return marshalOpenSSHPrivateKey(p256SSH, scalar, comment)
+}
+
+// P256ScalarFromOpenSSH returns the scalar of the PEM encoded, unencrypted
+// OpenSSH P-256 private key in data.
+func P256ScalarFromOpenSSH(data []byte) ([]byte, error) {
+	c, scalar, _, _, err := parseOpenSSHPrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+	if c != p256SSH {
+		return nil, errors.New("OpenSSH private key is not on P-256")
+	}
+	return scalar, nil
+}
+
+// P256SSHSignature returns the SSH signature blob of the ECDSA signature
+// (r, s), as specified in RFC 5656, Section 3.1.2. r and s are big-endian
+// integers in [1, n-1], where n is the order of the curve.
+func P256SSHSignature(r, s []byte) ([]byte, error) {
+	return marshalSSHSignature(p256SSH, r, s)
+}
+
+// P256ParseSSHSignature parses an "ecdsa-sha2-nist256" SSH signature blob, and
+// returns r and s as big-endian integers of the same length as the order.
+func P256ParseSSHSignature(sig []byte) (r, s []byte, err error) {
+	return parseSSHSignature(p256SSH, sig)
+}
+
+// BytesSSH returns the SSH public key blob of p, as specified in RFC 5656,
+// Section 3.1, or an error if p is the point at infinity.
+func (p *P384Point) BytesSSH() ([]byte, error) {
+	return marshalSSHPublicKey(p384SSH, p.Bytes())
+}
+
+// SetBytesSSH sets p to the public key in the SSH public key blob b, and
+// returns p. The key type must be "ecdsa-sha2-nist384". If b is not a valid
+// P-384 public key, SetBytesSSH returns nil and an error, and p is unchanged.
+func (p *P384Point) SetBytesSSH(b []byte) (*P384Point, error) {
+	c, point, err := parseSSHPublicKey(b)
+	if err != nil {
+		return nil, err
+	}
+	if c != p384SSH {
+		return nil, errors.New("SSH public key is not on P-384")
+	}
+	return p.SetBytes(point)
+}
+
+// SSHAuthorizedKey returns the OpenSSH public key line of p, with the given
+// comment, suitable for .pub and authorized_keys files.
+func (p *P384Point) SSHAuthorizedKey(comment string) ([]byte, error) {
+	return marshalSSHAuthorizedKey(p384SSH, p.Bytes(), comment)
+}
+
+// P384OpenSSHPrivateKey returns the PEM encoded, unencrypted OpenSSH private
+// key for scalar, in the format produced by ssh-keygen.
+func P384OpenSSHPrivateKey(scalar []byte, comment string) ([]byte, error) {
+	return marshalOpenSSHPrivateKey(p384SSH, scalar, comment)
+}
+
+// P384ScalarFromOpenSSH returns the scalar of the PEM encoded, unencrypted
+// OpenSSH P-384 private key in data.
+func P384ScalarFromOpenSSH(data []byte) ([]byte, error) {
+	c, scalar, _, _, err := parseOpenSSHPrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+	if c != p384SSH {
+		return nil, errors.New("OpenSSH private key is not on P-384")
+	}
+	return scalar, nil
+}
+
+// P384SSHSignature returns the SSH signature blob of the ECDSA signature
+// (r, s), as specified in RFC 5656, Section 3.1.2. r and s are big-endian
+// integers in [1, n-1], where n is the order of the curve.
+func P384SSHSignature(r, s []byte) ([]byte, error) {
+	return marshalSSHSignature(p384SSH, r, s)
+}
+
+// P384ParseSSHSignature parses an "ecdsa-sha2-nist384" SSH signature blob, and
+// returns r and s as big-endian integers of the same length as the order.
+func P384ParseSSHSignature(sig []byte) (r, s []byte, err error) {
+	return parseSSHSignature(p384SSH, sig)
+}
+
+// BytesSSH returns the SSH public key blob of p, as specified in RFC 5656,
+// Section 3.1, or an error if p is the point at infinity.
+func (p *P521Point) BytesSSH() ([]byte, error) {
+	return marshalSSHPublicKey(p521SSH, p.Bytes())
+}
+
+// SetBytesSSH sets p to the public key in the SSH public key blob b, and
+// returns p. The key type must be "ecdsa-sha2-nist521". If b is not a valid
+// P-521 public key, SetBytesSSH returns nil and an error, and p is unchanged.
+func (p *P521Point) SetBytesSSH(b []byte) (*P521Point, error) {
+	c, point, err := parseSSHPublicKey(b)
+	if err != nil {
+		return nil, err
+	}
+	if c != p521SSH {
+		return nil, errors.New("SSH public key is not on P-521")
+	}
+	return p.SetBytes(point)
+}
+
+// SSHAuthorizedKey returns the OpenSSH public key line of p, with the given
+// comment, suitable for .pub and authorized_keys files.
+func (p *P521Point) SSHAuthorizedKey(comment string) ([]byte, error) {
+	return marshalSSHAuthorizedKey(p521SSH, p.Bytes(), comment)
+}
+
+// P521OpenSSHPrivateKey returns the PEM encoded, unencrypted OpenSSH private
+// key for scalar, in the format produced by ssh-keygen.
+func P521OpenSSHPrivateKey(scalar []byte, comment string) ([]byte, error) {
+	return marshalOpenSSHPrivateKey(p521SSH, scalar, comment)
+}
+
+// P521ScalarFromOpenSSH returns the scalar of the PEM encoded, unencrypted
+// OpenSSH P-521 private key in data.
+func P521ScalarFromOpenSSH(data []byte) ([]byte, error) {
+	c, scalar, _, _, err := parseOpenSSHPrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+	if c != p521SSH {
+		return nil, errors.New("OpenSSH private key is not on P-521")
+	}
+	return scalar, nil
+}
+
+// P521SSHSignature returns the SSH signature blob of the ECDSA signature
+// (r, s), as specified in RFC 5656, Section 3.1.2. r and s are big-endian
+// integers in [1, n-1], where n is the order of the curve.
+func P521SSHSignature(r, s []byte) ([]byte, error) {
+	return marshalSSHSignature(p521SSH, r, s)
+}
+
+// P521ParseSSHSignature parses an "ecdsa-sha2-nist521" SSH signature blob, and
+// returns r and s as big-endian integers of the same length as the order.
+func P521ParseSSHSignature(sig []byte) (r, s []byte, err error) {
+	return parseSSHSignature(p521SSH, sig)
+}
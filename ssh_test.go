@@ -0,0 +1,285 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha512"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magical/nistec-extra"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// The files in testdata/ssh were generated with OpenSSH 9.2 by
+//
+//	ssh-keygen -t ecdsa -b $bits -N "" -C "nistec-test-$bits" -f id_ecdsa_$bits
+//	ssh-keygen -Y sign -f id_ecdsa_$bits -n file message.txt
+//
+// and renaming message.txt.sig to message_$bits.sig. id_ecdsa_256_encrypted
+// was generated with the passphrase "hunter2".
+
+type sshPoint[T any] interface {
+	nistPoint[T]
+	BytesSSH() ([]byte, error)
+	SetBytesSSH([]byte) (T, error)
+	SSHAuthorizedKey(string) ([]byte, error)
+}
+
+type sshFuncs struct {
+	privateKey          func([]byte, string) ([]byte, error)
+	scalarFromOpenSSH   func([]byte) ([]byte, error)
+	signature           func(r, s []byte) ([]byte, error)
+	parseSignature      func([]byte) (r, s []byte, err error)
+	curve               elliptic.Curve
+	hash                crypto.Hash
+	otherCurveScalarErr func([]byte) ([]byte, error)
+}
+
+func TestSSH(t *testing.T) {
+	t.Run("P256", func(t *testing.T) {
+		testSSH(t, nistec.NewP256Point, "256", sshFuncs{
+			nistec.P256OpenSSHPrivateKey, nistec.P256ScalarFromOpenSSH,
+			nistec.P256SSHSignature, nistec.P256ParseSSHSignature,
+			elliptic.P256(), crypto.SHA256, nistec.P384ScalarFromOpenSSH})
+	})
+	t.Run("P384", func(t *testing.T) {
+		testSSH(t, nistec.NewP384Point, "384", sshFuncs{
+			nistec.P384OpenSSHPrivateKey, nistec.P384ScalarFromOpenSSH,
+			nistec.P384SSHSignature, nistec.P384ParseSSHSignature,
+			elliptic.P384(), crypto.SHA384, nistec.P521ScalarFromOpenSSH})
+	})
+	t.Run("P521", func(t *testing.T) {
+		testSSH(t, nistec.NewP521Point, "521", sshFuncs{
+			nistec.P521OpenSSHPrivateKey, nistec.P521ScalarFromOpenSSH,
+			nistec.P521SSHSignature, nistec.P521ParseSSHSignature,
+			elliptic.P521(), crypto.SHA512, nistec.P256ScalarFromOpenSSH})
+	})
+}
+
+func readSSHFile(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", "ssh", name))
+	fatalIfErr(t, err)
+	return b
+}
+
+func testSSH[P sshPoint[P]](t *testing.T, newPoint func() P, bits string, f sshFuncs) {
+	privPEM := readSSHFile(t, "id_ecdsa_"+bits)
+	pubLine := readSSHFile(t, "id_ecdsa_"+bits+".pub")
+	comment := "nistec-test-" + bits
+
+	scalar, pub, gotComment, err := nistec.ParseOpenSSHPrivateKey(privPEM)
+	fatalIfErr(t, err)
+	p, ok := pub.(P)
+	if !ok {
+		t.Fatalf("ParseOpenSSHPrivateKey returned a %T", pub)
+	}
+	if gotComment != comment {
+		t.Errorf("ParseOpenSSHPrivateKey comment = %q, want %q", gotComment, comment)
+	}
+	s, err := f.scalarFromOpenSSH(privPEM)
+	fatalIfErr(t, err)
+	if !bytes.Equal(s, scalar) {
+		t.Errorf("ScalarFromOpenSSH() = %x, want %x", s, scalar)
+	}
+	if _, err := f.otherCurveScalarErr(privPEM); err == nil {
+		t.Error("ScalarFromOpenSSH accepted a key on a different curve")
+	}
+
+	pub, gotComment, err = nistec.ParseSSHAuthorizedKey(pubLine)
+	fatalIfErr(t, err)
+	if q, ok := pub.(P); !ok || !bytes.Equal(q.Bytes(), p.Bytes()) || gotComment != comment {
+		t.Errorf("ParseSSHAuthorizedKey() = %v, %q", pub, gotComment)
+	}
+	line, err := p.SSHAuthorizedKey(comment)
+	fatalIfErr(t, err)
+	if !bytes.Equal(line, pubLine) {
+		t.Errorf("SSHAuthorizedKey() = %q, want %q", line, pubLine)
+	}
+
+	blob, err := p.BytesSSH()
+	fatalIfErr(t, err)
+	q, err := newPoint().SetBytesSSH(blob)
+	fatalIfErr(t, err)
+	if !bytes.Equal(q.Bytes(), p.Bytes()) {
+		t.Errorf("SetBytesSSH() = %x, want %x", q.Bytes(), p.Bytes())
+	}
+	if q, err := nistec.ParseSSHPublicKey(blob); err != nil {
+		t.Errorf("ParseSSHPublicKey: %v", err)
+	} else if q, ok := q.(P); !ok || !bytes.Equal(q.Bytes(), p.Bytes()) {
+		t.Errorf("ParseSSHPublicKey() = %v", q)
+	}
+
+	// The re-encoded private key must match ssh-keygen's, except for the
+	// random check bytes.
+	out, err := f.privateKey(scalar, comment)
+	fatalIfErr(t, err)
+	want, _ := pem.Decode(privPEM)
+	got, _ := pem.Decode(out)
+	if got == nil || got.Type != want.Type || len(got.Bytes) != len(want.Bytes) {
+		t.Fatalf("PrivateKey() = %q", out)
+	}
+	i := 0
+	for i < len(got.Bytes) && got.Bytes[i] == want.Bytes[i] {
+		i++
+	}
+	if i+8 > len(got.Bytes) || !bytes.Equal(got.Bytes[:i], want.Bytes[:i]) ||
+		!bytes.Equal(got.Bytes[i+8:], want.Bytes[i+8:]) ||
+		!bytes.Equal(got.Bytes[i:i+4], got.Bytes[i+4:i+8]) {
+		t.Errorf("PrivateKey() = %x, want %x", got.Bytes, want.Bytes)
+	}
+	s, err = f.scalarFromOpenSSH(out)
+	fatalIfErr(t, err)
+	if !bytes.Equal(s, scalar) {
+		t.Errorf("ScalarFromOpenSSH(PrivateKey()) = %x, want %x", s, scalar)
+	}
+
+	// Verify the SSHSIG signature made by ssh-keygen with crypto/ecdsa, and
+	// check that the signature blob round-trips.
+	sshsig, _ := pem.Decode(readSSHFile(t, "message_"+bits+".sig"))
+	sigKey, sigBlob := parseSSHSIG(t, sshsig.Bytes)
+	if q, err := newPoint().SetBytesSSH(sigKey); err != nil || !bytes.Equal(q.Bytes(), p.Bytes()) {
+		t.Errorf("SSHSIG public key doesn't match: %v", err)
+	}
+	r, ss, err := f.parseSignature(sigBlob)
+	fatalIfErr(t, err)
+	msgHash := sha512.Sum512(readSSHFile(t, "message.txt"))
+	var signed cryptobyte.Builder
+	signed.AddBytes([]byte("SSHSIG"))
+	for _, field := range [][]byte{[]byte("file"), nil, []byte("sha512"), msgHash[:]} {
+		signed.AddUint32LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(field) })
+	}
+	h := f.hash.New()
+	h.Write(signed.BytesOrPanic())
+	x, y := elliptic.Unmarshal(f.curve, p.Bytes())
+	if !ecdsa.Verify(&ecdsa.PublicKey{Curve: f.curve, X: x, Y: y}, h.Sum(nil),
+		new(big.Int).SetBytes(r), new(big.Int).SetBytes(ss)) {
+		t.Error("ssh-keygen signature doesn't verify")
+	}
+	sig, err := f.signature(r, ss)
+	fatalIfErr(t, err)
+	if !bytes.Equal(sig, sigBlob) {
+		t.Errorf("SSHSignature() = %x, want %x", sig, sigBlob)
+	}
+
+	// Malformed signatures.
+	if _, err := f.signature(make([]byte, len(r)), ss); err == nil {
+		t.Error("SSHSignature accepted r = 0")
+	}
+	for name, sig := range map[string][]byte{
+		"truncated":      sigBlob[:len(sigBlob)-1],
+		"trailing data":  append(sigBlob[:len(sigBlob):len(sigBlob)], 0),
+		"wrong key type": bytes.Replace(sigBlob, []byte("nistp"+bits), []byte("nistp999"), 1),
+		"negative r":     sshSignatureBlob(bits, []byte{0x80, 1}, ss),
+		"non-minimal r":  sshSignatureBlob(bits, []byte{0, 1}, ss),
+		"zero s":         sshSignatureBlob(bits, r, nil),
+		"long s":         sshSignatureBlob(bits, r, append([]byte{1}, make([]byte, len(ss))...)),
+	} {
+		if _, _, err := f.parseSignature(sig); err == nil {
+			t.Errorf("ParseSSHSignature accepted a signature with %s", name)
+		}
+	}
+	if _, err := newPoint().SSHAuthorizedKey(""); err == nil {
+		t.Error("SSHAuthorizedKey accepted the point at infinity")
+	}
+}
+
+// parseSSHSIG returns the public key and signature blob of an SSHSIG
+// signature, as specified in PROTOCOL.sshsig in the OpenSSH sources.
+func parseSSHSIG(t *testing.T, b []byte) (pub, sig []byte) {
+	s := cryptobyte.String(b)
+	var magic []byte
+	var version uint32
+	if !s.ReadBytes(&magic, 6) || string(magic) != "SSHSIG" || !s.ReadUint32(&version) || version != 1 {
+		t.Fatal("invalid SSHSIG")
+	}
+	var fields [5][]byte
+	for i := range fields {
+		var n uint32
+		if !s.ReadUint32(&n) || !s.ReadBytes(&fields[i], int(n)) {
+			t.Fatal("invalid SSHSIG")
+		}
+	}
+	if string(fields[1]) != "file" || string(fields[3]) != "sha512" {
+		t.Fatalf("unexpected SSHSIG namespace %q or hash %q", fields[1], fields[3])
+	}
+	return fields[0], fields[4]
+}
+
+func sshSignatureBlob(bits string, r, s []byte) []byte {
+	var b cryptobyte.Builder
+	b.AddUint32LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes([]byte("ecdsa-sha2-nistp" + bits)) })
+	b.AddUint32LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint32LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(r) })
+		b.AddUint32LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(s) })
+	})
+	return b.BytesOrPanic()
+}
+
+func TestSSHInvalid(t *testing.T) {
+	encrypted := readSSHFile(t, "id_ecdsa_256_encrypted")
+	if _, _, _, err := nistec.ParseOpenSSHPrivateKey(encrypted); err == nil ||
+		!strings.Contains(err.Error(), "encrypted") {
+		t.Errorf("ParseOpenSSHPrivateKey(encrypted) error = %v", err)
+	}
+
+	pub256 := readSSHFile(t, "id_ecdsa_256.pub")
+	pub, _, err := nistec.ParseSSHAuthorizedKey(pub256)
+	fatalIfErr(t, err)
+	blob, err := pub.(*nistec.P256Point).BytesSSH()
+	fatalIfErr(t, err)
+	if _, err := nistec.NewP384Point().SetBytesSSH(blob); err == nil {
+		t.Error("P384Point.SetBytesSSH accepted a P-256 key")
+	}
+	for name, line := range map[string][]byte{
+		"mismatched type":   bytes.Replace(pub256, []byte("ecdsa-sha2-nistp256 "), []byte("ecdsa-sha2-nistp384 "), 1),
+		"invalid base64":    bytes.Replace(pub256, []byte("AAAA"), []byte("A*AA"), 1),
+		"ed25519":           []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAq+1jAEBhs3NNyrMCr1HL4RC27Oly7W7rk8LFtY2ZqN"),
+		"empty":             nil,
+		"mismatched curve":  []byte("ecdsa-sha2-nistp256 " + strings.Split(string(pub256), " ")[1][:20]),
+		"truncated payload": pub256[:len(pub256)-40],
+	} {
+		if _, _, err := nistec.ParseSSHAuthorizedKey(line); err == nil {
+			t.Errorf("ParseSSHAuthorizedKey accepted a line with %s", name)
+		}
+	}
+
+	// A private key whose check bytes don't match, as if decrypted with the
+	// wrong passphrase.
+	priv := readSSHFile(t, "id_ecdsa_256")
+	block, _ := pem.Decode(priv)
+	_, pub, _, err = nistec.ParseOpenSSHPrivateKey(priv)
+	fatalIfErr(t, err)
+	blob, _ = pub.(*nistec.P256Point).BytesSSH()
+	i := bytes.LastIndex(block.Bytes, blob) // the public key in the private section
+	block.Bytes[i-1] ^= 1                   // the last byte of check2
+	if _, _, _, err := nistec.ParseOpenSSHPrivateKey(pem.EncodeToMemory(block)); err == nil {
+		t.Error("ParseOpenSSHPrivateKey accepted mismatched check bytes")
+	}
+
+	// A private key with invalid padding. With a one byte comment, the private
+	// section of a P-256 key needs six or seven bytes of padding.
+	scalar, err := nistec.P256ScalarFromOpenSSH(priv)
+	fatalIfErr(t, err)
+	priv, err = nistec.P256OpenSSHPrivateKey(scalar, "x")
+	fatalIfErr(t, err)
+	block, _ = pem.Decode(priv)
+	block.Bytes[len(block.Bytes)-1] ^= 1
+	if _, _, _, err := nistec.ParseOpenSSHPrivateKey(pem.EncodeToMemory(block)); err == nil {
+		t.Error("ParseOpenSSHPrivateKey accepted invalid padding")
+	}
+	if _, err := nistec.P256OpenSSHPrivateKey(make([]byte, 32), ""); err == nil {
+		t.Error("P256OpenSSHPrivateKey accepted a zero scalar")
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec
+
+import (
+	"crypto/subtle"
+	"errors"
+)
+
+// checkPrivateScalar returns an error unless scalar is the big-endian encoding
+// of an integer in [1, n-1], of the same length as n.
+func checkPrivateScalar(scalar, n []byte) error {
+	if len(scalar) != len(n) {
+		return errors.New("invalid private key length")
+	}
+	// Compute scalar - n, and check that it borrows (scalar < n) and that
+	// scalar is not zero, without branching on the value of scalar.
+	var borrow int
+	var acc byte
+	for i := len(n) - 1; i >= 0; i-- {
+		borrow = (int(scalar[i]) - int(n[i]) - borrow) >> 8 & 1
+		acc |= scalar[i]
+	}
+	if borrow&^subtle.ConstantTimeByteEq(acc, 0) == 0 {
+		return errors.New("invalid private key: out of range")
+	}
+	return nil
+}
+
+// yIsOdd returns 1 if the uncompressed encoding b has an odd y-coordinate, and
+// 0 if it's even or b is the encoding of the point at infinity.
+func yIsOdd(b []byte) int {
+	if len(b) == 1 {
+		return 0
+	}
+	return int(b[len(b)-1] & 1)
+}
+
+// bytesXOnly returns the x-coordinate from the uncompressed encoding b, or an
+// error if b is the point at infinity or has an odd y-coordinate.
+func bytesXOnly(b []byte, curve string) ([]byte, error) {
+	if len(b) == 1 {
+		return nil, errors.New(curve + " point is the point at infinity")
+	}
+	if yIsOdd(b) == 1 {
+		return nil, errors.New(curve + " point has an odd y-coordinate")
+	}
+	return b[1 : 1+(len(b)-1)/2], nil
+}
+
+// evenYScalar returns n - k if negate is 1, and k otherwise, in constant time.
+func evenYScalar(k, n []byte, negate int) []byte {
+	out := make([]byte, len(k))
+	var borrow int
+	for i := len(k) - 1; i >= 0; i-- {
+		d := int(n[i]) - int(k[i]) - borrow
+		borrow = (d >> 8) & 1
+		out[i] = byte(subtle.ConstantTimeSelect(negate, int(byte(d)), int(k[i])))
+	}
+	return out
+}
+
+// SetBytesX sets p to the point with x-coordinate x, a 28-byte big-endian
+// value, and an even y-coordinate, and returns p. This is lift_x from BIP 340.
+// If x is not the x-coordinate of a point on the curve, SetBytesX returns nil
+// and an error, and the receiver is unchanged.
+func (p *P224Point) SetBytesX(x []byte) (*P224Point, error) {
+	if len(x) != p224ElementLength {
+		return nil, errors.New("invalid P224 x-only encoding")
+	}
+	// The compressed encoding with prefix 0x02 selects the even root.
+	var buf [1 + p224ElementLength]byte
+	buf[0] = 2
+	copy(buf[1:], x)
+	if _, err := p.SetBytes(buf[:]); err != nil {
+		return nil, errors.New("invalid P224 x-only encoding: not on the curve")
+	}
+	return p, nil
+}
+
+// BytesXOnly returns the x-only encoding of p, which is its 28-byte
+// x-coordinate. Since SetBytesX always selects the even y-coordinate,
+// BytesXOnly returns an error if p has an odd y-coordinate, as well as if p is
+// the point at infinity. See SetEvenY and P224EvenYScalar.
+func (p *P224Point) BytesXOnly() ([]byte, error) {
+	return bytesXOnly(p.Bytes(), "P224")
+}
+
+// SetEvenY sets p to q if q has an even y-coordinate, and to -q otherwise. It
+// returns p, and 1 if q was negated or 0 if not.
+func (p *P224Point) SetEvenY(q *P224Point) (*P224Point, int) {
+	odd := yIsOdd(q.Bytes())
+	neg := NewP224Point().Negate(q)
+	return p.Select(neg, q, odd), odd
+}
+
+// P224EvenYScalar returns scalar if scalar * G has an even y-coordinate, and
+// N - scalar otherwise, so that the public key of the result can be encoded
+// with BytesXOnly. scalar must be a 28-byte big-endian value in [1, N-1].
+func P224EvenYScalar(scalar []byte) ([]byte, error) {
+	if err := checkPrivateScalar(scalar, p224Order); err != nil {
+		return nil, err
+	}
+	q, err := NewP224Point().ScalarBaseMult(scalar)
+	if err != nil {
+		return nil, err
+	}
+	return evenYScalar(scalar, p224Order, yIsOdd(q.Bytes())), nil
+}
+
+// SetBytesX sets p to the point with x-coordinate x, a 32-byte big-endian
+// value, and an even y-coordinate, and returns p. This is lift_x from BIP 340.
+// If x is not the x-coordinate of a point on the curve, SetBytesX returns nil
+// and an error, and the receiver is unchanged.
+func (p *P256Point) SetBytesX(x []byte) (*P256Point, error) {
+	if len(x) != p256ElementLength {
+		return nil, errors.New("invalid P256 x-only encoding")
+	}
+	// The compressed encoding with prefix 0x02 selects the even root.
+	var buf [1 + p256ElementLength]byte
+	buf[0] = 2
+	copy(buf[1:], x)
+	if _, err := p.SetBytes(buf[:]); err != nil {
+		return nil, errors.New("invalid P256 x-only encoding: not on the curve")
+	}
+	return p, nil
+}
+
+// BytesXOnly returns the x-only encoding of p, which is its 32-byte
+// x-coordinate. Since SetBytesX always selects the even y-coordinate,
+// BytesXOnly returns an error if p has an odd y-coordinate, as well as if p is
+// the point at infinity. See SetEvenY and P256EvenYScalar.
+func (p *P256Point) BytesXOnly() ([]byte, error) {
+	return bytesXOnly(p.Bytes(), "P256")
+}
+
+// SetEvenY sets p to q if q has an even y-coordinate, and to -q otherwise. It
+// returns p, and 1 if q was negated or 0 if not.
+func (p *P256Point) SetEvenY(q *P256Point) (*P256Point, int) {
+	odd := yIsOdd(q.Bytes())
+	neg := NewP256Point().Negate(q)
+	return p.Select(neg, q, odd), odd
+}
+
+// P256EvenYScalar returns scalar if scalar * G has an even y-coordinate, and
+// N - scalar otherwise, so that the public key of the result can be encoded
+// with BytesXOnly. scalar must be a 32-byte big-endian value in [1, N-1].
+func P256EvenYScalar(scalar []byte) ([]byte, error) {
+	if err := checkPrivateScalar(scalar, p256Order); err != nil {
+		return nil, err
+	}
+	q, err := NewP256Point().ScalarBaseMult(scalar)
+	if err != nil {
+		return nil, err
+	}
+	return evenYScalar(scalar, p256Order, yIsOdd(q.Bytes())), nil
+}
+
+// SetBytesX sets p to the point with x-coordinate x, a 48-byte big-endian
+// value, and an even y-coordinate, and returns p. This is lift_x from BIP 340.
+// If x is not the x-coordinate of a point on the curve, SetBytesX returns nil
+// and an error, and the receiver is unchanged.
+func (p *P384Point) SetBytesX(x []byte) (*P384Point, error) {
+	if len(x) != p384ElementLength {
+		return nil, errors.New("invalid P384 x-only encoding")
+	}
+	// The compressed encoding with prefix 0x02 selects the even root.
+	var buf [1 + p384ElementLength]byte
+	buf[0] = 2
+	copy(buf[1:], x)
+	if _, err := p.SetBytes(buf[:]); err != nil {
+		return nil, errors.New("invalid P384 x-only encoding: not on the curve")
+	}
+	return p, nil
+}
+
+// BytesXOnly returns the x-only encoding of p, which is its 48-byte
+// x-coordinate. Since SetBytesX always selects the even y-coordinate,
+// BytesXOnly returns an error if p has an odd y-coordinate, as well as if p is
+// the point at infinity. See SetEvenY and P384EvenYScalar.
+func (p *P384Point) BytesXOnly() ([]byte, error) {
+	return bytesXOnly(p.Bytes(), "P384")
+}
+
+// SetEvenY sets p to q if q has an even y-coordinate, and to -q otherwise. It
+// returns p, and 1 if q was negated or 0 if not.
+func (p *P384Point) SetEvenY(q *P384Point) (*P384Point, int) {
+	odd := yIsOdd(q.Bytes())
+	neg := NewP384Point().Negate(q)
+	return p.Select(neg, q, odd), odd
+}
+
+// P384EvenYScalar returns scalar if scalar * G has an even y-coordinate, and
+// N - scalar otherwise, so that the public key of the result can be encoded
+// with BytesXOnly. scalar must be a 48-byte big-endian value in [1, N-1].
+func P384EvenYScalar(scalar []byte) ([]byte, error) {
+	if err := checkPrivateScalar(scalar, p384Order); err != nil {
+		return nil, err
+	}
+	q, err := NewP384Point().ScalarBaseMult(scalar)
+	if err != nil {
+		return nil, err
+	}
+	return evenYScalar(scalar, p384Order, yIsOdd(q.Bytes())), nil
+}
+
+// SetBytesX sets p to the point with x-coordinate x, a 66-byte big-endian
+// value, and an even y-coordinate, and returns p. This is lift_x from BIP 340.
+// If x is not the x-coordinate of a point on the curve, SetBytesX returns nil
+// and an error, and the receiver is unchanged.
+func (p *P521Point) SetBytesX(x []byte) (*P521Point, error) {
+	if len(x) != p521ElementLength {
+		return nil, errors.New("invalid P521 x-only encoding")
+	}
+	// The compressed encoding with prefix 0x02 selects the even root.
+	var buf [1 + p521ElementLength]byte
+	buf[0] = 2
+	copy(buf[1:], x)
+	if _, err := p.SetBytes(buf[:]); err != nil {
+		return nil, errors.New("invalid P521 x-only encoding: not on the curve")
+	}
+	return p, nil
+}
+
+// BytesXOnly returns the x-only encoding of p, which is its 66-byte
+// x-coordinate. Since SetBytesX always selects the even y-coordinate,
+// BytesXOnly returns an error if p has an odd y-coordinate, as well as if p is
+// the point at infinity. See SetEvenY and P521EvenYScalar.
+func (p *P521Point) BytesXOnly() ([]byte, error) {
+	return bytesXOnly(p.Bytes(), "P521")
+}
+
+// SetEvenY sets p to q if q has an even y-coordinate, and to -q otherwise. It
+// returns p, and 1 if q was negated or 0 if not.
+func (p *P521Point) SetEvenY(q *P521Point) (*P521Point, int) {
+	odd := yIsOdd(q.Bytes())
+	neg := NewP521Point().Negate(q)
+	return p.Select(neg, q, odd), odd
+}
+
+// P521EvenYScalar returns scalar if scalar * G has an even y-coordinate, and
+// N - scalar otherwise, so that the public key of the result can be encoded
+// with BytesXOnly. scalar must be a 66-byte big-endian value in [1, N-1].
+func P521EvenYScalar(scalar []byte) ([]byte, error) {
+	if err := checkPrivateScalar(scalar, p521Order); err != nil {
+		return nil, err
+	}
+	q, err := NewP521Point().ScalarBaseMult(scalar)
+	if err != nil {
+		return nil, err
+	}
+	return evenYScalar(scalar, p521Order, yIsOdd(q.Bytes())), nil
+}